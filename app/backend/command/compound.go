@@ -0,0 +1,60 @@
+package command
+
+import (
+	"time"
+
+	"Dr.uml/backend/utils/duerror"
+)
+
+// CompoundCommand groups several executed commands into a single undo step.
+// Commands are executed in order and unexecuted in reverse order.
+type CompoundCommand struct {
+	commands []Command
+	before   time.Time
+	after    time.Time
+}
+
+func NewCompoundCommand(commands []Command, before time.Time, after time.Time) *CompoundCommand {
+	return &CompoundCommand{
+		commands: commands,
+		before:   before,
+		after:    after,
+	}
+}
+
+func (cmd *CompoundCommand) GetCommands() []Command {
+	return cmd.commands
+}
+
+func (cmd *CompoundCommand) Execute() duerror.DUError {
+	for i, c := range cmd.commands {
+		if err := c.Execute(); err != nil {
+			// revert the part that has been applied, so a failed redo leaves no half state
+			for j := i - 1; j >= 0; j-- {
+				cmd.commands[j].Unexecute()
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (cmd *CompoundCommand) Unexecute() duerror.DUError {
+	for i := len(cmd.commands) - 1; i >= 0; i-- {
+		if err := cmd.commands[i].Unexecute(); err != nil {
+			for j := i + 1; j < len(cmd.commands); j++ {
+				cmd.commands[j].Execute()
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (cmd *CompoundCommand) GetBefore() time.Time {
+	return cmd.before
+}
+
+func (cmd *CompoundCommand) GetAfter() time.Time {
+	return cmd.after
+}
@@ -1,12 +1,17 @@
 package command
 
 import (
+	"errors"
+	"slices"
 	"time"
 
 	"Dr.uml/backend/utils/duerror"
 )
 
-const CMD_LIMIT = 20
+const (
+	CMD_LIMIT          = 20
+	MERGE_WINDOW_LIMIT = 500 * time.Millisecond
+)
 
 type Command interface {
 	Execute() duerror.DUError
@@ -15,26 +20,221 @@ type Command interface {
 	GetAfter() time.Time
 }
 
+// Mergeable is implemented by commands that can absorb the command executed right after them,
+// e.g. the stream of moves emitted while dragging a gadget.
+// Merge returns false if next is not of the same kind or does not target the same component.
+// On success the receiver keeps its original "before" state and takes over the "after" state of next.
+type Mergeable interface {
+	Command
+	Merge(next Command) bool
+}
+
 type Manager struct {
-	undoStack    []Command
-	redoStack    []Command
+	root         *historyNode
+	current      *historyNode         // state of the diagram, the path from root to it is the undo stack
+	nodes        map[int]*historyNode // every state still in the tree by id
+	nextID       int
 	lastModified time.Time
 	limit        int
+	transaction  *CompoundCommand // commands executed since Begin, nil if no transaction is open
+	mergeWindow  time.Duration    // consecutive mergeable commands closer than this are coalesced
+	merging      bool             // between BeginMerge and EndMerge, merge regardless of the window
+	mergeBarrier bool             // the next command must not be merged into the previous one
+}
+
+// historyNode is one state of the undo tree, reached by executing cmd from the parent state.
+// Executing a command from a state that already has children adds a new branch instead of
+// discarding the old redo path.
+type historyNode struct {
+	id       int
+	cmd      Command // nil for the root
+	parent   *historyNode
+	children []*historyNode
+	redo     int // index of the child followed by Redo, -1 if none
+}
+
+// Branch describes one path of the undo tree, identified by the state at its tip.
+type Branch struct {
+	ID           int       `json:"id"`
+	Length       int       `json:"length"`
+	LastModified time.Time `json:"lastModified"`
+	IsCurrent    bool      `json:"isCurrent"` // the current state lies on this branch
 }
 
 func NewManager(lastModified time.Time) *Manager {
-	return &Manager{
-		undoStack:    make([]Command, 0, CMD_LIMIT),
-		redoStack:    make([]Command, 0, CMD_LIMIT),
+	m := &Manager{
 		lastModified: lastModified,
 		limit:        CMD_LIMIT,
+		mergeWindow:  MERGE_WINDOW_LIMIT,
 	}
+	m.resetTree()
+	return m
 }
 
 func (m *Manager) GetLastModified() time.Time {
 	return m.lastModified
 }
 
+func (m *Manager) InTransaction() bool {
+	return m.transaction != nil
+}
+
+// GetHistory returns the current branch as undo and redo stacks, the last element of each is the next to undo/redo.
+// The redo stack follows the path Redo would take, other branches are not included.
+func (m *Manager) GetHistory() ([]Command, []Command) {
+	undo := make([]Command, 0, m.limit)
+	for _, n := range m.path(m.current)[1:] {
+		undo = append(undo, n.cmd)
+	}
+	redo := make([]Command, 0, m.limit)
+	for n := m.current; n.redo >= 0; n = n.children[n.redo] {
+		redo = append(redo, n.children[n.redo].cmd)
+	}
+	slices.Reverse(redo)
+	return undo, redo
+}
+
+// SetHistory replaces the whole tree with a single branch, e.g. with a history restored from a file.
+func (m *Manager) SetHistory(undo []Command, redo []Command) duerror.DUError {
+	if m.transaction != nil {
+		return duerror.NewInvalidArgumentError("cannot set history during a transaction")
+	}
+	if len(undo) > m.limit {
+		undo = undo[len(undo)-m.limit:]
+	}
+	if len(redo) > m.limit {
+		redo = redo[len(redo)-m.limit:]
+	}
+	m.resetTree()
+	for _, cmd := range undo {
+		m.current = m.addChild(m.current, cmd)
+	}
+	n := m.current
+	for i := len(redo) - 1; i >= 0; i-- {
+		n = m.addChild(n, redo[i])
+	}
+	m.mergeBarrier = true
+	return nil
+}
+
+// GetState returns the id of the current history state.
+func (m *Manager) GetState() int {
+	return m.current.id
+}
+
+// ListBranches returns every branch of the undo tree ordered by creation.
+func (m *Manager) ListBranches() []Branch {
+	branches := make([]Branch, 0)
+	for _, n := range m.nodes {
+		if len(n.children) > 0 {
+			continue
+		}
+		b := Branch{ID: n.id, Length: m.depth(n), LastModified: m.lastModified}
+		if n.cmd != nil {
+			b.LastModified = n.cmd.GetAfter()
+		}
+		b.IsCurrent = m.isAncestor(m.current, n)
+		branches = append(branches, b)
+	}
+	slices.SortFunc(branches, func(a, b Branch) int { return a.ID - b.ID })
+	return branches
+}
+
+// SwitchBranch moves to the tip of the branch with the given id.
+func (m *Manager) SwitchBranch(id int) duerror.DUError {
+	n, ok := m.nodes[id]
+	if !ok || len(n.children) > 0 {
+		return duerror.NewInvalidArgumentError("branch does not exist")
+	}
+	return m.GoToState(id)
+}
+
+// GoToState undoes back to the common ancestor of the current state and the target,
+// then redoes along the target's branch. Redo afterwards keeps following that branch.
+func (m *Manager) GoToState(id int) duerror.DUError {
+	if m.transaction != nil {
+		return duerror.NewInvalidArgumentError("cannot change state during a transaction")
+	}
+	target, ok := m.nodes[id]
+	if !ok {
+		return duerror.NewInvalidArgumentError("history state does not exist")
+	}
+	start := m.current
+	m.mergeBarrier = true
+	if err := m.walk(m.diff(start, target)); err != nil {
+		// go back to where we started, a failed jump must not leave the diagram halfway
+		m.walk(m.diff(m.current, start))
+		return err
+	}
+	return nil
+}
+
+// walk unexecutes undo and executes redo in order, moving the current state along.
+// It stops at the first command that fails, current is then the last state reached.
+func (m *Manager) walk(undo []*historyNode, redo []*historyNode) duerror.DUError {
+	for _, n := range undo {
+		if err := n.cmd.Unexecute(); err != nil {
+			return err
+		}
+		m.current = n.parent
+		m.lastModified = n.cmd.GetBefore()
+	}
+	for _, n := range redo {
+		if err := n.cmd.Execute(); err != nil {
+			return err
+		}
+		n.parent.redo = slices.Index(n.parent.children, n)
+		m.current = n
+		m.lastModified = n.cmd.GetAfter()
+	}
+	return nil
+}
+
+// Diff returns the commands that lead from one history state to another:
+// undo holds the commands to unexecute in order, redo the commands to execute afterwards.
+func (m *Manager) Diff(from int, to int) ([]Command, []Command, duerror.DUError) {
+	a, ok := m.nodes[from]
+	if !ok {
+		return nil, nil, duerror.NewInvalidArgumentError("history state does not exist")
+	}
+	b, ok := m.nodes[to]
+	if !ok {
+		return nil, nil, duerror.NewInvalidArgumentError("history state does not exist")
+	}
+	undoNodes, redoNodes := m.diff(a, b)
+	undo := make([]Command, 0, len(undoNodes))
+	for _, n := range undoNodes {
+		undo = append(undo, n.cmd)
+	}
+	redo := make([]Command, 0, len(redoNodes))
+	for _, n := range redoNodes {
+		redo = append(redo, n.cmd)
+	}
+	return undo, redo, nil
+}
+
+// SetMergeWindow sets the time window used to coalesce commands, 0 disables time based merging.
+func (m *Manager) SetMergeWindow(window time.Duration) duerror.DUError {
+	if window < 0 {
+		return duerror.NewInvalidArgumentError("merge window cannot be negative")
+	}
+	m.mergeWindow = window
+	return nil
+}
+
+// BeginMerge marks the start of a continuous edit such as a drag.
+// Mergeable commands executed until EndMerge collapse into one command.
+func (m *Manager) BeginMerge() {
+	m.merging = true
+	m.mergeBarrier = true
+}
+
+// EndMerge closes the continuous edit, the next command starts a new undo step.
+func (m *Manager) EndMerge() {
+	m.merging = false
+	m.mergeBarrier = true
+}
+
 func (m *Manager) Execute(cmd Command) duerror.DUError {
 	if cmd == nil {
 		return duerror.NewInvalidArgumentError("command is nil")
@@ -42,39 +242,234 @@ func (m *Manager) Execute(cmd Command) duerror.DUError {
 	if err := cmd.Execute(); err != nil {
 		return err
 	}
-	if len(m.undoStack) == m.limit {
-		m.undoStack = m.undoStack[1:]
+	if m.transaction != nil {
+		// grouped into the open transaction, pushed as one step on Commit
+		cmds := m.transaction.commands
+		if len(cmds) == 0 || !m.merge(cmds[len(cmds)-1], cmd) {
+			m.transaction.commands = append(cmds, cmd)
+		}
+		m.transaction.after = cmd.GetAfter()
+		m.lastModified = cmd.GetAfter()
+		m.mergeBarrier = false
+		return nil
 	}
-	m.undoStack = append(m.undoStack, cmd)
-	m.redoStack = nil
-	m.lastModified = cmd.GetAfter()
+	if m.current.cmd != nil && len(m.current.children) == 0 && m.merge(m.current.cmd, cmd) {
+		m.lastModified = cmd.GetAfter()
+		return nil
+	}
+	m.push(cmd)
 	return nil
 }
 
+// Undo steps back to the parent state. A command that fails to unexecute leaves the current state as it is.
 func (m *Manager) Undo() duerror.DUError {
-	if len(m.undoStack) == 0 {
+	if m.transaction != nil {
+		return duerror.NewInvalidArgumentError("cannot undo during a transaction")
+	}
+	if m.current.parent == nil {
 		return duerror.NewInvalidArgumentError("no undo command")
 	}
-	cmd := m.undoStack[len(m.undoStack)-1]
-	m.undoStack = m.undoStack[:len(m.undoStack)-1]
-	if err := cmd.Unexecute(); err != nil {
+	n := m.current
+	m.mergeBarrier = true
+	if err := n.cmd.Unexecute(); err != nil {
 		return err
 	}
-	m.redoStack = append(m.redoStack, cmd)
-	m.lastModified = cmd.GetBefore()
+	m.current = n.parent
+	m.lastModified = n.cmd.GetBefore()
 	return nil
 }
 
+// Redo steps forward along the most recently used branch.
+// A command that fails to execute leaves the current state and the branch as they are.
 func (m *Manager) Redo() duerror.DUError {
-	if len(m.redoStack) == 0 {
+	if m.transaction != nil {
+		return duerror.NewInvalidArgumentError("cannot redo during a transaction")
+	}
+	if m.current.redo < 0 {
 		return duerror.NewInvalidArgumentError("no redo command")
 	}
-	cmd := m.redoStack[len(m.redoStack)-1]
-	m.redoStack = m.redoStack[:len(m.redoStack)-1]
-	if err := cmd.Execute(); err != nil {
+	n := m.current.children[m.current.redo]
+	m.mergeBarrier = true
+	if err := n.cmd.Execute(); err != nil {
 		return err
 	}
-	m.undoStack = append(m.undoStack, cmd)
-	m.lastModified = cmd.GetAfter()
+	m.current = n
+	m.lastModified = n.cmd.GetAfter()
+	return nil
+}
+
+// Begin opens a transaction. Every command executed until Commit or Rollback
+// becomes part of one undo step.
+func (m *Manager) Begin() duerror.DUError {
+	if m.transaction != nil {
+		return duerror.NewInvalidArgumentError("transaction already in progress")
+	}
+	m.transaction = NewCompoundCommand(make([]Command, 0), m.lastModified, m.lastModified)
+	m.mergeBarrier = true
+	return nil
+}
+
+// Commit closes the transaction and pushes its commands as a single undo step.
+// An empty transaction leaves the history untouched.
+func (m *Manager) Commit() duerror.DUError {
+	if m.transaction == nil {
+		return duerror.NewInvalidArgumentError("no transaction in progress")
+	}
+	cmd := m.transaction
+	m.transaction = nil
+	if len(cmd.commands) > 0 {
+		m.push(cmd)
+	}
+	// a compound step is never merged into
+	m.mergeBarrier = true
+	return nil
+}
+
+// Rollback unexecutes the commands of the open transaction in reverse order and discards them.
+// A command that fails to unexecute does not stop the others, the errors are returned together.
+func (m *Manager) Rollback() duerror.DUError {
+	if m.transaction == nil {
+		return duerror.NewInvalidArgumentError("no transaction in progress")
+	}
+	cmd := m.transaction
+	m.transaction = nil
+	m.mergeBarrier = true
+	errs := make([]error, 0)
+	for i := len(cmd.commands) - 1; i >= 0; i-- {
+		if err := cmd.commands[i].Unexecute(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	m.lastModified = cmd.GetBefore()
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
 	return nil
 }
+
+// Transaction runs fn inside a transaction, committing if fn succeeds and rolling back otherwise.
+func (m *Manager) Transaction(fn func() duerror.DUError) duerror.DUError {
+	if fn == nil {
+		return duerror.NewInvalidArgumentError("transaction function is nil")
+	}
+	if err := m.Begin(); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		if rbErr := m.Rollback(); rbErr != nil {
+			return errors.Join(err, rbErr)
+		}
+		return err
+	}
+	return m.Commit()
+}
+
+// push adds cmd as a new branch of the current state and moves to it.
+func (m *Manager) push(cmd Command) {
+	m.current = m.addChild(m.current, cmd)
+	if m.depth(m.current) > m.limit {
+		m.prune()
+	}
+	m.lastModified = cmd.GetAfter()
+	m.mergeBarrier = false
+}
+
+func (m *Manager) resetTree() {
+	m.nextID = 0
+	m.nodes = make(map[int]*historyNode)
+	m.root = m.newNode(nil, nil)
+	m.current = m.root
+}
+
+func (m *Manager) newNode(parent *historyNode, cmd Command) *historyNode {
+	n := &historyNode{id: m.nextID, cmd: cmd, parent: parent, redo: -1}
+	m.nextID++
+	m.nodes[n.id] = n
+	return n
+}
+
+// addChild appends a new state under parent and makes it the one Redo follows.
+func (m *Manager) addChild(parent *historyNode, cmd Command) *historyNode {
+	n := m.newNode(parent, cmd)
+	parent.children = append(parent.children, n)
+	parent.redo = len(parent.children) - 1
+	return n
+}
+
+func (m *Manager) forget(n *historyNode) {
+	delete(m.nodes, n.id)
+	for _, c := range n.children {
+		m.forget(c)
+	}
+}
+
+// prune drops the oldest step of the current branch. The root's child on the current path
+// becomes the new root, branches forking off the old root are discarded.
+func (m *Manager) prune() {
+	path := m.path(m.current)
+	oldRoot, newRoot := path[0], path[1]
+	delete(m.nodes, oldRoot.id)
+	for _, c := range oldRoot.children {
+		if c != newRoot {
+			m.forget(c)
+		}
+	}
+	newRoot.parent = nil
+	newRoot.cmd = nil
+	m.root = newRoot
+}
+
+// path returns the states from the root down to n.
+func (m *Manager) path(n *historyNode) []*historyNode {
+	res := make([]*historyNode, 0, m.limit+1)
+	for ; n != nil; n = n.parent {
+		res = append(res, n)
+	}
+	slices.Reverse(res)
+	return res
+}
+
+func (m *Manager) depth(n *historyNode) int {
+	d := 0
+	for ; n.parent != nil; n = n.parent {
+		d++
+	}
+	return d
+}
+
+// isAncestor reports whether a lies on the path from the root to n, n included.
+func (m *Manager) isAncestor(a *historyNode, n *historyNode) bool {
+	for ; n != nil; n = n.parent {
+		if n == a {
+			return true
+		}
+	}
+	return false
+}
+
+// diff returns the states to leave, from a upwards, and the states to enter, downwards to b.
+func (m *Manager) diff(a *historyNode, b *historyNode) ([]*historyNode, []*historyNode) {
+	pa, pb := m.path(a), m.path(b)
+	common := 0
+	for common < len(pa) && common < len(pb) && pa[common] == pb[common] {
+		common++
+	}
+	undo := slices.Clone(pa[common:])
+	slices.Reverse(undo)
+	return undo, slices.Clone(pb[common:])
+}
+
+// merge tries to fold cmd into prev, which is the last executed command.
+func (m *Manager) merge(prev Command, cmd Command) bool {
+	if m.mergeBarrier {
+		return false
+	}
+	if !m.merging && cmd.GetAfter().Sub(prev.GetAfter()) > m.mergeWindow {
+		return false
+	}
+	mergeable, ok := prev.(Mergeable)
+	if !ok {
+		return false
+	}
+	return mergeable.Merge(cmd)
+}
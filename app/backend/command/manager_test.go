@@ -21,6 +21,16 @@ func (m *MockCommand) Unexecute() duerror.DUError { return m.unexecuteErr }
 func (m *MockCommand) GetBefore() time.Time       { return m.before }
 func (m *MockCommand) GetAfter() time.Time        { return m.after }
 
+func undoLen(m *Manager) int {
+	undo, _ := m.GetHistory()
+	return len(undo)
+}
+
+func redoLen(m *Manager) int {
+	_, redo := m.GetHistory()
+	return len(redo)
+}
+
 func TestManager_Execute_NilCommand(t *testing.T) {
 	m := NewManager(time.Now())
 	err := m.Execute(nil)
@@ -36,8 +46,8 @@ func TestManager_Execute_Success(t *testing.T) {
 	m := NewManager(before)
 	err := m.Execute(cmd)
 	assert.Nil(t, err)
-	assert.Equal(t, 1, len(m.undoStack))
-	assert.Equal(t, 0, len(m.redoStack))
+	assert.Equal(t, 1, undoLen(m))
+	assert.Equal(t, 0, redoLen(m))
 	assert.Equal(t, after, m.GetLastModified())
 }
 
@@ -53,7 +63,7 @@ func TestManager_Execute_ErrorFromCommand(t *testing.T) {
 	err := m.Execute(cmd)
 	assert.NotNil(t, err)
 	assert.Equal(t, "exec fail", err.Error())
-	assert.Equal(t, 0, len(m.undoStack))
+	assert.Equal(t, 0, undoLen(m))
 }
 
 func TestManager_Undo_NoCommand(t *testing.T) {
@@ -72,8 +82,8 @@ func TestManager_Undo_Success(t *testing.T) {
 	m.Execute(cmd)
 	err := m.Undo()
 	assert.Nil(t, err)
-	assert.Equal(t, 0, len(m.undoStack))
-	assert.Equal(t, 1, len(m.redoStack))
+	assert.Equal(t, 0, undoLen(m))
+	assert.Equal(t, 1, redoLen(m))
 	assert.Equal(t, before, m.GetLastModified())
 }
 
@@ -91,7 +101,10 @@ func TestManager_Undo_ErrorFromCommand(t *testing.T) {
 	err := m.Undo()
 	assert.NotNil(t, err)
 	assert.Equal(t, "undo fail", err.Error())
-	assert.Equal(t, 0, len(m.undoStack)) // still popped
+	// the command stays applied, so the state does not move
+	assert.Equal(t, 1, undoLen(m))
+	assert.Equal(t, 0, redoLen(m))
+	assert.Equal(t, after, m.GetLastModified())
 }
 
 func TestManager_Redo_NoCommand(t *testing.T) {
@@ -111,26 +124,29 @@ func TestManager_Redo_Success(t *testing.T) {
 	m.Undo()
 	err := m.Redo()
 	assert.Nil(t, err)
-	assert.Equal(t, 1, len(m.undoStack))
-	assert.Equal(t, 0, len(m.redoStack))
+	assert.Equal(t, 1, undoLen(m))
+	assert.Equal(t, 0, redoLen(m))
 	assert.Equal(t, after, m.GetLastModified())
 }
 
 func TestManager_Redo_ErrorFromCommand(t *testing.T) {
 	before := time.Now()
 	after := before.Add(time.Minute)
-	cmd := &MockCommand{
-		before:     before,
-		after:      after,
-		executeErr: duerror.NewInvalidArgumentError("redo fail"),
-	}
+	cmd := &MockCommand{before: before, after: after}
 
 	m := NewManager(before)
 	m.Execute(cmd)
 	m.Undo()
+	cmd.executeErr = duerror.NewInvalidArgumentError("redo fail")
 	err := m.Redo()
 	assert.NotNil(t, err)
-	assert.Equal(t, "no redo command", err.Error())
+	assert.Equal(t, "redo fail", err.Error())
+	// the branch is kept and can be tried again
+	assert.Equal(t, 0, undoLen(m))
+	assert.Equal(t, 1, redoLen(m))
+	cmd.executeErr = nil
+	assert.Nil(t, m.Redo())
+	assert.Equal(t, 1, undoLen(m))
 }
 
 func TestManager_StackLimit(t *testing.T) {
@@ -145,6 +161,400 @@ func TestManager_StackLimit(t *testing.T) {
 		m.Execute(cmd)
 	}
 
-	assert.Equal(t, CMD_LIMIT, len(m.undoStack))
-	assert.Equal(t, 0, len(m.redoStack))
+	assert.Equal(t, CMD_LIMIT, undoLen(m))
+	assert.Equal(t, 0, redoLen(m))
+}
+
+// countingCommand records how many times it has been executed and unexecuted
+type countingCommand struct {
+	MockCommand
+	executed   int
+	unexecuted int
+}
+
+func (c *countingCommand) Execute() duerror.DUError {
+	if c.executeErr != nil {
+		return c.executeErr
+	}
+	c.executed++
+	return nil
+}
+
+func (c *countingCommand) Unexecute() duerror.DUError {
+	if c.unexecuteErr != nil {
+		return c.unexecuteErr
+	}
+	c.unexecuted++
+	return nil
+}
+
+func TestManager_Transaction_Commit(t *testing.T) {
+	now := time.Now()
+	m := NewManager(now)
+	cmd1 := &countingCommand{MockCommand: MockCommand{before: now, after: now.Add(time.Minute)}}
+	cmd2 := &countingCommand{MockCommand: MockCommand{before: now, after: now.Add(2 * time.Minute)}}
+
+	assert.Nil(t, m.Begin())
+	assert.True(t, m.InTransaction())
+	assert.Nil(t, m.Execute(cmd1))
+	assert.Nil(t, m.Execute(cmd2))
+	assert.Equal(t, 0, undoLen(m))
+	assert.Nil(t, m.Commit())
+	assert.False(t, m.InTransaction())
+
+	assert.Equal(t, 1, undoLen(m))
+	assert.Equal(t, now.Add(2*time.Minute), m.GetLastModified())
+
+	// one undo reverts both commands
+	assert.Nil(t, m.Undo())
+	assert.Equal(t, 1, cmd1.unexecuted)
+	assert.Equal(t, 1, cmd2.unexecuted)
+	assert.Equal(t, now, m.GetLastModified())
+
+	assert.Nil(t, m.Redo())
+	assert.Equal(t, 2, cmd1.executed)
+	assert.Equal(t, 2, cmd2.executed)
+}
+
+func TestManager_Transaction_Empty(t *testing.T) {
+	m := NewManager(time.Now())
+	assert.Nil(t, m.Begin())
+	assert.Nil(t, m.Commit())
+	assert.Equal(t, 0, undoLen(m))
+}
+
+func TestManager_Transaction_Errors(t *testing.T) {
+	m := NewManager(time.Now())
+	assert.NotNil(t, m.Commit())
+	assert.NotNil(t, m.Rollback())
+
+	assert.Nil(t, m.Begin())
+	err := m.Begin()
+	assert.NotNil(t, err)
+	assert.Equal(t, "transaction already in progress", err.Error())
+	assert.NotNil(t, m.Undo())
+	assert.NotNil(t, m.Redo())
+	assert.NotNil(t, m.Transaction(nil))
+}
+
+func TestManager_Transaction_Rollback(t *testing.T) {
+	now := time.Now()
+	m := NewManager(now)
+	cmd1 := &countingCommand{MockCommand: MockCommand{before: now, after: now.Add(time.Minute)}}
+
+	assert.Nil(t, m.Begin())
+	assert.Nil(t, m.Execute(cmd1))
+	assert.Nil(t, m.Rollback())
+	assert.Equal(t, 1, cmd1.unexecuted)
+	assert.Equal(t, 0, undoLen(m))
+	assert.Equal(t, now, m.GetLastModified())
+}
+
+func TestManager_Transaction_FailedStepRollsBack(t *testing.T) {
+	now := time.Now()
+	m := NewManager(now)
+	prev := &countingCommand{MockCommand: MockCommand{before: now, after: now.Add(time.Minute)}}
+	m.Execute(prev)
+
+	cmd1 := &countingCommand{MockCommand: MockCommand{before: now, after: now.Add(2 * time.Minute)}}
+	cmd2 := &countingCommand{MockCommand: MockCommand{before: now, after: now.Add(3 * time.Minute)}}
+	fail := &countingCommand{MockCommand: MockCommand{executeErr: duerror.NewInvalidArgumentError("step fail")}}
+
+	err := m.Transaction(func() duerror.DUError {
+		for _, c := range []Command{cmd1, cmd2, fail} {
+			if err := m.Execute(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, "step fail", err.Error())
+	assert.False(t, m.InTransaction())
+	assert.Equal(t, 1, cmd1.unexecuted)
+	assert.Equal(t, 1, cmd2.unexecuted)
+	assert.Equal(t, 0, prev.unexecuted)
+	assert.Equal(t, 1, undoLen(m))
+	assert.Equal(t, now.Add(time.Minute), m.GetLastModified())
+}
+
+func TestManager_Transaction_RollbackError(t *testing.T) {
+	now := time.Now()
+	m := NewManager(now)
+	cmd1 := &countingCommand{MockCommand: MockCommand{before: now, after: now.Add(time.Minute)}}
+	cmd2 := &countingCommand{MockCommand: MockCommand{before: now, after: now.Add(2 * time.Minute)}}
+	cmd3 := &countingCommand{MockCommand: MockCommand{before: now, after: now.Add(3 * time.Minute)}}
+
+	err := m.Transaction(func() duerror.DUError {
+		for _, c := range []*countingCommand{cmd1, cmd2, cmd3} {
+			m.Execute(c)
+		}
+		cmd2.unexecuteErr = duerror.NewInvalidArgumentError("undo fail")
+		return duerror.NewInvalidArgumentError("step fail")
+	})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "step fail")
+	assert.Contains(t, err.Error(), "undo fail")
+	// the commands around the failing one are still undone
+	assert.Equal(t, 1, cmd1.unexecuted)
+	assert.Equal(t, 1, cmd3.unexecuted)
+	assert.False(t, m.InTransaction())
+	assert.Equal(t, 0, undoLen(m))
+}
+
+func TestCompoundCommand_ExecuteErrorReverts(t *testing.T) {
+	now := time.Now()
+	cmd1 := &countingCommand{}
+	fail := &countingCommand{MockCommand: MockCommand{executeErr: duerror.NewInvalidArgumentError("redo fail")}}
+	compound := NewCompoundCommand([]Command{cmd1, fail}, now, now)
+
+	err := compound.Execute()
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, cmd1.executed)
+	assert.Equal(t, 1, cmd1.unexecuted)
+	assert.Equal(t, 2, len(compound.GetCommands()))
+}
+
+// mergeableCommand merges with following mergeable commands that share its key
+type mergeableCommand struct {
+	countingCommand
+	key    string
+	merged int
+}
+
+func (c *mergeableCommand) Merge(next Command) bool {
+	n, ok := next.(*mergeableCommand)
+	if !ok || n.key != c.key {
+		return false
+	}
+	c.after = n.after
+	c.merged++
+	return true
+}
+
+func newMergeable(key string, before, after time.Time) *mergeableCommand {
+	return &mergeableCommand{
+		countingCommand: countingCommand{MockCommand: MockCommand{before: before, after: after}},
+		key:             key,
+	}
+}
+
+func TestManager_Merge_Window(t *testing.T) {
+	now := time.Now()
+	m := NewManager(now)
+
+	first := newMergeable("a", now, now.Add(100*time.Millisecond))
+	assert.Nil(t, m.Execute(first))
+	assert.Nil(t, m.Execute(newMergeable("a", now, now.Add(200*time.Millisecond))))
+	assert.Nil(t, m.Execute(newMergeable("a", now, now.Add(300*time.Millisecond))))
+	assert.Equal(t, 1, undoLen(m))
+	assert.Equal(t, 2, first.merged)
+	assert.Equal(t, now.Add(300*time.Millisecond), m.GetLastModified())
+
+	// other key is not merged
+	assert.Nil(t, m.Execute(newMergeable("b", now, now.Add(400*time.Millisecond))))
+	assert.Equal(t, 2, undoLen(m))
+
+	// outside the window is not merged
+	assert.Nil(t, m.Execute(newMergeable("b", now, now.Add(400*time.Millisecond+MERGE_WINDOW_LIMIT+time.Millisecond))))
+	assert.Equal(t, 3, undoLen(m))
+
+	// the merged step keeps the original before state
+	m.Undo()
+	m.Undo()
+	assert.Nil(t, m.Undo())
+	assert.Equal(t, now, m.GetLastModified())
+	assert.Equal(t, 1, first.unexecuted)
+}
+
+func TestManager_Merge_WindowDisabled(t *testing.T) {
+	now := time.Now()
+	m := NewManager(now)
+	assert.NotNil(t, m.SetMergeWindow(-time.Second))
+	assert.Nil(t, m.SetMergeWindow(0))
+
+	m.Execute(newMergeable("a", now, now.Add(time.Millisecond)))
+	m.Execute(newMergeable("a", now, now.Add(2*time.Millisecond)))
+	assert.Equal(t, 2, undoLen(m))
+}
+
+func TestManager_Merge_DragMarkers(t *testing.T) {
+	now := time.Now()
+	m := NewManager(now)
+	assert.Nil(t, m.SetMergeWindow(0))
+
+	m.Execute(newMergeable("a", now, now.Add(time.Second)))
+
+	// a drag does not merge into the command before it
+	m.BeginMerge()
+	for i := 2; i < 10; i++ {
+		m.Execute(newMergeable("a", now, now.Add(time.Duration(i)*time.Second)))
+	}
+	m.EndMerge()
+	assert.Equal(t, 2, undoLen(m))
+
+	// nor into the next one
+	m.Execute(newMergeable("a", now, now.Add(20*time.Second)))
+	assert.Equal(t, 3, undoLen(m))
+}
+
+func TestManager_Merge_NotAfterUndo(t *testing.T) {
+	now := time.Now()
+	m := NewManager(now)
+
+	m.Execute(newMergeable("a", now, now.Add(time.Millisecond)))
+	m.Execute(newMergeable("b", now, now.Add(2*time.Millisecond)))
+	m.Undo()
+	m.Execute(newMergeable("a", now, now.Add(3*time.Millisecond)))
+	assert.Equal(t, 2, undoLen(m))
+}
+
+func TestManager_Branch_KeepsRedoPath(t *testing.T) {
+	now := time.Now()
+	m := NewManager(now)
+	a := &countingCommand{MockCommand: MockCommand{before: now, after: now.Add(time.Minute)}}
+	b := &countingCommand{MockCommand: MockCommand{before: now.Add(time.Minute), after: now.Add(2 * time.Minute)}}
+	c := &countingCommand{MockCommand: MockCommand{before: now.Add(time.Minute), after: now.Add(3 * time.Minute)}}
+
+	assert.Nil(t, m.Execute(a))
+	stateA := m.GetState()
+	assert.Nil(t, m.Execute(b))
+	stateB := m.GetState()
+	assert.Nil(t, m.Undo())
+	assert.Nil(t, m.Execute(c))
+	stateC := m.GetState()
+
+	// the old redo path of b is a separate branch now
+	branches := m.ListBranches()
+	assert.Len(t, branches, 2)
+	assert.Equal(t, stateB, branches[0].ID)
+	assert.False(t, branches[0].IsCurrent)
+	assert.Equal(t, 2, branches[0].Length)
+	assert.Equal(t, stateC, branches[1].ID)
+	assert.True(t, branches[1].IsCurrent)
+	assert.Equal(t, now.Add(3*time.Minute), branches[1].LastModified)
+
+	assert.Nil(t, m.SwitchBranch(stateB))
+	assert.Equal(t, stateB, m.GetState())
+	assert.Equal(t, 1, c.unexecuted)
+	assert.Equal(t, 2, b.executed)
+	assert.Equal(t, now.Add(2*time.Minute), m.GetLastModified())
+
+	// redo follows the branch that was switched to
+	assert.Nil(t, m.Undo())
+	assert.Equal(t, stateA, m.GetState())
+	assert.Nil(t, m.Redo())
+	assert.Equal(t, stateB, m.GetState())
+
+	err := m.SwitchBranch(stateA)
+	assert.NotNil(t, err)
+	assert.Equal(t, "branch does not exist", err.Error())
+}
+
+func TestManager_GoToState(t *testing.T) {
+	now := time.Now()
+	m := NewManager(now)
+	a := &countingCommand{MockCommand: MockCommand{before: now, after: now.Add(time.Minute)}}
+	b := &countingCommand{MockCommand: MockCommand{before: now.Add(time.Minute), after: now.Add(2 * time.Minute)}}
+	root := m.GetState()
+	m.Execute(a)
+	m.Execute(b)
+
+	assert.Nil(t, m.GoToState(root))
+	assert.Equal(t, 1, a.unexecuted)
+	assert.Equal(t, 1, b.unexecuted)
+	assert.Equal(t, 0, undoLen(m))
+	assert.Equal(t, 2, redoLen(m))
+	assert.Equal(t, now, m.GetLastModified())
+
+	err := m.GoToState(100)
+	assert.NotNil(t, err)
+	assert.Equal(t, "history state does not exist", err.Error())
+
+	m.Begin()
+	err = m.GoToState(root)
+	assert.NotNil(t, err)
+	assert.Equal(t, "cannot change state during a transaction", err.Error())
+	m.Rollback()
+}
+
+func TestManager_GoToState_FailureGoesBack(t *testing.T) {
+	now := time.Now()
+	m := NewManager(now)
+	a := &countingCommand{MockCommand: MockCommand{before: now, after: now.Add(time.Minute)}}
+	b := &countingCommand{MockCommand: MockCommand{before: now.Add(time.Minute), after: now.Add(2 * time.Minute)}}
+	c := &countingCommand{MockCommand: MockCommand{before: now.Add(time.Minute), after: now.Add(3 * time.Minute)}}
+	d := &countingCommand{MockCommand: MockCommand{before: now.Add(3 * time.Minute), after: now.Add(4 * time.Minute)}}
+	m.Execute(a)
+	m.Execute(b)
+	start := m.GetState()
+	m.Undo()
+	m.Execute(c)
+	m.Execute(d)
+	tip := m.GetState()
+	assert.Nil(t, m.GoToState(start))
+
+	// c is redone before d fails, both have to be undone again
+	d.executeErr = duerror.NewInvalidArgumentError("redo fail")
+	err := m.GoToState(tip)
+	assert.NotNil(t, err)
+	assert.Equal(t, "redo fail", err.Error())
+	assert.Equal(t, start, m.GetState())
+	assert.Equal(t, 2, c.executed)
+	assert.Equal(t, 2, c.unexecuted)
+	assert.Equal(t, 3, b.executed)
+	assert.Equal(t, now.Add(2*time.Minute), m.GetLastModified())
+
+	// a failing undo leaves the state where it was
+	d.executeErr = nil
+	b.unexecuteErr = duerror.NewInvalidArgumentError("undo fail")
+	err = m.GoToState(tip)
+	assert.NotNil(t, err)
+	assert.Equal(t, start, m.GetState())
+	assert.Equal(t, 2, len(m.ListBranches()))
+}
+
+func TestManager_Diff(t *testing.T) {
+	now := time.Now()
+	m := NewManager(now)
+	a := &MockCommand{before: now, after: now.Add(time.Minute)}
+	b := &MockCommand{before: now.Add(time.Minute), after: now.Add(2 * time.Minute)}
+	c := &MockCommand{before: now.Add(time.Minute), after: now.Add(3 * time.Minute)}
+	d := &MockCommand{before: now.Add(3 * time.Minute), after: now.Add(4 * time.Minute)}
+	m.Execute(a)
+	m.Execute(b)
+	stateB := m.GetState()
+	m.Undo()
+	m.Execute(c)
+	m.Execute(d)
+	stateD := m.GetState()
+
+	undo, redo, err := m.Diff(stateD, stateB)
+	assert.Nil(t, err)
+	assert.Equal(t, []Command{d, c}, undo)
+	assert.Equal(t, []Command{b}, redo)
+
+	undo, redo, err = m.Diff(stateB, stateB)
+	assert.Nil(t, err)
+	assert.Empty(t, undo)
+	assert.Empty(t, redo)
+
+	_, _, err = m.Diff(stateB, 100)
+	assert.NotNil(t, err)
+}
+
+func TestManager_Branch_Prune(t *testing.T) {
+	now := time.Now()
+	m := NewManager(now)
+	m.Execute(&MockCommand{before: now, after: now})
+	m.Execute(&MockCommand{before: now, after: now})
+	m.Undo()
+	// the first branch forks off the state right after the first command
+	for i := 0; i < CMD_LIMIT; i++ {
+		m.Execute(&MockCommand{before: now, after: now})
+	}
+	assert.Len(t, m.ListBranches(), 2)
+	m.Execute(&MockCommand{before: now, after: now})
+	assert.Len(t, m.ListBranches(), 1)
+	assert.Equal(t, CMD_LIMIT, undoLen(m))
 }
@@ -0,0 +1,87 @@
+package component
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"Dr.uml/backend/utils/duerror"
+)
+
+// Stereotypes («entity», «service», ...) and tagged values ({version = 2}) annotate gadgets and associations.
+// A tagged value with an empty value is a flag, it is written as its key alone.
+
+// NormalizeStereotypes trims the stereotypes and the guillemets around them and drops the repeated ones.
+// Empty stereotypes and stereotypes with a comma are invalid.
+func NormalizeStereotypes(stereotypes []string) ([]string, duerror.DUError) {
+	res := make([]string, 0, len(stereotypes))
+	for _, s := range stereotypes {
+		s = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(s), "«"), "»"))
+		if s == "" {
+			return nil, duerror.NewInvalidArgumentError("stereotype cannot be empty")
+		}
+		if strings.Contains(s, ",") {
+			return nil, duerror.NewInvalidArgumentError(fmt.Sprintf("stereotype %q cannot contain a comma", s))
+		}
+		if !slices.Contains(res, s) {
+			res = append(res, s)
+		}
+	}
+	return res, nil
+}
+
+// NormalizeTaggedValues trims the keys of the tagged values, which cannot be empty or repeated once trimmed
+func NormalizeTaggedValues(tags map[string]string) (map[string]string, duerror.DUError) {
+	res := make(map[string]string, len(tags))
+	for key, value := range tags {
+		trimmed := strings.TrimSpace(key)
+		if trimmed == "" {
+			return nil, duerror.NewInvalidArgumentError("tag key cannot be empty")
+		}
+		if strings.ContainsAny(trimmed, ",=") {
+			return nil, duerror.NewInvalidArgumentError(fmt.Sprintf("tag key %q cannot contain a comma or an equal sign", trimmed))
+		}
+		if _, ok := res[trimmed]; ok {
+			return nil, duerror.NewInvalidArgumentError(fmt.Sprintf("tag key %q is repeated", trimmed))
+		}
+		res[trimmed] = value
+	}
+	return res, nil
+}
+
+// HasStereotype reports whether c has the given stereotype
+func HasStereotype(c Component, stereotype string) bool {
+	return slices.Contains(c.GetStereotypes(), stereotype)
+}
+
+// GetTaggedValue returns the value of the tagged value of c with the given key and whether c has it
+func GetTaggedValue(c Component, key string) (string, bool) {
+	value, ok := c.GetTaggedValues()[key]
+	return value, ok
+}
+
+// FormatStereotypes writes the stereotypes as UML does, e.g. «entity, aggregate», empty if there are none
+func FormatStereotypes(stereotypes []string) string {
+	if len(stereotypes) == 0 {
+		return ""
+	}
+	return "«" + strings.Join(stereotypes, ", ") + "»"
+}
+
+// FormatTaggedValues writes the tagged values as UML does, sorted by key, e.g. {persistent, version = 2},
+// empty if there are none
+func FormatTaggedValues(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(tags))
+	for _, key := range slices.Sorted(maps.Keys(tags)) {
+		if tags[key] == "" {
+			parts = append(parts, key)
+		} else {
+			parts = append(parts, key+" = "+tags[key])
+		}
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
@@ -1,7 +1,10 @@
 package component
 
 import (
+	"fmt"
+	"maps"
 	"math"
+	"slices"
 
 	"Dr.uml/backend/component/attribute"
 	"Dr.uml/backend/drawdata"
@@ -32,15 +35,25 @@ var AllAssociationTypes = []struct {
 type Association struct {
 	assType          AssociationType
 	layer            int
+	layerName        string // the named layer the association belongs to, empty for the default one
 	attributes       []*attribute.AssAttribute
 	parents          [2]*Gadget
 	drawdata         drawdata.Association
 	isSelected       bool
+	isLocked         bool // protects the association from accidental edits, it can still be selected and copied
 	updateParentDraw func() duerror.DUError
 	startPointRatio  [2]float64
 	endPointRatio    [2]float64
+	observers        map[interface{}]func() duerror.DUError // notified when the path of the association changes
+	fontFallbacks    []string                               // font files for glyphs missing from the attribute fonts
+	stereotypes      []string
+	taggedValues     map[string]string
+	creation         uint64 // when the association was created, later ones are on top on the same layer
 }
 
+// CoverThreshold is how far, in pixels, a point may be from the path of an association and still cover it
+const CoverThreshold = 4
+
 // Constructor
 func NewAssociation(parents [2]*Gadget, assType AssociationType, stPoint utils.Point, enPoint utils.Point) (*Association, duerror.DUError) {
 	if assType&supportedAssociationType != assType || assType == 0 {
@@ -52,8 +65,9 @@ func NewAssociation(parents [2]*Gadget, assType AssociationType, stPoint utils.P
 	stGdd := parents[0].GetDrawData().(drawdata.Gadget)
 	enGdd := parents[1].GetDrawData().(drawdata.Gadget)
 	a := &Association{
-		assType: assType,
-		parents: [2]*Gadget{parents[0], parents[1]},
+		assType:  assType,
+		parents:  [2]*Gadget{parents[0], parents[1]},
+		creation: nextCreation(),
 		startPointRatio: [2]float64{
 			float64(stPoint.X-stGdd.X) / float64(stGdd.Width),
 			float64(stPoint.Y-stGdd.Y) / float64(stGdd.Height)},
@@ -77,9 +91,19 @@ func FromSavedAssociation(saved utils.SavedAss, parents [2]*Gadget) (*Associatio
 	ass := &Association{
 		assType:         AssociationType(saved.AssType),
 		layer:           saved.Layer,
+		layerName:       saved.LayerName,
+		isLocked:        saved.Locked,
 		parents:         parents,
 		startPointRatio: saved.StartPointRatio,
 		endPointRatio:   saved.EndPointRatio,
+		creation:        nextCreation(),
+	}
+	var err duerror.DUError
+	if ass.stereotypes, err = NormalizeStereotypes(saved.Stereotypes); err != nil {
+		return nil, duerror.NewCorruptedFile(fmt.Sprintf("Error when creating association from saved data: %v", err))
+	}
+	if ass.taggedValues, err = NormalizeTaggedValues(saved.TaggedValues); err != nil {
+		return nil, duerror.NewCorruptedFile(fmt.Sprintf("Error when creating association from saved data: %v", err))
 	}
 
 	if err := ass.UpdateDrawData(); err != nil {
@@ -94,13 +118,56 @@ func FromSavedAssociation(saved utils.SavedAss, parents [2]*Gadget) (*Associatio
 	return ass, nil
 }
 
+// Copy creates a deep copy of the Association and its attributes between the given parents.
+// The copy is unselected and observes its new parents.
+func (ass *Association) Copy(parents [2]*Gadget) (*Association, duerror.DUError) {
+	if parents[0] == nil || parents[1] == nil {
+		return nil, duerror.NewInvalidArgumentError("parents are nil")
+	}
+	c := &Association{
+		assType:         ass.assType,
+		layer:           ass.layer,
+		layerName:       ass.layerName,
+		isLocked:        ass.isLocked,
+		parents:         parents,
+		attributes:      make([]*attribute.AssAttribute, 0, len(ass.attributes)),
+		startPointRatio: ass.startPointRatio,
+		endPointRatio:   ass.endPointRatio,
+		fontFallbacks:   slices.Clone(ass.fontFallbacks),
+		stereotypes:     slices.Clone(ass.stereotypes),
+		taggedValues:    maps.Clone(ass.taggedValues),
+		creation:        nextCreation(),
+	}
+	for _, att := range ass.attributes {
+		copied, err := att.Copy()
+		if err != nil {
+			return nil, err
+		}
+		if err = copied.RegisterUpdateParentDraw(c.UpdateDrawData); err != nil {
+			return nil, err
+		}
+		c.attributes = append(c.attributes, copied)
+	}
+	if err := c.UpdateDrawData(); err != nil {
+		return nil, err
+	}
+	if err := c.RegisterAsObserver(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
 func (ass *Association) ToSavedAssociation(parents [2]int) utils.SavedAss {
 	savedAss := utils.SavedAss{
 		AssType:         int(ass.assType),
 		Layer:           ass.layer,
+		LayerName:       ass.layerName,
+		Locked:          ass.isLocked,
 		StartPointRatio: ass.startPointRatio,
 		EndPointRatio:   ass.endPointRatio,
 		Attributes:      make([]utils.SavedAtt, 0, len(ass.attributes)),
+		Stereotypes:     slices.Clone(ass.stereotypes),
+		TaggedValues:    maps.Clone(ass.taggedValues),
 	}
 	savedAss.Parents = []int{parents[0], parents[1]}
 
@@ -200,6 +267,15 @@ func (ass *Association) GetLayer() int {
 	return ass.layer
 }
 
+// GetCreation tells when the association was created, relative to the other components
+func (ass *Association) GetCreation() uint64 {
+	return ass.creation
+}
+
+func (ass *Association) GetLayerName() string {
+	return ass.layerName
+}
+
 func (ass *Association) GetParentEnd() *Gadget {
 	return ass.parents[1]
 }
@@ -220,6 +296,18 @@ func (ass *Association) GetIsSelected() bool {
 	return ass.isSelected
 }
 
+func (ass *Association) GetIsLocked() bool {
+	return ass.isLocked
+}
+
+func (ass *Association) GetStereotypes() []string {
+	return slices.Clone(ass.stereotypes)
+}
+
+func (ass *Association) GetTaggedValues() map[string]string {
+	return maps.Clone(ass.taggedValues)
+}
+
 // Setters
 func (ass *Association) SetIsSelected(value bool) duerror.DUError {
 	ass.isSelected = value
@@ -230,6 +318,43 @@ func (ass *Association) SetIsSelected(value bool) duerror.DUError {
 	return ass.updateParentDraw()
 }
 
+// SetStereotypes sets the stereotypes of the association, see NormalizeStereotypes
+func (ass *Association) SetStereotypes(stereotypes []string) duerror.DUError {
+	normalized, err := NormalizeStereotypes(stereotypes)
+	if err != nil {
+		return err
+	}
+	ass.stereotypes = normalized
+	ass.drawdata.Stereotypes = slices.Clone(normalized)
+	if ass.updateParentDraw == nil {
+		return nil
+	}
+	return ass.updateParentDraw()
+}
+
+// SetTaggedValues sets the tagged values of the association, see NormalizeTaggedValues
+func (ass *Association) SetTaggedValues(tags map[string]string) duerror.DUError {
+	normalized, err := NormalizeTaggedValues(tags)
+	if err != nil {
+		return err
+	}
+	ass.taggedValues = normalized
+	ass.drawdata.TaggedValues = maps.Clone(normalized)
+	if ass.updateParentDraw == nil {
+		return nil
+	}
+	return ass.updateParentDraw()
+}
+
+func (ass *Association) SetIsLocked(value bool) duerror.DUError {
+	ass.isLocked = value
+	ass.drawdata.IsLocked = value
+	if ass.updateParentDraw == nil {
+		return nil
+	}
+	return ass.updateParentDraw()
+}
+
 func (ass *Association) SetAssType(assType AssociationType) duerror.DUError {
 	if assType&supportedAssociationType != assType || assType == 0 {
 		return duerror.NewInvalidArgumentError("unsupported association type")
@@ -245,6 +370,17 @@ func (ass *Association) SetAssType(assType AssociationType) duerror.DUError {
 	return ass.updateParentDraw()
 }
 
+func (ass *Association) SetLayerName(name string) duerror.DUError {
+	ass.layerName = name
+	if ass.updateParentDraw == nil {
+		return nil
+	}
+	if err := ass.UpdateDrawData(); err != nil {
+		return err
+	}
+	return ass.updateParentDraw()
+}
+
 func (ass *Association) SetLayer(layer int) duerror.DUError {
 	ass.layer = layer
 	ass.drawdata.Layer = layer
@@ -355,6 +491,32 @@ func (ass *Association) SetAttrStyle(index int, style int) duerror.DUError {
 	return nil
 }
 
+func (ass *Association) SetAttrColor(index int, color string) duerror.DUError {
+	if err := ass.validateIndex(index); err != nil {
+		return err
+	}
+	if err := ass.attributes[index].SetColor(color); err != nil {
+		return err
+	}
+	if err := ass.UpdateDrawData(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ass *Association) SetAttrAlign(index int, align int) duerror.DUError {
+	if err := ass.validateIndex(index); err != nil {
+		return err
+	}
+	if err := ass.attributes[index].SetAlign(attribute.TextAlign(align)); err != nil {
+		return err
+	}
+	if err := ass.UpdateDrawData(); err != nil {
+		return err
+	}
+	return nil
+}
+
 func (ass *Association) SetAttrFontFile(index int, fontFile string) duerror.DUError {
 	if err := ass.validateIndex(index); err != nil {
 		return err
@@ -369,6 +531,17 @@ func (ass *Association) SetAttrFontFile(index int, fontFile string) duerror.DUEr
 	return nil
 }
 
+// SetAttrFont sets the font of an attribute, keeping a font that is not available, see attribute.Attribute.SetFont
+func (ass *Association) SetAttrFont(index int, font string) duerror.DUError {
+	if err := ass.validateIndex(index); err != nil {
+		return err
+	}
+	if err := ass.attributes[index].SetFont(font); err != nil {
+		return err
+	}
+	return ass.UpdateDrawData()
+}
+
 func (ass *Association) SetAttrRatio(index int, ratio float64) duerror.DUError {
 	if err := ass.validateIndex(index); err != nil {
 		return err
@@ -395,12 +568,26 @@ func (ass *Association) Cover(p utils.Point) (bool, duerror.DUError) {
 	stDelta := utils.AddPoints(st, delta)
 	enDelta := utils.AddPoints(en, delta)
 
-	threshold := float64(4)
+	threshold := float64(CoverThreshold)
 	return dist(stDelta, enDelta, p) <= threshold ||
 		dist(st, stDelta, p) <= threshold ||
 		dist(en, enDelta, p) <= threshold, nil
 }
 
+// SetFontFallbacks sets the font files used, in order, for glyphs missing from the fonts of the attributes.
+func (ass *Association) SetFontFallbacks(fontFiles []string) duerror.DUError {
+	if slices.Equal(ass.fontFallbacks, fontFiles) {
+		return nil
+	}
+	ass.fontFallbacks = slices.Clone(fontFiles)
+	for _, att := range ass.attributes {
+		if err := att.SetFontFallbacks(fontFiles); err != nil {
+			return err
+		}
+	}
+	return ass.UpdateDrawData()
+}
+
 func (ass *Association) AddAttribute(index int, ratio float64, content string) duerror.DUError {
 	if index < -1 || index > len(ass.attributes) {
 		return duerror.NewInvalidArgumentError("index not allow")
@@ -410,6 +597,9 @@ func (ass *Association) AddAttribute(index int, ratio float64, content string) d
 	if err != nil {
 		return err
 	}
+	if err = att.SetFontFallbacks(ass.fontFallbacks); err != nil {
+		return err
+	}
     if err := att.RegisterUpdateParentDraw(ass.UpdateDrawData); err != nil {
 		return err
     }
@@ -422,6 +612,9 @@ func (ass *Association) AddAttribute(index int, ratio float64, content string) d
 }
 
 func (ass *Association) AddLoadedAttribute(att *attribute.AssAttribute) duerror.DUError {
+	if err := att.SetFontFallbacks(ass.fontFallbacks); err != nil {
+		return err
+	}
 	att.RegisterUpdateParentDraw(ass.UpdateDrawData)
 	ass.attributes = append(ass.attributes, att)
 
@@ -455,6 +648,8 @@ func (ass *Association) UpdateDrawData() duerror.DUError {
 		return duerror.NewInvalidArgumentError("association or parents are nil")
 	}
 
+	ass.drawdata.LayerName = ass.layerName
+	oldDelta := utils.Point{X: ass.drawdata.DeltaX, Y: ass.drawdata.DeltaY}
 	ass.drawdata.DeltaX = 0
 	ass.drawdata.DeltaY = 0
 	var startPoint, endPoint utils.Point
@@ -492,11 +687,18 @@ func (ass *Association) UpdateDrawData() duerror.DUError {
 		return duerror.NewInvalidArgumentError("start and end points are the same")
 	}
 
+	moved := ass.drawdata.StartX != startPoint.X || ass.drawdata.StartY != startPoint.Y ||
+		ass.drawdata.EndX != endPoint.X || ass.drawdata.EndY != endPoint.Y ||
+		ass.drawdata.DeltaX != oldDelta.X || ass.drawdata.DeltaY != oldDelta.Y
 	ass.drawdata.StartX = startPoint.X
 	ass.drawdata.StartY = startPoint.Y
 	ass.drawdata.EndX = endPoint.X
 	ass.drawdata.EndY = endPoint.Y
 	ass.drawdata.IsSelected = ass.isSelected
+	ass.drawdata.IsLocked = ass.isLocked
+	ass.drawdata.Stereotypes = slices.Clone(ass.stereotypes)
+	ass.drawdata.TaggedValues = maps.Clone(ass.taggedValues)
+	ass.drawdata.ID = ass.creation
 	ass.drawdata.AssType = int(ass.assType)
 	ass.drawdata.Attributes = make([]drawdata.AssAttribute, len(ass.attributes))
 
@@ -506,6 +708,11 @@ func (ass *Association) UpdateDrawData() duerror.DUError {
 		}
 		ass.drawdata.Attributes[i] = att.GetDrawData()
 	}
+	if moved {
+		if err := ass.notifyObservers(); err != nil {
+			return err
+		}
+	}
 	if ass.updateParentDraw == nil {
 		return nil
 	}
@@ -557,3 +764,35 @@ func (ass *Association) validateIndex(index int) duerror.DUError {
 	}
 	return nil
 }
+
+// AddObserver registers a function called whenever the path of the association changes.
+func (ass *Association) AddObserver(observerKey interface{}, observer func() duerror.DUError) duerror.DUError {
+	if observer == nil {
+		return duerror.NewInvalidArgumentError("observer function is nil")
+	}
+	if observerKey == nil {
+		return duerror.NewInvalidArgumentError("observer key is nil")
+	}
+	if ass.observers == nil {
+		ass.observers = make(map[interface{}]func() duerror.DUError)
+	}
+	ass.observers[observerKey] = observer
+	return nil
+}
+
+func (ass *Association) RemoveObserver(observerKey interface{}) duerror.DUError {
+	if observerKey == nil {
+		return duerror.NewInvalidArgumentError("observer key is nil")
+	}
+	delete(ass.observers, observerKey)
+	return nil
+}
+
+func (ass *Association) notifyObservers() duerror.DUError {
+	for _, observer := range ass.observers {
+		if err := observer(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
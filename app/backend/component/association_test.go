@@ -307,3 +307,82 @@ func Test_Association_ToSavedAssociation(t *testing.T) {
 		t.Errorf("expected 1 attribute, got %v", len(saved.Attributes))
 	}
 }
+
+func Test_Association_Copy(t *testing.T) {
+	st := newEmptyGadget(Class, utils.Point{X: 0, Y: 0})
+	en := newEmptyGadget(Class, utils.Point{X: 300, Y: 300})
+	ass, err := NewAssociation([2]*Gadget{st, en}, Composition, utils.Point{X: 1, Y: 1}, utils.Point{X: 301, Y: 301})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = ass.AddAttribute(0, 0.5, "label"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	newSt := newEmptyGadget(Class, utils.Point{X: 100, Y: 0})
+	newEn := newEmptyGadget(Class, utils.Point{X: 400, Y: 300})
+	c, err := ass.Copy([2]*Gadget{newSt, newEn})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.GetParentStart() != newSt || c.GetParentEnd() != newEn {
+		t.Errorf("copy is not attached to the new parents")
+	}
+	if c.GetAssType() != Composition || c.GetStartRatio() != ass.GetStartRatio() || c.GetEndRatio() != ass.GetEndRatio() {
+		t.Errorf("copy differs from the original")
+	}
+	dd := c.GetDrawData().(drawdata.Association)
+	if dd.StartX != ass.GetDrawData().(drawdata.Association).StartX+100 {
+		t.Errorf("copy is not drawn relative to its new parents, got start x %v", dd.StartX)
+	}
+	if c.GetAttributesLen() != 1 || c.attributes[0] == ass.attributes[0] {
+		t.Errorf("attributes are not deep copied")
+	}
+	if _, ok := newSt.observers[c]; !ok {
+		t.Errorf("copy does not observe its start parent")
+	}
+
+	if _, err = ass.Copy([2]*Gadget{nil, newEn}); err == nil {
+		t.Errorf("expected error for nil parent")
+	}
+}
+
+func Test_Association_Annotations(t *testing.T) {
+	st := newEmptyGadget(Class, utils.Point{X: 0, Y: 0})
+	en := newEmptyGadget(Class, utils.Point{X: 300, Y: 300})
+	ass, err := NewAssociation([2]*Gadget{st, en}, Dependency, utils.Point{X: 1, Y: 1}, utils.Point{X: 301, Y: 301})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = ass.SetStereotypes([]string{"«use»"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = ass.SetTaggedValues(map[string]string{"protocol": "http"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = ass.SetStereotypes([]string{" "}); err == nil {
+		t.Errorf("expected error for an empty stereotype")
+	}
+	dd := ass.GetDrawData().(drawdata.Association)
+	if len(dd.Stereotypes) != 1 || dd.Stereotypes[0] != "use" || dd.TaggedValues["protocol"] != "http" {
+		t.Errorf("expected the annotations in the draw data, got %v and %v", dd.Stereotypes, dd.TaggedValues)
+	}
+
+	loaded, err := FromSavedAssociation(ass.ToSavedAssociation([2]int{0, 1}), [2]*Gadget{st, en})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !HasStereotype(loaded, "use") {
+		t.Errorf("expected the stereotype to be loaded, got %v", loaded.GetStereotypes())
+	}
+	if value, ok := GetTaggedValue(loaded, "protocol"); !ok || value != "http" {
+		t.Errorf("expected the tagged value to be loaded, got %v", loaded.GetTaggedValues())
+	}
+	copied, err := ass.Copy([2]*Gadget{st, en})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !HasStereotype(copied, "use") {
+		t.Errorf("expected the stereotype to be copied, got %v", copied.GetStereotypes())
+	}
+}
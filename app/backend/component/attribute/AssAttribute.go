@@ -1,6 +1,8 @@
 package attribute
 
 import (
+	"slices"
+
 	"Dr.uml/backend/drawdata"
 	"Dr.uml/backend/utils"
 	"Dr.uml/backend/utils/duerror"
@@ -39,24 +41,44 @@ func NewAssAttribute(ratio float64, content string) (*AssAttribute, duerror.DUEr
 func FromSavedAssAttribute(savedAssAtt utils.SavedAtt) (*AssAttribute, duerror.DUError) {
 	ass := &AssAttribute{
 		Attribute: Attribute{
-			content:  savedAssAtt.Content,
-			size:     savedAssAtt.Size,
-			style:    Textstyle(savedAssAtt.Style),
-			fontFile: savedAssAtt.FontFile,
+			content: savedAssAtt.Content,
+			size:    savedAssAtt.Size,
+			style:   Textstyle(savedAssAtt.Style),
 		},
 		ratio: savedAssAtt.Ratio,
 	}
+	if err := ass.loadFont(savedAssAtt); err != nil {
+		return nil, err
+	}
+	if err := ass.loadLook(savedAssAtt); err != nil {
+		return nil, err
+	}
 	ass.updateDrawData()
 	return ass, nil
 }
 
+// Copy creates a deep copy of the AssAttribute, the copy is not attached to any association.
+func (att *AssAttribute) Copy() (*AssAttribute, duerror.DUError) {
+	inner, err := att.Attribute.Copy()
+	if err != nil {
+		return nil, err
+	}
+	return &AssAttribute{
+		Attribute: *inner,
+		ratio:     att.ratio,
+		assDD:     att.assDD,
+	}, nil
+}
+
 func (att *AssAttribute) ToSavedAssAttribute() utils.SavedAtt {
 	return utils.SavedAtt{
-		Content:  att.content,
-		Size:     att.size,
-		Style:    int(att.style),
-		FontFile: att.fontFile,
-		Ratio:    att.ratio,
+		Content: att.content,
+		Size:    att.size,
+		Style:   int(att.style),
+		Font:    att.font,
+		Color:   att.color,
+		Align:   int(att.align),
+		Ratio:   att.ratio,
 	}
 }
 
@@ -80,9 +102,18 @@ func (att *AssAttribute) SetRatio(ratio float64) duerror.DUError {
 	return nil
 }
 
+// SetFontFallbacks sets the font files tried, in order, for glyphs missing from the font of the attribute.
+func (att *AssAttribute) SetFontFallbacks(fontFiles []string) duerror.DUError {
+	if slices.Equal(att.fallbacks, fontFiles) {
+		return nil
+	}
+	att.fallbacks = slices.Clone(fontFiles)
+	return att.updateDrawData()
+}
+
 func (att *AssAttribute) updateDrawData() duerror.DUError {
 
-	height, _, err := utils.GetTextSize(att.content, att.size, att.fontFile)
+	height, _, lines, err := att.measure()
 	if err != nil {
 		return err
 	}
@@ -92,7 +123,11 @@ func (att *AssAttribute) updateDrawData() duerror.DUError {
 	att.assDD.FontSize = att.size
 	att.assDD.FontStyle = int(att.style)
 	att.assDD.FontFile = att.getFontFileBase()
+	att.assDD.FontFamily = att.font
+	att.assDD.Color = att.color
+	att.assDD.Align = int(att.align)
 	att.assDD.Ratio = att.ratio
+	att.assDD.Lines = lines
 	if att.updateParentDrawOuter != nil {
 		att.updateParentDrawOuter()
 	}
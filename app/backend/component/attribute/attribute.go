@@ -4,8 +4,8 @@ import (
 	"Dr.uml/backend/drawdata"
 	"Dr.uml/backend/utils"
 	"Dr.uml/backend/utils/duerror"
-	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 )
 
@@ -14,17 +14,29 @@ type Attribute struct {
 	content          string
 	size             int
 	style            Textstyle
-	fontFile         string
+	font             string // family the attribute asks for
+	fontFile         string // file drawing it, a substitute when fontMissing
+	fontMissing      bool
+	color            string
+	align            TextAlign
+	fallbacks        []string // font files tried in order for glyphs fontFile lacks
+	wrapWidth        int      // width the content wraps at, 0 for no wrapping
 	drawData         drawdata.Attribute
 	updateParentDraw func() duerror.DUError
 }
 
 func NewAttribute(content string) (*Attribute, duerror.DUError) {
+	font, err := utils.FontFamily(utils.DefaultFontFile())
+	if err != nil {
+		return nil, err
+	}
 	att := &Attribute{
 		content:  content,
 		size:     drawdata.DefaultAttributeFontSize,
 		style:    drawdata.DefaultAttributeFontStyle,
-		fontFile: os.Getenv("APP_ROOT") + drawdata.DefaultAttributeFontFile,
+		font:     font,
+		fontFile: utils.DefaultFontFile(),
+		color:    drawdata.DefaultAttributeColor,
 	}
 	if err := att.updateDrawData(); err != nil {
 		return nil, err
@@ -34,10 +46,15 @@ func NewAttribute(content string) (*Attribute, duerror.DUError) {
 
 func FromSavedAttribute(savedAtt utils.SavedAtt) (*Attribute, duerror.DUError) {
 	att := &Attribute{
-		content:  savedAtt.Content,
-		size:     savedAtt.Size,
-		style:    Textstyle(savedAtt.Style),
-		fontFile: savedAtt.FontFile,
+		content: savedAtt.Content,
+		size:    savedAtt.Size,
+		style:   Textstyle(savedAtt.Style),
+	}
+	if err := att.loadFont(savedAtt); err != nil {
+		return nil, err
+	}
+	if err := att.loadLook(savedAtt); err != nil {
+		return nil, err
 	}
 	if err := att.updateDrawData(); err != nil {
 		return nil, err
@@ -47,16 +64,69 @@ func FromSavedAttribute(savedAtt utils.SavedAtt) (*Attribute, duerror.DUError) {
 
 func ToSavedAttribute(att *Attribute) utils.SavedAtt {
 	return utils.SavedAtt{
-		Content:  att.content,
-		Size:     att.size,
-		Style:    int(att.style),
-		FontFile: att.fontFile,
+		Content: att.content,
+		Size:    att.size,
+		Style:   int(att.style),
+		Font:    att.font,
+		Color:   att.color,
+		Align:   int(att.align),
+	}
+}
+
+// loadLook sets the color and alignment of a saved attribute, files of older versions have neither.
+func (att *Attribute) loadLook(saved utils.SavedAtt) duerror.DUError {
+	att.color = drawdata.DefaultAttributeColor
+	if saved.Color != "" {
+		if err := utils.ValidateHexColor(saved.Color); err != nil {
+			return err
+		}
+		att.color = saved.Color
+	}
+	if err := validateAlign(TextAlign(saved.Align)); err != nil {
+		return err
+	}
+	att.align = TextAlign(saved.Align)
+	return nil
+}
+
+// loadFont finds the font of a saved attribute. Files saved by older versions refer to the font by its path.
+// A font that is not available is kept by name and drawn with the default font until it is.
+func (att *Attribute) loadFont(saved utils.SavedAtt) duerror.DUError {
+	name := saved.Font
+	if name == "" && saved.FontFile != "" {
+		if family, err := utils.FontFamily(saved.FontFile); err == nil {
+			att.font, att.fontFile = family, saved.FontFile
+			return nil
+		}
+		name = fontFileBase(saved.FontFile)
+	}
+	if name == "" {
+		name = utils.DefaultFontFile()
+	}
+	return att.setFont(name)
+}
+
+// setFont sets the font by family, file name or path. A font that is not available is kept by name and drawn with
+// the default font until it is.
+func (att *Attribute) setFont(name string) duerror.DUError {
+	if info, err := utils.LookupFont(name); err == nil {
+		att.font, att.fontFile, att.fontMissing = info.Family, info.File, false
+		return nil
+	}
+	if err := utils.ValidateFontFile(utils.DefaultFontFile()); err != nil {
+		return err
 	}
+	att.font, att.fontFile, att.fontMissing = name, utils.DefaultFontFile(), true
+	return nil
 }
 
 func (att *Attribute) getFontFileBase() string {
+	return fontFileBase(att.fontFile)
+}
+
+func fontFileBase(fontFile string) string {
 	// Extract the base filename with extension
-	baseWithExt := filepath.Base(att.fontFile)
+	baseWithExt := filepath.Base(fontFile)
 
 	// Extract the filename without extension
 	base := strings.TrimSuffix(baseWithExt, filepath.Ext(baseWithExt))
@@ -99,13 +169,21 @@ func (att *Attribute) GetStyle() Textstyle {
 
 // SetStyle sets the style attribute for the text. Returns an error if the style contains unsupported flags.
 func (att *Attribute) SetStyle(style Textstyle) duerror.DUError {
-	if style & ^supportedTextStyleFlags != 0 {
-		return duerror.NewInvalidArgumentError("style contains unsupported flags")
+	if err := ValidateTextStyle(style); err != nil {
+		return err
 	}
 	att.style = style
 	return att.updateDrawData()
 }
 
+// ValidateTextStyle checks that style only combines supported flags.
+func ValidateTextStyle(style Textstyle) duerror.DUError {
+	if style & ^supportedTextStyleFlags != 0 {
+		return duerror.NewInvalidArgumentError("style contains unsupported flags")
+	}
+	return nil
+}
+
 // SetBold sets or clears the bold style for the attribute based on the provided boolean value. Returns an error if any occurs.
 func (att *Attribute) SetBold(value bool) duerror.DUError {
 	if value {
@@ -136,13 +214,68 @@ func (att *Attribute) SetUnderline(value bool) duerror.DUError {
 	return att.updateDrawData()
 }
 
-// SetFontFile sets the font file path for the Attribute and updates the drawData accordingly.
-// Returns an error if the file path is invalid.
-func (att *Attribute) SetFontFile(fontFile string) duerror.DUError {
-	if err := utils.ValidateFilePath(fontFile); err != nil {
+// SetStrikethrough sets or clears the strikethrough style of the Attribute, used for deprecated members.
+func (att *Attribute) SetStrikethrough(value bool) duerror.DUError {
+	if value {
+		att.style |= Strikethrough
+	} else {
+		att.style &^= Strikethrough
+	}
+	return att.updateDrawData()
+}
+
+// GetColor returns the text color of the Attribute as a hex string.
+func (att *Attribute) GetColor() string {
+	return att.color
+}
+
+// SetColor sets the text color of the Attribute. Returns an error if color is not a hex color.
+func (att *Attribute) SetColor(color string) duerror.DUError {
+	if err := utils.ValidateHexColor(color); err != nil {
+		return err
+	}
+	att.color = color
+	return att.updateDrawData()
+}
+
+// GetAlign returns the horizontal alignment of the Attribute.
+func (att *Attribute) GetAlign() TextAlign {
+	return att.align
+}
+
+// SetAlign sets the horizontal alignment of the Attribute. Returns an error if align is unknown.
+func (att *Attribute) SetAlign(align TextAlign) duerror.DUError {
+	if err := validateAlign(align); err != nil {
+		return err
+	}
+	att.align = align
+	return att.updateDrawData()
+}
+
+func validateAlign(align TextAlign) duerror.DUError {
+	if align < AlignLeft || align > AlignRight {
+		return duerror.NewInvalidArgumentError("unknown alignment")
+	}
+	return nil
+}
+
+// SetFontFile sets the font of the Attribute by family, font file name or path, as understood by utils.LookupFont,
+// and updates the drawData accordingly. Returns an error if no such font is available.
+func (att *Attribute) SetFontFile(font string) duerror.DUError {
+	info, err := utils.LookupFont(font)
+	if err != nil {
+		return err
+	}
+	att.font, att.fontFile, att.fontMissing = info.Family, info.File, false
+	return att.updateDrawData()
+}
+
+// SetFont sets the font of the Attribute like SetFontFile, but keeps a font that is not available as loading does,
+// see IsFontMissing. It brings back a missing font an edit replaced.
+func (att *Attribute) SetFont(font string) duerror.DUError {
+	if err := att.setFont(font); err != nil {
 		return err
 	}
-	att.fontFile = os.Getenv("APP_ROOT") + "/frontend/src/assets/fonts/" + fontFile + ".ttf"
 	return att.updateDrawData()
 }
 
@@ -150,6 +283,47 @@ func (att *Attribute) GetFontFile() string {
 	return att.fontFile
 }
 
+// GetFont returns the family of the font of the Attribute.
+func (att *Attribute) GetFont() string {
+	return att.font
+}
+
+// IsFontMissing reports whether the font of the Attribute is not available and a substitute draws it.
+func (att *Attribute) IsFontMissing() bool {
+	return att.fontMissing
+}
+
+// GetFontFallbacks returns the font files used for glyphs missing from the font of the attribute.
+func (att *Attribute) GetFontFallbacks() []string {
+	return att.fallbacks
+}
+
+// SetFontFallbacks sets the font files tried, in order, for glyphs missing from the font of the attribute.
+func (att *Attribute) SetFontFallbacks(fontFiles []string) duerror.DUError {
+	if slices.Equal(att.fallbacks, fontFiles) {
+		return nil
+	}
+	att.fallbacks = slices.Clone(fontFiles)
+	return att.updateDrawData()
+}
+
+// GetWrapWidth returns the width the content wraps at, 0 if it does not wrap.
+func (att *Attribute) GetWrapWidth() int {
+	return att.wrapWidth
+}
+
+// SetWrapWidth sets the width the content wraps at, 0 turns wrapping off.
+func (att *Attribute) SetWrapWidth(width int) duerror.DUError {
+	if width < 0 {
+		return duerror.NewInvalidArgumentError("wrap width cannot be negative")
+	}
+	if width == att.wrapWidth {
+		return nil
+	}
+	att.wrapWidth = width
+	return att.updateDrawData()
+}
+
 // IsBold checks if the bold style is applied to the attribute and returns a boolean along with an error if any occurs.
 func (att *Attribute) IsBold() bool {
 	return att.style&Bold != 0
@@ -165,12 +339,26 @@ func (att *Attribute) IsUnderline() bool {
 	return att.style&Underline != 0
 }
 
-// Copy creates and returns a deep copy of the Attribute with identical content, size, and style. It returns an error if any occurs.
+// IsStrikethrough reports whether the strikethrough style is applied to the attribute.
+func (att *Attribute) IsStrikethrough() bool {
+	return att.style&Strikethrough != 0
+}
+
+// Copy creates and returns a deep copy of the Attribute with identical content, size, style and font. It returns an error if any occurs.
+// The copy is not attached to any parent.
 func (att *Attribute) Copy() (*Attribute, duerror.DUError) {
 	return &Attribute{
-		content: att.content,
-		size:    att.size,
-		style:   att.style,
+		content:     att.content,
+		size:        att.size,
+		style:       att.style,
+		font:        att.font,
+		fontFile:    att.fontFile,
+		fontMissing: att.fontMissing,
+		color:       att.color,
+		align:       att.align,
+		fallbacks:   slices.Clone(att.fallbacks),
+		wrapWidth:   att.wrapWidth,
+		drawData:    att.drawData,
 	}, nil
 }
 
@@ -178,6 +366,34 @@ func (att *Attribute) GetDrawData() drawdata.Attribute {
 	return att.drawData
 }
 
+// measure lays the content out in lines with the font of the attribute followed by its fallbacks.
+func (att *Attribute) measure() (int, int, []drawdata.TextLine, duerror.DUError) {
+	chain := append([]string{att.fontFile}, att.fallbacks...)
+	height, width, measured, err := utils.LayoutText(att.content, att.size, int(att.style), chain, att.wrapWidth)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	lines := make([]drawdata.TextLine, len(measured))
+	for i, line := range measured {
+		runs := make([]drawdata.TextRun, len(line.Runs))
+		for j, run := range line.Runs {
+			runs[j] = drawdata.TextRun{
+				Content:  run.Content,
+				FontFile: fontFileBase(run.FontFile),
+				Width:    run.Width,
+			}
+		}
+		lines[i] = drawdata.TextLine{
+			Content: line.Content,
+			Y:       line.Y,
+			Width:   line.Width,
+			Height:  line.Height,
+			Runs:    runs,
+		}
+	}
+	return height, width, lines, nil
+}
+
 func (att *Attribute) RegisterUpdateParentDraw(update func() duerror.DUError) duerror.DUError {
 	if update == nil {
 		return duerror.NewInvalidArgumentError("update function is nil")
@@ -191,7 +407,7 @@ func (att *Attribute) updateDrawData() duerror.DUError {
 		return duerror.NewInvalidArgumentError("attribute is nil")
 	}
 
-	height, width, err := utils.GetTextSize(att.content, att.size, att.fontFile)
+	height, width, lines, err := att.measure()
 	if err != nil {
 		return err
 	}
@@ -207,6 +423,10 @@ func (att *Attribute) updateDrawData() duerror.DUError {
 	att.drawData.FontSize = att.size
 	att.drawData.FontStyle = int(att.style)
 	att.drawData.FontFile = att.getFontFileBase()
+	att.drawData.FontFamily = att.font
+	att.drawData.Color = att.color
+	att.drawData.Align = int(att.align)
+	att.drawData.Lines = lines
 
 	if att.updateParentDraw == nil {
 		return nil
@@ -177,7 +177,7 @@ func TestAttribute_SetStyle(t *testing.T) {
 		},
 		{
 			name:     "invalid style",
-			setValue: 8,
+			setValue: 16,
 			hasError: true,
 			expected: 0,
 		},
@@ -313,6 +313,109 @@ func TestAttribute_SetUnderline(t *testing.T) {
 	}
 }
 
+func TestAttribute_SetStrikethrough(t *testing.T) {
+	tests := []struct {
+		name      string
+		initStyle Textstyle
+		setValue  bool
+		expected  Textstyle
+	}{
+		{
+			name:      "enable strikethrough",
+			initStyle: Bold,
+			setValue:  true,
+			expected:  Bold | Strikethrough,
+		},
+		{
+			name:      "disable strikethrough",
+			initStyle: Strikethrough,
+			setValue:  false,
+			expected:  0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			att := Attribute{style: tt.initStyle}
+			att.SetSize(69)
+			err := att.SetStrikethrough(tt.setValue)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if att.style != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, att.style)
+			}
+			if att.IsStrikethrough() != tt.setValue {
+				t.Errorf("expected IsStrikethrough %v", tt.setValue)
+			}
+		})
+	}
+}
+
+func TestAttribute_SetColor(t *testing.T) {
+	att, err := NewAttribute("a")
+	assert.NoError(t, err)
+	assert.Equal(t, drawdata.DefaultAttributeColor, att.GetColor())
+	assert.Equal(t, drawdata.DefaultAttributeColor, att.GetDrawData().Color)
+
+	assert.NoError(t, att.SetColor("#FF0000"))
+	assert.Equal(t, "#FF0000", att.GetColor())
+	assert.Equal(t, "#FF0000", att.GetDrawData().Color)
+
+	assert.Error(t, att.SetColor("red"))
+	assert.Equal(t, "#FF0000", att.GetColor())
+}
+
+func TestAttribute_SetAlign(t *testing.T) {
+	att, err := NewAttribute("a")
+	assert.NoError(t, err)
+	assert.Equal(t, AlignLeft, att.GetAlign())
+
+	assert.NoError(t, att.SetAlign(AlignCenter))
+	assert.Equal(t, AlignCenter, att.GetAlign())
+	assert.Equal(t, int(AlignCenter), att.GetDrawData().Align)
+
+	assert.Error(t, att.SetAlign(AlignRight+1))
+	assert.Error(t, att.SetAlign(-1))
+	assert.Equal(t, AlignCenter, att.GetAlign())
+}
+
+func TestAttribute_SavedLook(t *testing.T) {
+	att, err := NewAttribute("a")
+	assert.NoError(t, err)
+	assert.NoError(t, att.SetColor("#123456"))
+	assert.NoError(t, att.SetAlign(AlignRight))
+	assert.NoError(t, att.SetStrikethrough(true))
+
+	saved := ToSavedAttribute(att)
+	assert.Equal(t, "#123456", saved.Color)
+	assert.Equal(t, int(AlignRight), saved.Align)
+	loaded, err := FromSavedAttribute(saved)
+	assert.NoError(t, err)
+	assert.Equal(t, "#123456", loaded.GetColor())
+	assert.Equal(t, AlignRight, loaded.GetAlign())
+	assert.True(t, loaded.IsStrikethrough())
+
+	// files of older versions have no color
+	saved.Color = ""
+	loaded, err = FromSavedAttribute(saved)
+	assert.NoError(t, err)
+	assert.Equal(t, drawdata.DefaultAttributeColor, loaded.GetColor())
+
+	saved.Color = "#12345"
+	_, err = FromSavedAttribute(saved)
+	assert.Error(t, err)
+	saved.Color = ""
+	saved.Align = 3
+	_, err = FromSavedAttribute(saved)
+	assert.Error(t, err)
+
+	copied, err := att.Copy()
+	assert.NoError(t, err)
+	assert.Equal(t, "#123456", copied.GetColor())
+	assert.Equal(t, AlignRight, copied.GetAlign())
+}
+
 func TestAttribute_IsBold(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -421,6 +524,19 @@ func TestAttribute_Copy(t *testing.T) {
 			attribute: Attribute{},
 			expected:  Attribute{},
 		},
+		{
+			name: "copy attribute with font",
+			attribute: Attribute{
+				content:  "test content",
+				size:     12,
+				fontFile: "font.ttf",
+			},
+			expected: Attribute{
+				content:  "test content",
+				size:     12,
+				fontFile: "font.ttf",
+			},
+		},
 		{
 			name: "copy attribute with all styles",
 			attribute: Attribute{
@@ -452,6 +568,9 @@ func TestAttribute_Copy(t *testing.T) {
 			if copy.style != tt.expected.style {
 				t.Errorf("style: expected %v, got %v", tt.expected.style, copy.style)
 			}
+			if copy.fontFile != tt.expected.fontFile {
+				t.Errorf("fontFile: expected %v, got %v", tt.expected.fontFile, copy.fontFile)
+			}
 		})
 	}
 }
@@ -797,11 +916,87 @@ func TestToSavedAttribute(t *testing.T) {
 		content:  expectedContent,
 		size:     expectedSize,
 		style:    expectedStyle,
+		font:     "Ink Free",
 		fontFile: expectedFontFile,
 	}
 	savedAtt := ToSavedAttribute(att)
 	assert.Equal(t, expectedContent, savedAtt.Content)
 	assert.Equal(t, expectedSize, savedAtt.Size)
 	assert.Equal(t, int(expectedStyle), savedAtt.Style)
-	assert.Equal(t, expectedFontFile, savedAtt.FontFile)
+	assert.Equal(t, "Ink Free", savedAtt.Font)
+	assert.Empty(t, savedAtt.FontFile)
+}
+
+func TestAttribute_MultiLine(t *testing.T) {
+	att, err := NewAttribute("first line\nsecond")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dd := att.GetDrawData()
+	if len(dd.Lines) != 2 || dd.Lines[0].Content != "first line" || dd.Lines[1].Content != "second" {
+		t.Fatalf("expected two lines, got %v", dd.Lines)
+	}
+	if dd.Lines[1].Y != dd.Lines[0].Height || dd.Height != dd.Lines[0].Height+dd.Lines[1].Height {
+		t.Errorf("lines are not stacked: %v, height %d", dd.Lines, dd.Height)
+	}
+	if dd.Width != max(dd.Lines[0].Width, dd.Lines[1].Width) {
+		t.Errorf("expected the width of the widest line, got %d", dd.Width)
+	}
+
+	if err = att.SetWrapWidth(-1); err == nil {
+		t.Errorf("expected error for a negative wrap width")
+	}
+	if err = att.SetWrapWidth(dd.Lines[0].Width - 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dd = att.GetDrawData()
+	if len(dd.Lines) != 3 || dd.Lines[0].Content != "first" || dd.Lines[1].Content != "line" {
+		t.Errorf("expected the first line to wrap, got %v", dd.Lines)
+	}
+
+	copied, _ := att.Copy()
+	if copied.GetWrapWidth() != att.GetWrapWidth() {
+		t.Errorf("expected the copy to keep the wrap width")
+	}
+}
+
+func TestFromSavedAttribute_Font(t *testing.T) {
+	// by family
+	att, err := FromSavedAttribute(utils.SavedAtt{Content: "a", Size: 12, Font: "Arial"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Arial", att.GetFont())
+	assert.Equal(t, "Arial", att.GetDrawData().FontFile)
+	assert.False(t, att.IsFontMissing())
+
+	// files of older versions store the path of the font
+	legacy := os.Getenv("APP_ROOT") + "/frontend/src/assets/fonts/Inkfree.ttf"
+	att, err = FromSavedAttribute(utils.SavedAtt{Content: "a", Size: 12, FontFile: legacy})
+	assert.NoError(t, err)
+	assert.Equal(t, "Ink Free", att.GetFont())
+	assert.Equal(t, legacy, att.GetFontFile())
+
+	// a path from another machine is looked up by its file name
+	att, err = FromSavedAttribute(utils.SavedAtt{Content: "a", Size: 12, FontFile: "/elsewhere/fonts/Arial.ttf"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Arial", att.GetFont())
+	assert.False(t, att.IsFontMissing())
+
+	// a missing font is drawn with the default font and kept
+	att, err = FromSavedAttribute(utils.SavedAtt{Content: "a", Size: 12, Font: "No Such Font"})
+	assert.NoError(t, err)
+	assert.True(t, att.IsFontMissing())
+	assert.Equal(t, utils.DefaultFontFile(), att.GetFontFile())
+	assert.Equal(t, "No Such Font", ToSavedAttribute(att).Font)
+
+	// setting a font that exists replaces it
+	assert.NoError(t, att.SetFontFile("Arial"))
+	assert.False(t, att.IsFontMissing())
+	assert.Equal(t, "Arial", att.GetFont())
+	assert.Error(t, att.SetFontFile("No Such Font"))
+	assert.Equal(t, "Arial", att.GetFont())
+	// SetFont keeps it, as loading does
+	assert.NoError(t, att.SetFont("No Such Font"))
+	assert.True(t, att.IsFontMissing())
+	assert.Equal(t, "No Such Font", att.GetFont())
+	assert.Equal(t, utils.DefaultFontFile(), att.GetFontFile())
 }
@@ -6,7 +6,8 @@ const (
 	Bold                    = 1 << iota // 0x1
 	Italic                  = 1 << iota // 0x2
 	Underline               = 1 << iota // 0x4
-	supportedTextStyleFlags = Bold | Italic | Underline
+	Strikethrough           = 1 << iota // 0x8
+	supportedTextStyleFlags = Bold | Italic | Underline | Strikethrough
 )
 
 var AllTextstyleTypes = []struct {
@@ -16,4 +17,23 @@ var AllTextstyleTypes = []struct {
 	{Bold, "Bold"},
 	{Italic, "Italic"},
 	{Underline, "Underline"},
+	{Strikethrough, "Strikethrough"},
+}
+
+// TextAlign is the horizontal alignment of an attribute within its gadget
+type TextAlign int
+
+const (
+	AlignLeft TextAlign = iota
+	AlignCenter
+	AlignRight
+)
+
+var AllTextAlignTypes = []struct {
+	Value  TextAlign
+	TSName string
+}{
+	{AlignLeft, "AlignLeft"},
+	{AlignCenter, "AlignCenter"},
+	{AlignRight, "AlignRight"},
 }
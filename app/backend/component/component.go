@@ -1,6 +1,8 @@
 package component
 
 import (
+	"sync/atomic"
+
 	"Dr.uml/backend/utils"
 	"Dr.uml/backend/utils/duerror"
 )
@@ -11,9 +13,24 @@ type Component interface {
 	// Copy() (Component, duerror.DUError)
 	Cover(p utils.Point) (bool, duerror.DUError)
 	GetLayer() int
+	GetLayerName() string
 	GetIsSelected() bool
+	GetIsLocked() bool
 	SetLayer(layer int) duerror.DUError
+	SetLayerName(name string) duerror.DUError
 	SetIsSelected(isSelected bool) duerror.DUError
+	SetIsLocked(isLocked bool) duerror.DUError // a locked component cannot be moved, resized, edited or removed
+	GetStereotypes() []string
+	SetStereotypes(stereotypes []string) duerror.DUError
+	GetTaggedValues() map[string]string
+	SetTaggedValues(tags map[string]string) duerror.DUError
 	GetDrawData() any
 	RegisterUpdateParentDraw(update func() duerror.DUError) duerror.DUError
 }
+
+// creations counts the gadgets and associations created, it orders the components of a layer
+var creations atomic.Uint64
+
+func nextCreation() uint64 {
+	return creations.Add(1)
+}
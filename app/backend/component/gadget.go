@@ -2,6 +2,7 @@ package component
 
 import (
 	"fmt"
+	"maps"
 	"slices"
 
 	"Dr.uml/backend/component/attribute"
@@ -14,7 +15,22 @@ type GadgetType int
 
 const (
 	Class               GadgetType = 1 << iota // 0x01
-	supportedGadgetType            = Class
+	Package                                    // 0x02, groups other gadgets
+	supportedGadgetType = Class | Package
+)
+
+// PackagePadding is the space a package keeps around its children
+const PackagePadding = 10
+
+// StyleProperty is a property a style sets on a gadget. The ones set on the gadget itself override its style.
+type StyleProperty int
+
+const (
+	StyleColor         StyleProperty = 1 << iota // 0x01, fill color
+	StyleFont                                    // 0x02, font of the attributes
+	StyleSize                                    // 0x04, font size of the attributes
+	StyleTextStyle                               // 0x08, bold, italic, ... of the attributes
+	allStyleProperties = StyleColor | StyleFont | StyleSize | StyleTextStyle
 )
 
 var AllGadgetTypes = []struct {
@@ -22,23 +38,47 @@ var AllGadgetTypes = []struct {
 	TSName string
 }{
 	{Class, "Class"},
+	{Package, "Package"},
 }
 
 type Gadget struct {
 	gadgetType       GadgetType
 	point            utils.Point
 	layer            int
+	layerName        string                   // the named layer the gadget belongs to, empty for the default one
 	attributes       [][]*attribute.Attribute // Gadget has multiple sections, each section has multiple attributes
+	sections         []section                // names and states of the sections, parallel to attributes
 	color            string
 	isSelected       bool
+	isLocked         bool // protects the gadget from accidental edits, it can still be selected and copied
 	drawData         drawdata.Gadget
 	updateParentDraw func() duerror.DUError
 	observers        map[interface{}]func() duerror.DUError // Map of observer objects to their functions
+	fontFallbacks    []string                               // font files for glyphs missing from the attribute fonts
+	maxWidth         int                                    // the attributes wrap to keep the gadget this wide, 0 for no limit
+	width, height    int                                    // size set by the user, 0 for the size of the content, never below it
+	parent           *Gadget                                // the package containing the gadget, nil at the top level
+	children         []*Gadget                              // the gadgets a package contains, it grows to fit them
+	style            string                                 // the style of the gadget, empty for none
+	styleOverrides   StyleProperty                          // properties set on the gadget rather than by its style
+	stereotypes      []string
+	taggedValues     map[string]string
+	creation         uint64 // when the gadget was created, later ones are on top on the same layer
+}
+
+// DefaultSectionNames are the sections of a new gadget, the first one holds the header
+var DefaultSectionNames = []string{"Header", "Attributes", "Methods"}
+
+// section is a named group of attributes of a gadget
+type section struct {
+	name      string
+	collapsed bool // the attributes of the section are hidden
 }
 
 // Other functions
 func validateGadgetType(input GadgetType) duerror.DUError {
-	if !(input&supportedGadgetType == input && input != 0) {
+	// a gadget is of exactly one type
+	if !(input&supportedGadgetType == input && input != 0 && input&(input-1) == 0) {
 		return duerror.NewInvalidArgumentError("gadget type is not supported")
 	}
 	return nil
@@ -55,23 +95,24 @@ func NewGadget(gadgetType GadgetType, point utils.Point, layer int, colorHexStr
 		layer:      layer,
 		color:      colorHexStr,
 		observers:  make(map[interface{}]func() duerror.DUError),
+		creation:   nextCreation(),
 	}
 
-	// Init attributes with three sections
-	g.attributes = make([][]*attribute.Attribute, 3)
+	// Init attributes with the default sections, all empty
+	g.attributes = make([][]*attribute.Attribute, len(DefaultSectionNames))
+	g.sections = make([]section, len(DefaultSectionNames))
+	for i, name := range DefaultSectionNames {
+		g.attributes[i] = make([]*attribute.Attribute, 0)
+		g.sections[i] = section{name: name}
+	}
 
 	// The first section contains the header
-	g.attributes[0] = make([]*attribute.Attribute, 0, 1)
 	if header != "" {
 		if err := g.AddAttribute(0, -1, header); err != nil {
 			return nil, err
 		}
 	}
 
-	// The second and third sections are empty
-	g.attributes[1] = make([]*attribute.Attribute, 0)
-	g.attributes[2] = make([]*attribute.Attribute, 0)
-
 	if err := g.updateDrawData(); err != nil {
 		return nil, err
 	}
@@ -95,22 +136,120 @@ func FromSavedGadget(savedGadget utils.SavedGad) (*Gadget, duerror.DUError) {
 			fmt.Sprintf("Error when creating gadget from saved data: %v", err),
 		)
 	}
+	gadget.layerName = savedGadget.LayerName
+	gadget.isLocked = savedGadget.Locked
+	gadget.style = savedGadget.Style
+	if err = gadget.SetStereotypes(savedGadget.Stereotypes); err != nil {
+		return nil, duerror.NewCorruptedFile(
+			fmt.Sprintf("Error when creating gadget from saved data: %v", err),
+		)
+	}
+	if err = gadget.SetTaggedValues(savedGadget.TaggedValues); err != nil {
+		return nil, duerror.NewCorruptedFile(
+			fmt.Sprintf("Error when creating gadget from saved data: %v", err),
+		)
+	}
+	if err = gadget.SetStyleOverrides(StyleProperty(savedGadget.StyleOverrides)); err != nil {
+		return nil, duerror.NewCorruptedFile(
+			fmt.Sprintf("Error when creating gadget from saved data: %v", err),
+		)
+	}
+	if err = gadget.SetMaxWidth(savedGadget.MaxWidth); err != nil {
+		return nil, duerror.NewCorruptedFile(
+			fmt.Sprintf("Error when creating gadget from saved data: %v", err),
+		)
+	}
+	if err = gadget.SetSize(savedGadget.Width, savedGadget.Height); err != nil {
+		return nil, duerror.NewCorruptedFile(
+			fmt.Sprintf("Error when creating gadget from saved data: %v", err),
+		)
+	}
+	// files of older versions have the default sections
+	if len(savedGadget.Sections) > 0 {
+		gadget.attributes = make([][]*attribute.Attribute, len(savedGadget.Sections))
+		gadget.sections = make([]section, len(savedGadget.Sections))
+		for i, saved := range savedGadget.Sections {
+			if saved.Name == "" {
+				return nil, duerror.NewCorruptedFile("Error when creating gadget from saved data: section name is empty")
+			}
+			gadget.attributes[i] = make([]*attribute.Attribute, 0)
+			gadget.sections[i] = section{name: saved.Name, collapsed: saved.Collapsed}
+		}
+		if err = gadget.updateDrawData(); err != nil {
+			return nil, err
+		}
+	}
 	return gadget, nil
 }
 
+// Copy creates a deep copy of the Gadget and its attributes.
+// The copy is unselected, has no observers and is not attached to any diagram.
+func (g *Gadget) Copy() (*Gadget, duerror.DUError) {
+	c := &Gadget{
+		gadgetType:     g.gadgetType,
+		point:          g.point,
+		layer:          g.layer,
+		layerName:      g.layerName,
+		isLocked:       g.isLocked,
+		color:          g.color,
+		style:          g.style,
+		styleOverrides: g.styleOverrides,
+		stereotypes:    slices.Clone(g.stereotypes),
+		taggedValues:   maps.Clone(g.taggedValues),
+		attributes:     make([][]*attribute.Attribute, len(g.attributes)),
+		sections:       slices.Clone(g.sections),
+		observers:      make(map[interface{}]func() duerror.DUError),
+		fontFallbacks:  slices.Clone(g.fontFallbacks),
+		creation:       nextCreation(),
+		maxWidth:       g.maxWidth,
+		width:          g.width,
+		height:         g.height,
+	}
+	for section, atts := range g.attributes {
+		c.attributes[section] = make([]*attribute.Attribute, 0, len(atts))
+		for _, att := range atts {
+			copied, err := att.Copy()
+			if err != nil {
+				return nil, err
+			}
+			if err = copied.RegisterUpdateParentDraw(c.updateDrawData); err != nil {
+				return nil, err
+			}
+			c.attributes[section] = append(c.attributes[section], copied)
+		}
+	}
+	if err := c.updateDrawData(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
 // ToSavedGadget export the Gadget and its attributes to a SavedGadget struct.
 func (g *Gadget) ToSavedGadget() utils.SavedGad {
 	gad := utils.SavedGad{
-		GadgetType: int(g.gadgetType),
-		Point:      g.point.String(),
-		Layer:      g.layer,
-		Color:      g.color,
-		Attributes: make([]utils.SavedAtt, 0, len(g.attributes)),
+		GadgetType:     int(g.gadgetType),
+		Point:          g.point.String(),
+		Layer:          g.layer,
+		LayerName:      g.layerName,
+		Locked:         g.isLocked,
+		Color:          g.color,
+		Style:          g.style,
+		StyleOverrides: int(g.styleOverrides),
+		Stereotypes:    slices.Clone(g.stereotypes),
+		TaggedValues:   maps.Clone(g.taggedValues),
+		MaxWidth:       g.maxWidth,
+		Width:          g.width,
+		Height:         g.height,
+		Sections:       make([]utils.SavedSection, 0, len(g.sections)),
+		Attributes:     make([]utils.SavedAtt, 0, len(g.attributes)),
+	}
+	for _, s := range g.sections {
+		gad.Sections = append(gad.Sections, utils.SavedSection{Name: s.name, Collapsed: s.collapsed})
 	}
 	for section, atts := range g.attributes {
 		for _, att := range atts {
 			gad.Attributes = append(gad.Attributes, attribute.ToSavedAttribute(att))
-			gad.Attributes[len(gad.Attributes)-1].Ratio = 0.3 * float64(section)
+			gad.Attributes[len(gad.Attributes)-1].Section = section
 		}
 	}
 	return gad
@@ -125,10 +264,37 @@ func (g *Gadget) GetLayer() int {
 	return g.layer
 }
 
+// GetCreation tells when the gadget was created, relative to the other components
+func (g *Gadget) GetCreation() uint64 {
+	return g.creation
+}
+
+func (g *Gadget) GetLayerName() string {
+	return g.layerName
+}
+
 func (g *Gadget) GetColor() string {
 	return g.color
 }
 
+// GetStyle returns the name of the style of the gadget, empty for none
+func (g *Gadget) GetStyle() string {
+	return g.style
+}
+
+// GetStyleOverrides returns the properties set on the gadget itself, which its style does not change
+func (g *Gadget) GetStyleOverrides() StyleProperty {
+	return g.styleOverrides
+}
+
+func (g *Gadget) GetStereotypes() []string {
+	return slices.Clone(g.stereotypes)
+}
+
+func (g *Gadget) GetTaggedValues() map[string]string {
+	return maps.Clone(g.taggedValues)
+}
+
 func (g *Gadget) GetGadgetType() GadgetType {
 	return g.gadgetType
 }
@@ -141,10 +307,45 @@ func (g *Gadget) GetAttributesLen() []int {
 	return lengths
 }
 
+// GetSectionNames returns the names of the sections of the gadget, in order.
+func (g *Gadget) GetSectionNames() []string {
+	names := make([]string, len(g.sections))
+	for i, s := range g.sections {
+		names[i] = s.name
+	}
+	return names
+}
+
+// IsSectionCollapsed reports whether the attributes of a section are hidden.
+func (g *Gadget) IsSectionCollapsed(section int) (bool, duerror.DUError) {
+	if err := g.validateSection(section); err != nil {
+		return false, err
+	}
+	return g.sections[section].collapsed, nil
+}
+
+// GetSize returns the size set by the user, 0 on both axes if the gadget takes the size of its content.
+func (g *Gadget) GetSize() (int, int) {
+	return g.width, g.height
+}
+
+// IsAutoSize reports whether the gadget takes the size of its content.
+func (g *Gadget) IsAutoSize() bool {
+	return g.width == 0 && g.height == 0
+}
+
+func (g *Gadget) GetMaxWidth() int {
+	return g.maxWidth
+}
+
 func (g *Gadget) GetIsSelected() bool {
 	return g.isSelected
 }
 
+func (g *Gadget) GetIsLocked() bool {
+	return g.isLocked
+}
+
 func (g *Gadget) GetAttributes() [][]*attribute.Attribute {
 	return g.attributes
 }
@@ -169,8 +370,52 @@ func (g *Gadget) SetIsSelected(isSelected bool) duerror.DUError {
 	return g.updateDrawData()
 }
 
+func (g *Gadget) SetIsLocked(isLocked bool) duerror.DUError {
+	g.isLocked = isLocked
+	return g.updateDrawData()
+}
+
+// SetStyle names the style of the gadget, empty for none. It does not change how the gadget looks,
+// the diagram applies the style.
+func (g *Gadget) SetStyle(style string) duerror.DUError {
+	g.style = style
+	return g.updateDrawData()
+}
+
+// SetStereotypes sets the stereotypes of the gadget, see NormalizeStereotypes
+func (g *Gadget) SetStereotypes(stereotypes []string) duerror.DUError {
+	normalized, err := NormalizeStereotypes(stereotypes)
+	if err != nil {
+		return err
+	}
+	g.stereotypes = normalized
+	return g.updateDrawData()
+}
+
+// SetTaggedValues sets the tagged values of the gadget, see NormalizeTaggedValues
+func (g *Gadget) SetTaggedValues(tags map[string]string) duerror.DUError {
+	normalized, err := NormalizeTaggedValues(tags)
+	if err != nil {
+		return err
+	}
+	g.taggedValues = normalized
+	return g.updateDrawData()
+}
+
+func (g *Gadget) SetStyleOverrides(overrides StyleProperty) duerror.DUError {
+	if overrides&^allStyleProperties != 0 {
+		return duerror.NewInvalidArgumentError(fmt.Sprintf("unknown style properties %#x", int(overrides)))
+	}
+	g.styleOverrides = overrides
+	return g.updateDrawData()
+}
+
 func (g *Gadget) SetPoint(point utils.Point) duerror.DUError {
 	g.point = point
+	if len(g.children) > 0 {
+		// the box of a package also depends on its children
+		return g.updateDrawData()
+	}
 	g.drawData.X = point.X
 	g.drawData.Y = point.Y
 	// Notify observers about position change
@@ -192,6 +437,15 @@ func (g *Gadget) SetLayer(layer int) duerror.DUError {
 	return nil
 }
 
+func (g *Gadget) SetLayerName(name string) duerror.DUError {
+	g.layerName = name
+	g.drawData.LayerName = name
+	if g.updateParentDraw != nil {
+		return g.updateParentDraw()
+	}
+	return nil
+}
+
 func (g *Gadget) SetColor(colorHexStr string) duerror.DUError {
 	g.color = colorHexStr
 	g.drawData.Color = colorHexStr
@@ -240,6 +494,32 @@ func (g *Gadget) SetAttrStyle(section int, index int, style int) duerror.DUError
 	return g.updateDrawData()
 }
 
+func (g *Gadget) SetAttrColor(section int, index int, color string) duerror.DUError {
+	if err := g.validateSection(section); err != nil {
+		return err
+	}
+	if err := g.validateIndex(index, section); err != nil {
+		return err
+	}
+	if err := g.attributes[section][index].SetColor(color); err != nil {
+		return err
+	}
+	return g.updateDrawData()
+}
+
+func (g *Gadget) SetAttrAlign(section int, index int, align int) duerror.DUError {
+	if err := g.validateSection(section); err != nil {
+		return err
+	}
+	if err := g.validateIndex(index, section); err != nil {
+		return err
+	}
+	if err := g.attributes[section][index].SetAlign(attribute.TextAlign(align)); err != nil {
+		return err
+	}
+	return g.updateDrawData()
+}
+
 func (g *Gadget) SetAttrFontFile(section int, index int, fontFile string) duerror.DUError {
 	if err := g.validateSection(section); err != nil {
 		return err
@@ -253,10 +533,77 @@ func (g *Gadget) SetAttrFontFile(section int, index int, fontFile string) duerro
 	return g.updateDrawData()
 }
 
+// SetAttrFont sets the font of an attribute, keeping a font that is not available, see attribute.Attribute.SetFont
+func (g *Gadget) SetAttrFont(section int, index int, font string) duerror.DUError {
+	if err := g.validateSection(section); err != nil {
+		return err
+	}
+	if err := g.validateIndex(index, section); err != nil {
+		return err
+	}
+	if err := g.attributes[section][index].SetFont(font); err != nil {
+		return err
+	}
+	return g.updateDrawData()
+}
+
+// SetMaxWidth limits the width of the gadget, attributes wrap to fit in it. 0 removes the limit.
+// The limit must leave room for the text between the borders.
+func (g *Gadget) SetMaxWidth(width int) duerror.DUError {
+	if width < 0 {
+		return duerror.NewInvalidArgumentError("max width cannot be negative")
+	}
+	if width > 0 && g.attrWrapWidth(width) <= 0 {
+		return duerror.NewInvalidArgumentError("max width is too small")
+	}
+	g.maxWidth = width
+	for _, atts := range g.attributes {
+		for _, att := range atts {
+			if err := att.SetWrapWidth(g.attrWrapWidth(width)); err != nil {
+				return err
+			}
+		}
+	}
+	return g.updateDrawData()
+}
+
+// SetSize sets the size of the gadget, it still grows to fit its content. 0 on an axis takes the size of the content.
+func (g *Gadget) SetSize(width, height int) duerror.DUError {
+	if width < 0 || height < 0 {
+		return duerror.NewInvalidArgumentError("size cannot be negative")
+	}
+	g.width, g.height = width, height
+	return g.updateDrawData()
+}
+
+// attrWrapWidth returns the width attributes wrap at in a gadget at most width wide
+func (g *Gadget) attrWrapWidth(width int) int {
+	if width == 0 {
+		return 0
+	}
+	return width - drawdata.Margin*2 - drawdata.LineWidth*2
+}
+
+// SetFontFallbacks sets the font files used, in order, for glyphs missing from the fonts of the attributes.
+func (g *Gadget) SetFontFallbacks(fontFiles []string) duerror.DUError {
+	if slices.Equal(g.fontFallbacks, fontFiles) {
+		return nil
+	}
+	g.fontFallbacks = slices.Clone(fontFiles)
+	for _, atts := range g.attributes {
+		for _, att := range atts {
+			if err := att.SetFontFallbacks(fontFiles); err != nil {
+				return err
+			}
+		}
+	}
+	return g.updateDrawData()
+}
+
 // Methods
 func (g *Gadget) Cover(p utils.Point) (bool, duerror.DUError) {
-	tl := g.point                                                                          // top-left
-	br := utils.AddPoints(g.point, utils.Point{X: g.drawData.Width, Y: g.drawData.Height}) // bottom-right
+	tl := utils.Point{X: g.drawData.X, Y: g.drawData.Y}                               // top-left
+	br := utils.AddPoints(tl, utils.Point{X: g.drawData.Width, Y: g.drawData.Height}) // bottom-right
 	return p.X >= tl.X && p.X <= br.X && p.Y >= tl.Y && p.Y <= br.Y, nil
 }
 
@@ -272,6 +619,12 @@ func (g *Gadget) AddAttribute(section int, index int, content string) duerror.DU
 	if err != nil {
 		return err
 	}
+	if err = att.SetFontFallbacks(g.fontFallbacks); err != nil {
+		return err
+	}
+	if err = att.SetWrapWidth(g.attrWrapWidth(g.maxWidth)); err != nil {
+		return err
+	}
 	if err = att.RegisterUpdateParentDraw(g.updateDrawData); err != nil {
 		return err
 	}
@@ -293,6 +646,12 @@ func (g *Gadget) AddBuiltAttribute(section int, att *attribute.Attribute) duerro
 	if err := g.validateSection(section); err != nil {
 		return err
 	}
+	if err := att.SetFontFallbacks(g.fontFallbacks); err != nil {
+		return err
+	}
+	if err := att.SetWrapWidth(g.attrWrapWidth(g.maxWidth)); err != nil {
+		return err
+	}
 	if err := att.RegisterUpdateParentDraw(g.updateDrawData); err != nil {
 		return err
 	}
@@ -315,6 +674,77 @@ func (g *Gadget) RemoveAttribute(section int, index int) duerror.DUError {
 	return g.updateDrawData()
 }
 
+// AddSection inserts an empty section at index, -1 appends it.
+// The header section stays first, so no section can be inserted before it.
+func (g *Gadget) AddSection(index int, name string) duerror.DUError {
+	if name == "" {
+		return duerror.NewInvalidArgumentError("section name is empty")
+	}
+	if index == -1 {
+		index = len(g.sections)
+	}
+	if index < 1 || index > len(g.sections) {
+		return duerror.NewInvalidArgumentError("index not allow")
+	}
+	g.attributes = slices.Insert(g.attributes, index, make([]*attribute.Attribute, 0))
+	g.sections = slices.Insert(g.sections, index, section{name: name})
+	return g.updateDrawData()
+}
+
+// RemoveSection removes a section along with its attributes. The header section cannot be removed.
+func (g *Gadget) RemoveSection(section int) duerror.DUError {
+	if err := g.validateMovableSection(section); err != nil {
+		return err
+	}
+	g.attributes = slices.Delete(g.attributes, section, section+1)
+	g.sections = slices.Delete(g.sections, section, section+1)
+	return g.updateDrawData()
+}
+
+// MoveSection moves a section so that it ends up at index to. The header section cannot be moved.
+func (g *Gadget) MoveSection(from, to int) duerror.DUError {
+	if err := g.validateMovableSection(from); err != nil {
+		return err
+	}
+	if err := g.validateMovableSection(to); err != nil {
+		return err
+	}
+	atts, s := g.attributes[from], g.sections[from]
+	g.attributes = slices.Insert(slices.Delete(g.attributes, from, from+1), to, atts)
+	g.sections = slices.Insert(slices.Delete(g.sections, from, from+1), to, s)
+	return g.updateDrawData()
+}
+
+func (g *Gadget) RenameSection(section int, name string) duerror.DUError {
+	if err := g.validateSection(section); err != nil {
+		return err
+	}
+	if name == "" {
+		return duerror.NewInvalidArgumentError("section name is empty")
+	}
+	g.sections[section].name = name
+	return g.updateDrawData()
+}
+
+// SetSectionCollapsed hides or shows the attributes of a section.
+func (g *Gadget) SetSectionCollapsed(section int, collapsed bool) duerror.DUError {
+	if err := g.validateSection(section); err != nil {
+		return err
+	}
+	g.sections[section].collapsed = collapsed
+	return g.updateDrawData()
+}
+
+func (g *Gadget) validateMovableSection(section int) duerror.DUError {
+	if err := g.validateSection(section); err != nil {
+		return err
+	}
+	if section == 0 {
+		return duerror.NewInvalidArgumentError("the header section cannot be removed or moved")
+	}
+	return nil
+}
+
 func (g *Gadget) validateSection(section int) duerror.DUError {
 	if section < 0 || section >= len(g.attributes) {
 		return duerror.NewInvalidArgumentError("section out of range")
@@ -329,6 +759,103 @@ func (g *Gadget) validateIndex(index, section int) duerror.DUError {
 	return nil
 }
 
+// Nesting
+
+// GetParent returns the package containing the gadget, nil at the top level
+func (g *Gadget) GetParent() *Gadget {
+	return g.parent
+}
+
+func (g *Gadget) GetChildren() []*Gadget {
+	return slices.Clone(g.children)
+}
+
+// IsAncestorOf reports whether other is nested in g, at any depth
+func (g *Gadget) IsAncestorOf(other *Gadget) bool {
+	for p := other.parent; p != nil; p = p.parent {
+		if p == g {
+			return true
+		}
+	}
+	return false
+}
+
+// SetParent moves the gadget into the package parent, nil moves it to the top level.
+// The old and the new package fit themselves to their children.
+func (g *Gadget) SetParent(parent *Gadget) duerror.DUError {
+	if parent == g.parent {
+		return nil
+	}
+	if parent != nil {
+		if parent.gadgetType != Package {
+			return duerror.NewInvalidArgumentError("only a package can contain gadgets")
+		}
+		if parent == g || g.IsAncestorOf(parent) {
+			return duerror.NewInvalidArgumentError("a package cannot contain itself")
+		}
+	}
+	if err := g.DetachFromParent(); err != nil {
+		return err
+	}
+	g.parent = parent
+	return g.AttachToParent()
+}
+
+// DetachFromParent takes the gadget out of the children of its package, keeping it as the parent,
+// so that AttachToParent puts it back, e.g. when the gadget is removed from the diagram and restored.
+func (g *Gadget) DetachFromParent() duerror.DUError {
+	p := g.parent
+	if p == nil {
+		return nil
+	}
+	index := slices.Index(p.children, g)
+	if index < 0 {
+		return nil
+	}
+	p.children = slices.Delete(p.children, index, index+1)
+	if err := g.RemoveObserver(p); err != nil {
+		return err
+	}
+	return p.updateDrawData()
+}
+
+// AttachToParent adds the gadget to the children of its package again, see DetachFromParent
+func (g *Gadget) AttachToParent() duerror.DUError {
+	p := g.parent
+	if p == nil || slices.Contains(p.children, g) {
+		return nil
+	}
+	p.children = append(p.children, g)
+	if err := g.AddObserver(p, p.updateDrawData); err != nil {
+		return err
+	}
+	return p.updateDrawData()
+}
+
+// CoversWithout reports whether p is in the box the gadget would have without the nested gadgets for
+// which skip is true, so that a package growing around a child being dragged out does not hold it.
+func (g *Gadget) CoversWithout(p utils.Point, skip func(*Gadget) bool) bool {
+	tl, br := g.boxWithout(skip)
+	return p.X >= tl.X && p.X <= br.X && p.Y >= tl.Y && p.Y <= br.Y
+}
+
+// boxWithout returns the top-left and bottom-right corners of the gadget and the children for which skip is false
+func (g *Gadget) boxWithout(skip func(*Gadget) bool) (utils.Point, utils.Point) {
+	tl := g.point
+	br := utils.AddPoints(g.point, utils.Point{X: max(g.drawData.MinWidth, g.width), Y: max(g.drawData.MinHeight, g.height)})
+	for _, c := range g.children {
+		if skip(c) {
+			continue
+		}
+		ctl, cbr := c.boxWithout(skip)
+		tl.X = min(tl.X, ctl.X-PackagePadding)
+		tl.Y = min(tl.Y, ctl.Y-PackagePadding)
+		br.X = max(br.X, cbr.X+PackagePadding)
+		br.Y = max(br.Y, cbr.Y+PackagePadding)
+	}
+	return tl, br
+}
+
 // Draw
 func (g *Gadget) GetDrawData() any {
 	return g.drawData
@@ -343,9 +870,23 @@ func (g *Gadget) updateDrawData() duerror.DUError {
 
 	height := drawdata.LineWidth
 	maxAttWidth := 0
+	annotations, err := g.annotationLines()
+	if err != nil {
+		return err
+	}
+	for _, a := range annotations {
+		maxAttWidth = max(maxAttWidth, a.Width)
+		height += drawdata.Margin + a.Height
+	}
 	atts := make([][]drawdata.Attribute, len(g.attributes))
+	sections := make([]drawdata.Section, len(g.sections))
 	for i, attsRow := range g.attributes {
+		sections[i] = drawdata.Section{Name: g.sections[i].name, Collapsed: g.sections[i].collapsed}
 		atts[i] = make([]drawdata.Attribute, 0, len(attsRow))
+		if g.sections[i].collapsed {
+			height += drawdata.Margin + drawdata.LineWidth
+			continue
+		}
 		for _, att := range attsRow {
 			attDrawData := att.GetDrawData()
 			atts[i] = append(atts[i], attDrawData)
@@ -357,14 +898,29 @@ func (g *Gadget) updateDrawData() duerror.DUError {
 		height += drawdata.Margin + drawdata.LineWidth
 	}
 	width := maxAttWidth + drawdata.Margin*2 + drawdata.LineWidth*2
+	g.drawData.MinWidth = width
+	g.drawData.MinHeight = height
+
+	// a package grows around its children
+	tl, br := g.boxWithout(func(*Gadget) bool { return false })
+	width, height = br.X-tl.X, br.Y-tl.Y
 
+	g.drawData.ID = g.creation
 	g.drawData.GadgetType = int(g.gadgetType)
-	g.drawData.X = g.point.X
-	g.drawData.Y = g.point.Y
+	g.drawData.X = tl.X
+	g.drawData.Y = tl.Y
 	g.drawData.Layer = g.layer
+	g.drawData.LayerName = g.layerName
+	g.drawData.IsLocked = g.isLocked
 	g.drawData.Height = height
 	g.drawData.Width = width
 	g.drawData.Color = g.color
+	g.drawData.Style = g.style
+	g.drawData.Stereotypes = slices.Clone(g.stereotypes)
+	g.drawData.TaggedValues = maps.Clone(g.taggedValues)
+	g.drawData.Annotations = annotations
+	g.drawData.MaxWidth = g.maxWidth
+	g.drawData.Sections = sections
 	g.drawData.Attributes = atts
 
 	// Check if position or size changed and notify observers
@@ -380,6 +936,38 @@ func (g *Gadget) updateDrawData() duerror.DUError {
 	return g.updateParentDraw()
 }
 
+// annotationLines measures the stereotypes and the tagged values, each on its own line,
+// in the plain font of the header, or the default font when the header is empty
+func (g *Gadget) annotationLines() ([]drawdata.Attribute, duerror.DUError) {
+	labels := make([]string, 0, 2)
+	for _, label := range []string{FormatStereotypes(g.stereotypes), FormatTaggedValues(g.taggedValues)} {
+		if label != "" {
+			labels = append(labels, label)
+		}
+	}
+	lines := make([]drawdata.Attribute, 0, len(labels))
+	for _, label := range labels {
+		var line *attribute.Attribute
+		var err duerror.DUError
+		if len(g.attributes) > 0 && len(g.attributes[0]) > 0 {
+			line, err = g.attributes[0][0].Copy()
+		} else {
+			line, err = attribute.NewAttribute("")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err = line.SetStyle(0); err != nil {
+			return nil, err
+		}
+		if err = line.SetContent(label); err != nil {
+			return nil, err
+		}
+		lines = append(lines, line.GetDrawData())
+	}
+	return lines, nil
+}
+
 func (g *Gadget) RegisterUpdateParentDraw(update func() duerror.DUError) duerror.DUError {
 	if update == nil {
 		return duerror.NewInvalidArgumentError("update function is nil")
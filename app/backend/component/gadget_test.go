@@ -32,7 +32,9 @@ func newEmptyGadget(gadgetType GadgetType, point utils.Point) *Gadget {
 
 	// Initialize attributes using gadgetDefaultAtts
 	g.attributes = make([][]*attribute.Attribute, len(gadgetDefaultAtts[gadgetType]))
+	g.sections = make([]section, len(gadgetDefaultAtts[gadgetType]))
 	for i, contents := range gadgetDefaultAtts[gadgetType] {
+		g.sections[i] = section{name: DefaultSectionNames[i]}
 		g.attributes[i] = make([]*attribute.Attribute, 0, len(contents))
 		for _, content := range contents {
 			att, err := attribute.NewAttribute(content)
@@ -522,8 +524,337 @@ func TestGadget_ToSavedGadget(t *testing.T) {
 		assert.Equal(t, expectedContent, savedAtt.Content)
 		assert.Equal(t, expectedSize, savedAtt.Size)
 		assert.Equal(t, int(expectedStyle), savedAtt.Style)
-		assert.Equal(t, expectedFontFile, savedAtt.FontFile)
-		assert.Equal(t, 0.3*float64(index), savedAtt.Ratio)
+		assert.Equal(t, "Ink Free", savedAtt.Font)
+		assert.Empty(t, savedAtt.FontFile)
+		assert.Equal(t, index, savedAtt.Section)
+		assert.Zero(t, savedAtt.Ratio)
 	}
 
 }
+
+func TestGadget_Copy(t *testing.T) {
+	g, err := NewGadget(Class, utils.Point{X: 10, Y: 20}, 2, "#123456", "header")
+	assert.NoError(t, err)
+	assert.NoError(t, g.AddAttribute(1, -1, "field"))
+	assert.NoError(t, g.SetIsSelected(true))
+	g.AddObserver("observer", func() duerror.DUError { return nil })
+
+	c, err := g.Copy()
+	assert.NoError(t, err)
+	assert.Equal(t, g.ToSavedGadget(), c.ToSavedGadget())
+	assert.Equal(t, g.GetDrawData().(drawdata.Gadget).Width, c.GetDrawData().(drawdata.Gadget).Width)
+	assert.False(t, c.GetIsSelected())
+	assert.Empty(t, c.observers)
+
+	// the copy does not share attributes with the original
+	assert.NoError(t, c.SetAttrContent(0, 0, "changed"))
+	att, _ := g.GetAttribute(0, 0)
+	assert.Equal(t, "header", att.GetContent())
+}
+
+func TestSetMaxWidth(t *testing.T) {
+	g, err := NewGadget(Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "Header")
+	assert.NoError(t, err)
+	long := "GetLastOpenedDiagrams(limit: int, includeArchived: bool): List<String>"
+	assert.NoError(t, g.AddAttribute(2, -1, long))
+	wide := g.GetDrawData().(drawdata.Gadget).Width
+	oneLine := g.GetDrawData().(drawdata.Gadget).Height
+
+	assert.Error(t, g.SetMaxWidth(-1))
+	assert.Error(t, g.SetMaxWidth(drawdata.Margin*2+drawdata.LineWidth*2))
+
+	assert.NoError(t, g.SetMaxWidth(150))
+	dd := g.GetDrawData().(drawdata.Gadget)
+	assert.Equal(t, 150, dd.MaxWidth)
+	assert.LessOrEqual(t, dd.Width, 150)
+	assert.Greater(t, dd.Height, oneLine)
+	lines := dd.Attributes[2][0].Lines
+	assert.Greater(t, len(lines), 1)
+	assert.Equal(t, dd.Attributes[2][0].Height, lines[len(lines)-1].Y+lines[len(lines)-1].Height)
+
+	// attributes added later wrap too, and the limit is saved
+	assert.NoError(t, g.AddAttribute(1, -1, long))
+	assert.Equal(t, lines, g.GetDrawData().(drawdata.Gadget).Attributes[1][0].Lines)
+	saved := g.ToSavedGadget()
+	assert.Equal(t, 150, saved.MaxWidth)
+	loaded, err := FromSavedGadget(saved)
+	assert.NoError(t, err)
+	assert.Equal(t, 150, loaded.GetMaxWidth())
+
+	assert.NoError(t, g.SetMaxWidth(0))
+	assert.NoError(t, g.RemoveAttribute(1, 0))
+	assert.Equal(t, wide, g.GetDrawData().(drawdata.Gadget).Width)
+	assert.Equal(t, oneLine, g.GetDrawData().(drawdata.Gadget).Height)
+}
+
+func TestGadget_Sections(t *testing.T) {
+	g, err := NewGadget(Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "header")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultSectionNames, g.GetSectionNames())
+	assert.NoError(t, g.AddAttribute(2, -1, "m()"))
+
+	// add
+	assert.NoError(t, g.AddSection(-1, "Responsibilities"))
+	assert.NoError(t, g.AddSection(1, "Exceptions"))
+	assert.Equal(t, []string{"Header", "Exceptions", "Attributes", "Methods", "Responsibilities"}, g.GetSectionNames())
+	assert.Equal(t, []int{1, 0, 0, 1, 0}, g.GetAttributesLen())
+	assert.Error(t, g.AddSection(0, "BeforeHeader"))
+	assert.Error(t, g.AddSection(6, "TooFar"))
+	assert.Error(t, g.AddSection(-1, ""))
+
+	// move
+	assert.NoError(t, g.MoveSection(3, 1))
+	assert.Equal(t, []string{"Header", "Methods", "Exceptions", "Attributes", "Responsibilities"}, g.GetSectionNames())
+	assert.Equal(t, []int{1, 1, 0, 0, 0}, g.GetAttributesLen())
+	assert.Error(t, g.MoveSection(0, 1))
+	assert.Error(t, g.MoveSection(1, 0))
+	assert.Error(t, g.MoveSection(1, 5))
+
+	// rename
+	assert.NoError(t, g.RenameSection(4, "Notes"))
+	assert.Error(t, g.RenameSection(4, ""))
+	assert.Error(t, g.RenameSection(5, "Nope"))
+	assert.Equal(t, "Notes", g.GetSectionNames()[4])
+
+	// remove
+	assert.NoError(t, g.RemoveSection(2))
+	assert.Equal(t, []string{"Header", "Methods", "Attributes", "Notes"}, g.GetSectionNames())
+	assert.Error(t, g.RemoveSection(0))
+	assert.Error(t, g.RemoveSection(4))
+
+	// collapse
+	height := g.drawData.Height
+	assert.NoError(t, g.SetSectionCollapsed(1, true))
+	collapsed, err := g.IsSectionCollapsed(1)
+	assert.NoError(t, err)
+	assert.True(t, collapsed)
+	assert.Less(t, g.drawData.Height, height)
+	assert.Empty(t, g.drawData.Attributes[1])
+	assert.Equal(t, drawdata.Section{Name: "Methods", Collapsed: true}, g.drawData.Sections[1])
+	_, err = g.IsSectionCollapsed(4)
+	assert.Error(t, err)
+
+	// saved and copied
+	saved := g.ToSavedGadget()
+	assert.Equal(t, []utils.SavedSection{{Name: "Header"}, {Name: "Methods", Collapsed: true}, {Name: "Attributes"}, {Name: "Notes"}}, saved.Sections)
+	assert.Equal(t, 1, saved.Attributes[1].Section)
+	loaded, err := FromSavedGadget(saved)
+	assert.NoError(t, err)
+	assert.Equal(t, g.GetSectionNames(), loaded.GetSectionNames())
+	collapsed, err = loaded.IsSectionCollapsed(1)
+	assert.NoError(t, err)
+	assert.True(t, collapsed)
+
+	copied, err := g.Copy()
+	assert.NoError(t, err)
+	assert.NoError(t, copied.RenameSection(1, "Operations"))
+	assert.Equal(t, "Methods", g.GetSectionNames()[1])
+
+	saved.Sections[2].Name = ""
+	_, err = FromSavedGadget(saved)
+	assert.Error(t, err)
+}
+
+func TestGadget_SetSize(t *testing.T) {
+	g, err := NewGadget(Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "header")
+	assert.NoError(t, err)
+	assert.True(t, g.IsAutoSize())
+	minWidth, minHeight := g.drawData.Width, g.drawData.Height
+	assert.Equal(t, minWidth, g.drawData.MinWidth)
+	assert.Equal(t, minHeight, g.drawData.MinHeight)
+
+	assert.NoError(t, g.SetSize(minWidth+100, minHeight+50))
+	assert.False(t, g.IsAutoSize())
+	assert.Equal(t, minWidth+100, g.drawData.Width)
+	assert.Equal(t, minHeight+50, g.drawData.Height)
+	assert.Equal(t, minWidth, g.drawData.MinWidth)
+
+	// the content cannot shrink below its size
+	assert.NoError(t, g.SetSize(1, 1))
+	assert.Equal(t, minWidth, g.drawData.Width)
+	assert.Equal(t, minHeight, g.drawData.Height)
+	width, height := g.GetSize()
+	assert.Equal(t, 1, width)
+	assert.Equal(t, 1, height)
+
+	// 0 on one axis follows the content
+	assert.NoError(t, g.SetSize(minWidth+100, 0))
+	assert.Equal(t, minHeight, g.drawData.Height)
+	assert.NoError(t, g.AddAttribute(1, -1, "field"))
+	assert.Greater(t, g.drawData.Height, minHeight)
+	assert.Equal(t, minWidth+100, g.drawData.Width)
+
+	assert.Error(t, g.SetSize(-1, 0))
+
+	saved := g.ToSavedGadget()
+	assert.Equal(t, minWidth+100, saved.Width)
+	assert.Zero(t, saved.Height)
+	loaded, err := FromSavedGadget(saved)
+	assert.NoError(t, err)
+	width, height = loaded.GetSize()
+	assert.Equal(t, [2]int{minWidth + 100, 0}, [2]int{width, height})
+	copied, err := g.Copy()
+	assert.NoError(t, err)
+	width, _ = copied.GetSize()
+	assert.Equal(t, minWidth+100, width)
+
+	assert.NoError(t, g.SetSize(0, 0))
+	assert.True(t, g.IsAutoSize())
+}
+
+func TestGadget_SetSizeKeepsAssociations(t *testing.T) {
+	st, err := NewGadget(Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "st")
+	assert.NoError(t, err)
+	en, err := NewGadget(Class, utils.Point{X: 500, Y: 0}, 0, drawdata.DefaultGadgetColor, "en")
+	assert.NoError(t, err)
+	ass, err := NewAssociation([2]*Gadget{st, en}, Composition, utils.Point{X: st.drawData.Width, Y: 5}, utils.Point{X: 500, Y: 5})
+	assert.NoError(t, err)
+	ratio := ass.startPointRatio
+
+	assert.NoError(t, st.SetSize(st.drawData.MinWidth+200, st.drawData.MinHeight+100))
+	dd := ass.GetDrawData().(drawdata.Association)
+	expected := snapToEdge(st.GetPoint(), st.drawData.Width, st.drawData.Height, ratio)
+	assert.Equal(t, expected, utils.Point{X: dd.StartX, Y: dd.StartY})
+	assert.Equal(t, ratio, ass.startPointRatio)
+}
+
+func TestGadget_Nesting(t *testing.T) {
+	pkg, err := NewGadget(Package, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "pkg")
+	assert.NoError(t, err)
+	assert.NoError(t, pkg.SetSize(100, 100))
+	child, err := NewGadget(Class, utils.Point{X: 80, Y: 80}, 0, drawdata.DefaultGadgetColor, "child")
+	assert.NoError(t, err)
+	assert.NoError(t, child.SetSize(50, 50))
+
+	// only a package contains gadgets, never itself
+	assert.Error(t, pkg.SetParent(child))
+	assert.Error(t, pkg.SetParent(pkg))
+
+	// the package grows around its child
+	assert.NoError(t, child.SetParent(pkg))
+	assert.Equal(t, pkg, child.GetParent())
+	assert.Equal(t, []*Gadget{child}, pkg.GetChildren())
+	assert.True(t, pkg.IsAncestorOf(child))
+	assert.Equal(t, 80+50+PackagePadding, pkg.drawData.Width)
+	assert.Equal(t, 80+50+PackagePadding, pkg.drawData.Height)
+	assert.Error(t, pkg.SetParent(pkg), "a package cannot contain itself")
+
+	// and follows it
+	assert.NoError(t, child.SetPoint(utils.Point{X: 20, Y: 200}))
+	assert.Equal(t, 200+50+PackagePadding, pkg.drawData.Height)
+	assert.Equal(t, 100, pkg.drawData.Width)
+	covered, err := pkg.Cover(utils.Point{X: 30, Y: 220})
+	assert.NoError(t, err)
+	assert.True(t, covered)
+	// but does not hold it when left out
+	isChild := func(g *Gadget) bool { return g == child }
+	assert.False(t, pkg.CoversWithout(utils.Point{X: 30, Y: 220}, isChild))
+	assert.True(t, pkg.CoversWithout(utils.Point{X: 50, Y: 50}, isChild))
+
+	// detached it keeps its package to be attached again
+	assert.NoError(t, child.DetachFromParent())
+	assert.Empty(t, pkg.GetChildren())
+	assert.Equal(t, pkg, child.GetParent())
+	assert.Equal(t, 100, pkg.drawData.Height)
+	assert.NoError(t, child.AttachToParent())
+	assert.NoError(t, child.AttachToParent())
+	assert.Len(t, pkg.GetChildren(), 1)
+
+	assert.NoError(t, child.SetParent(nil))
+	assert.Nil(t, child.GetParent())
+	assert.Empty(t, pkg.GetChildren())
+	assert.Equal(t, [2]int{100, 100}, [2]int{pkg.drawData.Width, pkg.drawData.Height})
+
+	// a gadget has exactly one type
+	_, err = NewGadget(Class|Package, utils.Point{}, 0, drawdata.DefaultGadgetColor, "both")
+	assert.Error(t, err)
+}
+
+func TestGadget_Locked(t *testing.T) {
+	g, err := NewGadget(Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "locked")
+	assert.NoError(t, err)
+	assert.False(t, g.GetIsLocked())
+	assert.NoError(t, g.SetIsLocked(true))
+	assert.True(t, g.GetIsLocked())
+	assert.True(t, g.drawData.IsLocked)
+
+	saved := g.ToSavedGadget()
+	assert.True(t, saved.Locked)
+	loaded, err := FromSavedGadget(saved)
+	assert.NoError(t, err)
+	assert.True(t, loaded.GetIsLocked())
+	assert.True(t, loaded.drawData.IsLocked)
+	copied, err := g.Copy()
+	assert.NoError(t, err)
+	assert.True(t, copied.GetIsLocked())
+}
+
+func TestGadget_Style(t *testing.T) {
+	g, err := NewGadget(Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "styled")
+	assert.NoError(t, err)
+	assert.NoError(t, g.SetStyle("Entity"))
+	assert.NoError(t, g.SetStyleOverrides(StyleColor|StyleSize))
+	assert.Error(t, g.SetStyleOverrides(0x10))
+	assert.Equal(t, StyleColor|StyleSize, g.GetStyleOverrides())
+	assert.Equal(t, "Entity", g.drawData.Style)
+
+	saved := g.ToSavedGadget()
+	assert.Equal(t, "Entity", saved.Style)
+	loaded, err := FromSavedGadget(saved)
+	assert.NoError(t, err)
+	assert.Equal(t, "Entity", loaded.GetStyle())
+	assert.Equal(t, StyleColor|StyleSize, loaded.GetStyleOverrides())
+	copied, err := g.Copy()
+	assert.NoError(t, err)
+	assert.Equal(t, "Entity", copied.GetStyle())
+	assert.Equal(t, StyleColor|StyleSize, copied.GetStyleOverrides())
+
+	saved.StyleOverrides = 0x20
+	_, err = FromSavedGadget(saved)
+	assert.Error(t, err)
+}
+
+func TestGadget_Annotations(t *testing.T) {
+	g, err := NewGadget(Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "Order")
+	assert.NoError(t, err)
+	height := g.drawData.Height
+
+	assert.NoError(t, g.SetStereotypes([]string{" «entity» ", "aggregate", "entity"}))
+	assert.Equal(t, []string{"entity", "aggregate"}, g.GetStereotypes())
+	assert.Error(t, g.SetStereotypes([]string{"«»"}))
+	assert.Error(t, g.SetStereotypes([]string{"a,b"}))
+	assert.NoError(t, g.SetTaggedValues(map[string]string{" version ": "2", "persistent": ""}))
+	assert.Equal(t, map[string]string{"version": "2", "persistent": ""}, g.GetTaggedValues())
+	assert.Error(t, g.SetTaggedValues(map[string]string{" ": "x"}))
+	assert.Error(t, g.SetTaggedValues(map[string]string{"a": "1", " a": "2"}))
+	assert.Error(t, g.SetTaggedValues(map[string]string{"a=b": "1"}))
+
+	// drawn on their own lines at the top of the header
+	assert.Len(t, g.drawData.Annotations, 2)
+	assert.Equal(t, "«entity, aggregate»", g.drawData.Annotations[0].Content)
+	assert.Equal(t, "{persistent, version = 2}", g.drawData.Annotations[1].Content)
+	assert.Greater(t, g.drawData.Height, height)
+	assert.Equal(t, []string{"entity", "aggregate"}, g.drawData.Stereotypes)
+	assert.True(t, HasStereotype(g, "entity"))
+	value, ok := GetTaggedValue(g, "version")
+	assert.True(t, ok)
+	assert.Equal(t, "2", value)
+
+	saved := g.ToSavedGadget()
+	loaded, err := FromSavedGadget(saved)
+	assert.NoError(t, err)
+	assert.Equal(t, g.GetStereotypes(), loaded.GetStereotypes())
+	assert.Equal(t, g.GetTaggedValues(), loaded.GetTaggedValues())
+	copied, err := g.Copy()
+	assert.NoError(t, err)
+	assert.Equal(t, g.GetTaggedValues(), copied.GetTaggedValues())
+
+	assert.NoError(t, g.SetStereotypes(nil))
+	assert.NoError(t, g.SetTaggedValues(nil))
+	assert.Empty(t, g.drawData.Annotations)
+	assert.Equal(t, height, g.drawData.Height)
+
+	saved.Stereotypes = []string{""}
+	_, err = FromSavedGadget(saved)
+	assert.Error(t, err)
+}
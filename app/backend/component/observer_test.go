@@ -206,3 +206,42 @@ func TestObserverErrorHandling(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "observer key is nil")
 }
+
+func TestAssociationObserverOnPathChange(t *testing.T) {
+	st, err := NewGadget(Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "")
+	assert.NoError(t, err)
+	en, err := NewGadget(Class, utils.Point{X: 200, Y: 0}, 0, drawdata.DefaultGadgetColor, "")
+	assert.NoError(t, err)
+	ass, err := NewAssociation([2]*Gadget{st, en}, Extension, utils.Point{X: 1, Y: 1}, utils.Point{X: 201, Y: 1})
+	assert.NoError(t, err)
+
+	notificationCount := 0
+	err = ass.AddObserver("test", func() duerror.DUError {
+		notificationCount++
+		return nil
+	})
+	assert.NoError(t, err)
+
+	// moving a parent moves the path
+	err = st.SetPoint(utils.Point{X: 0, Y: 100})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, notificationCount)
+
+	// selecting does not
+	err = ass.RegisterUpdateParentDraw(func() duerror.DUError { return nil })
+	assert.NoError(t, err)
+	err = ass.SetIsSelected(true)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, notificationCount)
+
+	err = ass.RemoveObserver("test")
+	assert.NoError(t, err)
+	err = en.SetPoint(utils.Point{X: 300, Y: 300})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, notificationCount)
+
+	err = ass.AddObserver("test", nil)
+	assert.Error(t, err)
+	err = ass.AddObserver(nil, func() duerror.DUError { return nil })
+	assert.Error(t, err)
+}
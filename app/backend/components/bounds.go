@@ -0,0 +1,77 @@
+package components
+
+import (
+	"Dr.uml/backend/component"
+	"Dr.uml/backend/drawdata"
+	"Dr.uml/backend/utils"
+	"Dr.uml/backend/utils/duerror"
+)
+
+// rect is an axis aligned box, both corners are inclusive
+type rect struct {
+	min utils.Point
+	max utils.Point
+}
+
+// normalizeRect orders two opposite corners of a rectangle into a rect.
+func normalizeRect(p1, p2 utils.Point) rect {
+	return rect{
+		min: utils.Point{X: min(p1.X, p2.X), Y: min(p1.Y, p2.Y)},
+		max: utils.Point{X: max(p1.X, p2.X), Y: max(p1.Y, p2.Y)},
+	}
+}
+
+func (r rect) contains(o rect) bool {
+	return o.min.X >= r.min.X && o.min.Y >= r.min.Y && o.max.X <= r.max.X && o.max.Y <= r.max.Y
+}
+
+func (r rect) containsPoint(p utils.Point) bool {
+	return p.X >= r.min.X && p.Y >= r.min.Y && p.X <= r.max.X && p.Y <= r.max.Y
+}
+
+func (r rect) intersects(o rect) bool {
+	return o.min.X <= r.max.X && o.max.X >= r.min.X && o.min.Y <= r.max.Y && o.max.Y >= r.min.Y
+}
+
+func (r rect) inflate(d int) rect {
+	return rect{
+		min: utils.Point{X: r.min.X - d, Y: r.min.Y - d},
+		max: utils.Point{X: r.max.X + d, Y: r.max.Y + d},
+	}
+}
+
+// distance2 returns the squared distance from p to the closest point of r, 0 if p is inside.
+func (r rect) distance2(p utils.Point) int {
+	dx := max(r.min.X-p.X, 0, p.X-r.max.X)
+	dy := max(r.min.Y-p.Y, 0, p.Y-r.max.Y)
+	return dx*dx + dy*dy
+}
+
+// bounds returns the box enclosing c, taken from its draw data.
+func bounds(c component.Component) (rect, duerror.DUError) {
+	switch dd := c.GetDrawData().(type) {
+	case drawdata.Gadget:
+		return rect{
+			min: utils.Point{X: dd.X, Y: dd.Y},
+			max: utils.Point{X: dd.X + dd.Width, Y: dd.Y + dd.Height},
+		}, nil
+	case drawdata.Association:
+		// the path goes start -> start+delta -> end+delta -> end
+		xs := []int{dd.StartX, dd.EndX, dd.StartX + dd.DeltaX, dd.EndX + dd.DeltaX}
+		ys := []int{dd.StartY, dd.EndY, dd.StartY + dd.DeltaY, dd.EndY + dd.DeltaY}
+		return rect{
+			min: utils.Point{X: min(xs[0], xs[1], xs[2], xs[3]), Y: min(ys[0], ys[1], ys[2], ys[3])},
+			max: utils.Point{X: max(xs[0], xs[1], xs[2], xs[3]), Y: max(ys[0], ys[1], ys[2], ys[3])},
+		}, nil
+	default:
+		return rect{}, duerror.NewInvalidArgumentError("unsupported component draw data")
+	}
+}
+
+// hitBounds returns the box in which a point may cover c, associations can be hit slightly off their path.
+func hitBounds(c component.Component, b rect) rect {
+	if _, ok := c.(*component.Association); ok {
+		return b.inflate(component.CoverThreshold)
+	}
+	return b
+}
@@ -11,6 +11,12 @@ type Container interface {
 	Remove(c component.Component) duerror.DUError
 	Search(p utils.Point) (component.Component, duerror.DUError)
 	SearchGadget(p utils.Point) (*component.Gadget, duerror.DUError)
+	// SearchInRect returns the components lying entirely inside the rectangle spanned by p1 and p2
+	SearchInRect(p1, p2 utils.Point) ([]component.Component, duerror.DUError)
+	// SearchIntersecting returns the components whose bounding box intersects the rectangle spanned by p1 and p2
+	SearchIntersecting(p1, p2 utils.Point) ([]component.Component, duerror.DUError)
+	// Nearest returns the component whose bounding box is the closest to p, nil if the container is empty
+	Nearest(p utils.Point) (component.Component, duerror.DUError)
 	Contain(c component.Component) bool
 	GetAll() []component.Component
 	Len() int
@@ -45,7 +45,7 @@ func (cp *containerMap) Search(p utils.Point) (component.Component, duerror.DUEr
 			candidate = c
 			continue
 		}
-		if c.GetLayer() > candidate.GetLayer() {
+		if CompareLayer(c, candidate) > 0 {
 			candidate = c
 		}
 	}
@@ -68,7 +68,7 @@ func (cp *containerMap) SearchGadget(p utils.Point) (*component.Gadget, duerror.
 				candidate = c
 				continue
 			}
-			if c.GetLayer() < candidate.GetLayer() {
+			if CompareLayer(c, candidate) > 0 {
 				candidate = c
 			}
 		default:
@@ -78,6 +78,51 @@ func (cp *containerMap) SearchGadget(p utils.Point) (*component.Gadget, duerror.
 	return candidate, nil
 }
 
+func (cp *containerMap) SearchInRect(p1, p2 utils.Point) ([]component.Component, duerror.DUError) {
+	area := normalizeRect(p1, p2)
+	res := make([]component.Component, 0)
+	for c := range cp.compMap {
+		b, err := bounds(c)
+		if err != nil {
+			return nil, err
+		}
+		if area.contains(b) {
+			res = append(res, c)
+		}
+	}
+	return res, nil
+}
+
+func (cp *containerMap) SearchIntersecting(p1, p2 utils.Point) ([]component.Component, duerror.DUError) {
+	area := normalizeRect(p1, p2)
+	res := make([]component.Component, 0)
+	for c := range cp.compMap {
+		b, err := bounds(c)
+		if err != nil {
+			return nil, err
+		}
+		if area.intersects(b) {
+			res = append(res, c)
+		}
+	}
+	return res, nil
+}
+
+func (cp *containerMap) Nearest(p utils.Point) (component.Component, duerror.DUError) {
+	var candidate component.Component
+	best := 0
+	for c := range cp.compMap {
+		b, err := bounds(c)
+		if err != nil {
+			return nil, err
+		}
+		if d := b.distance2(p); candidate == nil || d < best {
+			candidate, best = c, d
+		}
+	}
+	return candidate, nil
+}
+
 func (cp *containerMap) Contain(c component.Component) bool {
 	_, ok := cp.compMap[c]
 	return ok
@@ -3,6 +3,8 @@ package components
 import (
 	"testing"
 
+	"Dr.uml/backend/component"
+	"Dr.uml/backend/drawdata"
 	"Dr.uml/backend/mocks"
 	"Dr.uml/backend/utils"
 	"Dr.uml/backend/utils/duerror"
@@ -143,6 +145,41 @@ func TestContainerMap_Search(t *testing.T) {
 	assert.Nil(t, c)
 }
 
+func TestContainerMap_SearchInRect(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	g := mocks.NewMockComponent(ctrl)
+	a := mocks.NewMockComponent(ctrl)
+	cm := NewContainerMap()
+	cm.Insert(g)
+	cm.Insert(a)
+
+	g.EXPECT().GetDrawData().Return(drawdata.Gadget{X: 10, Y: 10, Width: 20, Height: 20}).AnyTimes()
+	a.EXPECT().GetDrawData().Return(drawdata.Association{StartX: 30, StartY: 30, EndX: 60, EndY: 60, DeltaX: -30}).AnyTimes()
+
+	// corners given in any order
+	res, err := cm.SearchInRect(utils.Point{X: 40, Y: 40}, utils.Point{X: 0, Y: 0})
+	assert.NoError(t, err)
+	assert.Equal(t, []component.Component{g}, res)
+
+	// the bend of the association sticks out to the left
+	res, err = cm.SearchInRect(utils.Point{X: 0, Y: 5}, utils.Point{X: 60, Y: 60})
+	assert.NoError(t, err)
+	assert.Len(t, res, 2)
+	res, err = cm.SearchInRect(utils.Point{X: 5, Y: 5}, utils.Point{X: 60, Y: 60})
+	assert.NoError(t, err)
+	assert.Equal(t, []component.Component{g}, res)
+
+	// unknown draw data
+	cm.Insert(mocks.NewMockComponent(ctrl))
+	for _, c := range cm.GetAll() {
+		if c != g && c != a {
+			c.(*mocks.MockComponent).EXPECT().GetDrawData().Return(nil)
+		}
+	}
+	_, err = cm.SearchInRect(utils.Point{}, utils.Point{X: 100, Y: 100})
+	assert.Error(t, err)
+}
+
 func TestContainerMap_GetAll(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	c1 := mocks.NewMockComponent(ctrl)
@@ -0,0 +1,359 @@
+package components
+
+import (
+	"container/heap"
+	"maps"
+	"slices"
+
+	"Dr.uml/backend/component"
+	"Dr.uml/backend/utils"
+	"Dr.uml/backend/utils/duerror"
+)
+
+const (
+	quadNodeCapacity = 8    // items a leaf holds before it splits
+	quadMinSize      = 16   // nodes this small never split
+	quadInitialSize  = 1024 // side of the root before it has to grow
+)
+
+// observable is implemented by components that notify when their bounds change
+type observable interface {
+	AddObserver(observerKey interface{}, observer func() duerror.DUError) duerror.DUError
+	RemoveObserver(observerKey interface{}) duerror.DUError
+}
+
+// quadEntry is a component indexed in the tree
+type quadEntry struct {
+	bounds rect // box of the drawn component
+	hit    rect // box in which a point may cover the component, used to place it in the tree
+}
+
+// quadNode holds the entries that fit in its bounds but in none of its children
+type quadNode struct {
+	bounds   rect
+	items    map[component.Component]quadEntry
+	children []*quadNode // nil for a leaf, otherwise the four quadrants
+}
+
+// implement ComponentsContainer using a quadtree over the bounding boxes of the components.
+// Components are observed, moved ones are marked dirty and indexed again before the next query.
+type containerQuadtree struct {
+	root  *quadNode
+	nodes map[component.Component]*quadNode
+	dirty map[component.Component]bool
+}
+
+func NewContainerQuadtree() Container {
+	return &containerQuadtree{
+		root:  newQuadNode(rect{max: utils.Point{X: quadInitialSize, Y: quadInitialSize}}),
+		nodes: make(map[component.Component]*quadNode),
+		dirty: make(map[component.Component]bool),
+	}
+}
+
+func newQuadNode(bounds rect) *quadNode {
+	return &quadNode{bounds: bounds, items: make(map[component.Component]quadEntry)}
+}
+
+func (cq *containerQuadtree) Insert(c component.Component) duerror.DUError {
+	if c == nil {
+		return duerror.NewInvalidArgumentError("component is nil")
+	}
+	if cq.Contain(c) {
+		cq.unindex(c)
+	}
+	if err := cq.index(c); err != nil {
+		return err
+	}
+	if o, ok := c.(observable); ok {
+		return o.AddObserver(cq, func() duerror.DUError {
+			cq.dirty[c] = true
+			return nil
+		})
+	}
+	return nil
+}
+
+func (cq *containerQuadtree) Remove(c component.Component) duerror.DUError {
+	if !cq.Contain(c) {
+		return nil
+	}
+	cq.unindex(c)
+	delete(cq.dirty, c)
+	if o, ok := c.(observable); ok {
+		return o.RemoveObserver(cq)
+	}
+	return nil
+}
+
+func (cq *containerQuadtree) Search(p utils.Point) (component.Component, duerror.DUError) {
+	if err := cq.refresh(); err != nil {
+		return nil, err
+	}
+	var candidate component.Component
+	err := cq.visitPoint(cq.root, p, func(c component.Component) duerror.DUError {
+		cover, err := c.Cover(p)
+		if err != nil {
+			return err
+		}
+		if cover && (candidate == nil || CompareLayer(c, candidate) > 0) {
+			candidate = c
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return candidate, nil
+}
+
+func (cq *containerQuadtree) SearchGadget(p utils.Point) (*component.Gadget, duerror.DUError) {
+	if err := cq.refresh(); err != nil {
+		return nil, err
+	}
+	var candidate *component.Gadget
+	err := cq.visitPoint(cq.root, p, func(c component.Component) duerror.DUError {
+		g, ok := c.(*component.Gadget)
+		if !ok {
+			return nil
+		}
+		cover, err := g.Cover(p)
+		if err != nil {
+			return err
+		}
+		if cover && (candidate == nil || CompareLayer(g, candidate) > 0) {
+			candidate = g
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return candidate, nil
+}
+
+func (cq *containerQuadtree) SearchInRect(p1, p2 utils.Point) ([]component.Component, duerror.DUError) {
+	area := normalizeRect(p1, p2)
+	return cq.searchArea(area, func(e quadEntry) bool { return area.contains(e.bounds) })
+}
+
+func (cq *containerQuadtree) SearchIntersecting(p1, p2 utils.Point) ([]component.Component, duerror.DUError) {
+	area := normalizeRect(p1, p2)
+	return cq.searchArea(area, func(e quadEntry) bool { return area.intersects(e.bounds) })
+}
+
+// Nearest does a best-first walk of the tree, nodes and components are visited by their distance to p.
+func (cq *containerQuadtree) Nearest(p utils.Point) (component.Component, duerror.DUError) {
+	if err := cq.refresh(); err != nil {
+		return nil, err
+	}
+	queue := &nearestQueue{{node: cq.root, distance: cq.root.bounds.distance2(p)}}
+	for queue.Len() > 0 {
+		item := heap.Pop(queue).(nearestItem)
+		if item.node == nil {
+			return item.component, nil
+		}
+		for c, e := range item.node.items {
+			heap.Push(queue, nearestItem{component: c, distance: e.bounds.distance2(p)})
+		}
+		for _, child := range item.node.children {
+			heap.Push(queue, nearestItem{node: child, distance: child.bounds.distance2(p)})
+		}
+	}
+	return nil, nil
+}
+
+func (cq *containerQuadtree) Contain(c component.Component) bool {
+	_, ok := cq.nodes[c]
+	return ok
+}
+
+func (cq *containerQuadtree) GetAll() []component.Component {
+	return slices.Collect(maps.Keys(cq.nodes))
+}
+
+func (cq *containerQuadtree) Len() int {
+	return len(cq.nodes)
+}
+
+// refresh indexes the components that moved since the last query again.
+func (cq *containerQuadtree) refresh() duerror.DUError {
+	for c := range cq.dirty {
+		delete(cq.dirty, c)
+		if !cq.Contain(c) {
+			continue
+		}
+		cq.unindex(c)
+		if err := cq.index(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cq *containerQuadtree) index(c component.Component) duerror.DUError {
+	b, err := bounds(c)
+	if err != nil {
+		return err
+	}
+	e := quadEntry{bounds: b, hit: hitBounds(c, b)}
+	for !cq.root.bounds.contains(e.hit) {
+		cq.grow(e.hit)
+	}
+	cq.place(cq.root, c, e)
+	return nil
+}
+
+func (cq *containerQuadtree) unindex(c component.Component) {
+	delete(cq.nodes[c].items, c)
+	delete(cq.nodes, c)
+}
+
+// grow doubles the root towards r, the old root becomes one of the quadrants of the new one.
+func (cq *containerQuadtree) grow(r rect) {
+	old := cq.root.bounds
+	size := old.max.X - old.min.X
+	bounds := old
+	if r.min.X < old.min.X {
+		bounds.min.X -= size
+	} else {
+		bounds.max.X += size
+	}
+	if r.min.Y < old.min.Y {
+		bounds.min.Y -= size
+	} else {
+		bounds.max.Y += size
+	}
+	root := newQuadNode(bounds)
+	root.children = make([]*quadNode, 0, 4)
+	for _, q := range quadrants(bounds) {
+		if q == old {
+			root.children = append(root.children, cq.root)
+		} else {
+			root.children = append(root.children, newQuadNode(q))
+		}
+	}
+	cq.root = root
+}
+
+// place puts c in the deepest node containing it, splitting leaves that get too crowded.
+func (cq *containerQuadtree) place(n *quadNode, c component.Component, e quadEntry) {
+	for {
+		child := n.childContaining(e.hit)
+		if child == nil {
+			break
+		}
+		n = child
+	}
+	n.items[c] = e
+	cq.nodes[c] = n
+	if n.children == nil && len(n.items) > quadNodeCapacity && n.bounds.max.X-n.bounds.min.X > quadMinSize {
+		cq.split(n)
+	}
+}
+
+func (cq *containerQuadtree) split(n *quadNode) {
+	n.children = make([]*quadNode, 0, 4)
+	for _, q := range quadrants(n.bounds) {
+		n.children = append(n.children, newQuadNode(q))
+	}
+	for c, e := range n.items {
+		if n.childContaining(e.hit) == nil {
+			continue
+		}
+		delete(n.items, c)
+		cq.place(n, c, e)
+	}
+}
+
+func (n *quadNode) childContaining(r rect) *quadNode {
+	for _, child := range n.children {
+		if child.bounds.contains(r) {
+			return child
+		}
+	}
+	return nil
+}
+
+func quadrants(r rect) [4]rect {
+	mid := utils.Point{X: (r.min.X + r.max.X) / 2, Y: (r.min.Y + r.max.Y) / 2}
+	return [4]rect{
+		{min: r.min, max: mid},
+		{min: utils.Point{X: mid.X, Y: r.min.Y}, max: utils.Point{X: r.max.X, Y: mid.Y}},
+		{min: utils.Point{X: r.min.X, Y: mid.Y}, max: utils.Point{X: mid.X, Y: r.max.Y}},
+		{min: mid, max: r.max},
+	}
+}
+
+// visitPoint calls fn for every component whose hit box contains p.
+func (cq *containerQuadtree) visitPoint(n *quadNode, p utils.Point, fn func(c component.Component) duerror.DUError) duerror.DUError {
+	if !n.bounds.containsPoint(p) {
+		return nil
+	}
+	for c, e := range n.items {
+		if !e.hit.containsPoint(p) {
+			continue
+		}
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	for _, child := range n.children {
+		if err := cq.visitPoint(child, p, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cq *containerQuadtree) searchArea(area rect, match func(e quadEntry) bool) ([]component.Component, duerror.DUError) {
+	if err := cq.refresh(); err != nil {
+		return nil, err
+	}
+	res := make([]component.Component, 0)
+	stack := []*quadNode{cq.root}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !n.bounds.intersects(area) {
+			continue
+		}
+		for c, e := range n.items {
+			if match(e) {
+				res = append(res, c)
+			}
+		}
+		stack = append(stack, n.children...)
+	}
+	return res, nil
+}
+
+// nearestItem is either a node or a component waiting in the best-first queue of Nearest
+type nearestItem struct {
+	node      *quadNode
+	component component.Component
+	distance  int
+}
+
+type nearestQueue []nearestItem
+
+func (q nearestQueue) Len() int { return len(q) }
+
+// components win ties against nodes, a node at the same distance cannot hold anything closer
+func (q nearestQueue) Less(i, j int) bool {
+	if q[i].distance != q[j].distance {
+		return q[i].distance < q[j].distance
+	}
+	return q[i].node == nil && q[j].node != nil
+}
+
+func (q nearestQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *nearestQueue) Push(x any) { *q = append(*q, x.(nearestItem)) }
+
+func (q *nearestQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
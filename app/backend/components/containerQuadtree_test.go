@@ -0,0 +1,138 @@
+package components
+
+import (
+	"math/rand"
+	"testing"
+
+	"Dr.uml/backend/component"
+	"Dr.uml/backend/drawdata"
+	"Dr.uml/backend/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// test util
+func newTestGadget(t testing.TB, x, y, layer int) *component.Gadget {
+	g, err := component.NewGadget(component.Class, utils.Point{X: x, Y: y}, layer, drawdata.DefaultGadgetColor, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func Test_NewContainerQuadtree(t *testing.T) {
+	cq := NewContainerQuadtree()
+	assert.NotNil(t, cq)
+	assert.IsType(t, &containerQuadtree{}, cq)
+}
+
+func TestContainerQuadtree_InsertRemove(t *testing.T) {
+	cq := NewContainerQuadtree()
+	g := newTestGadget(t, 10, 10, 0)
+
+	assert.Error(t, cq.Insert(nil))
+	assert.NoError(t, cq.Insert(g))
+	assert.NoError(t, cq.Insert(g))
+	assert.Equal(t, 1, cq.Len())
+	assert.True(t, cq.Contain(g))
+	assert.Equal(t, []component.Component{g}, cq.GetAll())
+
+	assert.NoError(t, cq.Remove(g))
+	assert.Equal(t, 0, cq.Len())
+	assert.False(t, cq.Contain(g))
+	// removing twice is fine
+	assert.NoError(t, cq.Remove(g))
+
+	// a removed gadget no longer marks itself dirty
+	assert.NoError(t, g.SetPoint(utils.Point{X: 50, Y: 50}))
+	assert.Empty(t, cq.(*containerQuadtree).dirty)
+}
+
+func TestContainerQuadtree_FollowsMoves(t *testing.T) {
+	cq := NewContainerQuadtree()
+	st := newTestGadget(t, 0, 0, 0)
+	en := newTestGadget(t, 300, 0, 0)
+	ass, err := component.NewAssociation([2]*component.Gadget{st, en}, component.Extension, utils.Point{X: 1, Y: 1}, utils.Point{X: 301, Y: 1})
+	assert.NoError(t, err)
+	for _, c := range []component.Component{st, en, ass} {
+		assert.NoError(t, cq.Insert(c))
+	}
+
+	// far outside the initial root, also in the negative quadrant
+	assert.NoError(t, st.SetPoint(utils.Point{X: -5000, Y: 8000}))
+	c, err := cq.Search(utils.Point{X: -4992, Y: 8015})
+	assert.NoError(t, err)
+	assert.Equal(t, component.Component(st), c)
+	c, err = cq.Search(utils.Point{X: 2, Y: 2})
+	assert.NoError(t, err)
+	assert.Nil(t, c)
+
+	// the association was redrawn by its parent and told the container
+	dd := ass.GetDrawData().(drawdata.Association)
+	mid := utils.Point{X: (dd.StartX + dd.EndX) / 2, Y: (dd.StartY + dd.EndY) / 2}
+	cover, _ := ass.Cover(mid)
+	assert.True(t, cover)
+	c, err = cq.Search(mid)
+	assert.NoError(t, err)
+	assert.Equal(t, component.Component(ass), c)
+}
+
+// the quadtree must answer like the linear container
+func TestContainerQuadtree_MatchesContainerMap(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	cm := NewContainerMap()
+	cq := NewContainerQuadtree()
+	gadgets := make([]*component.Gadget, 0, 500)
+	for i := 0; i < 500; i++ {
+		// unique layers, so the topmost component is well defined
+		g := newTestGadget(t, rng.Intn(6000)-3000, rng.Intn(6000)-3000, i)
+		gadgets = append(gadgets, g)
+		assert.NoError(t, cm.Insert(g))
+		assert.NoError(t, cq.Insert(g))
+	}
+	for _, g := range gadgets[:100] {
+		assert.NoError(t, g.SetPoint(utils.Point{X: rng.Intn(6000) - 3000, Y: rng.Intn(6000) - 3000}))
+	}
+	for _, g := range gadgets[100:150] {
+		assert.NoError(t, cm.Remove(g))
+		assert.NoError(t, cq.Remove(g))
+	}
+
+	for i := 0; i < 300; i++ {
+		p := utils.Point{X: rng.Intn(6000) - 3000, Y: rng.Intn(6000) - 3000}
+		if i%3 == 0 {
+			// make sure some clicks hit something
+			p = utils.AddPoints(gadgets[150+i].GetPoint(), utils.Point{X: 2, Y: 2})
+		}
+		expected, err := cm.Search(p)
+		assert.NoError(t, err)
+		actual, err := cq.Search(p)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+
+		expectedGadget, _ := cm.SearchGadget(p)
+		actualGadget, _ := cq.SearchGadget(p)
+		assert.Equal(t, expectedGadget, actualGadget)
+
+		q := utils.AddPoints(p, utils.Point{X: rng.Intn(800) - 400, Y: rng.Intn(800) - 400})
+		expectedList, _ := cm.SearchInRect(p, q)
+		actualList, _ := cq.SearchInRect(p, q)
+		assert.ElementsMatch(t, expectedList, actualList)
+		expectedList, _ = cm.SearchIntersecting(p, q)
+		actualList, _ = cq.SearchIntersecting(p, q)
+		assert.ElementsMatch(t, expectedList, actualList)
+
+		// ties are resolved differently, compare the distance
+		expectedNearest, _ := cm.Nearest(p)
+		actualNearest, _ := cq.Nearest(p)
+		eb, _ := bounds(expectedNearest)
+		ab, _ := bounds(actualNearest)
+		assert.Equal(t, eb.distance2(p), ab.distance2(p))
+	}
+}
+
+func TestContainerQuadtree_Nearest_Empty(t *testing.T) {
+	cq := NewContainerQuadtree()
+	c, err := cq.Nearest(utils.Point{})
+	assert.NoError(t, err)
+	assert.Nil(t, c)
+}
@@ -0,0 +1,64 @@
+package components
+
+import (
+	"testing"
+
+	"Dr.uml/backend/utils"
+)
+
+const benchComponents = 10000
+
+// fillContainer inserts a 100x100 grid of gadgets spaced 150px apart
+func fillContainer(b *testing.B, c Container) {
+	for i := 0; i < benchComponents; i++ {
+		if err := c.Insert(newTestGadget(b, (i%100)*150, (i/100)*150, 0)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkSearch(b *testing.B, c Container) {
+	fillContainer(b, c)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p := utils.Point{X: (i * 37) % 15000, Y: (i * 91) % 15000}
+		if _, err := c.Search(p); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkSearchIntersecting(b *testing.B, c Container) {
+	fillContainer(b, c)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p := utils.Point{X: (i * 37) % 15000, Y: (i * 91) % 15000}
+		if _, err := c.SearchIntersecting(p, utils.AddPoints(p, utils.Point{X: 500, Y: 500})); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkNearest(b *testing.B, c Container) {
+	fillContainer(b, c)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p := utils.Point{X: (i * 37) % 15000, Y: (i * 91) % 15000}
+		if _, err := c.Nearest(p); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkContainerMap_Search(b *testing.B)      { benchmarkSearch(b, NewContainerMap()) }
+func BenchmarkContainerQuadtree_Search(b *testing.B) { benchmarkSearch(b, NewContainerQuadtree()) }
+
+func BenchmarkContainerMap_SearchIntersecting(b *testing.B) {
+	benchmarkSearchIntersecting(b, NewContainerMap())
+}
+func BenchmarkContainerQuadtree_SearchIntersecting(b *testing.B) {
+	benchmarkSearchIntersecting(b, NewContainerQuadtree())
+}
+
+func BenchmarkContainerMap_Nearest(b *testing.B)      { benchmarkNearest(b, NewContainerMap()) }
+func BenchmarkContainerQuadtree_Nearest(b *testing.B) { benchmarkNearest(b, NewContainerQuadtree()) }
@@ -0,0 +1,73 @@
+package components
+
+import (
+	"cmp"
+	"slices"
+
+	"Dr.uml/backend/component"
+)
+
+// CompareLayer orders components from the bottom to the top, it is the one definition of "topmost"
+// shared by hit-testing and the drawing order.
+// A gadget nested in a package is on top of it, so the innermost component wins. Otherwise the
+// components are compared through their ancestors that share a parent: a higher layer is on top,
+// on the same layer associations are on top of gadgets and the remaining ties are broken by creation,
+// so that the order does not depend on the container and moving a component does not restack it.
+func CompareLayer(a, b component.Component) int {
+	pa, pb := ancestry(a), ancestry(b)
+	for i := range min(len(pa), len(pb)) {
+		if pa[i] != pb[i] {
+			return compareSiblings(pa[i], pb[i])
+		}
+	}
+	// one is nested in the other, or they are the same
+	return cmp.Compare(len(pa), len(pb))
+}
+
+// ancestry returns the packages containing c from the outermost one, followed by c
+func ancestry(c component.Component) []component.Component {
+	path := []component.Component{c}
+	if g, ok := c.(*component.Gadget); ok {
+		for p := g.GetParent(); p != nil; p = p.GetParent() {
+			path = append(path, p)
+		}
+	}
+	slices.Reverse(path)
+	return path
+}
+
+// created is a component that tells when it was created, the later ones are on top
+type created interface {
+	GetCreation() uint64
+}
+
+func compareSiblings(a, b component.Component) int {
+	if c := cmp.Compare(a.GetLayer(), b.GetLayer()); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(kindRank(a), kindRank(b)); c != 0 {
+		return c
+	}
+	if ca, ok := a.(created); ok {
+		if cb, ok := b.(created); ok {
+			return cmp.Compare(ca.GetCreation(), cb.GetCreation())
+		}
+	}
+	// components that do not tell when they were created, e.g. mocks, are ordered by position
+	ba, errA := bounds(a)
+	bb, errB := bounds(b)
+	if errA != nil || errB != nil {
+		return 0
+	}
+	if c := cmp.Compare(ba.min.Y, bb.min.Y); c != 0 {
+		return c
+	}
+	return cmp.Compare(ba.min.X, bb.min.X)
+}
+
+func kindRank(c component.Component) int {
+	if _, ok := c.(*component.Association); ok {
+		return 1
+	}
+	return 0
+}
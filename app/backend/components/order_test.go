@@ -0,0 +1,51 @@
+package components
+
+import (
+	"testing"
+
+	"Dr.uml/backend/component"
+	"Dr.uml/backend/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareLayer(t *testing.T) {
+	low := newTestGadget(t, 0, 0, 0)
+	high := newTestGadget(t, 0, 0, 1)
+	below := newTestGadget(t, 10, 10, 0)
+	for _, g := range []*component.Gadget{low, high, below} {
+		assert.NoError(t, g.SetSize(30, 30))
+	}
+	ass, err := component.NewAssociation([2]*component.Gadget{low, below}, component.Extension, utils.Point{X: 1, Y: 1}, utils.Point{X: 11, Y: 11})
+	assert.NoError(t, err)
+
+	assert.Positive(t, CompareLayer(high, low))
+	assert.Negative(t, CompareLayer(low, high))
+	// the association is on top of the gadgets of its layer
+	assert.Positive(t, CompareLayer(ass, below))
+	assert.Negative(t, CompareLayer(ass, high))
+	// ties are broken by creation, not by position
+	assert.Positive(t, CompareLayer(below, low))
+	assert.Zero(t, CompareLayer(low, low))
+	above := newTestGadget(t, -10, -10, 0)
+	assert.Positive(t, CompareLayer(above, below))
+	copied, err := low.Copy()
+	assert.NoError(t, err)
+	assert.Positive(t, CompareLayer(copied, above))
+
+	// hit-testing picks the topmost one for both searches
+	for _, c := range []Container{NewContainerMap(), NewContainerQuadtree()} {
+		for _, g := range []component.Component{low, high, below} {
+			assert.NoError(t, c.Insert(g))
+		}
+		found, err := c.Search(utils.Point{X: 15, Y: 15})
+		assert.NoError(t, err)
+		assert.Equal(t, component.Component(high), found)
+		g, err := c.SearchGadget(utils.Point{X: 15, Y: 15})
+		assert.NoError(t, err)
+		assert.Equal(t, high, g)
+		assert.NoError(t, c.Remove(high))
+		g, err = c.SearchGadget(utils.Point{X: 15, Y: 15})
+		assert.NoError(t, err)
+		assert.Equal(t, below, g)
+	}
+}
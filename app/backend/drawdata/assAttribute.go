@@ -1,10 +1,14 @@
 package drawdata
 
 type AssAttribute struct {
-	Content   string  `json:"content"`
-	FontSize  int     `json:"fontSize"`
-	FontStyle int     `json:"fontStyle"`
-	FontFile  string  `json:"fontFile"`
-	Ratio     float64 `json:"ratio"`
-	Height    int     `json:"height"`
+	Content    string     `json:"content"`
+	FontSize   int        `json:"fontSize"`
+	FontStyle  int        `json:"fontStyle"`
+	FontFile   string     `json:"fontFile"`
+	FontFamily string     `json:"fontFamily"`
+	Color      string     `json:"color"`
+	Align      int        `json:"align"`
+	Ratio      float64    `json:"ratio"`
+	Height     int        `json:"height"`
+	Lines      []TextLine `json:"lines"`
 }
@@ -1,14 +1,19 @@
 package drawdata
 
 type Association struct {
-	AssType    int            `json:"assType"`
-	Layer      int            `json:"layer"`
-	StartX     int            `json:"startX"`
-	StartY     int            `json:"startY"`
-	EndX       int            `json:"endX"`
-	EndY       int            `json:"endY"`
-	DeltaX     int            `json:"deltaX"`
-	DeltaY     int            `json:"deltaY"`
-	IsSelected bool           `json:"isSelected"`
-	Attributes []AssAttribute `json:"attributes"`
+	ID           uint64            `json:"id"` // identifies the association for as long as it exists
+	AssType      int               `json:"assType"`
+	Layer        int               `json:"layer"`
+	LayerName    string            `json:"layerName"`
+	StartX       int               `json:"startX"`
+	StartY       int               `json:"startY"`
+	EndX         int               `json:"endX"`
+	EndY         int               `json:"endY"`
+	DeltaX       int               `json:"deltaX"`
+	DeltaY       int               `json:"deltaY"`
+	IsSelected   bool              `json:"isSelected"`
+	IsLocked     bool              `json:"isLocked"`
+	Stereotypes  []string          `json:"stereotypes"`
+	TaggedValues map[string]string `json:"taggedValues"`
+	Attributes   []AssAttribute    `json:"attributes"`
 }
@@ -4,13 +4,34 @@ const (
 	DefaultAttributeFontSize  = 12
 	DefaultAttributeFontStyle = 0
 	DefaultAttributeFontFile  = "/frontend/src/assets/fonts/Inkfree.ttf"
+	DefaultAttributeColor     = "#000000"
 )
 
 type Attribute struct {
-	Content   string `json:"content"`
-	Height    int    `json:"height"`
-	Width     int    `json:"width"`
-	FontSize  int    `json:"fontSize"`
-	FontStyle int    `json:"fontStyle"`
-	FontFile  string `json:"fontFile"`
+	Content    string     `json:"content"`
+	Height     int        `json:"height"`
+	Width      int        `json:"width"`
+	FontSize   int        `json:"fontSize"`
+	FontStyle  int        `json:"fontStyle"`
+	FontFile   string     `json:"fontFile"`
+	FontFamily string     `json:"fontFamily"`
+	Color      string     `json:"color"`
+	Align      int        `json:"align"`
+	Lines      []TextLine `json:"lines"`
+}
+
+// TextLine is a measured line of the content, Y is its offset from the top of the attribute
+type TextLine struct {
+	Content string    `json:"content"`
+	Y       int       `json:"y"`
+	Width   int       `json:"width"`
+	Height  int       `json:"height"`
+	Runs    []TextRun `json:"runs"`
+}
+
+// TextRun is a piece of the content drawn with one font of the fallback chain
+type TextRun struct {
+	Content  string `json:"content"`
+	FontFile string `json:"fontFile"`
+	Width    int    `json:"width"`
 }
@@ -4,12 +4,40 @@ const (
 	DefaultDiagramColor = "#FFFFFF"
 	Margin              = 4
 	LineWidth           = 2
+	DefaultGridSize     = 10
 )
 
+// Layer is a named layer of the diagram, the components of hidden layers are left out of the draw data
+type Layer struct {
+	Name   string `json:"name"`
+	Hidden bool   `json:"hidden"`
+	Locked bool   `json:"locked"`
+}
+
+// Guide is a line along which the moving gadgets align with another gadget
+type Guide struct {
+	Vertical bool `json:"vertical"` // a vertical line at x = Position, otherwise a horizontal one at y = Position
+	Position int  `json:"position"`
+	Start    int  `json:"start"` // extent of the line on the other axis
+	End      int  `json:"end"`
+}
+
+// DrawnComponent is a component in the drawing order, Index is its position in Gadgets or in Associations
+type DrawnComponent struct {
+	Association bool `json:"association"`
+	Index       int  `json:"index"`
+}
+
 type Diagram struct {
 	Margin       int           `json:"margin"`
 	LineWidth    int           `json:"lineWidth"`
 	Color        string        `json:"color"`
+	GridSize     int           `json:"gridSize"`
+	GridEnabled  bool          `json:"gridEnabled"`
+	Guides       []Guide       `json:"guides"` // only set while gadgets are dragged
+	Layers       []Layer       `json:"layers"`
 	Gadgets      []Gadget      `json:"gadgets"`
 	Associations []Association `json:"associations"`
+	// the gadgets and associations from the bottom to the top, the order they are drawn and hit in
+	Order []DrawnComponent `json:"order"`
 }
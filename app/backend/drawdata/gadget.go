@@ -4,13 +4,29 @@ package drawdata
 const DefaultGadgetColor = "#808080"
 
 type Gadget struct {
-	GadgetType int           `json:"gadgetType"`
-	X          int           `json:"x"`
-	Y          int           `json:"y"`
-	Layer      int           `json:"layer"`
-	Height     int           `json:"height"`
-	Width      int           `json:"width"`
-	Color      string        `json:"color"`
-	IsSelected bool          `json:"isSelected"`
-	Attributes [][]Attribute `json:"attributes"`
+	ID           uint64            `json:"id"` // identifies the gadget for as long as it exists
+	GadgetType   int               `json:"gadgetType"`
+	X            int               `json:"x"`
+	Y            int               `json:"y"`
+	Layer        int               `json:"layer"`
+	LayerName    string            `json:"layerName"`
+	Height       int               `json:"height"`
+	Width        int               `json:"width"`
+	Color        string            `json:"color"`
+	Style        string            `json:"style"` // name of the style of the gadget, empty for none
+	Stereotypes  []string          `json:"stereotypes"`
+	TaggedValues map[string]string `json:"taggedValues"`
+	Annotations  []Attribute       `json:"annotations"` // the stereotypes and the tagged values, each on a line at the top of the header
+	IsSelected   bool              `json:"isSelected"`
+	IsLocked     bool              `json:"isLocked"`
+	MaxWidth     int               `json:"maxWidth"` // width the attributes wrap at, 0 if they do not wrap
+	MinWidth     int               `json:"minWidth"` // size of the content, the gadget cannot be resized below it
+	MinHeight    int               `json:"minHeight"`
+	Sections     []Section         `json:"sections"`
+	Attributes   [][]Attribute     `json:"attributes"` // per section, empty for collapsed sections
+}
+
+type Section struct {
+	Name      string `json:"name"`
+	Collapsed bool   `json:"collapsed"`
 }
@@ -64,6 +64,20 @@ func (mr *MockComponentMockRecorder) GetDrawData() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDrawData", reflect.TypeOf((*MockComponent)(nil).GetDrawData))
 }
 
+// GetIsLocked mocks base method.
+func (m *MockComponent) GetIsLocked() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIsLocked")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// GetIsLocked indicates an expected call of GetIsLocked.
+func (mr *MockComponentMockRecorder) GetIsLocked() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIsLocked", reflect.TypeOf((*MockComponent)(nil).GetIsLocked))
+}
+
 // GetIsSelected mocks base method.
 func (m *MockComponent) GetIsSelected() bool {
 	m.ctrl.T.Helper()
@@ -92,6 +106,48 @@ func (mr *MockComponentMockRecorder) GetLayer() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLayer", reflect.TypeOf((*MockComponent)(nil).GetLayer))
 }
 
+// GetLayerName mocks base method.
+func (m *MockComponent) GetLayerName() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLayerName")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetLayerName indicates an expected call of GetLayerName.
+func (mr *MockComponentMockRecorder) GetLayerName() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLayerName", reflect.TypeOf((*MockComponent)(nil).GetLayerName))
+}
+
+// GetStereotypes mocks base method.
+func (m *MockComponent) GetStereotypes() []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStereotypes")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// GetStereotypes indicates an expected call of GetStereotypes.
+func (mr *MockComponentMockRecorder) GetStereotypes() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStereotypes", reflect.TypeOf((*MockComponent)(nil).GetStereotypes))
+}
+
+// GetTaggedValues mocks base method.
+func (m *MockComponent) GetTaggedValues() map[string]string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTaggedValues")
+	ret0, _ := ret[0].(map[string]string)
+	return ret0
+}
+
+// GetTaggedValues indicates an expected call of GetTaggedValues.
+func (mr *MockComponentMockRecorder) GetTaggedValues() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTaggedValues", reflect.TypeOf((*MockComponent)(nil).GetTaggedValues))
+}
+
 // RegisterUpdateParentDraw mocks base method.
 func (m *MockComponent) RegisterUpdateParentDraw(update func() duerror.DUError) duerror.DUError {
 	m.ctrl.T.Helper()
@@ -106,6 +162,20 @@ func (mr *MockComponentMockRecorder) RegisterUpdateParentDraw(update interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterUpdateParentDraw", reflect.TypeOf((*MockComponent)(nil).RegisterUpdateParentDraw), update)
 }
 
+// SetIsLocked mocks base method.
+func (m *MockComponent) SetIsLocked(isLocked bool) duerror.DUError {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetIsLocked", isLocked)
+	ret0, _ := ret[0].(duerror.DUError)
+	return ret0
+}
+
+// SetIsLocked indicates an expected call of SetIsLocked.
+func (mr *MockComponentMockRecorder) SetIsLocked(isLocked interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetIsLocked", reflect.TypeOf((*MockComponent)(nil).SetIsLocked), isLocked)
+}
+
 // SetIsSelected mocks base method.
 func (m *MockComponent) SetIsSelected(isSelected bool) duerror.DUError {
 	m.ctrl.T.Helper()
@@ -133,3 +203,45 @@ func (mr *MockComponentMockRecorder) SetLayer(layer interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLayer", reflect.TypeOf((*MockComponent)(nil).SetLayer), layer)
 }
+
+// SetLayerName mocks base method.
+func (m *MockComponent) SetLayerName(name string) duerror.DUError {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetLayerName", name)
+	ret0, _ := ret[0].(duerror.DUError)
+	return ret0
+}
+
+// SetLayerName indicates an expected call of SetLayerName.
+func (mr *MockComponentMockRecorder) SetLayerName(name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLayerName", reflect.TypeOf((*MockComponent)(nil).SetLayerName), name)
+}
+
+// SetStereotypes mocks base method.
+func (m *MockComponent) SetStereotypes(stereotypes []string) duerror.DUError {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetStereotypes", stereotypes)
+	ret0, _ := ret[0].(duerror.DUError)
+	return ret0
+}
+
+// SetStereotypes indicates an expected call of SetStereotypes.
+func (mr *MockComponentMockRecorder) SetStereotypes(stereotypes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetStereotypes", reflect.TypeOf((*MockComponent)(nil).SetStereotypes), stereotypes)
+}
+
+// SetTaggedValues mocks base method.
+func (m *MockComponent) SetTaggedValues(tags map[string]string) duerror.DUError {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTaggedValues", tags)
+	ret0, _ := ret[0].(duerror.DUError)
+	return ret0
+}
+
+// SetTaggedValues indicates an expected call of SetTaggedValues.
+func (mr *MockComponentMockRecorder) SetTaggedValues(tags interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTaggedValues", reflect.TypeOf((*MockComponent)(nil).SetTaggedValues), tags)
+}
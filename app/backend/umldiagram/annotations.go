@@ -0,0 +1,80 @@
+package umldiagram
+
+import (
+	"maps"
+	"slices"
+
+	"Dr.uml/backend/component"
+	"Dr.uml/backend/utils/duerror"
+)
+
+// SetStereotypesComponent sets the stereotypes of the selected component, see component.NormalizeStereotypes
+func (ud *UMLDiagram) SetStereotypesComponent(stereotypes []string) duerror.DUError {
+	c, err := ud.getEditableComponent()
+	if err != nil {
+		return err
+	}
+	normalized, err := component.NormalizeStereotypes(stereotypes)
+	if err != nil {
+		return err
+	}
+	if slices.Equal(c.GetStereotypes(), normalized) {
+		return nil
+	}
+	return ud.executeSetter(c, propertyStereotypes, 0, 0, c.GetStereotypes(), normalized)
+}
+
+// SetTaggedValuesComponent sets the tagged values of the selected component, see component.NormalizeTaggedValues
+func (ud *UMLDiagram) SetTaggedValuesComponent(tags map[string]string) duerror.DUError {
+	c, err := ud.getEditableComponent()
+	if err != nil {
+		return err
+	}
+	normalized, err := component.NormalizeTaggedValues(tags)
+	if err != nil {
+		return err
+	}
+	if maps.Equal(c.GetTaggedValues(), normalized) {
+		return nil
+	}
+	return ud.executeSetter(c, propertyTaggedValues, 0, 0, c.GetTaggedValues(), normalized)
+}
+
+// ComponentsWithStereotype returns the components with the given stereotype from the bottom to the top
+func (ud *UMLDiagram) ComponentsWithStereotype(stereotype string) []component.Component {
+	return ud.filterComponents(func(c component.Component) bool {
+		return component.HasStereotype(c, stereotype)
+	})
+}
+
+// ComponentsWithTaggedValue returns the components with a tagged value of the given key from the bottom to the top
+func (ud *UMLDiagram) ComponentsWithTaggedValue(key string) []component.Component {
+	return ud.filterComponents(func(c component.Component) bool {
+		_, ok := component.GetTaggedValue(c, key)
+		return ok
+	})
+}
+
+// GetStereotypes returns the stereotypes used in the diagram, sorted
+func (ud *UMLDiagram) GetStereotypes() []string {
+	res := make([]string, 0)
+	for _, c := range ud.componentsContainer.GetAll() {
+		for _, s := range c.GetStereotypes() {
+			if !slices.Contains(res, s) {
+				res = append(res, s)
+			}
+		}
+	}
+	slices.Sort(res)
+	return res
+}
+
+func (ud *UMLDiagram) filterComponents(keep func(c component.Component) bool) []component.Component {
+	res := make([]component.Component, 0)
+	for _, c := range ud.componentsByLayer() {
+		if keep(c) {
+			res = append(res, c)
+		}
+	}
+	return res
+}
@@ -0,0 +1,228 @@
+package umldiagram
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"Dr.uml/backend/command"
+	"Dr.uml/backend/component"
+	"Dr.uml/backend/utils"
+	"Dr.uml/backend/utils/duerror"
+)
+
+type Alignment int
+
+const (
+	AlignLeft Alignment = iota
+	AlignRight
+	AlignTop
+	AlignBottom
+	AlignCenter // horizontal centers
+	AlignMiddle // vertical centers
+)
+
+var AllAlignments = []struct {
+	Value  Alignment
+	TSName string
+}{
+	{AlignLeft, "AlignLeft"},
+	{AlignRight, "AlignRight"},
+	{AlignTop, "AlignTop"},
+	{AlignBottom, "AlignBottom"},
+	{AlignCenter, "AlignCenter"},
+	{AlignMiddle, "AlignMiddle"},
+}
+
+// AlignComponent lines the selected gadgets up along the edge or center of the area they cover, as one undo step
+func (ud *UMLDiagram) AlignComponent(alignment Alignment) duerror.DUError {
+	gadgets, err := ud.getArrangedGadgets(2)
+	if err != nil {
+		return err
+	}
+	bound := gadgetBox(gadgets[0])
+	for _, g := range gadgets[1:] {
+		b := gadgetBox(g)
+		bound.min = utils.Point{X: min(bound.min.X, b.min.X), Y: min(bound.min.Y, b.min.Y)}
+		bound.max = utils.Point{X: max(bound.max.X, b.max.X), Y: max(bound.max.Y, b.max.Y)}
+	}
+	points := make(map[*component.Gadget]utils.Point, len(gadgets))
+	for _, g := range gadgets {
+		b := gadgetBox(g)
+		p := b.min
+		switch alignment {
+		case AlignLeft:
+			p.X = bound.min.X
+		case AlignRight:
+			p.X = bound.max.X - (b.max.X - b.min.X)
+		case AlignTop:
+			p.Y = bound.min.Y
+		case AlignBottom:
+			p.Y = bound.max.Y - (b.max.Y - b.min.Y)
+		case AlignCenter:
+			p.X = (bound.min.X+bound.max.X)/2 - (b.max.X-b.min.X)/2
+		case AlignMiddle:
+			p.Y = (bound.min.Y+bound.max.Y)/2 - (b.max.Y-b.min.Y)/2
+		default:
+			return duerror.NewInvalidArgumentError("invalid alignment")
+		}
+		points[g] = p
+	}
+	return ud.moveGadgetsTo(points)
+}
+
+// DistributeHorizontallyComponent spreads the selected gadgets so that the gaps between them are equal,
+// the leftmost and the rightmost one stay in place
+func (ud *UMLDiagram) DistributeHorizontallyComponent() duerror.DUError {
+	return ud.distribute(true)
+}
+
+// DistributeVerticallyComponent spreads the selected gadgets so that the gaps between them are equal,
+// the topmost and the bottommost one stay in place
+func (ud *UMLDiagram) DistributeVerticallyComponent() duerror.DUError {
+	return ud.distribute(false)
+}
+
+// MatchWidthComponent gives the selected gadgets the width of the widest one
+func (ud *UMLDiagram) MatchWidthComponent() duerror.DUError {
+	return ud.matchSize(true)
+}
+
+// MatchHeightComponent gives the selected gadgets the height of the tallest one
+func (ud *UMLDiagram) MatchHeightComponent() duerror.DUError {
+	return ud.matchSize(false)
+}
+
+// getArrangedGadgets returns the selected gadgets, failing if there are fewer than least or one is locked
+func (ud *UMLDiagram) getArrangedGadgets(least int) ([]*component.Gadget, duerror.DUError) {
+	gadgets, err := ud.getEditableGadgets()
+	if err != nil {
+		return nil, err
+	}
+	if err := ud.checkMovable(gadgets); err != nil {
+		return nil, err
+	}
+	if len(gadgets) < least {
+		return nil, duerror.NewInvalidArgumentError(fmt.Sprintf("at least %d gadgets must be selected", least))
+	}
+	return gadgets, nil
+}
+
+func (ud *UMLDiagram) distribute(horizontal bool) duerror.DUError {
+	gadgets, err := ud.getArrangedGadgets(3)
+	if err != nil {
+		return err
+	}
+	start := func(b box) int {
+		if horizontal {
+			return b.min.X
+		}
+		return b.min.Y
+	}
+	length := func(b box) int {
+		if horizontal {
+			return b.max.X - b.min.X
+		}
+		return b.max.Y - b.min.Y
+	}
+	slices.SortStableFunc(gadgets, func(a, b *component.Gadget) int {
+		return start(gadgetBox(a)) - start(gadgetBox(b))
+	})
+	first, last := gadgetBox(gadgets[0]), gadgetBox(gadgets[len(gadgets)-1])
+	space := start(last) + length(last) - start(first)
+	for _, g := range gadgets {
+		space -= length(gadgetBox(g))
+	}
+
+	points := make(map[*component.Gadget]utils.Point, len(gadgets))
+	pos := start(first)
+	for i, g := range gadgets {
+		b := gadgetBox(g)
+		// spread the rounding over the gaps so that the last gadget stays in place
+		p := b.min
+		if horizontal {
+			p.X = pos + space*i/(len(gadgets)-1)
+		} else {
+			p.Y = pos + space*i/(len(gadgets)-1)
+		}
+		points[g] = p
+		pos += length(b)
+	}
+	return ud.moveGadgetsTo(points)
+}
+
+func (ud *UMLDiagram) matchSize(width bool) duerror.DUError {
+	gadgets, err := ud.getArrangedGadgets(2)
+	if err != nil {
+		return err
+	}
+	size := func(g *component.Gadget) int {
+		b := gadgetBox(g)
+		if width {
+			return b.max.X - b.min.X
+		}
+		return b.max.Y - b.min.Y
+	}
+	target := 0
+	for _, g := range gadgets {
+		target = max(target, size(g))
+	}
+	cmds := make([]command.Command, 0, len(gadgets))
+	for _, g := range gadgets {
+		if size(g) == target {
+			continue
+		}
+		oldW, oldH := g.GetSize()
+		w, h := oldW, oldH
+		if width {
+			w = target
+		} else {
+			h = target
+		}
+		cmds = append(cmds, &resizeGadgetsCommand{
+			baseCommand: baseCommand{
+				diagram: ud,
+				before:  ud.GetLastModified(),
+				after:   time.Now(),
+			},
+			oldSizes: map[*component.Gadget][2]int{g: {oldW, oldH}},
+			newSize:  [2]int{w, h},
+		})
+	}
+	return ud.executeAll(cmds)
+}
+
+// moveGadgetsTo moves every gadget so that its drawn top-left corner is at its point, as one undo step.
+// Gadgets already there and gadgets in a package that is moved too are left out.
+func (ud *UMLDiagram) moveGadgetsTo(points map[*component.Gadget]utils.Point) duerror.DUError {
+	inMovedPackage := func(g *component.Gadget) bool {
+		for p := g.GetParent(); p != nil; p = p.GetParent() {
+			if _, ok := points[p]; ok {
+				return true
+			}
+		}
+		return false
+	}
+	cmds := make([]command.Command, 0, len(points))
+	for g, p := range points {
+		if inMovedPackage(g) {
+			// moves with its package
+			continue
+		}
+		offset := utils.SubPoints(p, gadgetBox(g).min)
+		if utils.EqualPoints(offset, utils.Point{}) {
+			continue
+		}
+		cmds = append(cmds, &moveGadgetCommand{
+			baseCommand: baseCommand{
+				diagram: ud,
+				before:  ud.GetLastModified(),
+				after:   time.Now(),
+			},
+			gadget:   g,
+			newPoint: utils.AddPoints(g.GetPoint(), offset),
+			oldPoint: g.GetPoint(),
+		})
+	}
+	return ud.executeAll(cmds)
+}
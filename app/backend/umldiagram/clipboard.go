@@ -0,0 +1,189 @@
+package umldiagram
+
+import (
+	"fmt"
+	"maps"
+	"time"
+
+	"Dr.uml/backend/command"
+	"Dr.uml/backend/component"
+	"Dr.uml/backend/utils"
+	"Dr.uml/backend/utils/duerror"
+)
+
+// CopySelectedComponents exports the selected gadgets, with the gadgets in them, as a SavedDiagram fragment.
+// Associations between two copied gadgets come along, the others are dropped.
+func (ud *UMLDiagram) CopySelectedComponents() (utils.SavedDiagram, duerror.DUError) {
+	selected, err := ud.getSelectedGadgets()
+	if err != nil {
+		return utils.SavedDiagram{}, err
+	}
+	gadgets := withDescendants(selected)
+	fragment := utils.SavedDiagram{
+		Filetype:     utils.FiletypeDiagram | int(ud.diagramType)<<1,
+		Gadgets:      make([]utils.SavedGad, 0, len(gadgets)),
+		Associations: make([]utils.SavedAss, 0),
+	}
+	indices := make(map[*component.Gadget]int, len(gadgets))
+	for _, g := range gadgets {
+		indices[g] = len(fragment.Gadgets)
+		fragment.Gadgets = append(fragment.Gadgets, g.ToSavedGadget())
+	}
+	saveChildren(indices, fragment.Gadgets)
+	for _, g := range gadgets {
+		for _, a := range ud.associations[g][0] {
+			end, ok := indices[a.GetParentEnd()]
+			if !ok {
+				continue
+			}
+			fragment.Associations = append(fragment.Associations, a.ToSavedAssociation([2]int{indices[g], end}))
+		}
+	}
+	return fragment, nil
+}
+
+// PasteComponents adds the components of a fragment made by CopySelectedComponents, moved by offset.
+// The pasted components replace the selection, the whole paste is one undo step.
+func (ud *UMLDiagram) PasteComponents(fragment utils.SavedDiagram, offset utils.Point) duerror.DUError {
+	gadgets := make([]*component.Gadget, 0, len(fragment.Gadgets))
+	loaded := make(map[int]*component.Gadget, len(fragment.Gadgets))
+	for index, saved := range fragment.Gadgets {
+		g, err := component.FromSavedGadget(saved)
+		if err != nil {
+			return err
+		}
+		if err, attIndex := ud.loadGadgetAttributes(g, saved); err != nil {
+			return duerror.NewParsingError(fmt.Sprintf(
+				"Error on parsing %d-th attribute of %d-th pasted gadget. Detail: %s", attIndex, index, err.Error()))
+		}
+		// the style may look different in this diagram
+		if err = ud.applyStyle(g); err != nil {
+			return err
+		}
+		if err = g.SetPoint(utils.AddPoints(g.GetPoint(), offset)); err != nil {
+			return err
+		}
+		gadgets = append(gadgets, g)
+		loaded[index] = g
+	}
+	if err := loadChildren(fragment.Gadgets, loaded); err != nil {
+		return duerror.NewParsingError(err.Error())
+	}
+	asses := make([]*component.Association, 0, len(fragment.Associations))
+	for index, saved := range fragment.Associations {
+		if len(saved.Parents) != 2 ||
+			saved.Parents[0] < 0 || saved.Parents[0] >= len(gadgets) ||
+			saved.Parents[1] < 0 || saved.Parents[1] >= len(gadgets) {
+			return duerror.NewParsingError(fmt.Sprintf("%d-th pasted association refers to a missing gadget", index))
+		}
+		a, err := component.FromSavedAssociation(saved, [2]*component.Gadget{gadgets[saved.Parents[0]], gadgets[saved.Parents[1]]})
+		if err != nil {
+			return err
+		}
+		if err, attIndex := ud.loadAssAttributes(a, saved.Attributes); err != nil {
+			return duerror.NewParsingError(fmt.Sprintf(
+				"Error on adding %d-th attribute for %d-th pasted association: %s", attIndex, index, err.Error()))
+		}
+		asses = append(asses, a)
+	}
+	return ud.addCopies(gadgets, asses)
+}
+
+// DuplicateSelectedComponents copies the selected gadgets, with the gadgets in them, and the associations
+// between them in place, moved by offset. The clipboard is not involved.
+func (ud *UMLDiagram) DuplicateSelectedComponents(offset utils.Point) duerror.DUError {
+	selected, err := ud.getSelectedGadgets()
+	if err != nil {
+		return err
+	}
+	originals := withDescendants(selected)
+	copies := make(map[*component.Gadget]*component.Gadget, len(originals))
+	gadgets := make([]*component.Gadget, 0, len(originals))
+	for _, g := range originals {
+		c, err := g.Copy()
+		if err != nil {
+			return err
+		}
+		if err = c.SetPoint(utils.AddPoints(c.GetPoint(), offset)); err != nil {
+			return err
+		}
+		copies[g] = c
+		gadgets = append(gadgets, c)
+	}
+	// the copies are nested like the originals, a copy is not in a package when its original's package is not copied
+	for _, g := range originals {
+		if p, ok := copies[g.GetParent()]; ok {
+			if err := copies[g].SetParent(p); err != nil {
+				return err
+			}
+		}
+	}
+	asses := make([]*component.Association, 0)
+	for _, g := range originals {
+		for _, a := range ud.associations[g][0] {
+			end, ok := copies[a.GetParentEnd()]
+			if !ok {
+				continue
+			}
+			c, err := a.Copy([2]*component.Gadget{copies[g], end})
+			if err != nil {
+				return err
+			}
+			asses = append(asses, c)
+		}
+	}
+	return ud.addCopies(gadgets, asses)
+}
+
+// addCopies adds freshly built components as one undo step, unselecting the current selection
+// and selecting the new components instead.
+func (ud *UMLDiagram) addCopies(gadgets []*component.Gadget, asses []*component.Association) duerror.DUError {
+	comps := make([]component.Component, 0, len(gadgets)+len(asses))
+	for _, g := range gadgets {
+		comps = append(comps, g)
+	}
+	for _, a := range asses {
+		comps = append(comps, a)
+	}
+	if len(comps) == 0 {
+		return nil
+	}
+
+	cmds := make([]command.Command, 0, len(comps)+1)
+	if len(ud.componentsSelected) > 0 {
+		cmds = append(cmds, &selectAllCommand{
+			baseCommand: baseCommand{
+				diagram: ud,
+				before:  ud.GetLastModified(),
+				after:   time.Now(),
+			},
+			components: maps.Clone(ud.componentsSelected),
+			newValue:   false,
+		})
+	}
+	// gadgets come first so the associations find their parents
+	for _, c := range comps {
+		// components of a layer this diagram lacks, or that cannot be selected, go to the default layer
+		if !ud.acceptsComponents(c.GetLayerName()) {
+			if err := c.SetLayerName(""); err != nil {
+				return err
+			}
+		}
+		if err := c.RegisterUpdateParentDraw(ud.updateDrawData); err != nil {
+			return err
+		}
+		// selected before being added, addComponent records it in the selection
+		if err := c.SetIsSelected(true); err != nil {
+			return err
+		}
+		cmds = append(cmds, &addComponentCommand{
+			baseCommand: baseCommand{
+				diagram: ud,
+				before:  ud.GetLastModified(),
+				after:   time.Now(),
+			},
+			component: c,
+		})
+	}
+	return ud.executeAll(cmds)
+}
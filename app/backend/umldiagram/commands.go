@@ -1,8 +1,10 @@
 package umldiagram
 
 import (
+	"maps"
 	"time"
 
+	"Dr.uml/backend/command"
 	"Dr.uml/backend/component"
 	"Dr.uml/backend/utils"
 	"Dr.uml/backend/utils/duerror"
@@ -65,13 +67,58 @@ func (cmd *selectAllCommand) Unexecute() duerror.DUError {
 }
 
 // simple setters
+const (
+	propertyLayer          = "layer"
+	propertyLayerName      = "layerName"
+	propertyLocked         = "locked"
+	propertyStereotypes    = "stereotypes"
+	propertyTaggedValues   = "taggedValues"
+	propertyColor          = "color"
+	propertyStyle          = "style"
+	propertyStyleOverrides = "styleOverrides"
+	propertyMaxWidth       = "maxWidth"
+	propertyAssType        = "assType"
+	propertyAttrContent    = "attrContent"
+	propertyAttrSize       = "attrSize"
+	propertyAttrStyle      = "attrStyle"
+	propertyAttrFont       = "attrFont"
+	propertyAttrColor      = "attrColor"
+	propertyAttrAlign      = "attrAlign"
+
+	propertySectionName      = "sectionName"
+	propertySectionCollapsed = "sectionCollapsed"
+)
+
 type setterCommand struct {
 	baseCommand
 	component component.Component
+	property  string
+	section   int
+	index     int
+	oldValue  any
+	newValue  any
 	execute   func() duerror.DUError
 	unexecute func() duerror.DUError
 }
 
+func (ud *UMLDiagram) newSetterCommand(c component.Component, property string, section, index int, oldValue, newValue any) (*setterCommand, duerror.DUError) {
+	set, err := ud.propertySetter(c, property, section, index)
+	if err != nil {
+		return nil, err
+	}
+	return &setterCommand{
+		baseCommand: baseCommand{diagram: ud},
+		component:   c,
+		property:    property,
+		section:     section,
+		index:       index,
+		oldValue:    oldValue,
+		newValue:    newValue,
+		execute:     func() duerror.DUError { return set(newValue) },
+		unexecute:   func() duerror.DUError { return set(oldValue) },
+	}, nil
+}
+
 func (cmd *setterCommand) Execute() duerror.DUError {
 	return cmd.execute()
 }
@@ -86,14 +133,105 @@ type moveGadgetCommand struct {
 	gadget   *component.Gadget
 	newPoint utils.Point
 	oldPoint utils.Point
+	parents  parentChanges // the gadget dropped in or out of a package
 }
 
 func (cmd *moveGadgetCommand) Execute() duerror.DUError {
-	return cmd.diagram.moveGadget(cmd.gadget, cmd.newPoint)
+	if err := cmd.diagram.moveGadget(cmd.gadget, cmd.newPoint); err != nil {
+		return err
+	}
+	return cmd.parents.apply(1)
 }
 
 func (cmd *moveGadgetCommand) Unexecute() duerror.DUError {
-	return cmd.diagram.moveGadget(cmd.gadget, cmd.oldPoint)
+	if err := cmd.diagram.moveGadget(cmd.gadget, cmd.oldPoint); err != nil {
+		return err
+	}
+	return cmd.parents.apply(0)
+}
+
+func (cmd *moveGadgetCommand) Merge(next command.Command) bool {
+	n, ok := next.(*moveGadgetCommand)
+	if !ok || n.gadget != cmd.gadget {
+		return false
+	}
+	cmd.newPoint = n.newPoint
+	cmd.parents = cmd.parents.merge(n.parents)
+	cmd.after = n.after
+	return true
+}
+
+// resizeGadgetsCommand gives several gadgets the same size, [0, 0] returns them to the size of their content
+type resizeGadgetsCommand struct {
+	baseCommand
+	oldSizes map[*component.Gadget][2]int
+	newSize  [2]int
+}
+
+func (cmd *resizeGadgetsCommand) Execute() duerror.DUError {
+	for g := range cmd.oldSizes {
+		if err := g.SetSize(cmd.newSize[0], cmd.newSize[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cmd *resizeGadgetsCommand) Unexecute() duerror.DUError {
+	for g, size := range cmd.oldSizes {
+		if err := g.SetSize(size[0], size[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cmd *resizeGadgetsCommand) Merge(next command.Command) bool {
+	n, ok := next.(*resizeGadgetsCommand)
+	if !ok || len(n.oldSizes) != len(cmd.oldSizes) {
+		return false
+	}
+	for g := range n.oldSizes {
+		if _, ok := cmd.oldSizes[g]; !ok {
+			return false
+		}
+	}
+	cmd.newSize = n.newSize
+	cmd.after = n.after
+	return true
+}
+
+// moveGadgetsCommand moves several gadgets by the same offset
+type moveGadgetsCommand struct {
+	baseCommand
+	gadgets map[*component.Gadget]bool
+	offset  utils.Point
+	parents parentChanges // the gadgets dropped in or out of a package
+}
+
+func (cmd *moveGadgetsCommand) Execute() duerror.DUError {
+	if err := cmd.diagram.moveGadgets(cmd.gadgets, cmd.offset); err != nil {
+		return err
+	}
+	return cmd.parents.apply(1)
+}
+
+func (cmd *moveGadgetsCommand) Unexecute() duerror.DUError {
+	if err := cmd.diagram.moveGadgets(cmd.gadgets, utils.SubPoints(utils.Point{}, cmd.offset)); err != nil {
+		return err
+	}
+	return cmd.parents.apply(0)
+}
+
+func (cmd *moveGadgetsCommand) Merge(next command.Command) bool {
+	n, ok := next.(*moveGadgetsCommand)
+	if !ok || !maps.Equal(n.gadgets, cmd.gadgets) {
+		return false
+	}
+	cmd.offset = utils.AddPoints(cmd.offset, n.offset)
+	cmd.parents = cmd.parents.merge(n.parents)
+	cmd.after = n.after
+	return true
 }
 
 // set parent association
@@ -122,6 +260,17 @@ func (cmd *setParentStartCommand) Unexecute() duerror.DUError {
 	)
 }
 
+func (cmd *setParentStartCommand) Merge(next command.Command) bool {
+	n, ok := next.(*setParentStartCommand)
+	if !ok || n.association != cmd.association {
+		return false
+	}
+	cmd.stNew = n.stNew
+	cmd.stRatioNew = n.stRatioNew
+	cmd.after = n.after
+	return true
+}
+
 type setParentEndCommand struct {
 	baseCommand
 	association *component.Association
@@ -147,6 +296,17 @@ func (cmd *setParentEndCommand) Unexecute() duerror.DUError {
 	)
 }
 
+func (cmd *setParentEndCommand) Merge(next command.Command) bool {
+	n, ok := next.(*setParentEndCommand)
+	if !ok || n.association != cmd.association {
+		return false
+	}
+	cmd.enNew = n.enNew
+	cmd.enRatioNew = n.enRatioNew
+	cmd.after = n.after
+	return true
+}
+
 // add/remove attribute
 type addAttributeGadgetCommand struct {
 	baseCommand
@@ -198,6 +358,54 @@ func (cmd *removeAttributeGadgetCommand) Unexecute() duerror.DUError {
 	)
 }
 
+// add/remove/move section
+type addSectionCommand struct {
+	baseCommand
+	gadget  *component.Gadget
+	section int
+	name    string
+}
+
+func (cmd *addSectionCommand) Execute() duerror.DUError {
+	return cmd.gadget.AddSection(cmd.section, cmd.name)
+}
+
+func (cmd *addSectionCommand) Unexecute() duerror.DUError {
+	return cmd.gadget.RemoveSection(cmd.section)
+}
+
+type removeSectionCommand struct {
+	baseCommand
+	gadget     *component.Gadget
+	section    int
+	name       string
+	collapsed  bool
+	attributes []utils.SavedAtt // the attributes of the section, restored on undo
+}
+
+func (cmd *removeSectionCommand) Execute() duerror.DUError {
+	return cmd.gadget.RemoveSection(cmd.section)
+}
+
+func (cmd *removeSectionCommand) Unexecute() duerror.DUError {
+	return cmd.diagram.restoreSection(cmd.gadget, cmd.section, cmd.name, cmd.collapsed, cmd.attributes)
+}
+
+type moveSectionCommand struct {
+	baseCommand
+	gadget *component.Gadget
+	from   int
+	to     int
+}
+
+func (cmd *moveSectionCommand) Execute() duerror.DUError {
+	return cmd.gadget.MoveSection(cmd.from, cmd.to)
+}
+
+func (cmd *moveSectionCommand) Unexecute() duerror.DUError {
+	return cmd.gadget.MoveSection(cmd.to, cmd.from)
+}
+
 type addAttributeAssociationCommand struct {
 	baseCommand
 	association *component.Association
@@ -245,3 +453,27 @@ func (cmd *removeAttributeAssociationCommand) Unexecute() duerror.DUError {
 		cmd.content,
 	)
 }
+
+// diagram settings
+const (
+	propertyFontFallbacks = "fontFallbacks"
+	propertyGridSize      = "gridSize"
+	propertyGridEnabled   = "gridEnabled"
+	propertyLayers        = "layers"
+	propertyProperties    = "properties"
+)
+
+type diagramSetterCommand struct {
+	baseCommand
+	property string
+	oldValue any
+	newValue any
+}
+
+func (cmd *diagramSetterCommand) Execute() duerror.DUError {
+	return cmd.diagram.setDiagramProperty(cmd.property, cmd.newValue)
+}
+
+func (cmd *diagramSetterCommand) Unexecute() duerror.DUError {
+	return cmd.diagram.setDiagramProperty(cmd.property, cmd.oldValue)
+}
@@ -0,0 +1,858 @@
+package umldiagram
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"Dr.uml/backend/command"
+	"Dr.uml/backend/component"
+	"Dr.uml/backend/utils"
+	"Dr.uml/backend/utils/duerror"
+)
+
+// kinds of saved commands
+const (
+	savedKindAdd                        = "add"
+	savedKindRemove                     = "remove"
+	savedKindSelect                     = "select"
+	savedKindSetter                     = "setter"
+	savedKindMove                       = "move"
+	savedKindMoveGadgets                = "moveGadgets"
+	savedKindResize                     = "resize"
+	savedKindSetParentStart             = "setParentStart"
+	savedKindSetParentEnd               = "setParentEnd"
+	savedKindAddAttributeGadget         = "addAttributeGadget"
+	savedKindRemoveAttributeGadget      = "removeAttributeGadget"
+	savedKindAddAttributeAssociation    = "addAttributeAssociation"
+	savedKindRemoveAttributeAssociation = "removeAttributeAssociation"
+	savedKindCompound                   = "compound"
+	savedKindDiagramSetter              = "diagramSetter"
+	savedKindAddSection                 = "addSection"
+	savedKindRemoveSection              = "removeSection"
+	savedKindMoveSection                = "moveSection"
+
+	savedKindGadget      = "gadget"
+	savedKindAssociation = "association"
+)
+
+// HistoryChange is one step between two history states, as reported by DiffHistoryStates.
+type HistoryChange struct {
+	Undo     bool      `json:"undo"` // the step is reverted, otherwise it is applied
+	Kind     string    `json:"kind"`
+	Property string    `json:"property,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+func describeCommand(cmd command.Command, undo bool) HistoryChange {
+	change := HistoryChange{Undo: undo, Time: cmd.GetAfter()}
+	switch c := cmd.(type) {
+	case *addComponentCommand:
+		change.Kind = savedKindAdd
+	case *removeSelectedComponentCommand:
+		change.Kind = savedKindRemove
+	case *selectAllCommand:
+		change.Kind = savedKindSelect
+	case *setterCommand:
+		change.Kind = savedKindSetter
+		change.Property = c.property
+	case *moveGadgetCommand:
+		change.Kind = savedKindMove
+	case *moveGadgetsCommand:
+		change.Kind = savedKindMoveGadgets
+	case *resizeGadgetsCommand:
+		change.Kind = savedKindResize
+	case *setParentStartCommand:
+		change.Kind = savedKindSetParentStart
+	case *setParentEndCommand:
+		change.Kind = savedKindSetParentEnd
+	case *addAttributeGadgetCommand:
+		change.Kind = savedKindAddAttributeGadget
+	case *removeAttributeGadgetCommand:
+		change.Kind = savedKindRemoveAttributeGadget
+	case *addAttributeAssociationCommand:
+		change.Kind = savedKindAddAttributeAssociation
+	case *removeAttributeAssociationCommand:
+		change.Kind = savedKindRemoveAttributeAssociation
+	case *diagramSetterCommand:
+		change.Kind = savedKindDiagramSetter
+		change.Property = c.property
+	case *addSectionCommand:
+		change.Kind = savedKindAddSection
+	case *removeSectionCommand:
+		change.Kind = savedKindRemoveSection
+	case *moveSectionCommand:
+		change.Kind = savedKindMoveSection
+	case *command.CompoundCommand:
+		change.Kind = savedKindCompound
+	default:
+		change.Kind = fmt.Sprintf("%T", cmd)
+	}
+	return change
+}
+
+// historyWriter turns commands into their saved form.
+// Components are referenced by their index in the history's component table.
+type historyWriter struct {
+	gadgets map[*component.Gadget]int      // index in the saved diagram of live gadgets
+	asses   map[*component.Association]int // index in the saved diagram of live associations
+	refs    map[component.Component]int
+	history *utils.SavedHistory
+}
+
+// historyReader rebuilds commands from their saved form.
+type historyReader struct {
+	diagram    *UMLDiagram
+	components []component.Component
+}
+
+func (ud *UMLDiagram) saveHistory(gadgets map[*component.Gadget]int, asses map[*component.Association]int) *utils.SavedHistory {
+	w := &historyWriter{
+		gadgets: gadgets,
+		asses:   asses,
+		refs:    make(map[component.Component]int),
+		history: &utils.SavedHistory{
+			Components: make([]utils.SavedHistoryComponent, 0),
+			Undo:       make([]utils.SavedCommand, 0),
+			Redo:       make([]utils.SavedCommand, 0),
+		},
+	}
+	undo, redo := ud.cmdManager.GetHistory()
+	w.history.Undo = w.writeStack(undo)
+	w.history.Redo = w.writeStack(redo)
+	return w.history
+}
+
+func (ud *UMLDiagram) loadHistory(history utils.SavedHistory, gadgets map[int]*component.Gadget, asses map[int]*component.Association) duerror.DUError {
+	r := &historyReader{
+		diagram:    ud,
+		components: make([]component.Component, 0, len(history.Components)),
+	}
+	for i, saved := range history.Components {
+		c, err := r.readComponent(saved, gadgets, asses)
+		if err != nil {
+			return duerror.NewCorruptedFile(fmt.Sprintf("Error on loading %d-th component of history: %s", i, err.Error()))
+		}
+		r.components = append(r.components, c)
+	}
+	undo, err := r.readStack(history.Undo)
+	if err != nil {
+		return err
+	}
+	redo, err := r.readStack(history.Redo)
+	if err != nil {
+		return err
+	}
+	return ud.cmdManager.SetHistory(undo, redo)
+}
+
+// writeStack saves a stack from the top down and stops at the first command that cannot be saved,
+// since undoing or redoing past it would start from a wrong state.
+func (w *historyWriter) writeStack(stack []command.Command) []utils.SavedCommand {
+	saved := make([]utils.SavedCommand, 0, len(stack))
+	for i := len(stack) - 1; i >= 0; i-- {
+		cmd, err := w.writeCommand(stack[i])
+		if err != nil {
+			break
+		}
+		saved = append(saved, cmd)
+	}
+	// back to bottom-up order
+	for i, j := 0, len(saved)-1; i < j; i, j = i+1, j-1 {
+		saved[i], saved[j] = saved[j], saved[i]
+	}
+	return saved
+}
+
+func (w *historyWriter) writeCommand(cmd command.Command) (utils.SavedCommand, duerror.DUError) {
+	saved := utils.SavedCommand{
+		Before: cmd.GetBefore().Format(time.RFC3339Nano),
+		After:  cmd.GetAfter().Format(time.RFC3339Nano),
+	}
+	var err duerror.DUError
+	switch cmd := cmd.(type) {
+	case *addComponentCommand:
+		saved.Kind = savedKindAdd
+		saved.Components, err = w.refList(cmd.component)
+	case *removeSelectedComponentCommand:
+		saved.Kind = savedKindRemove
+		saved.Components, err = w.refSet(cmd.components)
+	case *selectAllCommand:
+		saved.Kind = savedKindSelect
+		saved.Components, err = w.refSet(cmd.components)
+		if err == nil {
+			saved.NewValue, err = marshalValue(cmd.newValue)
+		}
+	case *setterCommand:
+		saved.Kind = savedKindSetter
+		saved.Property = cmd.property
+		saved.Section = cmd.section
+		saved.Index = cmd.index
+		saved.Components, err = w.refList(cmd.component)
+		if err == nil {
+			saved.OldValue, err = marshalValue(cmd.oldValue)
+		}
+		if err == nil {
+			saved.NewValue, err = marshalValue(cmd.newValue)
+		}
+	case *moveGadgetCommand:
+		saved.Kind = savedKindMove
+		saved.Components, err = w.refList(cmd.gadget)
+		if err == nil {
+			saved.OldValue, err = marshalValue(cmd.oldPoint.String())
+		}
+		if err == nil {
+			saved.NewValue, err = marshalValue(cmd.newPoint.String())
+		}
+		if err == nil {
+			saved.Parents, err = w.refParents(cmd.parents)
+		}
+	case *moveGadgetsCommand:
+		saved.Kind = savedKindMoveGadgets
+		comps := make(map[component.Component]bool, len(cmd.gadgets))
+		for g := range cmd.gadgets {
+			comps[g] = true
+		}
+		saved.Components, err = w.refSet(comps)
+		if err == nil {
+			saved.NewValue, err = marshalValue(cmd.offset.String())
+		}
+		if err == nil {
+			saved.Parents, err = w.refParents(cmd.parents)
+		}
+	case *resizeGadgetsCommand:
+		saved.Kind = savedKindResize
+		gadgets := make([]component.Component, 0, len(cmd.oldSizes))
+		oldSizes := make([][2]int, 0, len(cmd.oldSizes))
+		for g, size := range cmd.oldSizes {
+			gadgets = append(gadgets, g)
+			oldSizes = append(oldSizes, size)
+		}
+		saved.Components, err = w.refList(gadgets...)
+		if err == nil {
+			saved.OldValue, err = marshalValue(oldSizes)
+		}
+		if err == nil {
+			saved.NewValue, err = marshalValue(cmd.newSize)
+		}
+	case *setParentStartCommand:
+		saved.Kind = savedKindSetParentStart
+		saved.Components, err = w.refList(cmd.association, cmd.stNew, cmd.stOld)
+		if err == nil {
+			saved.OldValue, err = marshalValue(cmd.stRatioOld)
+		}
+		if err == nil {
+			saved.NewValue, err = marshalValue(cmd.stRatioNew)
+		}
+	case *setParentEndCommand:
+		saved.Kind = savedKindSetParentEnd
+		saved.Components, err = w.refList(cmd.association, cmd.enNew, cmd.enOld)
+		if err == nil {
+			saved.OldValue, err = marshalValue(cmd.enRatioOld)
+		}
+		if err == nil {
+			saved.NewValue, err = marshalValue(cmd.enRatioNew)
+		}
+	case *addAttributeGadgetCommand:
+		saved.Kind = savedKindAddAttributeGadget
+		saved.Section, saved.Index, saved.Content = cmd.section, cmd.index, cmd.content
+		saved.Components, err = w.refList(cmd.gadget)
+	case *removeAttributeGadgetCommand:
+		saved.Kind = savedKindRemoveAttributeGadget
+		saved.Section, saved.Index, saved.Content = cmd.section, cmd.index, cmd.content
+		saved.Components, err = w.refList(cmd.gadget)
+	case *addAttributeAssociationCommand:
+		saved.Kind = savedKindAddAttributeAssociation
+		saved.Index, saved.Content, saved.Ratio = cmd.index, cmd.content, cmd.ratio
+		saved.Components, err = w.refList(cmd.association)
+	case *removeAttributeAssociationCommand:
+		saved.Kind = savedKindRemoveAttributeAssociation
+		saved.Index, saved.Content, saved.Ratio = cmd.index, cmd.content, cmd.ratio
+		saved.Components, err = w.refList(cmd.association)
+	case *diagramSetterCommand:
+		saved.Kind = savedKindDiagramSetter
+		saved.Property = cmd.property
+		saved.OldValue, err = marshalValue(cmd.oldValue)
+		if err == nil {
+			saved.NewValue, err = marshalValue(cmd.newValue)
+		}
+	case *addSectionCommand:
+		saved.Kind = savedKindAddSection
+		saved.Section, saved.Content = cmd.section, cmd.name
+		saved.Components, err = w.refList(cmd.gadget)
+	case *removeSectionCommand:
+		saved.Kind = savedKindRemoveSection
+		saved.Section, saved.Content = cmd.section, cmd.name
+		saved.Components, err = w.refList(cmd.gadget)
+		if err == nil {
+			saved.OldValue, err = marshalValue(cmd.collapsed)
+		}
+		if err == nil {
+			saved.NewValue, err = marshalValue(cmd.attributes)
+		}
+	case *moveSectionCommand:
+		saved.Kind = savedKindMoveSection
+		saved.Section, saved.Index = cmd.from, cmd.to
+		saved.Components, err = w.refList(cmd.gadget)
+	case *command.CompoundCommand:
+		saved.Kind = savedKindCompound
+		saved.Commands = make([]utils.SavedCommand, 0, len(cmd.GetCommands()))
+		for _, c := range cmd.GetCommands() {
+			child, err := w.writeCommand(c)
+			if err != nil {
+				return utils.SavedCommand{}, err
+			}
+			saved.Commands = append(saved.Commands, child)
+		}
+	default:
+		return utils.SavedCommand{}, duerror.NewParsingError(fmt.Sprintf("command %T cannot be saved", cmd))
+	}
+	if err != nil {
+		return utils.SavedCommand{}, err
+	}
+	return saved, nil
+}
+
+func (w *historyWriter) refList(comps ...component.Component) ([]int, duerror.DUError) {
+	refs := make([]int, 0, len(comps))
+	for _, c := range comps {
+		ref, err := w.ref(c)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+func (w *historyWriter) refSet(comps map[component.Component]bool) ([]int, duerror.DUError) {
+	refs := make([]int, 0, len(comps))
+	for c, ok := range comps {
+		if !ok {
+			continue
+		}
+		ref, err := w.ref(c)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// ref returns the index of c in the component table, adding it if needed.
+// Components that are not in the saved diagram are stored in full.
+func (w *historyWriter) ref(c component.Component) (int, duerror.DUError) {
+	if ref, ok := w.refs[c]; ok {
+		return ref, nil
+	}
+	var saved utils.SavedHistoryComponent
+	switch c := c.(type) {
+	case *component.Gadget:
+		saved.Kind = savedKindGadget
+		if index, ok := w.gadgets[c]; ok {
+			saved.Live = index
+		} else {
+			gad := c.ToSavedGadget()
+			saved.Live = -1
+			saved.Gadget = &gad
+			// the package comes first so that it is loaded when the gadget is
+			if p := c.GetParent(); p != nil {
+				ref, err := w.ref(p)
+				if err != nil {
+					return 0, err
+				}
+				saved.Parent = &ref
+			}
+		}
+	case *component.Association:
+		saved.Kind = savedKindAssociation
+		if index, ok := w.asses[c]; ok {
+			saved.Live = index
+		} else {
+			parents, err := w.refList(c.GetParentStart(), c.GetParentEnd())
+			if err != nil {
+				return 0, err
+			}
+			ass := c.ToSavedAssociation([2]int{parents[0], parents[1]})
+			saved.Live = -1
+			saved.Association = &ass
+		}
+	default:
+		return 0, duerror.NewParsingError(fmt.Sprintf("component %T cannot be saved", c))
+	}
+	w.refs[c] = len(w.history.Components)
+	w.history.Components = append(w.history.Components, saved)
+	return w.refs[c], nil
+}
+
+// refParents saves parent changes as the gadget, its old and its new package, -1 for none
+func (w *historyWriter) refParents(parents parentChanges) ([][3]int, duerror.DUError) {
+	saved := make([][3]int, 0, len(parents))
+	for g, change := range parents {
+		refs := [3]int{0, -1, -1}
+		var err duerror.DUError
+		for i, c := range []*component.Gadget{g, change[0], change[1]} {
+			if c == nil {
+				continue
+			}
+			if refs[i], err = w.ref(c); err != nil {
+				return nil, err
+			}
+		}
+		saved = append(saved, refs)
+	}
+	return saved, nil
+}
+
+func marshalValue(value any) (json.RawMessage, duerror.DUError) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, duerror.NewParsingError(err.Error())
+	}
+	return data, nil
+}
+
+func unmarshalValue[T any](data json.RawMessage) (T, duerror.DUError) {
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return value, duerror.NewParsingError(err.Error())
+	}
+	return value, nil
+}
+
+// decodePropertyValue decodes a saved setter value to the type its property setter expects.
+func decodePropertyValue(property string, data json.RawMessage) (any, duerror.DUError) {
+	switch property {
+	case propertyLayer, propertyAssType, propertyAttrSize, propertyAttrStyle, propertyAttrAlign, propertyMaxWidth, propertyStyleOverrides:
+		return unmarshalValue[int](data)
+	case propertyColor, propertyStyle, propertyLayerName, propertyAttrContent, propertyAttrFont, propertyAttrColor, propertySectionName:
+		return unmarshalValue[string](data)
+	case propertySectionCollapsed, propertyLocked:
+		return unmarshalValue[bool](data)
+	case propertyStereotypes:
+		return unmarshalValue[[]string](data)
+	case propertyTaggedValues:
+		return unmarshalValue[map[string]string](data)
+	default:
+		return nil, duerror.NewParsingError("unknown property " + property)
+	}
+}
+
+func decodeDiagramPropertyValue(property string, data json.RawMessage) (any, duerror.DUError) {
+	switch property {
+	case propertyFontFallbacks:
+		return unmarshalValue[[]string](data)
+	case propertyGridSize:
+		return unmarshalValue[int](data)
+	case propertyGridEnabled:
+		return unmarshalValue[bool](data)
+	case propertyLayers:
+		return unmarshalValue[[]utils.SavedLayer](data)
+	case propertyProperties:
+		return unmarshalValue[DiagramProperties](data)
+	default:
+		return nil, duerror.NewParsingError("unknown diagram property " + property)
+	}
+}
+
+func (r *historyReader) readComponent(saved utils.SavedHistoryComponent, gadgets map[int]*component.Gadget, asses map[int]*component.Association) (component.Component, duerror.DUError) {
+	ud := r.diagram
+	switch saved.Kind {
+	case savedKindGadget:
+		if saved.Live >= 0 {
+			g, ok := gadgets[saved.Live]
+			if !ok {
+				return nil, duerror.NewCorruptedFile(fmt.Sprintf("gadget %d not found in diagram", saved.Live))
+			}
+			return g, nil
+		}
+		if saved.Gadget == nil {
+			return nil, duerror.NewCorruptedFile("removed gadget is not saved")
+		}
+		g, err := component.FromSavedGadget(*saved.Gadget)
+		if err != nil {
+			return nil, err
+		}
+		if err, _ := ud.loadGadgetAttributes(g, *saved.Gadget); err != nil {
+			return nil, err
+		}
+		if err = g.RegisterUpdateParentDraw(ud.updateDrawData); err != nil {
+			return nil, err
+		}
+		if saved.Parent != nil {
+			p, err := r.gadget(*saved.Parent)
+			if err != nil {
+				return nil, err
+			}
+			// not in the diagram, it goes back in its package once added back
+			if err = g.SetParent(p); err != nil {
+				return nil, err
+			}
+			if err = g.DetachFromParent(); err != nil {
+				return nil, err
+			}
+		}
+		return g, nil
+	case savedKindAssociation:
+		if saved.Live >= 0 {
+			a, ok := asses[saved.Live]
+			if !ok {
+				return nil, duerror.NewCorruptedFile(fmt.Sprintf("association %d not found in diagram", saved.Live))
+			}
+			return a, nil
+		}
+		if saved.Association == nil || len(saved.Association.Parents) != 2 {
+			return nil, duerror.NewCorruptedFile("removed association is not saved")
+		}
+		st, err := r.gadget(saved.Association.Parents[0])
+		if err != nil {
+			return nil, err
+		}
+		en, err := r.gadget(saved.Association.Parents[1])
+		if err != nil {
+			return nil, err
+		}
+		a, err := component.FromSavedAssociation(*saved.Association, [2]*component.Gadget{st, en})
+		if err != nil {
+			return nil, err
+		}
+		if err, _ := ud.loadAssAttributes(a, saved.Association.Attributes); err != nil {
+			return nil, err
+		}
+		if err = a.RegisterUpdateParentDraw(ud.updateDrawData); err != nil {
+			return nil, err
+		}
+		// not in the diagram, it observes its parents again once added back
+		if err = a.UnregisterAsObserver(); err != nil {
+			return nil, err
+		}
+		return a, nil
+	default:
+		return nil, duerror.NewCorruptedFile("unknown component kind " + saved.Kind)
+	}
+}
+
+func (r *historyReader) readStack(saved []utils.SavedCommand) ([]command.Command, duerror.DUError) {
+	stack := make([]command.Command, 0, len(saved))
+	for i, s := range saved {
+		cmd, err := r.readCommand(s)
+		if err != nil {
+			return nil, duerror.NewCorruptedFile(fmt.Sprintf("Error on loading %d-th command of history: %s", i, err.Error()))
+		}
+		stack = append(stack, cmd)
+	}
+	return stack, nil
+}
+
+func (r *historyReader) readCommand(saved utils.SavedCommand) (command.Command, duerror.DUError) {
+	before, err := time.Parse(time.RFC3339Nano, saved.Before)
+	if err != nil {
+		return nil, duerror.NewParsingError(err.Error())
+	}
+	after, err := time.Parse(time.RFC3339Nano, saved.After)
+	if err != nil {
+		return nil, duerror.NewParsingError(err.Error())
+	}
+	base := baseCommand{diagram: r.diagram, before: before, after: after}
+
+	switch saved.Kind {
+	case savedKindAdd:
+		c, err := r.component(saved.Components, 0)
+		if err != nil {
+			return nil, err
+		}
+		return &addComponentCommand{baseCommand: base, component: c}, nil
+	case savedKindRemove, savedKindSelect:
+		comps := make(map[component.Component]bool, len(saved.Components))
+		for i := range saved.Components {
+			c, err := r.component(saved.Components, i)
+			if err != nil {
+				return nil, err
+			}
+			comps[c] = true
+		}
+		if saved.Kind == savedKindRemove {
+			return &removeSelectedComponentCommand{baseCommand: base, components: comps}, nil
+		}
+		newValue, err := unmarshalValue[bool](saved.NewValue)
+		if err != nil {
+			return nil, err
+		}
+		return &selectAllCommand{baseCommand: base, components: comps, newValue: newValue}, nil
+	case savedKindSetter:
+		c, err := r.component(saved.Components, 0)
+		if err != nil {
+			return nil, err
+		}
+		oldValue, err := decodePropertyValue(saved.Property, saved.OldValue)
+		if err != nil {
+			return nil, err
+		}
+		newValue, err := decodePropertyValue(saved.Property, saved.NewValue)
+		if err != nil {
+			return nil, err
+		}
+		cmd, err := r.diagram.newSetterCommand(c, saved.Property, saved.Section, saved.Index, oldValue, newValue)
+		if err != nil {
+			return nil, err
+		}
+		cmd.baseCommand = base
+		return cmd, nil
+	case savedKindMove:
+		g, err := r.gadgetAt(saved.Components, 0)
+		if err != nil {
+			return nil, err
+		}
+		oldPoint, err := unmarshalPoint(saved.OldValue)
+		if err != nil {
+			return nil, err
+		}
+		newPoint, err := unmarshalPoint(saved.NewValue)
+		if err != nil {
+			return nil, err
+		}
+		parents, err := r.readParents(saved.Parents)
+		if err != nil {
+			return nil, err
+		}
+		return &moveGadgetCommand{baseCommand: base, gadget: g, oldPoint: oldPoint, newPoint: newPoint, parents: parents}, nil
+	case savedKindMoveGadgets:
+		gadgets := make(map[*component.Gadget]bool, len(saved.Components))
+		for i := range saved.Components {
+			g, err := r.gadgetAt(saved.Components, i)
+			if err != nil {
+				return nil, err
+			}
+			gadgets[g] = true
+		}
+		offset, err := unmarshalPoint(saved.NewValue)
+		if err != nil {
+			return nil, err
+		}
+		parents, err := r.readParents(saved.Parents)
+		if err != nil {
+			return nil, err
+		}
+		return &moveGadgetsCommand{baseCommand: base, gadgets: gadgets, offset: offset, parents: parents}, nil
+	case savedKindResize:
+		oldSizes, err := unmarshalValue[[][2]int](saved.OldValue)
+		if err != nil {
+			return nil, err
+		}
+		if len(oldSizes) != len(saved.Components) {
+			return nil, duerror.NewCorruptedFile("resized gadgets do not match their sizes")
+		}
+		sizes := make(map[*component.Gadget][2]int, len(saved.Components))
+		for i := range saved.Components {
+			g, err := r.gadgetAt(saved.Components, i)
+			if err != nil {
+				return nil, err
+			}
+			sizes[g] = oldSizes[i]
+		}
+		newSize, err := unmarshalValue[[2]int](saved.NewValue)
+		if err != nil {
+			return nil, err
+		}
+		return &resizeGadgetsCommand{baseCommand: base, oldSizes: sizes, newSize: newSize}, nil
+	case savedKindSetParentStart, savedKindSetParentEnd:
+		a, err := r.associationAt(saved.Components, 0)
+		if err != nil {
+			return nil, err
+		}
+		newParent, err := r.gadgetAt(saved.Components, 1)
+		if err != nil {
+			return nil, err
+		}
+		oldParent, err := r.gadgetAt(saved.Components, 2)
+		if err != nil {
+			return nil, err
+		}
+		oldRatio, err := unmarshalValue[[2]float64](saved.OldValue)
+		if err != nil {
+			return nil, err
+		}
+		newRatio, err := unmarshalValue[[2]float64](saved.NewValue)
+		if err != nil {
+			return nil, err
+		}
+		if saved.Kind == savedKindSetParentStart {
+			return &setParentStartCommand{
+				baseCommand: base,
+				association: a,
+				stNew:       newParent,
+				stOld:       oldParent,
+				stRatioNew:  newRatio,
+				stRatioOld:  oldRatio,
+			}, nil
+		}
+		return &setParentEndCommand{
+			baseCommand: base,
+			association: a,
+			enNew:       newParent,
+			enOld:       oldParent,
+			enRatioNew:  newRatio,
+			enRatioOld:  oldRatio,
+		}, nil
+	case savedKindAddAttributeGadget, savedKindRemoveAttributeGadget:
+		g, err := r.gadgetAt(saved.Components, 0)
+		if err != nil {
+			return nil, err
+		}
+		if saved.Kind == savedKindAddAttributeGadget {
+			return &addAttributeGadgetCommand{
+				baseCommand: base,
+				gadget:      g,
+				content:     saved.Content,
+				section:     saved.Section,
+				index:       saved.Index,
+			}, nil
+		}
+		return &removeAttributeGadgetCommand{
+			baseCommand: base,
+			gadget:      g,
+			content:     saved.Content,
+			section:     saved.Section,
+			index:       saved.Index,
+		}, nil
+	case savedKindAddAttributeAssociation, savedKindRemoveAttributeAssociation:
+		a, err := r.associationAt(saved.Components, 0)
+		if err != nil {
+			return nil, err
+		}
+		if saved.Kind == savedKindAddAttributeAssociation {
+			return &addAttributeAssociationCommand{
+				baseCommand: base,
+				association: a,
+				content:     saved.Content,
+				ratio:       saved.Ratio,
+				index:       saved.Index,
+			}, nil
+		}
+		return &removeAttributeAssociationCommand{
+			baseCommand: base,
+			association: a,
+			content:     saved.Content,
+			ratio:       saved.Ratio,
+			index:       saved.Index,
+		}, nil
+	case savedKindDiagramSetter:
+		oldValue, err := decodeDiagramPropertyValue(saved.Property, saved.OldValue)
+		if err != nil {
+			return nil, err
+		}
+		newValue, err := decodeDiagramPropertyValue(saved.Property, saved.NewValue)
+		if err != nil {
+			return nil, err
+		}
+		return &diagramSetterCommand{baseCommand: base, property: saved.Property, oldValue: oldValue, newValue: newValue}, nil
+	case savedKindAddSection, savedKindRemoveSection, savedKindMoveSection:
+		g, err := r.gadgetAt(saved.Components, 0)
+		if err != nil {
+			return nil, err
+		}
+		switch saved.Kind {
+		case savedKindAddSection:
+			return &addSectionCommand{baseCommand: base, gadget: g, section: saved.Section, name: saved.Content}, nil
+		case savedKindMoveSection:
+			return &moveSectionCommand{baseCommand: base, gadget: g, from: saved.Section, to: saved.Index}, nil
+		}
+		collapsed, err := unmarshalValue[bool](saved.OldValue)
+		if err != nil {
+			return nil, err
+		}
+		atts, err := unmarshalValue[[]utils.SavedAtt](saved.NewValue)
+		if err != nil {
+			return nil, err
+		}
+		return &removeSectionCommand{
+			baseCommand: base,
+			gadget:      g,
+			section:     saved.Section,
+			name:        saved.Content,
+			collapsed:   collapsed,
+			attributes:  atts,
+		}, nil
+	case savedKindCompound:
+		cmds := make([]command.Command, 0, len(saved.Commands))
+		for _, child := range saved.Commands {
+			cmd, err := r.readCommand(child)
+			if err != nil {
+				return nil, err
+			}
+			cmds = append(cmds, cmd)
+		}
+		return command.NewCompoundCommand(cmds, before, after), nil
+	default:
+		return nil, duerror.NewParsingError("unknown command kind " + saved.Kind)
+	}
+}
+
+func unmarshalPoint(data json.RawMessage) (utils.Point, duerror.DUError) {
+	str, err := unmarshalValue[string](data)
+	if err != nil {
+		return utils.Point{}, err
+	}
+	return utils.FromString(str)
+}
+
+func (r *historyReader) component(refs []int, i int) (component.Component, duerror.DUError) {
+	if i >= len(refs) || refs[i] < 0 || refs[i] >= len(r.components) {
+		return nil, duerror.NewCorruptedFile("component reference out of range")
+	}
+	return r.components[refs[i]], nil
+}
+
+func (r *historyReader) gadget(ref int) (*component.Gadget, duerror.DUError) {
+	return r.gadgetAt([]int{ref}, 0)
+}
+
+func (r *historyReader) gadgetAt(refs []int, i int) (*component.Gadget, duerror.DUError) {
+	c, err := r.component(refs, i)
+	if err != nil {
+		return nil, err
+	}
+	g, ok := c.(*component.Gadget)
+	if !ok {
+		return nil, duerror.NewCorruptedFile("component reference is not a gadget")
+	}
+	return g, nil
+}
+
+// readParents reads the parent changes saved by refParents
+func (r *historyReader) readParents(saved [][3]int) (parentChanges, duerror.DUError) {
+	if len(saved) == 0 {
+		return nil, nil
+	}
+	parents := make(parentChanges, len(saved))
+	for _, refs := range saved {
+		g, err := r.gadget(refs[0])
+		if err != nil {
+			return nil, err
+		}
+		var change [2]*component.Gadget
+		for i := range change {
+			if refs[i+1] < 0 {
+				continue
+			}
+			if change[i], err = r.gadget(refs[i+1]); err != nil {
+				return nil, err
+			}
+		}
+		parents[g] = change
+	}
+	return parents, nil
+}
+
+func (r *historyReader) associationAt(refs []int, i int) (*component.Association, duerror.DUError) {
+	c, err := r.component(refs, i)
+	if err != nil {
+		return nil, err
+	}
+	a, ok := c.(*component.Association)
+	if !ok {
+		return nil, duerror.NewCorruptedFile("component reference is not an association")
+	}
+	return a, nil
+}
@@ -0,0 +1,331 @@
+package umldiagram
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"Dr.uml/backend/command"
+	"Dr.uml/backend/component"
+	"Dr.uml/backend/components"
+	"Dr.uml/backend/drawdata"
+	"Dr.uml/backend/utils"
+	"Dr.uml/backend/utils/duerror"
+)
+
+// Named layers group components into views of the diagram. Components without a layer name are on
+// the default layer, which is always visible and unlocked.
+// The components of a hidden layer are not drawn and cannot be hit, those of a locked layer are drawn
+// but cannot be selected, so they cannot be edited either.
+
+func (ud *UMLDiagram) GetLayers() []drawdata.Layer {
+	layers := make([]drawdata.Layer, 0, len(ud.layers))
+	for _, l := range ud.layers {
+		layers = append(layers, drawdata.Layer{Name: l.Name, Hidden: l.Hidden, Locked: l.Locked})
+	}
+	return layers
+}
+
+// AddLayer adds an empty named layer
+func (ud *UMLDiagram) AddLayer(name string) duerror.DUError {
+	if err := ud.validateNewLayerName(name); err != nil {
+		return err
+	}
+	layers := append(slices.Clone(ud.layers), utils.SavedLayer{Name: name})
+	return ud.cmdManager.Execute(ud.newLayersCommand(layers))
+}
+
+// RemoveLayer removes a named layer, its components move to the default layer
+func (ud *UMLDiagram) RemoveLayer(name string) duerror.DUError {
+	index, err := ud.findLayer(name)
+	if err != nil {
+		return err
+	}
+	cmds, err := ud.moveToLayerCommands(ud.componentsInLayer(name), "")
+	if err != nil {
+		return err
+	}
+	layers := slices.Delete(slices.Clone(ud.layers), index, index+1)
+	return ud.executeAll(append(cmds, ud.newLayersCommand(layers)))
+}
+
+// RenameLayer renames a named layer, its components follow
+func (ud *UMLDiagram) RenameLayer(name string, newName string) duerror.DUError {
+	index, err := ud.findLayer(name)
+	if err != nil {
+		return err
+	}
+	if newName == name {
+		return nil
+	}
+	if err := ud.validateNewLayerName(newName); err != nil {
+		return err
+	}
+	cmds, err := ud.moveToLayerCommands(ud.componentsInLayer(name), newName)
+	if err != nil {
+		return err
+	}
+	layers := slices.Clone(ud.layers)
+	layers[index].Name = newName
+	return ud.executeAll(append(cmds, ud.newLayersCommand(layers)))
+}
+
+// SetLayerHidden hides or shows the components of a named layer, hidden components are unselected
+func (ud *UMLDiagram) SetLayerHidden(name string, hidden bool) duerror.DUError {
+	return ud.setLayerState(name, func(l *utils.SavedLayer) { l.Hidden = hidden })
+}
+
+// SetLayerLocked locks or unlocks the components of a named layer, locked components are unselected
+func (ud *UMLDiagram) SetLayerLocked(name string, locked bool) duerror.DUError {
+	return ud.setLayerState(name, func(l *utils.SavedLayer) { l.Locked = locked })
+}
+
+// SetLayerNameComponent moves the selected components to a named layer, "" for the default layer
+func (ud *UMLDiagram) SetLayerNameComponent(name string) duerror.DUError {
+	comps, err := ud.getSelectedComponents()
+	if err != nil {
+		return err
+	}
+	if _, err := ud.findLayer(name); name != "" && err != nil {
+		return err
+	}
+	if !ud.acceptsComponents(name) {
+		return duerror.NewInvalidArgumentError(fmt.Sprintf("layer %q is hidden or locked", name))
+	}
+	cmds, err := ud.moveToLayerCommands(comps, name)
+	if err != nil {
+		return err
+	}
+	return ud.executeAll(cmds)
+}
+
+func (ud *UMLDiagram) findLayer(name string) (int, duerror.DUError) {
+	index := slices.IndexFunc(ud.layers, func(l utils.SavedLayer) bool { return l.Name == name })
+	if index < 0 {
+		return -1, duerror.NewInvalidArgumentError(fmt.Sprintf("layer %q does not exist", name))
+	}
+	return index, nil
+}
+
+func (ud *UMLDiagram) validateNewLayerName(name string) duerror.DUError {
+	if name == "" {
+		return duerror.NewInvalidArgumentError("layer name is empty")
+	}
+	if _, err := ud.findLayer(name); err == nil {
+		return duerror.NewInvalidArgumentError(fmt.Sprintf("layer %q already exists", name))
+	}
+	return nil
+}
+
+func (ud *UMLDiagram) setLayerState(name string, change func(l *utils.SavedLayer)) duerror.DUError {
+	index, err := ud.findLayer(name)
+	if err != nil {
+		return err
+	}
+	layers := slices.Clone(ud.layers)
+	change(&layers[index])
+	cmds := make([]command.Command, 0, 2)
+	if layers[index].Hidden || layers[index].Locked {
+		toUnselect := map[component.Component]bool{}
+		for _, c := range ud.componentsInLayer(name) {
+			if c.GetIsSelected() {
+				toUnselect[c] = true
+			}
+			g, ok := c.(*component.Gadget)
+			if !ok || !layers[index].Hidden {
+				continue
+			}
+			// the associations of a hidden gadget are hidden too
+			for _, a := range slices.Concat(ud.associations[g][0], ud.associations[g][1]) {
+				if a.GetIsSelected() {
+					toUnselect[a] = true
+				}
+			}
+		}
+		if len(toUnselect) > 0 {
+			cmds = append(cmds, &selectAllCommand{
+				baseCommand: baseCommand{
+					diagram: ud,
+					before:  ud.GetLastModified(),
+					after:   time.Now(),
+				},
+				components: toUnselect,
+				newValue:   false,
+			})
+		}
+	}
+	return ud.executeAll(append(cmds, ud.newLayersCommand(layers)))
+}
+
+func (ud *UMLDiagram) newLayersCommand(layers []utils.SavedLayer) command.Command {
+	return &diagramSetterCommand{
+		baseCommand: baseCommand{
+			diagram: ud,
+			before:  ud.GetLastModified(),
+			after:   time.Now(),
+		},
+		property: propertyLayers,
+		oldValue: slices.Clone(ud.layers),
+		newValue: layers,
+	}
+}
+
+func (ud *UMLDiagram) moveToLayerCommands(comps []component.Component, name string) ([]command.Command, duerror.DUError) {
+	cmds := make([]command.Command, 0, len(comps))
+	for _, c := range comps {
+		if c.GetLayerName() == name {
+			continue
+		}
+		cmd, err := ud.newSetterCommand(c, propertyLayerName, 0, 0, c.GetLayerName(), name)
+		if err != nil {
+			return nil, err
+		}
+		cmd.before = ud.GetLastModified()
+		cmd.after = time.Now()
+		cmds = append(cmds, cmd)
+	}
+	return cmds, nil
+}
+
+func (ud *UMLDiagram) componentsInLayer(name string) []component.Component {
+	comps := make([]component.Component, 0)
+	for _, c := range ud.componentsContainer.GetAll() {
+		if c.GetLayerName() == name {
+			comps = append(comps, c)
+		}
+	}
+	return comps
+}
+
+// layerState returns whether the named layer is hidden and whether it is locked, both false for the default layer
+func (ud *UMLDiagram) layerState(name string) (bool, bool) {
+	if name == "" {
+		return false, false
+	}
+	index, err := ud.findLayer(name)
+	if err != nil {
+		return false, false
+	}
+	return ud.layers[index].Hidden, ud.layers[index].Locked
+}
+
+// isFrozen reports whether c is locked or on a locked layer. Edits that sweep the whole diagram,
+// such as restyling and replacing text, leave such components as they are.
+func (ud *UMLDiagram) isFrozen(c component.Component) bool {
+	_, locked := ud.layerState(c.GetLayerName())
+	return locked || c.GetIsLocked()
+}
+
+// checkMovable fails if moving or removing the gadgets would change a frozen component: the gadgets
+// nested in them go along, and so do the associations of all of them
+func (ud *UMLDiagram) checkMovable(gadgets []*component.Gadget) duerror.DUError {
+	for _, g := range withDescendants(gadgets) {
+		if ud.isFrozen(g) {
+			return duerror.NewInvalidArgumentError("component is locked")
+		}
+		for a := range ud.getAllAssociationsInGadget(g) {
+			if ud.isFrozen(a) {
+				return duerror.NewInvalidArgumentError("component is locked")
+			}
+		}
+	}
+	return nil
+}
+
+// acceptsComponents reports whether components can be put on the named layer:
+// it is the default layer, or it exists and is neither hidden nor locked
+func (ud *UMLDiagram) acceptsComponents(name string) bool {
+	if name == "" {
+		return true
+	}
+	index, err := ud.findLayer(name)
+	return err == nil && !ud.layers[index].Hidden && !ud.layers[index].Locked
+}
+
+// isVisible reports whether c is drawn and can be hit. An association is hidden along with either of its ends.
+func (ud *UMLDiagram) isVisible(c component.Component) bool {
+	if hidden, _ := ud.layerState(c.GetLayerName()); hidden {
+		return false
+	}
+	if a, ok := c.(*component.Association); ok {
+		return ud.isVisible(a.GetParentStart()) && ud.isVisible(a.GetParentEnd())
+	}
+	return true
+}
+
+// isSelectable reports whether c can be selected, and so edited
+func (ud *UMLDiagram) isSelectable(c component.Component) bool {
+	_, locked := ud.layerState(c.GetLayerName())
+	return ud.isVisible(c) && !locked
+}
+
+// topmostAt returns the topmost component covering p for which keep is true, nil if there is none
+func (ud *UMLDiagram) topmostAt(p utils.Point, keep func(c component.Component) bool) (component.Component, duerror.DUError) {
+	// associations can be hit slightly off their path
+	reach := utils.Point{X: component.CoverThreshold, Y: component.CoverThreshold}
+	near, err := ud.componentsContainer.SearchIntersecting(utils.SubPoints(p, reach), utils.AddPoints(p, reach))
+	if err != nil {
+		return nil, err
+	}
+	var candidate component.Component
+	for _, c := range near {
+		if !keep(c) {
+			continue
+		}
+		cover, err := c.Cover(p)
+		if err != nil {
+			return nil, err
+		}
+		if cover && (candidate == nil || components.CompareLayer(c, candidate) > 0) {
+			candidate = c
+		}
+	}
+	return candidate, nil
+}
+
+// visibleGadgetAt returns the topmost visible gadget covering p, nil if there is none
+func (ud *UMLDiagram) visibleGadgetAt(p utils.Point) (*component.Gadget, duerror.DUError) {
+	c, err := ud.topmostAt(p, func(c component.Component) bool {
+		_, ok := c.(*component.Gadget)
+		return ok && ud.isVisible(c)
+	})
+	if err != nil || c == nil {
+		return nil, err
+	}
+	return c.(*component.Gadget), nil
+}
+
+// loadLayers sets the named layers of a file being loaded, components on a layer that is not
+// defined are moved to the default layer with a warning
+func (ud *UMLDiagram) loadLayers(layers []utils.SavedLayer) duerror.DUError {
+	for i, l := range layers {
+		if l.Name == "" {
+			return duerror.NewCorruptedFile("layer name is empty")
+		}
+		if slices.ContainsFunc(layers[:i], func(o utils.SavedLayer) bool { return o.Name == l.Name }) {
+			return duerror.NewCorruptedFile(fmt.Sprintf("layer %q is defined twice", l.Name))
+		}
+	}
+	ud.layers = slices.Clone(layers)
+	missing := make([]string, 0)
+	for _, c := range ud.componentsContainer.GetAll() {
+		name := c.GetLayerName()
+		if name == "" {
+			continue
+		}
+		if _, err := ud.findLayer(name); err == nil {
+			continue
+		}
+		if !slices.Contains(missing, name) {
+			missing = append(missing, name)
+		}
+		if err := c.SetLayerName(""); err != nil {
+			return err
+		}
+	}
+	slices.Sort(missing)
+	for _, name := range missing {
+		ud.loadWarnings = append(ud.loadWarnings, fmt.Sprintf("layer %q is not defined, its components are on the default layer", name))
+	}
+	return nil
+}
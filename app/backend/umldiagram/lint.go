@@ -0,0 +1,285 @@
+package umldiagram
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"Dr.uml/backend/component"
+	"Dr.uml/backend/drawdata"
+	"Dr.uml/backend/utils/duerror"
+)
+
+// Linting runs rules over a diagram and reports what they find. Rules are registered once, usually
+// from an init function, and run on every diagram. The built-in ones are registered first.
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// InterfaceStereotype marks the gadgets that are interfaces
+const InterfaceStereotype = "interface"
+
+// LintRule checks a diagram, Check returns what is wrong with it
+type LintRule struct {
+	Name  string
+	Check func(ud *UMLDiagram) []LintFinding
+}
+
+// LintFinding is a problem found by a rule, on a component or on the whole diagram if Component is nil
+type LintFinding struct {
+	Severity  Severity
+	Component component.Component
+	Message   string
+}
+
+// Diagnostic is a LintFinding as reported to the UI. Kind and ID identify the component:
+// Kind is "gadget" or "association" and ID the id of its draw data, which does not change while it exists.
+// Kind is empty and ID is 0 for the whole diagram.
+type Diagnostic struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Kind     string   `json:"kind"`
+	ID       uint64   `json:"id"`
+	Message  string   `json:"message"`
+}
+
+var lintRules = []LintRule{
+	{"duplicate-class-name", lintDuplicateClassNames},
+	{"cyclic-inheritance", lintCyclicInheritance},
+	{"interface-extends-class", lintInterfaceExtendsClass},
+	{"empty-header", lintEmptyHeaders},
+	{"overlapping-association-attributes", lintOverlappingAssociationAttributes},
+	{"unconnected-class", lintUnconnectedClasses},
+}
+
+// RegisterLintRule adds a rule run by Lint, rule names are unique
+func RegisterLintRule(rule LintRule) duerror.DUError {
+	if rule.Name == "" {
+		return duerror.NewInvalidArgumentError("rule name cannot be empty")
+	}
+	if rule.Check == nil {
+		return duerror.NewInvalidArgumentError("rule has no check")
+	}
+	if slices.ContainsFunc(lintRules, func(r LintRule) bool { return r.Name == rule.Name }) {
+		return duerror.NewInvalidArgumentError(fmt.Sprintf("rule %q is already registered", rule.Name))
+	}
+	lintRules = append(lintRules, rule)
+	return nil
+}
+
+// LintRuleNames returns the names of the registered rules in the order they run
+func LintRuleNames() []string {
+	names := make([]string, 0, len(lintRules))
+	for _, r := range lintRules {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+// Lint runs the registered rules over the diagram, the diagnostics are sorted by severity, then by component
+func (ud *UMLDiagram) Lint() []Diagnostic {
+	res := make([]Diagnostic, 0)
+	for _, rule := range lintRules {
+		for _, f := range rule.Check(ud) {
+			d := Diagnostic{Rule: rule.Name, Severity: f.Severity, Message: f.Message}
+			d.Kind, d.ID = componentID(f.Component)
+			res = append(res, d)
+		}
+	}
+	rank := map[Severity]int{SeverityError: 0, SeverityWarning: 1, SeverityInfo: 2}
+	slices.SortStableFunc(res, func(a, b Diagnostic) int {
+		return cmp.Or(
+			cmp.Compare(rank[a.Severity], rank[b.Severity]),
+			cmp.Compare(a.Kind, b.Kind),
+			cmp.Compare(a.ID, b.ID),
+		)
+	})
+	return res
+}
+
+// GetComponents returns the components of the diagram from the bottom to the top, for lint rules
+func (ud *UMLDiagram) GetComponents() []component.Component {
+	return ud.componentsByLayer()
+}
+
+// componentID returns the kind of a component and the id of its draw data, empty and 0 for nil
+func componentID(c component.Component) (string, uint64) {
+	switch c := c.(type) {
+	case *component.Gadget:
+		return savedKindGadget, c.GetCreation()
+	case *component.Association:
+		return savedKindAssociation, c.GetCreation()
+	}
+	return "", 0
+}
+
+// HeaderOf returns the name of a gadget, the content of the first attribute of its header, empty if it has none
+func HeaderOf(g *component.Gadget) string {
+	atts := g.GetAttributes()
+	if len(atts) == 0 || len(atts[0]) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(atts[0][0].GetContent())
+}
+
+func (ud *UMLDiagram) gadgetsAndAssociations() ([]*component.Gadget, []*component.Association) {
+	gadgets := make([]*component.Gadget, 0)
+	associations := make([]*component.Association, 0)
+	for _, c := range ud.componentsByLayer() {
+		switch c := c.(type) {
+		case *component.Gadget:
+			gadgets = append(gadgets, c)
+		case *component.Association:
+			associations = append(associations, c)
+		}
+	}
+	return gadgets, associations
+}
+
+// lintDuplicateClassNames finds classes named like another class of the same package
+func lintDuplicateClassNames(ud *UMLDiagram) []LintFinding {
+	type key struct {
+		parent *component.Gadget
+		name   string
+	}
+	groups := make(map[key][]*component.Gadget)
+	gadgets, _ := ud.gadgetsAndAssociations()
+	for _, g := range gadgets {
+		if g.GetGadgetType() != component.Class || HeaderOf(g) == "" {
+			continue
+		}
+		k := key{g.GetParent(), HeaderOf(g)}
+		groups[k] = append(groups[k], g)
+	}
+	res := make([]LintFinding, 0)
+	for _, g := range gadgets {
+		group := groups[key{g.GetParent(), HeaderOf(g)}]
+		if len(group) > 1 && slices.Contains(group, g) {
+			res = append(res, LintFinding{SeverityError, g, fmt.Sprintf("class name %q is used by %d classes", HeaderOf(g), len(group))})
+		}
+	}
+	return res
+}
+
+// lintCyclicInheritance finds extensions that are part of a cycle
+func lintCyclicInheritance(ud *UMLDiagram) []LintFinding {
+	_, associations := ud.gadgetsAndAssociations()
+	parents := make(map[*component.Gadget][]*component.Gadget)
+	for _, a := range associations {
+		if a.GetAssType() == component.Extension {
+			parents[a.GetParentStart()] = append(parents[a.GetParentStart()], a.GetParentEnd())
+		}
+	}
+	reaches := func(from, to *component.Gadget) bool {
+		seen := map[*component.Gadget]bool{from: true}
+		stack := []*component.Gadget{from}
+		for len(stack) > 0 {
+			g := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if g == to {
+				return true
+			}
+			for _, p := range parents[g] {
+				if !seen[p] {
+					seen[p] = true
+					stack = append(stack, p)
+				}
+			}
+		}
+		return false
+	}
+	res := make([]LintFinding, 0)
+	for _, a := range associations {
+		if a.GetAssType() == component.Extension && reaches(a.GetParentEnd(), a.GetParentStart()) {
+			res = append(res, LintFinding{SeverityError, a, fmt.Sprintf("%q inherits from itself", HeaderOf(a.GetParentStart()))})
+		}
+	}
+	return res
+}
+
+// lintInterfaceExtendsClass finds interfaces extending a gadget that is not an interface
+func lintInterfaceExtendsClass(ud *UMLDiagram) []LintFinding {
+	_, associations := ud.gadgetsAndAssociations()
+	res := make([]LintFinding, 0)
+	for _, a := range associations {
+		child, parent := a.GetParentStart(), a.GetParentEnd()
+		if a.GetAssType() != component.Extension || !component.HasStereotype(child, InterfaceStereotype) ||
+			component.HasStereotype(parent, InterfaceStereotype) || parent.GetGadgetType() != component.Class {
+			continue
+		}
+		res = append(res, LintFinding{SeverityError, a, fmt.Sprintf("interface %q extends class %q", HeaderOf(child), HeaderOf(parent))})
+	}
+	return res
+}
+
+// lintEmptyHeaders finds gadgets without a name
+func lintEmptyHeaders(ud *UMLDiagram) []LintFinding {
+	gadgets, _ := ud.gadgetsAndAssociations()
+	res := make([]LintFinding, 0)
+	for _, g := range gadgets {
+		if HeaderOf(g) == "" {
+			res = append(res, LintFinding{SeverityWarning, g, "the header is empty"})
+		}
+	}
+	return res
+}
+
+// lintOverlappingAssociationAttributes finds attributes of associations drawn over each other.
+// An attribute is drawn at its ratio along the line from the start to the end, a margin to the right of it
+// and vertically centered on it.
+func lintOverlappingAssociationAttributes(ud *UMLDiagram) []LintFinding {
+	type box struct {
+		association *component.Association
+		content     string
+		min, max    [2]int
+	}
+	_, associations := ud.gadgetsAndAssociations()
+	boxes := make([]box, 0)
+	for _, a := range associations {
+		if !ud.isVisible(a) {
+			continue
+		}
+		dd := a.GetDrawData().(drawdata.Association)
+		for _, att := range dd.Attributes {
+			x := dd.StartX + int(float64(dd.EndX-dd.StartX)*att.Ratio) + drawdata.Margin
+			y := dd.StartY + int(float64(dd.EndY-dd.StartY)*att.Ratio) - att.Height/2
+			width := 0
+			for _, line := range att.Lines {
+				width = max(width, line.Width)
+			}
+			boxes = append(boxes, box{a, att.Content, [2]int{x, y}, [2]int{x + width, y + att.Height}})
+		}
+	}
+	res := make([]LintFinding, 0)
+	for i, b := range boxes {
+		for _, o := range boxes[i+1:] {
+			if b.min[0] < o.max[0] && o.min[0] < b.max[0] && b.min[1] < o.max[1] && o.min[1] < b.max[1] {
+				res = append(res, LintFinding{SeverityWarning, b.association, fmt.Sprintf("attribute %q overlaps attribute %q", b.content, o.content)})
+			}
+		}
+	}
+	return res
+}
+
+// lintUnconnectedClasses finds classes without any association
+func lintUnconnectedClasses(ud *UMLDiagram) []LintFinding {
+	gadgets, associations := ud.gadgetsAndAssociations()
+	connected := make(map[*component.Gadget]bool)
+	for _, a := range associations {
+		connected[a.GetParentStart()] = true
+		connected[a.GetParentEnd()] = true
+	}
+	res := make([]LintFinding, 0)
+	for _, g := range gadgets {
+		if g.GetGadgetType() == component.Class && !connected[g] {
+			res = append(res, LintFinding{SeverityInfo, g, fmt.Sprintf("class %q has no relationships", HeaderOf(g))})
+		}
+	}
+	return res
+}
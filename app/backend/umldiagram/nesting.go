@@ -0,0 +1,156 @@
+package umldiagram
+
+import (
+	"fmt"
+
+	"Dr.uml/backend/component"
+	"Dr.uml/backend/components"
+	"Dr.uml/backend/drawdata"
+	"Dr.uml/backend/utils"
+	"Dr.uml/backend/utils/duerror"
+)
+
+// Packages contain other gadgets. A gadget dropped with its center inside a package goes into it,
+// dragged out of it goes back to the package around that one, if any.
+// Gadgets in a package move with it and are removed, copied and pasted with it.
+
+// parentChanges maps gadgets to their old and new package, nil for the top level
+type parentChanges map[*component.Gadget][2]*component.Gadget
+
+// merge records the changes of next after those of c, keeping the packages from before the first one
+func (c parentChanges) merge(next parentChanges) parentChanges {
+	if len(next) == 0 {
+		return c
+	}
+	if c == nil {
+		c = make(parentChanges, len(next))
+	}
+	for g, change := range next {
+		if prev, ok := c[g]; ok {
+			change[0] = prev[0]
+		}
+		if change[0] == change[1] {
+			delete(c, g)
+			continue
+		}
+		c[g] = change
+	}
+	return c
+}
+
+// apply puts every gadget in its old package (which = 0) or its new one (which = 1)
+func (c parentChanges) apply(which int) duerror.DUError {
+	for g, change := range c {
+		if err := g.SetParent(change[which]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropParents returns the packages the moved gadgets end up in when moved by offset.
+// Gadgets in a moved package move along and keep their package.
+func (ud *UMLDiagram) dropParents(moved map[*component.Gadget]bool, offset utils.Point) (parentChanges, duerror.DUError) {
+	inMoved := func(g *component.Gadget) bool {
+		for ; g != nil; g = g.GetParent() {
+			if moved[g] {
+				return true
+			}
+		}
+		return false
+	}
+	var changes parentChanges
+	for g := range moved {
+		if inMoved(g.GetParent()) {
+			continue
+		}
+		dd := g.GetDrawData().(drawdata.Gadget)
+		center := utils.AddPoints(utils.Point{X: dd.X + dd.Width/2, Y: dd.Y + dd.Height/2}, offset)
+		p, err := ud.packageAt(center, inMoved)
+		if err != nil {
+			return nil, err
+		}
+		if p == g.GetParent() {
+			continue
+		}
+		if changes == nil {
+			changes = make(parentChanges)
+		}
+		changes[g] = [2]*component.Gadget{g.GetParent(), p}
+	}
+	return changes, nil
+}
+
+// packageAt returns the innermost visible package around p, leaving out the gadgets for which skip is true
+func (ud *UMLDiagram) packageAt(p utils.Point, skip func(g *component.Gadget) bool) (*component.Gadget, duerror.DUError) {
+	near, err := ud.componentsContainer.SearchIntersecting(p, p)
+	if err != nil {
+		return nil, err
+	}
+	var candidate *component.Gadget
+	for _, c := range near {
+		g, ok := c.(*component.Gadget)
+		if !ok || g.GetGadgetType() != component.Package || skip(g) || !ud.isVisible(g) {
+			continue
+		}
+		if !g.CoversWithout(p, skip) {
+			continue
+		}
+		if candidate == nil || components.CompareLayer(g, candidate) > 0 {
+			candidate = g
+		}
+	}
+	return candidate, nil
+}
+
+// withDescendants returns the gadgets and all the gadgets nested in them
+func withDescendants(gadgets []*component.Gadget) []*component.Gadget {
+	res := make([]*component.Gadget, 0, len(gadgets))
+	seen := make(map[*component.Gadget]bool, len(gadgets))
+	var visit func(g *component.Gadget)
+	visit = func(g *component.Gadget) {
+		if seen[g] {
+			return
+		}
+		seen[g] = true
+		res = append(res, g)
+		for _, c := range g.GetChildren() {
+			visit(c)
+		}
+	}
+	for _, g := range gadgets {
+		visit(g)
+	}
+	return res
+}
+
+// saveChildren records in saved the children of the gadgets, which are at their index in saved.
+// Children that are not saved are left out.
+func saveChildren(indices map[*component.Gadget]int, saved []utils.SavedGad) {
+	for g, index := range indices {
+		for _, c := range g.GetChildren() {
+			if ci, ok := indices[c]; ok {
+				saved[index].Children = append(saved[index].Children, ci)
+			}
+		}
+	}
+}
+
+// loadChildren puts the loaded gadgets in the packages recorded by saveChildren
+func loadChildren(saved []utils.SavedGad, gadgets map[int]*component.Gadget) duerror.DUError {
+	for index, s := range saved {
+		for _, ci := range s.Children {
+			child, ok := gadgets[ci]
+			if !ok {
+				return duerror.NewInvalidArgumentError(fmt.Sprintf("%d-th gadget contains a missing gadget %d", index, ci))
+			}
+			if child.GetParent() != nil {
+				return duerror.NewInvalidArgumentError(fmt.Sprintf("gadget %d is in more than one package", ci))
+			}
+			if err := child.SetParent(gadgets[index]); err != nil {
+				return duerror.NewInvalidArgumentError(fmt.Sprintf("%d-th gadget cannot contain gadget %d: %s", index, ci, err.Error()))
+			}
+		}
+	}
+	return nil
+}
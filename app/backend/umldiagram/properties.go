@@ -0,0 +1,119 @@
+package umldiagram
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"Dr.uml/backend/drawdata"
+	"Dr.uml/backend/utils"
+	"Dr.uml/backend/utils/duerror"
+)
+
+// DiagramProperties describe a diagram. Created and Modified are kept by the diagram,
+// SetProperties ignores them.
+type DiagramProperties struct {
+	Title           string    `json:"title"`
+	Description     string    `json:"description"`
+	Author          string    `json:"author"`
+	Tags            []string  `json:"tags"`
+	BackgroundColor string    `json:"backgroundColor"`
+	GadgetColor     string    `json:"gadgetColor"` // color of new gadgets added without one
+	Created         time.Time `json:"created"`     // zero for diagrams saved before it was recorded
+	Modified        time.Time `json:"modified"`
+}
+
+func defaultProperties() DiagramProperties {
+	return DiagramProperties{
+		Tags:            []string{},
+		BackgroundColor: drawdata.DefaultDiagramColor,
+		GadgetColor:     drawdata.DefaultGadgetColor,
+	}
+}
+
+func (ud *UMLDiagram) GetProperties() DiagramProperties {
+	props := ud.properties
+	props.Tags = slices.Clone(ud.properties.Tags)
+	props.Created = ud.created
+	props.Modified = ud.GetLastModified()
+	return props
+}
+
+// SetProperties sets the title, description, author, tags and colors of the diagram as one undo step.
+// Tags are trimmed, empty and repeated ones are dropped.
+func (ud *UMLDiagram) SetProperties(props DiagramProperties) duerror.DUError {
+	if err := utils.ValidateHexColor(props.BackgroundColor); err != nil {
+		return err
+	}
+	if err := utils.ValidateHexColor(props.GadgetColor); err != nil {
+		return err
+	}
+	props.Tags = normalizeTags(props.Tags)
+	props.Created, props.Modified = time.Time{}, time.Time{}
+	return ud.cmdManager.Execute(&diagramSetterCommand{
+		baseCommand: baseCommand{
+			diagram: ud,
+			before:  ud.GetLastModified(),
+			after:   time.Now(),
+		},
+		property: propertyProperties,
+		oldValue: ud.properties,
+		newValue: props,
+	})
+}
+
+func normalizeTags(tags []string) []string {
+	res := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !slices.Contains(res, tag) {
+			res = append(res, tag)
+		}
+	}
+	return res
+}
+
+// saveProperties records the properties in a file being saved
+func (ud *UMLDiagram) saveProperties(res *utils.SavedDiagram) {
+	res.Title = ud.properties.Title
+	res.Description = ud.properties.Description
+	res.Author = ud.properties.Author
+	res.Tags = slices.Clone(ud.properties.Tags)
+	res.BackgroundColor = ud.properties.BackgroundColor
+	res.GadgetColor = ud.properties.GadgetColor
+	if !ud.created.IsZero() {
+		res.Created = ud.created.Format(time.RFC3339)
+	}
+}
+
+// loadProperties sets the properties of a file being loaded, files of older versions have none
+func (ud *UMLDiagram) loadProperties(file utils.SavedDiagram) duerror.DUError {
+	props := defaultProperties()
+	props.Title = file.Title
+	props.Description = file.Description
+	props.Author = file.Author
+	props.Tags = normalizeTags(file.Tags)
+	for _, color := range []struct {
+		saved string
+		value *string
+	}{{file.BackgroundColor, &props.BackgroundColor}, {file.GadgetColor, &props.GadgetColor}} {
+		if color.saved == "" {
+			continue
+		}
+		if err := utils.ValidateHexColor(color.saved); err != nil {
+			return duerror.NewCorruptedFile(fmt.Sprintf("invalid color %q", color.saved))
+		}
+		*color.value = color.saved
+	}
+	ud.properties = props
+	ud.created = time.Time{}
+	if file.Created != "" {
+		created, err := time.Parse(time.RFC3339, file.Created)
+		if err != nil {
+			return duerror.NewCorruptedFile(fmt.Sprintf("invalid creation time %q", file.Created))
+		}
+		ud.created = created
+	}
+	return nil
+}
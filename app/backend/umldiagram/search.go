@@ -0,0 +1,149 @@
+package umldiagram
+
+import (
+	"regexp"
+	"time"
+	"unicode/utf8"
+
+	"Dr.uml/backend/command"
+	"Dr.uml/backend/component"
+	"Dr.uml/backend/utils/duerror"
+)
+
+// SearchOptions tell how to look for Query in the content of the attributes
+type SearchOptions struct {
+	Query         string `json:"query"`
+	Regex         bool   `json:"regex"` // Query is a regular expression, as understood by regexp
+	CaseSensitive bool   `json:"caseSensitive"`
+}
+
+// SearchMatch is an attribute whose content matches a search. Kind and ID identify the component as
+// in a Diagnostic, Section is 0 for the attributes of associations.
+type SearchMatch struct {
+	Diagram   string   `json:"diagram"`
+	Kind      string   `json:"kind"`
+	ID        uint64   `json:"id"`
+	Section   int      `json:"section"`
+	Attribute int      `json:"attribute"`
+	Content   string   `json:"content"`
+	Ranges    [][2]int `json:"ranges"` // start and end of the matches in the content, in characters
+}
+
+// Validate tells whether the options can be searched with, e.g. before searching several diagrams
+func (opts SearchOptions) Validate() duerror.DUError {
+	_, err := opts.compile()
+	return err
+}
+
+func (opts SearchOptions) compile() (*regexp.Regexp, duerror.DUError) {
+	if opts.Query == "" {
+		return nil, duerror.NewInvalidArgumentError("search query cannot be empty")
+	}
+	expr := opts.Query
+	if !opts.Regex {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if !opts.CaseSensitive {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, duerror.NewInvalidArgumentError(err.Error())
+	}
+	return re, nil
+}
+
+// searchedAttribute is an attribute of a component, in the order they are searched
+type searchedAttribute struct {
+	component component.Component
+	section   int
+	index     int
+	content   string
+}
+
+// searchedAttributes returns the attributes of the components from the bottom to the top
+func (ud *UMLDiagram) searchedAttributes() []searchedAttribute {
+	res := make([]searchedAttribute, 0)
+	for _, c := range ud.componentsByLayer() {
+		switch c := c.(type) {
+		case *component.Gadget:
+			for section, atts := range c.GetAttributes() {
+				for index, att := range atts {
+					res = append(res, searchedAttribute{c, section, index, att.GetContent()})
+				}
+			}
+		case *component.Association:
+			for index, att := range c.GetAttributes() {
+				res = append(res, searchedAttribute{c, 0, index, att.GetContent()})
+			}
+		}
+	}
+	return res
+}
+
+// Search returns the attributes of the diagram whose content matches, from the bottom to the top
+func (ud *UMLDiagram) Search(opts SearchOptions) ([]SearchMatch, duerror.DUError) {
+	re, err := opts.compile()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]SearchMatch, 0)
+	for _, att := range ud.searchedAttributes() {
+		found := re.FindAllStringIndex(att.content, -1)
+		if len(found) == 0 {
+			continue
+		}
+		match := SearchMatch{
+			Diagram:   ud.GetName(),
+			Section:   att.section,
+			Attribute: att.index,
+			Content:   att.content,
+			Ranges:    make([][2]int, 0, len(found)),
+		}
+		match.Kind, match.ID = componentID(att.component)
+		for _, f := range found {
+			match.Ranges = append(match.Ranges, [2]int{
+				utf8.RuneCountInString(att.content[:f[0]]),
+				utf8.RuneCountInString(att.content[:f[1]]),
+			})
+		}
+		res = append(res, match)
+	}
+	return res, nil
+}
+
+// ReplaceAll replaces the matches of a search in the content of the attributes as one undo step and returns
+// how many attributes changed. With Regex, $1 and ${name} in replacement stand for the groups of the match.
+// Locked components and those on locked layers are left out.
+func (ud *UMLDiagram) ReplaceAll(opts SearchOptions, replacement string) (int, duerror.DUError) {
+	re, err := opts.compile()
+	if err != nil {
+		return 0, err
+	}
+	cmds := make([]command.Command, 0)
+	for _, att := range ud.searchedAttributes() {
+		if ud.isFrozen(att.component) {
+			continue
+		}
+		var content string
+		if opts.Regex {
+			content = re.ReplaceAllString(att.content, replacement)
+		} else {
+			content = re.ReplaceAllLiteralString(att.content, replacement)
+		}
+		if content == att.content {
+			continue
+		}
+		cmd, err := ud.newSetterCommand(att.component, propertyAttrContent, att.section, att.index, att.content, content)
+		if err != nil {
+			return 0, err
+		}
+		cmd.before = ud.GetLastModified()
+		cmd.after = time.Now()
+		cmds = append(cmds, cmd)
+	}
+	if err := ud.executeAll(cmds); err != nil {
+		return 0, err
+	}
+	return len(cmds), nil
+}
@@ -0,0 +1,137 @@
+package umldiagram
+
+import (
+	"math"
+	"slices"
+
+	"Dr.uml/backend/component"
+	"Dr.uml/backend/drawdata"
+	"Dr.uml/backend/utils"
+)
+
+// snapDistance is how close an edge or center has to come to the one of another gadget to snap to it
+const snapDistance = 5
+
+// box is the area a gadget is drawn in
+type box struct {
+	min utils.Point
+	max utils.Point
+}
+
+func gadgetBox(g *component.Gadget) box {
+	dd := g.GetDrawData().(drawdata.Gadget)
+	return box{
+		min: utils.Point{X: dd.X, Y: dd.Y},
+		max: utils.Point{X: dd.X + dd.Width, Y: dd.Y + dd.Height},
+	}
+}
+
+// lines returns the left, center and right (or top, middle and bottom) coordinates of the box
+func (b box) lines(vertical bool) [3]int {
+	if vertical {
+		return [3]int{b.min.X, (b.min.X + b.max.X) / 2, b.max.X}
+	}
+	return [3]int{b.min.Y, (b.min.Y + b.max.Y) / 2, b.max.Y}
+}
+
+func (b box) offset(p utils.Point) box {
+	return box{min: utils.AddPoints(b.min, p), max: utils.AddPoints(b.max, p)}
+}
+
+func snapToGrid(v int, size int) int {
+	return int(math.Round(float64(v)/float64(size))) * size
+}
+
+// snapPoint returns where the top-left corner of the moving gadgets ends up when moved to point,
+// together with the guides along which they then align with the other gadgets.
+// The point is first rounded to the grid if it is enabled, a nearby edge or center of another gadget wins over the grid.
+func (ud *UMLDiagram) snapPoint(moving []*component.Gadget, point utils.Point) (utils.Point, []drawdata.Guide) {
+	if len(moving) == 0 {
+		return point, nil
+	}
+	if ud.gridEnabled {
+		point = utils.Point{X: snapToGrid(point.X, ud.gridSize), Y: snapToGrid(point.Y, ud.gridSize)}
+	}
+
+	bound := gadgetBox(moving[0])
+	for _, g := range moving[1:] {
+		b := gadgetBox(g)
+		bound.min = utils.Point{X: min(bound.min.X, b.min.X), Y: min(bound.min.Y, b.min.Y)}
+		bound.max = utils.Point{X: max(bound.max.X, b.max.X), Y: max(bound.max.Y, b.max.Y)}
+	}
+	others := make([]box, 0)
+	for _, c := range ud.componentsContainer.GetAll() {
+		g, ok := c.(*component.Gadget)
+		if !ok || !ud.isVisible(g) {
+			continue
+		}
+		// the gadgets in a moving package move along, the packages around a moving gadget grow with it
+		if slices.ContainsFunc(moving, func(m *component.Gadget) bool {
+			return m == g || m.IsAncestorOf(g) || g.IsAncestorOf(m)
+		}) {
+			continue
+		}
+		others = append(others, gadgetBox(g))
+	}
+
+	moved := bound.offset(utils.SubPoints(point, bound.min))
+	point.X += nearestSnap(moved, others, true)
+	point.Y += nearestSnap(moved, others, false)
+
+	moved = bound.offset(utils.SubPoints(point, bound.min))
+	return point, alignGuides(moved, others)
+}
+
+// nearestSnap returns the smallest shift that aligns a line of b with one of the other boxes, 0 if none is close enough
+func nearestSnap(b box, others []box, vertical bool) int {
+	best, found := 0, false
+	for _, o := range others {
+		for _, own := range b.lines(vertical) {
+			for _, line := range o.lines(vertical) {
+				d := line - own
+				if utils.AbsInt(d) <= snapDistance && (!found || utils.AbsInt(d) < utils.AbsInt(best)) {
+					best, found = d, true
+				}
+			}
+		}
+	}
+	return best
+}
+
+// alignGuides returns a guide for every line b shares with one of the other boxes,
+// each guide spans all the boxes aligned along it
+func alignGuides(b box, others []box) []drawdata.Guide {
+	guides := make([]drawdata.Guide, 0)
+	add := func(vertical bool, position int, o box) {
+		start, end := min(b.min.Y, o.min.Y), max(b.max.Y, o.max.Y)
+		if !vertical {
+			start, end = min(b.min.X, o.min.X), max(b.max.X, o.max.X)
+		}
+		for i, g := range guides {
+			if g.Vertical == vertical && g.Position == position {
+				guides[i].Start, guides[i].End = min(g.Start, start), max(g.End, end)
+				return
+			}
+		}
+		guides = append(guides, drawdata.Guide{Vertical: vertical, Position: position, Start: start, End: end})
+	}
+	for _, o := range others {
+		for _, vertical := range []bool{true, false} {
+			for _, own := range b.lines(vertical) {
+				if lines := o.lines(vertical); slices.Contains(lines[:], own) {
+					add(vertical, own, o)
+				}
+			}
+		}
+	}
+	slices.SortFunc(guides, func(a, b drawdata.Guide) int {
+		if a.Vertical != b.Vertical {
+			if a.Vertical {
+				return -1
+			}
+			return 1
+		}
+		return a.Position - b.Position
+	})
+	return guides
+}
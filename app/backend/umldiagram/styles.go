@@ -0,0 +1,265 @@
+package umldiagram
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"Dr.uml/backend/command"
+	"Dr.uml/backend/component"
+	"Dr.uml/backend/component/attribute"
+	"Dr.uml/backend/utils"
+	"Dr.uml/backend/utils/duerror"
+)
+
+// Styles are kept by the project and shared by its diagrams. A gadget refers to its style by name, the
+// diagram copies the color, font, size and text style of the style to the gadget and its attributes,
+// leaving out the properties the gadget overrides. Setting one of them on a gadget with a style
+// overrides it, setting the style again drops the overrides.
+// A gadget whose style is not in the style sheet keeps its look and its style name.
+
+// ValidateStyle checks the name and the properties a style sets
+func ValidateStyle(style utils.SavedStyle) duerror.DUError {
+	if strings.TrimSpace(style.Name) == "" {
+		return duerror.NewInvalidArgumentError("style name cannot be empty")
+	}
+	if style.Name != strings.TrimSpace(style.Name) {
+		return duerror.NewInvalidArgumentError("style name cannot start or end with spaces")
+	}
+	if style.Color != "" {
+		if err := utils.ValidateHexColor(style.Color); err != nil {
+			return err
+		}
+	}
+	if style.Size < 0 {
+		return duerror.NewInvalidArgumentError("size cannot be negative")
+	}
+	if style.TextStyle != nil {
+		if err := attribute.ValidateTextStyle(attribute.Textstyle(*style.TextStyle)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetStyleSheet returns the styles the gadgets of the diagram can refer to
+func (ud *UMLDiagram) GetStyleSheet() []utils.SavedStyle {
+	return slices.Clone(ud.styles)
+}
+
+// ValidateStyleSheet checks the styles and that their names are unique
+func ValidateStyleSheet(styles []utils.SavedStyle) duerror.DUError {
+	names := make(map[string]bool, len(styles))
+	for _, style := range styles {
+		if err := ValidateStyle(style); err != nil {
+			return err
+		}
+		if names[style.Name] {
+			return duerror.NewInvalidArgumentError(fmt.Sprintf("style %q is defined twice", style.Name))
+		}
+		names[style.Name] = true
+	}
+	return nil
+}
+
+// SetStyleSheet replaces the styles of the diagram and restyles the gadgets that use them, the locked ones too.
+// It leaves the history alone: the styles belong to the project, undoing in the diagram must not change them.
+// Fonts that are not available are left out, the gadgets keep theirs.
+func (ud *UMLDiagram) SetStyleSheet(styles []utils.SavedStyle) duerror.DUError {
+	if err := ValidateStyleSheet(styles); err != nil {
+		return err
+	}
+	ud.styles = slices.Clone(styles)
+	return ud.restyle()
+}
+
+// restyle gives the gadgets the look of their style in the style sheet. A lock keeps a gadget from being edited,
+// not from following its style, which is edited for the whole project. The history
+// records looks given by older style sheets, so it is run again after moving through it.
+func (ud *UMLDiagram) restyle() duerror.DUError {
+	for _, c := range ud.componentsContainer.GetAll() {
+		g, ok := c.(*component.Gadget)
+		if !ok {
+			continue
+		}
+		if err := ud.applyStyle(g); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetStyleComponent gives the selected gadgets the named style, empty for none, and drops their overrides.
+// Gadgets left without a style keep their look.
+func (ud *UMLDiagram) SetStyleComponent(name string) duerror.DUError {
+	gadgets, err := ud.getEditableGadgets()
+	if err != nil {
+		return err
+	}
+	var style utils.SavedStyle
+	if name != "" {
+		var ok bool
+		if style, ok = findStyle(ud.styles, name); !ok {
+			return duerror.NewInvalidArgumentError(fmt.Sprintf("style %q not found", name))
+		}
+	}
+	cmds := make([]command.Command, 0, len(gadgets))
+	for _, g := range gadgets {
+		for _, change := range []struct {
+			property           string
+			oldValue, newValue any
+		}{
+			{propertyStyle, g.GetStyle(), name},
+			{propertyStyleOverrides, int(g.GetStyleOverrides()), 0},
+		} {
+			if change.oldValue == change.newValue {
+				continue
+			}
+			cmd, err := ud.newSetterCommand(g, change.property, 0, 0, change.oldValue, change.newValue)
+			if err != nil {
+				return err
+			}
+			cmd.before = ud.GetLastModified()
+			cmd.after = time.Now()
+			cmds = append(cmds, cmd)
+		}
+		if name == "" {
+			continue
+		}
+		restyle, err := ud.styleCommands(g, style, 0)
+		if err != nil {
+			return err
+		}
+		cmds = append(cmds, restyle...)
+	}
+	return ud.executeAll(cmds)
+}
+
+func findStyle(styles []utils.SavedStyle, name string) (utils.SavedStyle, bool) {
+	index := slices.IndexFunc(styles, func(s utils.SavedStyle) bool { return s.Name == name })
+	if index < 0 {
+		return utils.SavedStyle{}, false
+	}
+	return styles[index], true
+}
+
+// styleChange is a property of a gadget or of one of its attributes set by a style
+type styleChange struct {
+	property           string
+	section, index     int
+	oldValue, newValue any
+}
+
+// styleChanges returns what gives g the look of style, except for the overridden properties
+func styleChanges(g *component.Gadget, style utils.SavedStyle, overrides component.StyleProperty) []styleChange {
+	changes := make([]styleChange, 0)
+	if style.Color != "" && overrides&component.StyleColor == 0 && g.GetColor() != style.Color {
+		changes = append(changes, styleChange{propertyColor, 0, 0, g.GetColor(), style.Color})
+	}
+	font := ""
+	if style.Font != "" && overrides&component.StyleFont == 0 {
+		if info, err := utils.LookupFont(style.Font); err == nil {
+			font = info.Family
+		}
+	}
+	for section, atts := range g.GetAttributes() {
+		for index, att := range atts {
+			if font != "" && (att.GetFont() != font || att.IsFontMissing()) {
+				changes = append(changes, styleChange{propertyAttrFont, section, index, att.GetFont(), style.Font})
+			}
+			if style.Size > 0 && overrides&component.StyleSize == 0 && att.GetSize() != style.Size {
+				changes = append(changes, styleChange{propertyAttrSize, section, index, att.GetSize(), style.Size})
+			}
+			if style.TextStyle != nil && overrides&component.StyleTextStyle == 0 && int(att.GetStyle()) != *style.TextStyle {
+				changes = append(changes, styleChange{propertyAttrStyle, section, index, int(att.GetStyle()), *style.TextStyle})
+			}
+		}
+	}
+	return changes
+}
+
+// styleCommands returns the setters that give g the look of style, except for the overridden properties
+func (ud *UMLDiagram) styleCommands(g *component.Gadget, style utils.SavedStyle, overrides component.StyleProperty) ([]command.Command, duerror.DUError) {
+	changes := styleChanges(g, style, overrides)
+	cmds := make([]command.Command, 0, len(changes))
+	for _, ch := range changes {
+		cmd, err := ud.newSetterCommand(g, ch.property, ch.section, ch.index, ch.oldValue, ch.newValue)
+		if err != nil {
+			return nil, err
+		}
+		cmd.before = ud.GetLastModified()
+		cmd.after = time.Now()
+		cmds = append(cmds, cmd)
+	}
+	return cmds, nil
+}
+
+// applyStyle gives g the look of its style, except for the properties it overrides, outside the history
+func (ud *UMLDiagram) applyStyle(g *component.Gadget) duerror.DUError {
+	return ud.applyStyleChanges(g, func(styleChange) bool { return true })
+}
+
+// styleAttribute gives an attribute added to g the font, size and text style of the style of g, except for the
+// properties g overrides. It is part of adding the attribute and not an undo step of its own.
+func (ud *UMLDiagram) styleAttribute(g *component.Gadget, section, index int) duerror.DUError {
+	return ud.applyStyleChanges(g, func(ch styleChange) bool {
+		return ch.property != propertyColor && ch.section == section && ch.index == index
+	})
+}
+
+func (ud *UMLDiagram) applyStyleChanges(g *component.Gadget, keep func(styleChange) bool) duerror.DUError {
+	style, ok := findStyle(ud.styles, g.GetStyle())
+	if g.GetStyle() == "" || !ok {
+		return nil
+	}
+	for _, ch := range styleChanges(g, style, g.GetStyleOverrides()) {
+		if !keep(ch) {
+			continue
+		}
+		set, err := ud.propertySetter(g, ch.property, ch.section, ch.index)
+		if err != nil {
+			return err
+		}
+		if err := set(ch.newValue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// overrideCommand returns the setter that makes property of c an override of its style,
+// nil if c has no style or already overrides it
+func (ud *UMLDiagram) overrideCommand(c component.Component, property component.StyleProperty) (command.Command, duerror.DUError) {
+	g, ok := c.(*component.Gadget)
+	if !ok || g.GetStyle() == "" || g.GetStyleOverrides()&property != 0 {
+		return nil, nil
+	}
+	overrides := g.GetStyleOverrides()
+	cmd, err := ud.newSetterCommand(g, propertyStyleOverrides, 0, 0, int(overrides), int(overrides|property))
+	if err != nil {
+		return nil, err
+	}
+	cmd.before = ud.GetLastModified()
+	cmd.after = time.Now()
+	return cmd, nil
+}
+
+// executeOverridingSetter sets a property of c that its style also sets, overriding the style
+func (ud *UMLDiagram) executeOverridingSetter(c component.Component, override component.StyleProperty, property string, section, index int, oldValue, newValue any) duerror.DUError {
+	cmd, err := ud.newSetterCommand(c, property, section, index, oldValue, newValue)
+	if err != nil {
+		return err
+	}
+	cmd.before = ud.GetLastModified()
+	cmd.after = time.Now()
+	cmds := []command.Command{cmd}
+	overrideCmd, err := ud.overrideCommand(c, override)
+	if err != nil {
+		return err
+	}
+	if overrideCmd != nil {
+		cmds = append(cmds, overrideCmd)
+	}
+	return ud.executeAll(cmds)
+}
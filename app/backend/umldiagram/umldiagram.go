@@ -2,6 +2,7 @@ package umldiagram
 
 import (
 	"fmt"
+	"maps"
 	"slices"
 	"time"
 
@@ -40,10 +41,11 @@ func validateDiagramType(input DiagramType) duerror.DUError {
 }
 
 type UMLDiagram struct {
-	name            string
-	diagramType     DiagramType // e.g., "Class", "UseCase", "Sequence"
-	startPoint      utils.Point // for dragging and linking ass
-	backgroundColor string
+	name        string
+	diagramType DiagramType // e.g., "Class", "UseCase", "Sequence"
+	startPoint  utils.Point // for dragging and linking ass
+	properties  DiagramProperties
+	created     time.Time // zero if unknown
 
 	cmdManager          *command.Manager
 	componentsContainer components.Container
@@ -52,6 +54,19 @@ type UMLDiagram struct {
 
 	lastSave time.Time // for saving and loading
 
+	fontFallbacks     []string // font names tried for glyphs missing from the attribute fonts
+	fontFallbackFiles []string // the available files of fontFallbacks
+
+	loadWarnings []string // problems found while loading that did not stop it, e.g. missing fonts
+
+	gridSize    int
+	gridEnabled bool
+	dragging    bool             // between StartDrag and EndDrag
+	guides      []drawdata.Guide // guides of the last move while dragging
+
+	layers []utils.SavedLayer // the named layers
+	styles []utils.SavedStyle // the styles of the project the gadgets refer to
+
 	updateParentDraw func() duerror.DUError
 	drawData         drawdata.Diagram
 }
@@ -69,41 +84,88 @@ func CreateEmptyUMLDiagram(name string, dt DiagramType) (*UMLDiagram, duerror.DU
 		name:                name,
 		diagramType:         dt,
 		startPoint:          utils.Point{X: 0, Y: 0},
-		backgroundColor:     drawdata.DefaultDiagramColor, // Default white background
+		properties:          defaultProperties(),
+		created:             time.Now(),
 		cmdManager:          command.NewManager(time.Now()),
-		componentsContainer: components.NewContainerMap(),
+		componentsContainer: components.NewContainerQuadtree(),
 		componentsSelected:  make(map[component.Component]bool),
 		associations:        make(map[*component.Gadget][2][]*component.Association),
+		gridSize:            drawdata.DefaultGridSize,
 		drawData: drawdata.Diagram{
 			Margin:    drawdata.Margin,
 			LineWidth: drawdata.LineWidth,
 			Color:     drawdata.DefaultDiagramColor,
+			GridSize:  drawdata.DefaultGridSize,
 		},
 	}, nil
 }
 
 func LoadExistUMLDiagram(filename string, file utils.SavedDiagram) (*UMLDiagram, duerror.DUError) {
-	dia, err := CreateEmptyUMLDiagram(filename, DiagramType(file.Filetype)) // Shift right to remove the filetype bit
+	dia, _, _, err := loadExistUMLDiagram(filename, file)
+	if err != nil {
+		return nil, err
+	}
+	return dia, nil
+}
+
+// LoadExistUMLDiagramWithHistory loads the diagram and restores the undo/redo history saved along with it.
+func LoadExistUMLDiagramWithHistory(filename string, file utils.SavedDiagram, history utils.SavedHistory) (*UMLDiagram, duerror.DUError) {
+	if history.DiagramLastEdit != file.LastEdit {
+		return nil, duerror.NewCorruptedFile(fmt.Sprintf("history does not belong to the saved version of %s", filename))
+	}
+	dia, gadgets, asses, err := loadExistUMLDiagram(filename, file)
 	if err != nil {
 		return nil, err
 	}
+	if err = dia.loadHistory(history, gadgets, asses); err != nil {
+		return nil, err
+	}
+	return dia, nil
+}
+
+func loadExistUMLDiagram(filename string, file utils.SavedDiagram) (*UMLDiagram, map[int]*component.Gadget, map[int]*component.Association, duerror.DUError) {
+	dia, err := CreateEmptyUMLDiagram(filename, DiagramType(file.Filetype)) // Shift right to remove the filetype bit
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	dia.applyFontFallbacks(file.FontFallbacks)
+	if file.GridSize < 0 {
+		return nil, nil, nil, duerror.NewCorruptedFile(fmt.Sprintf("invalid grid size %d in %s", file.GridSize, filename))
+	}
+	if file.GridSize > 0 {
+		dia.gridSize = file.GridSize
+	}
+	dia.gridEnabled = file.GridEnabled
+	if err = dia.loadProperties(file); err != nil {
+		return nil, nil, nil, err
+	}
 
 	dp, err := dia.loadGadgets(file.Gadgets)
 	if err != nil {
-		return nil, duerror.NewCorruptedFile(fmt.Sprintf(err.Error()+"from %s", filename))
+		return nil, nil, nil, duerror.NewCorruptedFile(fmt.Sprintf(err.Error()+"from %s", filename))
+	}
+	if err = loadChildren(file.Gadgets, dp); err != nil {
+		return nil, nil, nil, duerror.NewCorruptedFile(fmt.Sprintf("%s in %s", err.Error(), filename))
 	}
 
-	if err = dia.loadAsses(file.Associations, dp); err != nil {
-		return nil, err
+	asses, err := dia.loadAsses(file.Associations, dp)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if err = dia.loadLayers(file.Layers); err != nil {
+		return nil, nil, nil, err
 	}
 
 	if err = dia.updateDrawData(); err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	dia.name = filename
+	dia.collectFontWarnings()
 
-	return dia, nil
+	return dia, dp, asses, nil
 }
 
 // Getters
@@ -119,273 +181,337 @@ func (ud *UMLDiagram) GetLastModified() time.Time {
 	return ud.cmdManager.GetLastModified()
 }
 
+func (ud *UMLDiagram) GetFontFallbacks() []string {
+	return slices.Clone(ud.fontFallbacks)
+}
+
+// GetLoadWarnings returns the problems found while loading the diagram that did not stop it.
+func (ud *UMLDiagram) GetLoadWarnings() []string {
+	return slices.Clone(ud.loadWarnings)
+}
+
+func (ud *UMLDiagram) GetGridSize() int {
+	return ud.gridSize
+}
+
+func (ud *UMLDiagram) IsGridEnabled() bool {
+	return ud.gridEnabled
+}
+
 // Setters
+
+// SetPointComponent moves the selected gadgets so that the top-left one ends up at point,
+// the others keep their position relative to it.
+// The point is snapped to the grid and to the edges and centers of the other gadgets,
+// while dragging the lines it snapped along are reported as guides in the draw data.
 func (ud *UMLDiagram) SetPointComponent(point utils.Point) duerror.DUError {
-	c, err := ud.getSelectedComponent()
+	gadgets, err := ud.getEditableGadgets()
 	if err != nil {
 		return err
 	}
-	g, ok := c.(*component.Gadget)
-	if !ok {
-		return duerror.NewInvalidArgumentError("selected component is not a gadget")
+	if err := ud.checkMovable(gadgets); err != nil {
+		return err
+	}
+	point, guides := ud.snapPoint(gadgets, point)
+	if ud.dragging {
+		ud.guides = guides
+	}
+	// point is where the drawn top-left corner goes, a package is drawn around its children which may overhang it
+	anchor := gadgetBox(gadgets[0]).min
+	set := make(map[*component.Gadget]bool, len(gadgets))
+	for _, g := range gadgets {
+		b := gadgetBox(g)
+		anchor.X = min(anchor.X, b.min.X)
+		anchor.Y = min(anchor.Y, b.min.Y)
+		set[g] = true
+	}
+	offset := utils.SubPoints(point, anchor)
+	parents, err := ud.dropParents(set, offset)
+	if err != nil {
+		return err
+	}
+	if len(gadgets) == 1 {
+		g := gadgets[0]
+		cmd := &moveGadgetCommand{
+			baseCommand: baseCommand{
+				diagram: ud,
+				before:  ud.GetLastModified(),
+				after:   time.Now(),
+			},
+			gadget:   g,
+			newPoint: utils.AddPoints(g.GetPoint(), offset),
+			oldPoint: g.GetPoint(),
+			parents:  parents,
+		}
+		return ud.cmdManager.Execute(cmd)
 	}
-	cmd := &moveGadgetCommand{
+	cmd := &moveGadgetsCommand{
 		baseCommand: baseCommand{
 			diagram: ud,
 			before:  ud.GetLastModified(),
 			after:   time.Now(),
 		},
-		gadget:   g,
-		newPoint: point,
-		oldPoint: g.GetPoint(),
-	}
-	if err := ud.cmdManager.Execute(cmd); err != nil {
-		return err
+		gadgets: set,
+		offset:  offset,
+		parents: parents,
 	}
-	return nil
+	return ud.cmdManager.Execute(cmd)
 }
 
 func (ud *UMLDiagram) SetLayerComponent(layer int) duerror.DUError {
-	c, err := ud.getSelectedComponent()
+	comps, err := ud.getSelectedComponents()
 	if err != nil {
 		return err
 	}
-	oldLayer := c.GetLayer()
-	cmd := &setterCommand{
-		baseCommand: baseCommand{
-			diagram: ud,
-			before:  ud.GetLastModified(),
-			after:   time.Now(),
-		},
-		component: c,
-		execute:   func() duerror.DUError { return c.SetLayer(layer) },
-		unexecute: func() duerror.DUError { return c.SetLayer(oldLayer) },
-	}
-	if err := ud.cmdManager.Execute(cmd); err != nil {
-		return err
+	cmds := make([]command.Command, 0, len(comps))
+	for _, c := range comps {
+		cmd, err := ud.newSetterCommand(c, propertyLayer, 0, 0, c.GetLayer(), layer)
+		if err != nil {
+			return err
+		}
+		cmd.before = ud.GetLastModified()
+		cmd.after = time.Now()
+		cmds = append(cmds, cmd)
 	}
-	return nil
-
+	return ud.executeAll(cmds)
 }
 
-func (ud *UMLDiagram) SetColorComponent(colorHexStr string) duerror.DUError {
-	c, err := ud.getSelectedComponent()
+// SetLockedComponent locks or unlocks the selected components
+func (ud *UMLDiagram) SetLockedComponent(locked bool) duerror.DUError {
+	comps, err := ud.getSelectedComponents()
 	if err != nil {
 		return err
 	}
-	g, ok := c.(*component.Gadget)
-	if !ok {
-		return duerror.NewInvalidArgumentError("selected component is not a gadget")
-	}
-	oldColor := g.GetColor()
-	cmd := &setterCommand{
-		baseCommand: baseCommand{
-			diagram: ud,
-			before:  ud.GetLastModified(),
-			after:   time.Now(),
-		},
-		component: g,
-		execute:   func() duerror.DUError { return g.SetColor(colorHexStr) },
-		unexecute: func() duerror.DUError { return g.SetColor(oldColor) },
-	}
-	if err := ud.cmdManager.Execute(cmd); err != nil {
-		return err
+	cmds := make([]command.Command, 0, len(comps))
+	for _, c := range comps {
+		if c.GetIsLocked() == locked {
+			continue
+		}
+		cmd, err := ud.newSetterCommand(c, propertyLocked, 0, 0, c.GetIsLocked(), locked)
+		if err != nil {
+			return err
+		}
+		cmd.before = ud.GetLastModified()
+		cmd.after = time.Now()
+		cmds = append(cmds, cmd)
 	}
-	return nil
-
+	return ud.executeAll(cmds)
 }
 
-func (ud *UMLDiagram) SetAttrContentComponent(section int, index int, content string) duerror.DUError {
-	c, err := ud.getSelectedComponent()
+func (ud *UMLDiagram) SetColorComponent(colorHexStr string) duerror.DUError {
+	gadgets, err := ud.getEditableGadgets()
 	if err != nil {
 		return err
 	}
-
-	var setContent func(content string) duerror.DUError
-	var oldContent string
-	switch c := c.(type) {
-	case *component.Gadget:
-		att, err := c.GetAttribute(section, index)
+	cmds := make([]command.Command, 0, len(gadgets))
+	for _, g := range gadgets {
+		cmd, err := ud.newSetterCommand(g, propertyColor, 0, 0, g.GetColor(), colorHexStr)
 		if err != nil {
-			return nil
-		}
-		oldContent = att.GetContent()
-		setContent = func(content string) duerror.DUError {
-			return c.SetAttrContent(section, index, content)
+			return err
 		}
-	case *component.Association:
-		att, err := c.GetAttribute(index)
+		cmd.before = ud.GetLastModified()
+		cmd.after = time.Now()
+		cmds = append(cmds, cmd)
+		overrideCmd, err := ud.overrideCommand(g, component.StyleColor)
 		if err != nil {
-			return nil
+			return err
 		}
-		oldContent = att.GetContent()
-		setContent = func(content string) duerror.DUError {
-			return c.SetAttrContent(index, content)
+		if overrideCmd != nil {
+			cmds = append(cmds, overrideCmd)
 		}
-	default:
-		return duerror.NewInvalidArgumentError("invalid selected component")
 	}
+	return ud.executeAll(cmds)
+}
 
-	cmd := &setterCommand{
+// SetSizeComponent resizes the selected gadgets, all to the same size. They still grow to fit their content.
+// 0 on an axis takes the size of the content.
+func (ud *UMLDiagram) SetSizeComponent(width int, height int) duerror.DUError {
+	if width < 0 || height < 0 {
+		return duerror.NewInvalidArgumentError("size cannot be negative")
+	}
+	gadgets, err := ud.getEditableGadgets()
+	if err != nil {
+		return err
+	}
+	oldSizes := make(map[*component.Gadget][2]int, len(gadgets))
+	for _, g := range gadgets {
+		w, h := g.GetSize()
+		oldSizes[g] = [2]int{w, h}
+	}
+	return ud.cmdManager.Execute(&resizeGadgetsCommand{
 		baseCommand: baseCommand{
 			diagram: ud,
 			before:  ud.GetLastModified(),
 			after:   time.Now(),
 		},
-		component: c,
-		execute:   func() duerror.DUError { return setContent(content) },
-		unexecute: func() duerror.DUError { return setContent(oldContent) },
-	}
-	if err := ud.cmdManager.Execute(cmd); err != nil {
-		return err
-	}
-	return nil
+		oldSizes: oldSizes,
+		newSize:  [2]int{width, height},
+	})
 }
 
-func (ud *UMLDiagram) SetAttrSizeComponent(section int, index int, size int) duerror.DUError {
-	c, err := ud.getSelectedComponent()
+// AutoSizeComponent returns the selected gadgets to the size of their content.
+func (ud *UMLDiagram) AutoSizeComponent() duerror.DUError {
+	return ud.SetSizeComponent(0, 0)
+}
+
+// SetMaxWidthComponent limits the width of the selected gadgets, their attributes wrap to fit. 0 removes the limit.
+func (ud *UMLDiagram) SetMaxWidthComponent(width int) duerror.DUError {
+	gadgets, err := ud.getEditableGadgets()
 	if err != nil {
 		return err
 	}
-
-	var setSize func(size int) duerror.DUError
-	var oldSize int
-	switch c := c.(type) {
-	case *component.Gadget:
-		att, err := c.GetAttribute(section, index)
+	cmds := make([]command.Command, 0, len(gadgets))
+	for _, g := range gadgets {
+		cmd, err := ud.newSetterCommand(g, propertyMaxWidth, 0, 0, g.GetMaxWidth(), width)
 		if err != nil {
-			return nil
+			return err
 		}
-		oldSize = att.GetSize()
-		setSize = func(size int) duerror.DUError {
-			return c.SetAttrSize(section, index, size)
+		cmd.before = ud.GetLastModified()
+		cmd.after = time.Now()
+		cmds = append(cmds, cmd)
+	}
+	return ud.executeAll(cmds)
+}
+
+// SetFontFallbacks sets the fonts tried, in order, for glyphs missing from the font of an attribute.
+// Fonts are bundled font names or absolute paths to font files, each must be loadable.
+func (ud *UMLDiagram) SetFontFallbacks(fonts []string) duerror.DUError {
+	for _, name := range fonts {
+		if name == "" {
+			return duerror.NewInvalidArgumentError("font name is empty")
 		}
-	case *component.Association:
-		att, err := c.GetAttribute(index)
+		file, err := utils.ResolveFontFile(name)
 		if err != nil {
-			return nil
+			return err
 		}
-		oldSize = att.GetSize()
-		setSize = func(size int) duerror.DUError {
-			return c.SetAttrSize(index, size)
+		if err := utils.ValidateFontFile(file); err != nil {
+			return err
 		}
-	default:
-		return duerror.NewInvalidArgumentError("invalid selected component")
 	}
+	return ud.cmdManager.Execute(&diagramSetterCommand{
+		baseCommand: baseCommand{
+			diagram: ud,
+			before:  ud.GetLastModified(),
+			after:   time.Now(),
+		},
+		property: propertyFontFallbacks,
+		oldValue: slices.Clone(ud.fontFallbacks),
+		newValue: slices.Clone(fonts),
+	})
+}
+
+// SetGridSize sets the spacing of the grid moves snap to
+func (ud *UMLDiagram) SetGridSize(size int) duerror.DUError {
+	if size <= 0 {
+		return duerror.NewInvalidArgumentError("grid size must be positive")
+	}
+	return ud.cmdManager.Execute(&diagramSetterCommand{
+		baseCommand: baseCommand{
+			diagram: ud,
+			before:  ud.GetLastModified(),
+			after:   time.Now(),
+		},
+		property: propertyGridSize,
+		oldValue: ud.gridSize,
+		newValue: size,
+	})
+}
 
-	cmd := &setterCommand{
+// SetGridEnabled turns snapping to the grid on or off
+func (ud *UMLDiagram) SetGridEnabled(enabled bool) duerror.DUError {
+	return ud.cmdManager.Execute(&diagramSetterCommand{
 		baseCommand: baseCommand{
 			diagram: ud,
 			before:  ud.GetLastModified(),
 			after:   time.Now(),
 		},
-		component: c,
-		execute:   func() duerror.DUError { return setSize(size) },
-		unexecute: func() duerror.DUError { return setSize(oldSize) },
+		property: propertyGridEnabled,
+		oldValue: ud.gridEnabled,
+		newValue: enabled,
+	})
+}
+
+func (ud *UMLDiagram) SetAttrContentComponent(section int, index int, content string) duerror.DUError {
+	c, err := ud.getEditableComponent()
+	if err != nil {
+		return err
 	}
-	if err := ud.cmdManager.Execute(cmd); err != nil {
+	att, err := getAttribute(c, section, index)
+	if err != nil {
 		return err
 	}
-	return nil
+	return ud.executeSetter(c, propertyAttrContent, section, index, att.GetContent(), content)
 }
 
-func (ud *UMLDiagram) SetAttrStyleComponent(section int, index int, style int) duerror.DUError {
-	c, err := ud.getSelectedComponent()
+func (ud *UMLDiagram) SetAttrSizeComponent(section int, index int, size int) duerror.DUError {
+	c, err := ud.getEditableComponent()
 	if err != nil {
 		return err
 	}
-
-	var setStyle func(style int) duerror.DUError
-	var oldStyle int
-	switch c := c.(type) {
-	case *component.Gadget:
-		att, err := c.GetAttribute(section, index)
-		if err != nil {
-			return nil
-		}
-		oldStyle = int(att.GetStyle())
-		setStyle = func(style int) duerror.DUError {
-			return c.SetAttrStyle(section, index, style)
-		}
-	case *component.Association:
-		att, err := c.GetAttribute(index)
-		if err != nil {
-			return nil
-		}
-		oldStyle = int(att.GetStyle())
-		setStyle = func(style int) duerror.DUError {
-			return c.SetAttrStyle(index, style)
-		}
-	default:
-		return duerror.NewInvalidArgumentError("invalid selected component")
+	att, err := getAttribute(c, section, index)
+	if err != nil {
+		return err
 	}
+	return ud.executeOverridingSetter(c, component.StyleSize, propertyAttrSize, section, index, att.GetSize(), size)
+}
 
-	cmd := &setterCommand{
-		baseCommand: baseCommand{
-			diagram: ud,
-			before:  ud.GetLastModified(),
-			after:   time.Now(),
-		},
-		component: c,
-		execute:   func() duerror.DUError { return setStyle(style) },
-		unexecute: func() duerror.DUError { return setStyle(oldStyle) },
+func (ud *UMLDiagram) SetAttrStyleComponent(section int, index int, style int) duerror.DUError {
+	c, err := ud.getEditableComponent()
+	if err != nil {
+		return err
 	}
-	if err := ud.cmdManager.Execute(cmd); err != nil {
+	att, err := getAttribute(c, section, index)
+	if err != nil {
 		return err
 	}
-	return nil
+	return ud.executeOverridingSetter(c, component.StyleTextStyle, propertyAttrStyle, section, index, int(att.GetStyle()), style)
 }
 
 func (ud *UMLDiagram) SetAttrFontComponent(section int, index int, fontFile string) duerror.DUError {
-	c, err := ud.getSelectedComponent()
+	c, err := ud.getEditableComponent()
+	if err != nil {
+		return err
+	}
+	att, err := getAttribute(c, section, index)
 	if err != nil {
 		return err
 	}
+	// the setter keeps a missing font, which undoing brings back
+	if _, err := utils.LookupFont(fontFile); err != nil {
+		return err
+	}
+	return ud.executeOverridingSetter(c, component.StyleFont, propertyAttrFont, section, index, att.GetFont(), fontFile)
+}
 
-	var setFont func(font string) duerror.DUError
-	var oldFontFile string
-	switch c := c.(type) {
-	case *component.Gadget:
-		att, err := c.GetAttribute(section, index)
-		if err != nil {
-			return nil
-		}
-		oldFontFile = att.GetFontFile()
-		setFont = func(font string) duerror.DUError {
-			return c.SetAttrFontFile(section, index, font)
-		}
-	case *component.Association:
-		att, err := c.GetAttribute(index)
-		if err != nil {
-			return nil
-		}
-		oldFontFile = att.GetFontFile()
-		setFont = func(font string) duerror.DUError {
-			return c.SetAttrFontFile(index, font)
-		}
-	default:
-		return duerror.NewInvalidArgumentError("invalid selected component")
+// SetAttrColorComponent sets the text color of an attribute of the selected component.
+func (ud *UMLDiagram) SetAttrColorComponent(section int, index int, color string) duerror.DUError {
+	c, err := ud.getEditableComponent()
+	if err != nil {
+		return err
+	}
+	att, err := getAttribute(c, section, index)
+	if err != nil {
+		return err
 	}
+	return ud.executeSetter(c, propertyAttrColor, section, index, att.GetColor(), color)
+}
 
-	cmd := &setterCommand{
-		baseCommand: baseCommand{
-			diagram: ud,
-			before:  ud.GetLastModified(),
-			after:   time.Now(),
-		},
-		component: c,
-		execute:   func() duerror.DUError { return setFont(fontFile) },
-		unexecute: func() duerror.DUError { return setFont(oldFontFile) },
+// SetAttrAlignComponent sets the horizontal alignment of an attribute of the selected component.
+func (ud *UMLDiagram) SetAttrAlignComponent(section int, index int, align int) duerror.DUError {
+	c, err := ud.getEditableComponent()
+	if err != nil {
+		return err
 	}
-	if err := ud.cmdManager.Execute(cmd); err != nil {
+	att, err := getAttribute(c, section, index)
+	if err != nil {
 		return err
 	}
-	return nil
+	return ud.executeSetter(c, propertyAttrAlign, section, index, int(att.GetAlign()), align)
 }
 
 func (ud *UMLDiagram) SetAttrRatioComponent(section int, index int, ratio float64) duerror.DUError {
 	// section arg is not used, just to keep similar signature
-	c, err := ud.getSelectedComponent()
+	c, err := ud.getEditableComponent()
 	if err != nil {
 		return err
 	}
@@ -398,7 +524,7 @@ func (ud *UMLDiagram) SetAttrRatioComponent(section int, index int, ratio float6
 }
 
 func (ud *UMLDiagram) SetParentStartComponent(point utils.Point) duerror.DUError {
-	c, err := ud.getSelectedComponent()
+	c, err := ud.getEditableComponent()
 	if err != nil {
 		return err
 	}
@@ -407,7 +533,7 @@ func (ud *UMLDiagram) SetParentStartComponent(point utils.Point) duerror.DUError
 		return duerror.NewInvalidArgumentError("selected component is not an association")
 	}
 
-	c, err = ud.componentsContainer.Search(point)
+	c, err = ud.topmostAt(point, ud.isVisible)
 	if err != nil {
 		return err
 	}
@@ -439,7 +565,7 @@ func (ud *UMLDiagram) SetParentStartComponent(point utils.Point) duerror.DUError
 }
 
 func (ud *UMLDiagram) SetParentEndComponent(point utils.Point) duerror.DUError {
-	c, err := ud.getSelectedComponent()
+	c, err := ud.getEditableComponent()
 	if err != nil {
 		return err
 	}
@@ -448,7 +574,7 @@ func (ud *UMLDiagram) SetParentEndComponent(point utils.Point) duerror.DUError {
 		return duerror.NewInvalidArgumentError("selected component is not an association")
 	}
 
-	c, err = ud.componentsContainer.Search(point)
+	c, err = ud.topmostAt(point, ud.isVisible)
 	if err != nil {
 		return err
 	}
@@ -480,7 +606,7 @@ func (ud *UMLDiagram) SetParentEndComponent(point utils.Point) duerror.DUError {
 }
 
 func (ud *UMLDiagram) SetAssociationType(value component.AssociationType) duerror.DUError {
-	c, err := ud.getSelectedComponent()
+	c, err := ud.getEditableComponent()
 	if err != nil {
 		return err
 	}
@@ -488,21 +614,7 @@ func (ud *UMLDiagram) SetAssociationType(value component.AssociationType) duerro
 	if !ok {
 		return duerror.NewInvalidArgumentError("selected component is not an association")
 	}
-	oldAssType := a.GetAssType() // Capture the original value before change
-	cmd := &setterCommand{
-		baseCommand: baseCommand{
-			diagram: ud,
-			before:  ud.GetLastModified(),
-			after:   time.Now(),
-		},
-		component: c,
-		execute:   func() duerror.DUError { return a.SetAssType(value) },
-		unexecute: func() duerror.DUError { return a.SetAssType(oldAssType) },
-	}
-	if err := ud.cmdManager.Execute(cmd); err != nil {
-		return err
-	}
-	return nil
+	return ud.executeSetter(a, propertyAssType, 0, 0, int(a.GetAssType()), int(value))
 }
 
 // Methods
@@ -510,6 +622,9 @@ func (ud *UMLDiagram) Undo() duerror.DUError {
 	if err := ud.cmdManager.Undo(); err != nil {
 		return err
 	}
+	if err := ud.restyle(); err != nil {
+		return err
+	}
 	ud.updateDrawData()
 	return nil
 }
@@ -518,11 +633,96 @@ func (ud *UMLDiagram) Redo() duerror.DUError {
 	if err := ud.cmdManager.Redo(); err != nil {
 		return err
 	}
+	if err := ud.restyle(); err != nil {
+		return err
+	}
 	ud.updateDrawData()
 	return nil
 }
 
+// GetHistoryState returns the id of the current state in the undo tree.
+func (ud *UMLDiagram) GetHistoryState() int {
+	return ud.cmdManager.GetState()
+}
+
+func (ud *UMLDiagram) ListHistoryBranches() []command.Branch {
+	return ud.cmdManager.ListBranches()
+}
+
+// SwitchHistoryBranch moves the diagram to the tip of another branch of the undo tree.
+func (ud *UMLDiagram) SwitchHistoryBranch(id int) duerror.DUError {
+	err := ud.cmdManager.SwitchBranch(id)
+	// a failed switch may stop half way, redraw whatever state was reached
+	if drawErr := ud.updateDrawData(); err == nil {
+		err = drawErr
+	}
+	return err
+}
+
+func (ud *UMLDiagram) GoToHistoryState(id int) duerror.DUError {
+	err := ud.cmdManager.GoToState(id)
+	if styleErr := ud.restyle(); err == nil {
+		err = styleErr
+	}
+	if drawErr := ud.updateDrawData(); err == nil {
+		err = drawErr
+	}
+	return err
+}
+
+// DiffHistoryStates lists the steps that lead from one history state to another.
+func (ud *UMLDiagram) DiffHistoryStates(from int, to int) ([]HistoryChange, duerror.DUError) {
+	undo, redo, err := ud.cmdManager.Diff(from, to)
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]HistoryChange, 0, len(undo)+len(redo))
+	for _, cmd := range undo {
+		changes = append(changes, describeCommand(cmd, true))
+	}
+	for _, cmd := range redo {
+		changes = append(changes, describeCommand(cmd, false))
+	}
+	return changes, nil
+}
+
+// BeginTransaction groups the following edits into one undo step until CommitTransaction.
+func (ud *UMLDiagram) BeginTransaction() duerror.DUError {
+	return ud.cmdManager.Begin()
+}
+
+func (ud *UMLDiagram) CommitTransaction() duerror.DUError {
+	return ud.cmdManager.Commit()
+}
+
+// RollbackTransaction reverts every edit made since BeginTransaction.
+func (ud *UMLDiagram) RollbackTransaction() duerror.DUError {
+	if err := ud.cmdManager.Rollback(); err != nil {
+		return err
+	}
+	return ud.updateDrawData()
+}
+
+// StartDrag marks the beginning of a continuous edit, such as dragging a gadget or an association end.
+// The moves until EndDrag are collapsed into one undo step.
+func (ud *UMLDiagram) StartDrag() duerror.DUError {
+	ud.cmdManager.BeginMerge()
+	ud.dragging = true
+	return nil
+}
+
+func (ud *UMLDiagram) EndDrag() duerror.DUError {
+	ud.cmdManager.EndMerge()
+	ud.dragging = false
+	ud.guides = nil
+	return ud.updateDrawData()
+}
+
+// AddGadget adds a gadget, an empty color takes the gadget color of the diagram
 func (ud *UMLDiagram) AddGadget(gadgetType component.GadgetType, point utils.Point, layer int, colorHexStr string, header string) duerror.DUError {
+	if colorHexStr == "" {
+		colorHexStr = ud.properties.GadgetColor
+	}
 	g, err := component.NewGadget(gadgetType, point, layer, colorHexStr, header)
 	if err != nil {
 		return err
@@ -530,6 +730,21 @@ func (ud *UMLDiagram) AddGadget(gadgetType component.GadgetType, point utils.Poi
 	if err = g.RegisterUpdateParentDraw(ud.updateDrawData); err != nil {
 		return err
 	}
+	// a gadget added inside a package goes into it, addGadget attaches it
+	dd := g.GetDrawData().(drawdata.Gadget)
+	center := utils.Point{X: dd.X + dd.Width/2, Y: dd.Y + dd.Height/2}
+	parent, err := ud.packageAt(center, func(*component.Gadget) bool { return false })
+	if err != nil {
+		return err
+	}
+	if parent != nil {
+		if err = g.SetParent(parent); err != nil {
+			return err
+		}
+		if err = g.DetachFromParent(); err != nil {
+			return err
+		}
+	}
 	cmd := &addComponentCommand{
 		baseCommand: baseCommand{
 			diagram: ud,
@@ -560,14 +775,14 @@ func (ud *UMLDiagram) EndAddAssociation(assType component.AssociationType, endPo
 	}
 
 	// search parents
-	stGad, err := ud.componentsContainer.SearchGadget(stPoint)
+	stGad, err := ud.visibleGadgetAt(stPoint)
 	if err != nil {
 		return err
 	}
 	if stGad == nil {
 		return duerror.NewInvalidArgumentError("start point does not contain a gadget")
 	}
-	enGad, err := ud.componentsContainer.SearchGadget(endPoint)
+	enGad, err := ud.visibleGadgetAt(endPoint)
 	if err != nil {
 		return err
 	}
@@ -601,13 +816,23 @@ func (ud *UMLDiagram) EndAddAssociation(assType component.AssociationType, endPo
 
 func (ud *UMLDiagram) RemoveSelectedComponents() duerror.DUError {
 	comps := map[component.Component]bool{}
+	selected := make([]*component.Gadget, 0, len(ud.componentsSelected))
 	for c := range ud.componentsSelected {
 		comps[c] = true
-		switch g := c.(type) {
-		case *component.Gadget:
-			for a := range ud.getAllAssociationsInGadget(g) {
-				comps[a] = true
-			}
+		if g, ok := c.(*component.Gadget); ok {
+			selected = append(selected, g)
+		}
+	}
+	// the gadgets in a package go with it
+	for _, g := range withDescendants(selected) {
+		comps[g] = true
+		for a := range ud.getAllAssociationsInGadget(g) {
+			comps[a] = true
+		}
+	}
+	for c := range comps {
+		if ud.isFrozen(c) {
+			return duerror.NewInvalidArgumentError("component is locked")
 		}
 	}
 	cmd := &removeSelectedComponentCommand{
@@ -625,7 +850,7 @@ func (ud *UMLDiagram) RemoveSelectedComponents() duerror.DUError {
 }
 
 func (ud *UMLDiagram) SelectComponent(point utils.Point) duerror.DUError {
-	c, err := ud.componentsContainer.Search(point)
+	c, err := ud.topmostAt(point, ud.isSelectable)
 	if err != nil {
 		return err
 	}
@@ -660,8 +885,72 @@ func (ud *UMLDiagram) SelectComponent(point utils.Point) duerror.DUError {
 	return nil
 }
 
+// ToggleSelectComponent flips the selection of the component under point, keeping the rest of the selection.
+func (ud *UMLDiagram) ToggleSelectComponent(point utils.Point) duerror.DUError {
+	c, err := ud.topmostAt(point, ud.isSelectable)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return nil
+	}
+	if c.GetIsSelected() {
+		return ud.changeSelection(nil, map[component.Component]bool{c: true})
+	}
+	return ud.changeSelection(map[component.Component]bool{c: true}, nil)
+}
+
+// SelectComponentsInRect selects the components lying entirely inside the rectangle spanned by p1 and p2.
+// If additive is false, components outside the rectangle are unselected.
+func (ud *UMLDiagram) SelectComponentsInRect(p1 utils.Point, p2 utils.Point, additive bool) duerror.DUError {
+	inside, err := ud.componentsContainer.SearchInRect(p1, p2)
+	if err != nil {
+		return err
+	}
+	toSelect := map[component.Component]bool{}
+	for _, c := range inside {
+		if !c.GetIsSelected() && ud.isSelectable(c) {
+			toSelect[c] = true
+		}
+	}
+	toUnselect := map[component.Component]bool{}
+	if !additive {
+		for c := range ud.componentsSelected {
+			if !slices.Contains(inside, c) {
+				toUnselect[c] = true
+			}
+		}
+	}
+	return ud.changeSelection(toSelect, toUnselect)
+}
+
+func (ud *UMLDiagram) SelectAllComponents() duerror.DUError {
+	toSelect := map[component.Component]bool{}
+	for _, c := range ud.componentsContainer.GetAll() {
+		if !c.GetIsSelected() && ud.isSelectable(c) {
+			toSelect[c] = true
+		}
+	}
+	return ud.changeSelection(toSelect, nil)
+}
+
+func (ud *UMLDiagram) ClearSelection() duerror.DUError {
+	return ud.changeSelection(nil, maps.Clone(ud.componentsSelected))
+}
+
+// InvertSelection selects every unselected component and unselects the selected ones.
+func (ud *UMLDiagram) InvertSelection() duerror.DUError {
+	toSelect := map[component.Component]bool{}
+	for _, c := range ud.componentsContainer.GetAll() {
+		if !c.GetIsSelected() && ud.isSelectable(c) {
+			toSelect[c] = true
+		}
+	}
+	return ud.changeSelection(toSelect, maps.Clone(ud.componentsSelected))
+}
+
 func (ud *UMLDiagram) AddAttributeToGadget(section int, content string) duerror.DUError {
-	c, err := ud.getSelectedComponent()
+	c, err := ud.getEditableComponent()
 	if err != nil {
 		return err
 	}
@@ -691,7 +980,7 @@ func (ud *UMLDiagram) AddAttributeToGadget(section int, content string) duerror.
 }
 
 func (ud *UMLDiagram) RemoveAttributeFromGadget(section int, index int) duerror.DUError {
-	c, err := ud.getSelectedComponent()
+	c, err := ud.getEditableComponent()
 	if err != nil {
 		return err
 	}
@@ -715,14 +1004,105 @@ func (ud *UMLDiagram) RemoveAttributeFromGadget(section int, index int) duerror.
 		section: section,
 		index:   index,
 	}
-	if err := ud.cmdManager.Execute(cmd); err != nil {
+	if err := ud.cmdManager.Execute(cmd); err != nil {
+		return err
+	}
+	return nil
+}
+
+// AddSectionToGadget inserts an empty section named name into the selected gadget at index, -1 appends it.
+func (ud *UMLDiagram) AddSectionToGadget(index int, name string) duerror.DUError {
+	g, err := ud.getEditableGadget()
+	if err != nil {
+		return err
+	}
+	if index == -1 {
+		index = len(g.GetSectionNames())
+	}
+	return ud.cmdManager.Execute(&addSectionCommand{
+		baseCommand: baseCommand{
+			diagram: ud,
+			before:  ud.GetLastModified(),
+			after:   time.Now(),
+		},
+		gadget:  g,
+		section: index,
+		name:    name,
+	})
+}
+
+// RemoveSectionFromGadget removes a section of the selected gadget along with its attributes.
+func (ud *UMLDiagram) RemoveSectionFromGadget(section int) duerror.DUError {
+	g, err := ud.getEditableGadget()
+	if err != nil {
+		return err
+	}
+	collapsed, err := g.IsSectionCollapsed(section)
+	if err != nil {
+		return err
+	}
+	atts := make([]utils.SavedAtt, 0, len(g.GetAttributes()[section]))
+	for _, att := range g.GetAttributes()[section] {
+		atts = append(atts, attribute.ToSavedAttribute(att))
+	}
+	return ud.cmdManager.Execute(&removeSectionCommand{
+		baseCommand: baseCommand{
+			diagram: ud,
+			before:  ud.GetLastModified(),
+			after:   time.Now(),
+		},
+		gadget:     g,
+		section:    section,
+		name:       g.GetSectionNames()[section],
+		collapsed:  collapsed,
+		attributes: atts,
+	})
+}
+
+// MoveSectionInGadget moves a section of the selected gadget so that it ends up at index to.
+func (ud *UMLDiagram) MoveSectionInGadget(from int, to int) duerror.DUError {
+	g, err := ud.getEditableGadget()
+	if err != nil {
+		return err
+	}
+	return ud.cmdManager.Execute(&moveSectionCommand{
+		baseCommand: baseCommand{
+			diagram: ud,
+			before:  ud.GetLastModified(),
+			after:   time.Now(),
+		},
+		gadget: g,
+		from:   from,
+		to:     to,
+	})
+}
+
+func (ud *UMLDiagram) SetSectionNameComponent(section int, name string) duerror.DUError {
+	g, err := ud.getEditableGadget()
+	if err != nil {
+		return err
+	}
+	if section < 0 || section >= len(g.GetSectionNames()) {
+		return duerror.NewInvalidArgumentError("section out of range")
+	}
+	return ud.executeSetter(g, propertySectionName, section, 0, g.GetSectionNames()[section], name)
+}
+
+// SetSectionCollapsedComponent hides or shows the attributes of a section of the selected gadget.
+func (ud *UMLDiagram) SetSectionCollapsedComponent(section int, collapsed bool) duerror.DUError {
+	g, err := ud.getEditableGadget()
+	if err != nil {
 		return err
 	}
-	return nil
+	old, err := g.IsSectionCollapsed(section)
+	if err != nil {
+		return err
+	}
+	return ud.executeSetter(g, propertySectionCollapsed, section, 0, old, collapsed)
 }
 
 func (ud *UMLDiagram) AddAttributeToAssociation(ratio float64, content string) duerror.DUError {
-	c, err := ud.getSelectedComponent()
+	c, err := ud.getEditableComponent()
 	if err != nil {
 		return err
 	}
@@ -749,7 +1129,7 @@ func (ud *UMLDiagram) AddAttributeToAssociation(ratio float64, content string) d
 }
 
 func (ud *UMLDiagram) RemoveAttributeFromAssociation(index int) duerror.DUError {
-	c, err := ud.getSelectedComponent()
+	c, err := ud.getEditableComponent()
 	if err != nil {
 		return err
 	}
@@ -790,6 +1170,357 @@ func (ud *UMLDiagram) getSelectedComponent() (component.Component, duerror.DUErr
 	return nil, duerror.NewInvalidArgumentError("no component selected")
 }
 
+// getSelectedGadget returns the only selected component, which must be a gadget.
+func (ud *UMLDiagram) getSelectedGadget() (*component.Gadget, duerror.DUError) {
+	c, err := ud.getSelectedComponent()
+	if err != nil {
+		return nil, err
+	}
+	g, ok := c.(*component.Gadget)
+	if !ok {
+		return nil, duerror.NewInvalidArgumentError("selected component is not a gadget")
+	}
+	return g, nil
+}
+
+// getSelectedComponents returns every selected component, at least one.
+func (ud *UMLDiagram) getSelectedComponents() ([]component.Component, duerror.DUError) {
+	if len(ud.componentsSelected) == 0 {
+		return nil, duerror.NewInvalidArgumentError("no component selected")
+	}
+	// undoing a selection may select components that have been hidden or locked since
+	for c := range ud.componentsSelected {
+		if !ud.isSelectable(c) {
+			return nil, duerror.NewInvalidArgumentError("a selected component is on a hidden or locked layer")
+		}
+	}
+	return slices.Collect(maps.Keys(ud.componentsSelected)), nil
+}
+
+// getSelectedGadgets returns the selected gadgets, associations in the selection are skipped.
+func (ud *UMLDiagram) getSelectedGadgets() ([]*component.Gadget, duerror.DUError) {
+	comps, err := ud.getSelectedComponents()
+	if err != nil {
+		return nil, err
+	}
+	gadgets := make([]*component.Gadget, 0, len(comps))
+	for _, c := range comps {
+		if g, ok := c.(*component.Gadget); ok {
+			gadgets = append(gadgets, g)
+		}
+	}
+	if len(gadgets) == 0 {
+		return nil, duerror.NewInvalidArgumentError("selected component is not a gadget")
+	}
+	return gadgets, nil
+}
+
+// getEditableComponent returns the only selected component, which must not be locked.
+func (ud *UMLDiagram) getEditableComponent() (component.Component, duerror.DUError) {
+	c, err := ud.getSelectedComponent()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUnlocked(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// getEditableGadget returns the only selected component, which must be a gadget that is not locked.
+func (ud *UMLDiagram) getEditableGadget() (*component.Gadget, duerror.DUError) {
+	g, err := ud.getSelectedGadget()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUnlocked(g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// getEditableGadgets returns the selected gadgets, none of which may be locked.
+func (ud *UMLDiagram) getEditableGadgets() ([]*component.Gadget, duerror.DUError) {
+	gadgets, err := ud.getSelectedGadgets()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUnlocked(gadgets...); err != nil {
+		return nil, err
+	}
+	return gadgets, nil
+}
+
+// checkUnlocked fails on the first locked component. Locked components can be selected and copied,
+// but not moved, resized, edited or removed.
+func checkUnlocked[T component.Component](comps ...T) duerror.DUError {
+	for _, c := range comps {
+		if c.GetIsLocked() {
+			return duerror.NewInvalidArgumentError("component is locked")
+		}
+	}
+	return nil
+}
+
+// executeAll executes cmds as a single undo step. The commands must not have been executed yet.
+func (ud *UMLDiagram) executeAll(cmds []command.Command) duerror.DUError {
+	switch len(cmds) {
+	case 0:
+		return nil
+	case 1:
+		return ud.cmdManager.Execute(cmds[0])
+	default:
+		return ud.cmdManager.Execute(command.NewCompoundCommand(cmds, ud.GetLastModified(), time.Now()))
+	}
+}
+
+// changeSelection selects and unselects the given components as one undo step.
+func (ud *UMLDiagram) changeSelection(toSelect map[component.Component]bool, toUnselect map[component.Component]bool) duerror.DUError {
+	cmds := make([]command.Command, 0, 2)
+	for _, group := range []struct {
+		comps    map[component.Component]bool
+		newValue bool
+	}{{toUnselect, false}, {toSelect, true}} {
+		if len(group.comps) == 0 {
+			continue
+		}
+		cmds = append(cmds, &selectAllCommand{
+			baseCommand: baseCommand{
+				diagram: ud,
+				before:  ud.GetLastModified(),
+				after:   time.Now(),
+			},
+			components: group.comps,
+			newValue:   group.newValue,
+		})
+	}
+	return ud.executeAll(cmds)
+}
+
+func (ud *UMLDiagram) executeSetter(c component.Component, property string, section, index int, oldValue, newValue any) duerror.DUError {
+	cmd, err := ud.newSetterCommand(c, property, section, index, oldValue, newValue)
+	if err != nil {
+		return err
+	}
+	cmd.before = ud.GetLastModified()
+	cmd.after = time.Now()
+	return ud.cmdManager.Execute(cmd)
+}
+
+// propertySetter returns the function that sets the given property of c.
+// section is ignored by associations, section and index are ignored by component level properties.
+func (ud *UMLDiagram) propertySetter(c component.Component, property string, section, index int) (func(value any) duerror.DUError, duerror.DUError) {
+	switch property {
+	case propertyLayer:
+		return func(value any) duerror.DUError {
+			layer, err := valueAs[int](value)
+			if err != nil {
+				return err
+			}
+			return c.SetLayer(layer)
+		}, nil
+	case propertyLayerName:
+		return func(value any) duerror.DUError {
+			name, err := valueAs[string](value)
+			if err != nil {
+				return err
+			}
+			return c.SetLayerName(name)
+		}, nil
+	case propertyLocked:
+		return func(value any) duerror.DUError {
+			locked, err := valueAs[bool](value)
+			if err != nil {
+				return err
+			}
+			return c.SetIsLocked(locked)
+		}, nil
+	case propertyStereotypes:
+		return func(value any) duerror.DUError {
+			stereotypes, err := valueAs[[]string](value)
+			if err != nil {
+				return err
+			}
+			return c.SetStereotypes(stereotypes)
+		}, nil
+	case propertyTaggedValues:
+		return func(value any) duerror.DUError {
+			tags, err := valueAs[map[string]string](value)
+			if err != nil {
+				return err
+			}
+			return c.SetTaggedValues(tags)
+		}, nil
+	case propertyColor:
+		g, ok := c.(*component.Gadget)
+		if !ok {
+			return nil, duerror.NewInvalidArgumentError("component is not a gadget")
+		}
+		return func(value any) duerror.DUError {
+			color, err := valueAs[string](value)
+			if err != nil {
+				return err
+			}
+			return g.SetColor(color)
+		}, nil
+	case propertyStyle, propertyStyleOverrides:
+		g, ok := c.(*component.Gadget)
+		if !ok {
+			return nil, duerror.NewInvalidArgumentError("component is not a gadget")
+		}
+		if property == propertyStyle {
+			return func(value any) duerror.DUError {
+				style, err := valueAs[string](value)
+				if err != nil {
+					return err
+				}
+				return g.SetStyle(style)
+			}, nil
+		}
+		return func(value any) duerror.DUError {
+			overrides, err := valueAs[int](value)
+			if err != nil {
+				return err
+			}
+			return g.SetStyleOverrides(component.StyleProperty(overrides))
+		}, nil
+	case propertyMaxWidth:
+		g, ok := c.(*component.Gadget)
+		if !ok {
+			return nil, duerror.NewInvalidArgumentError("component is not a gadget")
+		}
+		return func(value any) duerror.DUError {
+			width, err := valueAs[int](value)
+			if err != nil {
+				return err
+			}
+			return g.SetMaxWidth(width)
+		}, nil
+	case propertySectionName:
+		g, ok := c.(*component.Gadget)
+		if !ok {
+			return nil, duerror.NewInvalidArgumentError("component is not a gadget")
+		}
+		return func(value any) duerror.DUError {
+			name, err := valueAs[string](value)
+			if err != nil {
+				return err
+			}
+			return g.RenameSection(section, name)
+		}, nil
+	case propertySectionCollapsed:
+		g, ok := c.(*component.Gadget)
+		if !ok {
+			return nil, duerror.NewInvalidArgumentError("component is not a gadget")
+		}
+		return func(value any) duerror.DUError {
+			collapsed, err := valueAs[bool](value)
+			if err != nil {
+				return err
+			}
+			return g.SetSectionCollapsed(section, collapsed)
+		}, nil
+	case propertyAssType:
+		a, ok := c.(*component.Association)
+		if !ok {
+			return nil, duerror.NewInvalidArgumentError("component is not an association")
+		}
+		return func(value any) duerror.DUError {
+			assType, err := valueAs[int](value)
+			if err != nil {
+				return err
+			}
+			return a.SetAssType(component.AssociationType(assType))
+		}, nil
+	case propertyAttrContent, propertyAttrFont, propertyAttrColor:
+		var set func(value string) duerror.DUError
+		switch c := c.(type) {
+		case *component.Gadget:
+			set = map[string]func(value string) duerror.DUError{
+				propertyAttrContent: func(value string) duerror.DUError { return c.SetAttrContent(section, index, value) },
+				propertyAttrFont:    func(value string) duerror.DUError { return c.SetAttrFont(section, index, value) },
+				propertyAttrColor:   func(value string) duerror.DUError { return c.SetAttrColor(section, index, value) },
+			}[property]
+		case *component.Association:
+			set = map[string]func(value string) duerror.DUError{
+				propertyAttrContent: func(value string) duerror.DUError { return c.SetAttrContent(index, value) },
+				propertyAttrFont:    func(value string) duerror.DUError { return c.SetAttrFont(index, value) },
+				propertyAttrColor:   func(value string) duerror.DUError { return c.SetAttrColor(index, value) },
+			}[property]
+		default:
+			return nil, duerror.NewInvalidArgumentError("invalid selected component")
+		}
+		return func(value any) duerror.DUError {
+			v, err := valueAs[string](value)
+			if err != nil {
+				return err
+			}
+			if err := set(v); err != nil {
+				return err
+			}
+			if property == propertyAttrFont {
+				// a missing font brought back by undoing is reported as when it was loaded
+				if att, err := getAttribute(c, section, index); err == nil && att.IsFontMissing() {
+					ud.addFontWarning(att.GetFont())
+				}
+			}
+			return nil
+		}, nil
+	case propertyAttrSize, propertyAttrStyle, propertyAttrAlign:
+		var set func(value int) duerror.DUError
+		switch c := c.(type) {
+		case *component.Gadget:
+			set = map[string]func(value int) duerror.DUError{
+				propertyAttrSize:  func(value int) duerror.DUError { return c.SetAttrSize(section, index, value) },
+				propertyAttrStyle: func(value int) duerror.DUError { return c.SetAttrStyle(section, index, value) },
+				propertyAttrAlign: func(value int) duerror.DUError { return c.SetAttrAlign(section, index, value) },
+			}[property]
+		case *component.Association:
+			set = map[string]func(value int) duerror.DUError{
+				propertyAttrSize:  func(value int) duerror.DUError { return c.SetAttrSize(index, value) },
+				propertyAttrStyle: func(value int) duerror.DUError { return c.SetAttrStyle(index, value) },
+				propertyAttrAlign: func(value int) duerror.DUError { return c.SetAttrAlign(index, value) },
+			}[property]
+		default:
+			return nil, duerror.NewInvalidArgumentError("invalid selected component")
+		}
+		return func(value any) duerror.DUError {
+			v, err := valueAs[int](value)
+			if err != nil {
+				return err
+			}
+			return set(v)
+		}, nil
+	default:
+		return nil, duerror.NewInvalidArgumentError("unknown property " + property)
+	}
+}
+
+// getAttribute returns the attribute of a gadget or an association, section is ignored by associations
+func getAttribute(c component.Component, section, index int) (*attribute.Attribute, duerror.DUError) {
+	switch c := c.(type) {
+	case *component.Gadget:
+		return c.GetAttribute(section, index)
+	case *component.Association:
+		att, err := c.GetAttribute(index)
+		if err != nil {
+			return nil, err
+		}
+		return &att.Attribute, nil
+	default:
+		return nil, duerror.NewInvalidArgumentError("invalid selected component")
+	}
+}
+
+func valueAs[T any](value any) (T, duerror.DUError) {
+	v, ok := value.(T)
+	if !ok {
+		var zero T
+		return zero, duerror.NewInvalidArgumentError(fmt.Sprintf("invalid value type %T, expected %T", value, zero))
+	}
+	return v, nil
+}
+
 func (ud *UMLDiagram) validatePoint(point utils.Point) duerror.DUError {
 	if point.X < 0 || point.Y < 0 {
 		return duerror.NewInvalidArgumentError("point coordinates must be non-negative")
@@ -797,18 +1528,22 @@ func (ud *UMLDiagram) validatePoint(point utils.Point) duerror.DUError {
 	return nil
 }
 
-func (ud *UMLDiagram) loadGadgetAttributes(gadget *component.Gadget, attributes []utils.SavedAtt) (duerror.DUError, int) {
-	const SectionBound = 0.3 // A gadget has 3 sections: header, attributes, methods. Saving sections in SavedAtt.Ratio
+func (ud *UMLDiagram) loadGadgetAttributes(gadget *component.Gadget, savedGadget utils.SavedGad) (duerror.DUError, int) {
+	const SectionBound = 0.3 // Files of older versions save the section of an attribute in SavedAtt.Ratio
 	if gadget == nil {
 		return duerror.NewInvalidArgumentError("Cannot load attributes to a nil gadget"), 0
 	}
-	for index, savedAtt := range attributes {
+	for index, savedAtt := range savedGadget.Attributes {
 		newAtt, err := attribute.FromSavedAttribute(savedAtt)
 		if err != nil {
 			return err, index
 		}
 
-		if err = gadget.AddBuiltAttribute(int(savedAtt.Ratio/SectionBound), newAtt); err != nil {
+		section := savedAtt.Section
+		if len(savedGadget.Sections) == 0 {
+			section = int(savedAtt.Ratio / SectionBound)
+		}
+		if err = gadget.AddBuiltAttribute(section, newAtt); err != nil {
 			return err, index
 		}
 	}
@@ -824,8 +1559,11 @@ func (ud *UMLDiagram) loadGadgets(gadgets []utils.SavedGad) (map[int]*component.
 		if err != nil {
 			return nil, err
 		}
+		if err = gadget.SetFontFallbacks(ud.fontFallbackFiles); err != nil {
+			return nil, err
+		}
 
-		if err, errIndex := ud.loadGadgetAttributes(gadget, savedGadget.Attributes); err != nil {
+		if err, errIndex := ud.loadGadgetAttributes(gadget, savedGadget); err != nil {
 			return nil, duerror.NewCorruptedFile(fmt.Sprintf(
 				"Error on parsing %d-th attribute of %d-th gadget.  Detail: %s",
 				errIndex, index, err.Error()),
@@ -865,18 +1603,22 @@ func (ud *UMLDiagram) loadAssAttributes(ass *component.Association, attributes [
 	return nil, 0
 }
 
-func (ud *UMLDiagram) loadAsses(asses []utils.SavedAss, dp map[int]*component.Gadget) duerror.DUError {
+func (ud *UMLDiagram) loadAsses(asses []utils.SavedAss, dp map[int]*component.Gadget) (map[int]*component.Association, duerror.DUError) {
+	loaded := make(map[int]*component.Association, len(asses))
 	for index, ass := range asses {
 		parents := [2]*component.Gadget{dp[ass.Parents[0]], dp[ass.Parents[1]]}
 		newAss, err := component.FromSavedAssociation(ass, parents)
 		if err != nil {
-			return duerror.NewCorruptedFile(fmt.Sprintf("Error on creating %d-th association: %s", index, err.Error()))
+			return nil, duerror.NewCorruptedFile(fmt.Sprintf("Error on creating %d-th association: %s", index, err.Error()))
+		}
+		if err = newAss.SetFontFallbacks(ud.fontFallbackFiles); err != nil {
+			return nil, err
 		}
 		if err = ud.componentsContainer.Insert(newAss); err != nil {
-			return err
+			return nil, err
 		}
 		if err, attIndex := ud.loadAssAttributes(newAss, ass.Attributes); err != nil {
-			return duerror.NewCorruptedFile(fmt.Sprintf("Error on adding %d-th attribute for %d-th association: %s", attIndex, index, err.Error()))
+			return nil, duerror.NewCorruptedFile(fmt.Sprintf("Error on adding %d-th attribute for %d-th association: %s", attIndex, index, err.Error()))
 		}
 
 		// I'm a thief
@@ -889,16 +1631,18 @@ func (ud *UMLDiagram) loadAsses(asses []utils.SavedAss, dp map[int]*component.Ga
 		ud.associations[parents[1]] = tmp
 
 		if err = newAss.RegisterUpdateParentDraw(ud.updateDrawData); err != nil {
-			return err
+			return nil, err
 		}
+		loaded[index] = newAss
 	}
 
-	return nil
+	return loaded, nil
 }
 func (ud *UMLDiagram) collectGadgets(res *utils.SavedDiagram) (map[*component.Gadget]int, duerror.DUError) {
 	dp := make(map[*component.Gadget]int, ud.componentsContainer.Len())
 	cnt := 0
-	for _, comp := range ud.componentsContainer.GetAll() {
+	// saved from the bottom to the top, loading them in this order keeps the stacking of each layer
+	for _, comp := range ud.componentsByLayer() {
 		switch comp.(type) {
 		case *component.Gadget:
 			if _, ok := dp[comp.(*component.Gadget)]; !ok {
@@ -910,50 +1654,85 @@ func (ud *UMLDiagram) collectGadgets(res *utils.SavedDiagram) (map[*component.Ga
 			continue
 		}
 	}
+	saveChildren(dp, res.Gadgets)
 	return dp, nil
 }
 
-func (ud *UMLDiagram) collectAssociations(dp map[*component.Gadget]int, res *utils.SavedDiagram) duerror.DUError {
-	for comp, index := range dp {
-		for _, ass := range ud.associations[comp][0] {
-			milkBuyer := ass.GetParentEnd()
-			milkBuyerIndex, ok := dp[milkBuyer]
-			if !ok {
-				return duerror.NewParsingError("SecondParent not found")
-			}
-			res.Associations = append(res.Associations, ass.ToSavedAssociation(
-				[2]int{
-					index, milkBuyerIndex,
-				}))
+func (ud *UMLDiagram) collectAssociations(dp map[*component.Gadget]int, res *utils.SavedDiagram) (map[*component.Association]int, duerror.DUError) {
+	asses := make(map[*component.Association]int)
+	for _, comp := range ud.componentsByLayer() {
+		ass, ok := comp.(*component.Association)
+		if !ok {
+			continue
 		}
+		index, ok := dp[ass.GetParentStart()]
+		if !ok {
+			return nil, duerror.NewParsingError("FirstParent not found")
+		}
+		milkBuyer := ass.GetParentEnd()
+		milkBuyerIndex, ok := dp[milkBuyer]
+		if !ok {
+			return nil, duerror.NewParsingError("SecondParent not found")
+		}
+		asses[ass] = len(res.Associations)
+		res.Associations = append(res.Associations, ass.ToSavedAssociation(
+			[2]int{
+				index, milkBuyerIndex,
+			}))
 	}
-	return nil
+	return asses, nil
 }
 
 func (ud *UMLDiagram) SaveToFile(filename string) (*utils.SavedDiagram, duerror.DUError) {
+	res, _, _, err := ud.saveToFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// SaveToFileWithHistory exports the diagram together with its undo/redo history.
+// Commands that cannot be saved cut the history at that point.
+func (ud *UMLDiagram) SaveToFileWithHistory(filename string) (*utils.SavedDiagram, *utils.SavedHistory, duerror.DUError) {
+	res, gadgets, asses, err := ud.saveToFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	history := ud.saveHistory(gadgets, asses)
+	history.DiagramLastEdit = res.LastEdit
+	return res, history, nil
+}
+
+func (ud *UMLDiagram) saveToFile(filename string) (*utils.SavedDiagram, map[*component.Gadget]int, map[*component.Association]int, duerror.DUError) {
 	if filename != ud.name {
 		ud.name = filename
 	}
 
 	res := &utils.SavedDiagram{
-		Filetype:     utils.FiletypeDiagram | int(ud.diagramType)<<1,
-		LastEdit:     "",
-		Gadgets:      nil,
-		Associations: nil,
+		Filetype:      utils.FiletypeDiagram | int(ud.diagramType)<<1,
+		LastEdit:      "",
+		Gadgets:       nil,
+		Associations:  nil,
+		FontFallbacks: slices.Clone(ud.fontFallbacks),
+		GridSize:      ud.gridSize,
+		GridEnabled:   ud.gridEnabled,
+		Layers:        slices.Clone(ud.layers),
 	}
+	ud.saveProperties(res)
 
 	dp, err := ud.collectGadgets(res)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
-	if err := ud.collectAssociations(dp, res); err != nil {
-		return nil, err
+	asses, err := ud.collectAssociations(dp, res)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 	ud.lastSave = time.Now()
 	res.LastEdit = ud.lastSave.Format(time.RFC3339)
 
-	return res, nil
+	return res, dp, asses, nil
 }
 
 func (ud *UMLDiagram) HasUnsavedChanges() bool {
@@ -977,20 +1756,32 @@ func (ud *UMLDiagram) RegisterUpdateParentDraw(update func() duerror.DUError) du
 func (ud *UMLDiagram) updateDrawData() duerror.DUError {
 	gs := make([]drawdata.Gadget, 0, len(ud.componentsSelected))
 	as := make([]drawdata.Association, 0, len(ud.componentsSelected))
-	for _, c := range ud.componentsContainer.GetAll() {
+	order := make([]drawdata.DrawnComponent, 0, ud.componentsContainer.Len())
+	for _, c := range ud.componentsByLayer() {
+		if !ud.isVisible(c) {
+			continue
+		}
 		cDrawData := c.GetDrawData()
 		if cDrawData == nil {
 			continue
 		}
 		switch c.(type) {
 		case *component.Gadget:
+			order = append(order, drawdata.DrawnComponent{Index: len(gs)})
 			gs = append(gs, cDrawData.(drawdata.Gadget))
 		case *component.Association:
+			order = append(order, drawdata.DrawnComponent{Association: true, Index: len(as)})
 			as = append(as, cDrawData.(drawdata.Association))
 		}
 	}
 	ud.drawData.Gadgets = gs
 	ud.drawData.Associations = as
+	ud.drawData.Order = order
+	ud.drawData.GridSize = ud.gridSize
+	ud.drawData.GridEnabled = ud.gridEnabled
+	ud.drawData.Guides = slices.Clone(ud.guides)
+	ud.drawData.Color = ud.properties.BackgroundColor
+	ud.drawData.Layers = ud.GetLayers()
 	if ud.updateParentDraw == nil {
 		return nil
 	}
@@ -1009,9 +1800,15 @@ func (ud *UMLDiagram) addComponent(c component.Component) duerror.DUError {
 }
 
 func (ud *UMLDiagram) addGadget(g *component.Gadget) duerror.DUError {
+	if err := g.SetFontFallbacks(ud.fontFallbackFiles); err != nil {
+		return err
+	}
 	if err := ud.componentsContainer.Insert(g); err != nil {
 		return err
 	}
+	if err := g.AttachToParent(); err != nil {
+		return err
+	}
 	if _, ok := ud.associations[g]; !ok {
 		ud.associations[g] = [2][]*component.Association{{}, {}}
 	}
@@ -1025,9 +1822,16 @@ func (ud *UMLDiagram) addGadget(g *component.Gadget) duerror.DUError {
 }
 
 func (ud *UMLDiagram) addAssociation(a *component.Association) duerror.DUError {
+	if err := a.SetFontFallbacks(ud.fontFallbackFiles); err != nil {
+		return err
+	}
 	if err := ud.componentsContainer.Insert(a); err != nil {
 		return err
 	}
+	// removeAssociation unregisters it, so it must observe its parents again when added back
+	if err := a.RegisterAsObserver(); err != nil {
+		return err
+	}
 	// record it, cant modify the slice, being a value of the map, directly
 	stGad := a.GetParentStart()
 	enGad := a.GetParentEnd()
@@ -1055,6 +1859,120 @@ func (ud *UMLDiagram) addAssociation(a *component.Association) duerror.DUError {
 	return nil
 }
 
+func (ud *UMLDiagram) setDiagramProperty(property string, value any) duerror.DUError {
+	switch property {
+	case propertyFontFallbacks:
+		fonts, err := valueAs[[]string](value)
+		if err != nil {
+			return err
+		}
+		ud.applyFontFallbacks(fonts)
+		for _, c := range ud.componentsContainer.GetAll() {
+			switch c := c.(type) {
+			case *component.Gadget:
+				err = c.SetFontFallbacks(ud.fontFallbackFiles)
+			case *component.Association:
+				err = c.SetFontFallbacks(ud.fontFallbackFiles)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return ud.updateDrawData()
+	case propertyGridSize:
+		size, err := valueAs[int](value)
+		if err != nil {
+			return err
+		}
+		if size <= 0 {
+			return duerror.NewInvalidArgumentError("grid size must be positive")
+		}
+		ud.gridSize = size
+		return ud.updateDrawData()
+	case propertyGridEnabled:
+		enabled, err := valueAs[bool](value)
+		if err != nil {
+			return err
+		}
+		ud.gridEnabled = enabled
+		return ud.updateDrawData()
+	case propertyLayers:
+		layers, err := valueAs[[]utils.SavedLayer](value)
+		if err != nil {
+			return err
+		}
+		ud.layers = slices.Clone(layers)
+		return ud.updateDrawData()
+	case propertyProperties:
+		props, err := valueAs[DiagramProperties](value)
+		if err != nil {
+			return err
+		}
+		props.Tags = slices.Clone(props.Tags)
+		ud.properties = props
+		return ud.updateDrawData()
+	default:
+		return duerror.NewInvalidArgumentError("unknown diagram property " + property)
+	}
+}
+
+// applyFontFallbacks records the fallback fonts, those that cannot be loaded are left out of the chain
+// but kept in the diagram so they are used again where they exist.
+func (ud *UMLDiagram) applyFontFallbacks(fonts []string) {
+	ud.fontFallbacks = slices.Clone(fonts)
+	ud.fontFallbackFiles = make([]string, 0, len(fonts))
+	for _, name := range fonts {
+		if file, err := utils.ResolveFontFile(name); err == nil && utils.ValidateFontFile(file) == nil {
+			ud.fontFallbackFiles = append(ud.fontFallbackFiles, file)
+		}
+	}
+}
+
+// collectFontWarnings records a warning for every font of the diagram that is not available,
+// attributes using such a font are drawn with the default font instead.
+func (ud *UMLDiagram) collectFontWarnings() {
+	missing := make([]string, 0)
+	add := func(font string) {
+		if !slices.Contains(missing, font) {
+			missing = append(missing, font)
+		}
+	}
+	for _, name := range ud.fontFallbacks {
+		if file, err := utils.ResolveFontFile(name); err != nil || utils.ValidateFontFile(file) != nil {
+			add(name)
+		}
+	}
+	for _, c := range ud.componentsContainer.GetAll() {
+		switch c := c.(type) {
+		case *component.Gadget:
+			for _, section := range c.GetAttributes() {
+				for _, att := range section {
+					if att.IsFontMissing() {
+						add(att.GetFont())
+					}
+				}
+			}
+		case *component.Association:
+			for _, att := range c.GetAttributes() {
+				if att.IsFontMissing() {
+					add(att.GetFont())
+				}
+			}
+		}
+	}
+	for _, font := range missing {
+		ud.addFontWarning(font)
+	}
+}
+
+// addFontWarning records that a font is not available, once
+func (ud *UMLDiagram) addFontWarning(font string) {
+	warning := fmt.Sprintf("font %q is not available, using a substitute", font)
+	if !slices.Contains(ud.loadWarnings, warning) {
+		ud.loadWarnings = append(ud.loadWarnings, warning)
+	}
+}
+
 func (ud *UMLDiagram) removeComponent(c component.Component) duerror.DUError {
 	switch c := c.(type) {
 	case *component.Gadget:
@@ -1081,6 +1999,10 @@ func (ud *UMLDiagram) removeGadget(gad *component.Gadget) duerror.DUError {
 		delete(ud.associations, gad)
 	}
 	delete(ud.componentsSelected, gad)
+	// it keeps its package, so that it goes back in it when added again
+	if err := gad.DetachFromParent(); err != nil {
+		return err
+	}
 	if err := ud.componentsContainer.Remove(gad); err != nil {
 		return err
 	}
@@ -1177,9 +2099,16 @@ func (ud *UMLDiagram) selectAll(comps map[component.Component]bool, newValue boo
 }
 
 func (ud *UMLDiagram) moveGadget(g *component.Gadget, point utils.Point) duerror.DUError {
+	offset := utils.SubPoints(point, g.GetPoint())
 	if err := g.SetPoint(point); err != nil {
 		return err
 	}
+	// the gadgets in a package move with it
+	for _, c := range g.GetChildren() {
+		if err := ud.moveGadget(c, utils.AddPoints(c.GetPoint(), offset)); err != nil {
+			return err
+		}
+	}
 	if _, ok := ud.associations[g]; ok {
 		for _, a := range ud.associations[g][0] {
 			if err := a.UpdateDrawData(); err != nil {
@@ -1195,6 +2124,27 @@ func (ud *UMLDiagram) moveGadget(g *component.Gadget, point utils.Point) duerror
 	return nil
 }
 
+func (ud *UMLDiagram) moveGadgets(gadgets map[*component.Gadget]bool, offset utils.Point) duerror.DUError {
+	inMovedPackage := func(g *component.Gadget) bool {
+		for p := g.GetParent(); p != nil; p = p.GetParent() {
+			if gadgets[p] {
+				return true
+			}
+		}
+		return false
+	}
+	for g := range gadgets {
+		if inMovedPackage(g) {
+			// moves with its package
+			continue
+		}
+		if err := ud.moveGadget(g, utils.AddPoints(g.GetPoint(), offset)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (ud *UMLDiagram) setParentStartAssociation(a *component.Association, stNew *component.Gadget, stRatio [2]float64) duerror.DUError {
 	stOld := a.GetParentStart()
 	if err := a.SetParentStart(stNew, stRatio); err != nil {
@@ -1240,13 +2190,36 @@ func (ud *UMLDiagram) setParentEndAssociation(a *component.Association, enNew *c
 }
 
 func (ud *UMLDiagram) addAttributeGadget(g *component.Gadget, section, index int, content string) duerror.DUError {
-	return g.AddAttribute(section, index, content)
+	if err := g.AddAttribute(section, index, content); err != nil {
+		return err
+	}
+	return ud.styleAttribute(g, section, index)
 }
 
 func (ud *UMLDiagram) removeAttributeGadget(g *component.Gadget, section, index int) duerror.DUError {
 	return g.RemoveAttribute(section, index)
 }
 
+// restoreSection puts back a section removed by RemoveSectionFromGadget.
+func (ud *UMLDiagram) restoreSection(g *component.Gadget, section int, name string, collapsed bool, attributes []utils.SavedAtt) duerror.DUError {
+	if err := g.AddSection(section, name); err != nil {
+		return err
+	}
+	if err := g.SetSectionCollapsed(section, collapsed); err != nil {
+		return err
+	}
+	for _, saved := range attributes {
+		att, err := attribute.FromSavedAttribute(saved)
+		if err != nil {
+			return err
+		}
+		if err = g.AddBuiltAttribute(section, att); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (ud *UMLDiagram) addAttributeAssociation(a *component.Association, index int, ratio float64, content string) duerror.DUError {
 	return a.AddAttribute(index, ratio, content)
 }
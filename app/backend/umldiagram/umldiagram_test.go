@@ -87,7 +87,7 @@ func TestCreateEmptyDiagram(t *testing.T) {
 				assert.WithinDuration(t, time.Now(), diagram.GetLastModified(), time.Second)
 				assert.Equal(t, utils.Point{X: 0, Y: 0}, diagram.startPoint)
 				// New assertions
-				assert.Equal(t, "#FFFFFF", diagram.backgroundColor)
+				assert.Equal(t, "#FFFFFF", diagram.properties.BackgroundColor)
 				assert.NotNil(t, diagram.componentsContainer)
 			}
 		})
@@ -566,7 +566,7 @@ func TestLoadGadgetAttributes(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, gad)
 
-	err, _ = dia.loadGadgetAttributes(gad, savedAttributes) // Err-index is not important cuz we expect err is nil
+	err, _ = dia.loadGadgetAttributes(gad, utils.SavedGad{Attributes: savedAttributes}) // Err-index is not important cuz we expect err is nil
 	assert.NoError(t, err)
 
 	loadedAttributes := gad.GetAttributes()
@@ -607,7 +607,7 @@ func TestLoadGadgetAttributesButWithJsonStr(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, gad)
 
-	err, _ = dia.loadGadgetAttributes(gad, savedAttributes) // Err-index is not important cuz we expect err is nil
+	err, _ = dia.loadGadgetAttributes(gad, utils.SavedGad{Attributes: savedAttributes}) // Err-index is not important cuz we expect err is nil
 	assert.NoError(t, err)
 
 	loadedAttributes := gad.GetAttributes()
@@ -699,7 +699,7 @@ func TestUMLDiagram_LoadAsses(t *testing.T) {
 		savedAsses[i] = savedAssBase
 		savedAsses[i].Parents = []int{i, i + 1} // Assuming each association connects
 	}
-	err = dia.loadAsses(savedAsses, dp)
+	_, err = dia.loadAsses(savedAsses, dp)
 	assert.NoError(t, err)
 	// Check if associations are loaded correctly
 	components := dia.componentsContainer.GetAll()
@@ -1524,6 +1524,66 @@ func CMD_SET_ATTR_GAD(t *testing.T) {
 			t.Errorf("unexpected value: %v, got %v", oldValue, getValue(g))
 		}
 	})
+
+	t.Run("set color", func(t *testing.T) {
+		getValue := func(g *component.Gadget) string {
+			return g.GetDrawData().(drawdata.Gadget).Attributes[0][0].Color
+		}
+		newValue := "#FF0000"
+		oldValue := getValue(g)
+
+		err := d.SetAttrColorComponent(0, 0, newValue)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if getValue(g) != newValue {
+			t.Errorf("unexpected value: %v, got %v", newValue, getValue(g))
+		}
+		err = d.Undo()
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if getValue(g) != oldValue {
+			t.Errorf("unexpected value: %v, got %v", oldValue, getValue(g))
+		}
+		err = d.Redo()
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if getValue(g) != newValue {
+			t.Errorf("unexpected value: %v, got %v", oldValue, getValue(g))
+		}
+	})
+
+	t.Run("set align", func(t *testing.T) {
+		getValue := func(g *component.Gadget) int {
+			return g.GetDrawData().(drawdata.Gadget).Attributes[0][0].Align
+		}
+		newValue := int(attribute.AlignCenter)
+		oldValue := getValue(g)
+
+		err := d.SetAttrAlignComponent(0, 0, newValue)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if getValue(g) != newValue {
+			t.Errorf("unexpected value: %v, got %v", newValue, getValue(g))
+		}
+		err = d.Undo()
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if getValue(g) != oldValue {
+			t.Errorf("unexpected value: %v, got %v", oldValue, getValue(g))
+		}
+		err = d.Redo()
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if getValue(g) != newValue {
+			t.Errorf("unexpected value: %v, got %v", oldValue, getValue(g))
+		}
+	})
 }
 
 func CMD_SET_ATTR_ASS(t *testing.T) {
@@ -1640,6 +1700,66 @@ func CMD_SET_ATTR_ASS(t *testing.T) {
 		}
 	})
 
+	t.Run("set color", func(t *testing.T) {
+		getValue := func(a *component.Association) string {
+			return a.GetDrawData().(drawdata.Association).Attributes[0].Color
+		}
+		newValue := "#FF0000"
+		oldValue := getValue(a)
+
+		err := d.SetAttrColorComponent(0, 0, newValue)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if getValue(a) != newValue {
+			t.Errorf("unexpected value: %v, got %v", newValue, getValue(a))
+		}
+		err = d.Undo()
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if getValue(a) != oldValue {
+			t.Errorf("unexpected value: %v, got %v", oldValue, getValue(a))
+		}
+		err = d.Redo()
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if getValue(a) != newValue {
+			t.Errorf("unexpected value: %v, got %v", oldValue, getValue(a))
+		}
+	})
+
+	t.Run("set align", func(t *testing.T) {
+		getValue := func(a *component.Association) int {
+			return a.GetDrawData().(drawdata.Association).Attributes[0].Align
+		}
+		newValue := int(attribute.AlignRight)
+		oldValue := getValue(a)
+
+		err := d.SetAttrAlignComponent(0, 0, newValue)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if getValue(a) != newValue {
+			t.Errorf("unexpected value: %v, got %v", newValue, getValue(a))
+		}
+		err = d.Undo()
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if getValue(a) != oldValue {
+			t.Errorf("unexpected value: %v, got %v", oldValue, getValue(a))
+		}
+		err = d.Redo()
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if getValue(a) != newValue {
+			t.Errorf("unexpected value: %v, got %v", oldValue, getValue(a))
+		}
+	})
+
 	t.Run("set ratio", func(t *testing.T) {
 		getValue := func(a *component.Association) float64 {
 			return a.GetDrawData().(drawdata.Association).Attributes[0].Ratio
@@ -2334,3 +2454,1704 @@ func TestSetAssociationType(t *testing.T) {
 		assert.Equal(t, int(component.Composition), diagramDrawData.Associations[0].AssType)
 	})
 }
+
+func TestUMLDiagram_Transaction(t *testing.T) {
+	t.Run("commit groups edits into one undo step", func(t *testing.T) {
+		d, err := CreateEmptyUMLDiagram("test.uml", ClassDiagram)
+		assert.NoError(t, err)
+
+		assert.NoError(t, d.BeginTransaction())
+		assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "A"))
+		assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 200, Y: 200}, 0, drawdata.DefaultGadgetColor, "B"))
+		assert.NoError(t, d.CommitTransaction())
+		assert.Equal(t, 2, d.componentsContainer.Len())
+
+		assert.NoError(t, d.Undo())
+		assert.Equal(t, 0, d.componentsContainer.Len())
+		assert.Equal(t, 0, len(d.GetDrawData().Gadgets))
+
+		assert.NoError(t, d.Redo())
+		assert.Equal(t, 2, d.componentsContainer.Len())
+	})
+
+	t.Run("rollback reverts edits", func(t *testing.T) {
+		d, err := CreateEmptyUMLDiagram("test.uml", ClassDiagram)
+		assert.NoError(t, err)
+		assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "A"))
+
+		assert.NoError(t, d.BeginTransaction())
+		assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 200, Y: 200}, 0, drawdata.DefaultGadgetColor, "B"))
+		assert.NoError(t, d.RollbackTransaction())
+		assert.Equal(t, 1, d.componentsContainer.Len())
+		assert.Equal(t, 1, len(d.GetDrawData().Gadgets))
+
+		// the edit before the transaction is still undoable
+		assert.NoError(t, d.Undo())
+		assert.Equal(t, 0, d.componentsContainer.Len())
+	})
+}
+
+func TestUMLDiagram_DragMerge(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("test.uml", ClassDiagram)
+	assert.NoError(t, err)
+	start := utils.Point{X: 10, Y: 10}
+	assert.NoError(t, d.AddGadget(component.Class, start, 0, drawdata.DefaultGadgetColor, "A"))
+	assert.NoError(t, d.SelectComponent(start))
+
+	assert.NoError(t, d.StartDrag())
+	for i := 1; i <= 50; i++ {
+		assert.NoError(t, d.SetPointComponent(utils.Point{X: 10 + i, Y: 10 + i}))
+	}
+	assert.NoError(t, d.EndDrag())
+	assert.Equal(t, 60, d.GetDrawData().Gadgets[0].X)
+
+	// one undo brings the gadget back to where the drag started
+	assert.NoError(t, d.Undo())
+	assert.Equal(t, start.X, d.GetDrawData().Gadgets[0].X)
+	assert.Equal(t, start.Y, d.GetDrawData().Gadgets[0].Y)
+
+	assert.NoError(t, d.Redo())
+	assert.Equal(t, 60, d.GetDrawData().Gadgets[0].X)
+}
+
+func TestUMLDiagram_HistoryBranches(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("test.uml", ClassDiagram)
+	assert.NoError(t, err)
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 10, Y: 10}, 0, drawdata.DefaultGadgetColor, "A"))
+	assert.NoError(t, d.SelectComponent(utils.Point{X: 15, Y: 15}))
+	fork := d.GetHistoryState()
+
+	// two alternative colors for the same gadget
+	assert.NoError(t, d.SetColorComponent("#111111"))
+	red := d.GetHistoryState()
+	assert.NoError(t, d.Undo())
+	assert.NoError(t, d.SetColorComponent("#222222"))
+	blue := d.GetHistoryState()
+	assert.Len(t, d.ListHistoryBranches(), 2)
+
+	assert.NoError(t, d.SwitchHistoryBranch(red))
+	assert.Equal(t, "#111111", d.GetDrawData().Gadgets[0].Color)
+	assert.NoError(t, d.SwitchHistoryBranch(blue))
+	assert.Equal(t, "#222222", d.GetDrawData().Gadgets[0].Color)
+
+	changes, err := d.DiffHistoryStates(red, blue)
+	assert.NoError(t, err)
+	assert.Equal(t, []HistoryChange{
+		{Undo: true, Kind: savedKindSetter, Property: propertyColor, Time: changes[0].Time},
+		{Undo: false, Kind: savedKindSetter, Property: propertyColor, Time: changes[1].Time},
+	}, changes)
+
+	assert.NoError(t, d.GoToHistoryState(fork))
+	assert.Equal(t, drawdata.DefaultGadgetColor, d.GetDrawData().Gadgets[0].Color)
+	assert.Error(t, d.SwitchHistoryBranch(fork))
+}
+
+func TestUMLDiagram_MultiSelection(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("test.uml", ClassDiagram)
+	assert.NoError(t, err)
+	for i, header := range []string{"A", "B", "C"} {
+		p := utils.Point{X: 10 + 200*i, Y: 10 + 200*i}
+		assert.NoError(t, d.AddGadget(component.Class, p, 0, drawdata.DefaultGadgetColor, header))
+	}
+	inA := utils.Point{X: 15, Y: 15}
+	inB := utils.Point{X: 215, Y: 215}
+	inC := utils.Point{X: 415, Y: 415}
+
+	// marquee around A and B, corners in any order
+	assert.NoError(t, d.SelectComponentsInRect(utils.Point{X: 390, Y: 390}, utils.Point{X: 0, Y: 0}, false))
+	assert.Len(t, d.componentsSelected, 2)
+
+	// a new marquee replaces the selection unless it is additive
+	assert.NoError(t, d.SelectComponentsInRect(utils.Point{X: 390, Y: 390}, utils.Point{X: 800, Y: 800}, true))
+	assert.Len(t, d.componentsSelected, 3)
+	assert.NoError(t, d.SelectComponentsInRect(utils.Point{X: 390, Y: 390}, utils.Point{X: 800, Y: 800}, false))
+	assert.Len(t, d.componentsSelected, 1)
+	c, _ := d.componentsContainer.Search(inC)
+	assert.True(t, c.GetIsSelected())
+
+	// the replacing marquee is a single undo step
+	assert.NoError(t, d.Undo())
+	assert.Len(t, d.componentsSelected, 3)
+	assert.NoError(t, d.Redo())
+
+	assert.NoError(t, d.ToggleSelectComponent(inA))
+	assert.NoError(t, d.ToggleSelectComponent(inC))
+	a, _ := d.componentsContainer.Search(inA)
+	assert.True(t, a.GetIsSelected())
+	assert.False(t, c.GetIsSelected())
+	// toggling empty space keeps the selection
+	assert.NoError(t, d.ToggleSelectComponent(utils.Point{X: 1000, Y: 1000}))
+	assert.Len(t, d.componentsSelected, 1)
+
+	assert.NoError(t, d.InvertSelection())
+	assert.False(t, a.GetIsSelected())
+	b, _ := d.componentsContainer.Search(inB)
+	assert.True(t, b.GetIsSelected())
+	assert.True(t, c.GetIsSelected())
+
+	assert.NoError(t, d.SelectAllComponents())
+	assert.Len(t, d.componentsSelected, 3)
+	assert.NoError(t, d.ClearSelection())
+	assert.Len(t, d.componentsSelected, 0)
+	assert.NoError(t, d.Undo())
+	assert.Len(t, d.componentsSelected, 3)
+}
+
+func TestUMLDiagram_MultiSetters(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("test.uml", ClassDiagram)
+	assert.NoError(t, err)
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 10, Y: 20}, 0, drawdata.DefaultGadgetColor, "A"))
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 200, Y: 100}, 0, drawdata.DefaultGadgetColor, "B"))
+	assert.NoError(t, d.StartAddAssociation(utils.Point{X: 10, Y: 20}))
+	assert.NoError(t, d.EndAddAssociation(component.Extension, utils.Point{X: 200, Y: 100}))
+
+	err = d.SetColorComponent("#123456")
+	assert.Error(t, err)
+	assert.Equal(t, "no component selected", err.Error())
+
+	assert.NoError(t, d.SelectAllComponents())
+	assert.Len(t, d.componentsSelected, 3)
+
+	// associations in the selection are skipped by gadget only setters
+	assert.NoError(t, d.SetColorComponent("#123456"))
+	for _, g := range d.GetDrawData().Gadgets {
+		assert.Equal(t, "#123456", g.Color)
+	}
+	assert.NoError(t, d.SetLayerComponent(3))
+	for _, c := range d.componentsContainer.GetAll() {
+		assert.Equal(t, 3, c.GetLayer())
+	}
+
+	// the top-left gadget lands on the point, the other keeps its offset
+	assert.NoError(t, d.SetPointComponent(utils.Point{X: 0, Y: 0}))
+	gadgets := d.GetDrawData().Gadgets
+	points := []utils.Point{{X: gadgets[0].X, Y: gadgets[0].Y}, {X: gadgets[1].X, Y: gadgets[1].Y}}
+	assert.ElementsMatch(t, []utils.Point{{X: 0, Y: 0}, {X: 190, Y: 80}}, points)
+
+	// every setter was a single undo step
+	assert.NoError(t, d.Undo())
+	gadgets = d.GetDrawData().Gadgets
+	points = []utils.Point{{X: gadgets[0].X, Y: gadgets[0].Y}, {X: gadgets[1].X, Y: gadgets[1].Y}}
+	assert.ElementsMatch(t, []utils.Point{{X: 10, Y: 20}, {X: 200, Y: 100}}, points)
+	assert.NoError(t, d.Undo())
+	for _, c := range d.componentsContainer.GetAll() {
+		assert.Equal(t, 0, c.GetLayer())
+	}
+	assert.NoError(t, d.Undo())
+	for _, g := range d.GetDrawData().Gadgets {
+		assert.Equal(t, drawdata.DefaultGadgetColor, g.Color)
+	}
+}
+
+func TestUMLDiagram_MultiMove_DragAndHistory(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("test.uml", ClassDiagram)
+	assert.NoError(t, err)
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 10, Y: 10}, 0, drawdata.DefaultGadgetColor, "A"))
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 200, Y: 200}, 0, drawdata.DefaultGadgetColor, "B"))
+	assert.NoError(t, d.SelectAllComponents())
+
+	assert.NoError(t, d.StartDrag())
+	for i := 1; i <= 10; i++ {
+		assert.NoError(t, d.SetPointComponent(utils.Point{X: 10 + i, Y: 10}))
+	}
+	assert.NoError(t, d.EndDrag())
+
+	saved, history, err := d.SaveToFileWithHistory("test.uml")
+	assert.NoError(t, err)
+	saved.Filetype >>= 1
+	loaded, err := LoadExistUMLDiagramWithHistory("test.uml", *saved, *history)
+	assert.NoError(t, err)
+	xs := func(d *UMLDiagram) []int {
+		res := []int{}
+		for _, g := range d.GetDrawData().Gadgets {
+			res = append(res, g.X)
+		}
+		return res
+	}
+	assert.ElementsMatch(t, []int{20, 210}, xs(loaded))
+
+	// the whole drag is one step, also after reloading
+	assert.NoError(t, loaded.Undo())
+	assert.ElementsMatch(t, []int{10, 200}, xs(loaded))
+}
+
+func TestUMLDiagram_SaveLoadHistory(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("history.uml", ClassDiagram)
+	assert.NoError(t, err)
+
+	p0 := utils.Point{X: 0, Y: 0}
+	p1 := utils.Point{X: 200, Y: 200}
+	p2 := utils.Point{X: 400, Y: 400}
+	assert.NoError(t, d.AddGadget(component.Class, p0, 0, drawdata.DefaultGadgetColor, "A"))
+	assert.NoError(t, d.AddGadget(component.Class, p1, 0, drawdata.DefaultGadgetColor, "B"))
+	assert.NoError(t, d.AddGadget(component.Class, p2, 0, drawdata.DefaultGadgetColor, "C"))
+	assert.NoError(t, d.StartAddAssociation(p0))
+	assert.NoError(t, d.EndAddAssociation(component.Extension, p1))
+
+	// edit A, then remove C and undo one step so there is something to redo
+	// click inside the gadgets, away from the association between their corners
+	assert.NoError(t, d.SelectComponent(utils.Point{X: 3, Y: 15}))
+	assert.NoError(t, d.SetColorComponent("#123456"))
+	assert.NoError(t, d.SetAttrContentComponent(0, 0, "A2"))
+	assert.NoError(t, d.BeginTransaction())
+	assert.NoError(t, d.SetPointComponent(utils.Point{X: 10, Y: 10}))
+	assert.NoError(t, d.AddAttributeToGadget(1, "+ field: int"))
+	assert.NoError(t, d.CommitTransaction())
+	assert.NoError(t, d.SelectComponent(utils.Point{X: 1000, Y: 1000}))
+	assert.NoError(t, d.SelectComponent(p2))
+	assert.NoError(t, d.RemoveSelectedComponents())
+	assert.NoError(t, d.SelectComponent(utils.Point{X: 203, Y: 215}))
+	assert.NoError(t, d.RemoveSelectedComponents())
+	assert.NoError(t, d.Undo())
+
+	saved, history, err := d.SaveToFileWithHistory("history.uml")
+	assert.NoError(t, err)
+	assert.Equal(t, saved.LastEdit, history.DiagramLastEdit)
+	undo, redo := d.cmdManager.GetHistory()
+	assert.Equal(t, len(undo), len(history.Undo))
+	assert.Equal(t, len(redo), len(history.Redo))
+
+	// through JSON, as it is written to the sidecar file
+	data, err := json.Marshal(history)
+	assert.NoError(t, err)
+	var loadedHistory utils.SavedHistory
+	assert.NoError(t, json.Unmarshal(data, &loadedHistory))
+	data, err = json.Marshal(saved)
+	assert.NoError(t, err)
+	var loadedFile utils.SavedDiagram
+	assert.NoError(t, json.Unmarshal(data, &loadedFile))
+	loadedFile.Filetype >>= 1
+
+	loaded, err := LoadExistUMLDiagramWithHistory("history.uml", loadedFile, loadedHistory)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(loaded.GetDrawData().Gadgets))
+	assert.Equal(t, 1, len(loaded.GetDrawData().Associations))
+
+	findGadget := func(header string) *drawdata.Gadget {
+		for _, g := range loaded.GetDrawData().Gadgets {
+			if g.Attributes[0][0].Content == header {
+				return &g
+			}
+		}
+		return nil
+	}
+
+	// redo the removal of B and its association
+	assert.NoError(t, loaded.Redo())
+	assert.Equal(t, 1, len(loaded.GetDrawData().Gadgets))
+	assert.Equal(t, 0, len(loaded.GetDrawData().Associations))
+	assert.NoError(t, loaded.Undo())
+	assert.Equal(t, 1, len(loaded.GetDrawData().Associations))
+
+	// select B, then removing C, which only existed in the history
+	assert.NoError(t, loaded.Undo())
+	assert.NoError(t, loaded.Undo())
+	assert.NotNil(t, findGadget("C"))
+
+	// deselect, select C
+	assert.NoError(t, loaded.Undo())
+	assert.NoError(t, loaded.Undo())
+
+	// the transaction is one step
+	assert.NoError(t, loaded.Undo())
+	a := findGadget("A2")
+	assert.NotNil(t, a)
+	assert.Equal(t, p0.X, a.X)
+	assert.Equal(t, 0, len(a.Attributes[1]))
+
+	assert.NoError(t, loaded.Undo())
+	a = findGadget("A")
+	assert.NotNil(t, a)
+	assert.Equal(t, "#123456", a.Color)
+	assert.NoError(t, loaded.Undo())
+	assert.Equal(t, drawdata.DefaultGadgetColor, findGadget("A").Color)
+}
+
+func TestUMLDiagram_LoadHistory_Mismatch(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("history.uml", ClassDiagram)
+	assert.NoError(t, err)
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "A"))
+	saved, history, err := d.SaveToFileWithHistory("history.uml")
+	assert.NoError(t, err)
+	saved.Filetype >>= 1
+
+	history.DiagramLastEdit = "yesterday"
+	_, err = LoadExistUMLDiagramWithHistory("history.uml", *saved, *history)
+	assert.Error(t, err)
+
+	history.DiagramLastEdit = saved.LastEdit
+	history.Undo[0].Kind = "unknown"
+	_, err = LoadExistUMLDiagramWithHistory("history.uml", *saved, *history)
+	assert.Error(t, err)
+}
+
+func TestUMLDiagram_PasteComponents_Invalid(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("test.uml", ClassDiagram)
+	assert.NoError(t, err)
+
+	_, err = d.CopySelectedComponents()
+	assert.Error(t, err)
+
+	fragment := utils.SavedDiagram{
+		Filetype: utils.FiletypeDiagram,
+		Gadgets:  []utils.SavedGad{{GadgetType: int(component.Class), Point: "0, 0", Color: drawdata.DefaultGadgetColor}},
+		Associations: []utils.SavedAss{
+			{AssType: int(component.Extension), Parents: []int{0, 1}},
+		},
+	}
+	assert.Error(t, d.PasteComponents(fragment, utils.Point{}))
+	assert.Len(t, d.GetDrawData().Gadgets, 0)
+
+	fragment.Associations = nil
+	assert.NoError(t, d.PasteComponents(fragment, utils.Point{X: 5, Y: 5}))
+	assert.Equal(t, 5, d.GetDrawData().Gadgets[0].X)
+}
+
+func TestUMLDiagram_FontFallbacks(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("fonts.uml", ClassDiagram)
+	assert.NoError(t, err)
+	// Inkfree has no greek letters
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "ab αβ"))
+	runs := d.GetDrawData().Gadgets[0].Attributes[0][0].Lines[0].Runs
+	assert.Len(t, runs, 1)
+	assert.Equal(t, "Inkfree", runs[0].FontFile)
+
+	assert.Error(t, d.SetFontFallbacks([]string{"NoSuchFont"}))
+	assert.Error(t, d.SetFontFallbacks([]string{""}))
+	assert.Empty(t, d.GetFontFallbacks())
+
+	assert.NoError(t, d.SetFontFallbacks([]string{"Arial"}))
+	assert.Equal(t, []string{"Arial"}, d.GetFontFallbacks())
+	runs = d.GetDrawData().Gadgets[0].Attributes[0][0].Lines[0].Runs
+	assert.Len(t, runs, 2)
+	assert.Equal(t, drawdata.TextRun{Content: "αβ", FontFile: "Arial", Width: runs[1].Width}, runs[1])
+
+	// components added later use the chain too
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 300, Y: 0}, 0, drawdata.DefaultGadgetColor, "γ"))
+	for _, g := range d.GetDrawData().Gadgets {
+		assert.Equal(t, "Arial", g.Attributes[0][0].Lines[0].Runs[len(g.Attributes[0][0].Lines[0].Runs)-1].FontFile)
+	}
+
+	// saved, along with the command in the history
+	saved, history, err := d.SaveToFileWithHistory("fonts.uml")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Arial"}, saved.FontFallbacks)
+	saved.Filetype >>= 1
+	loaded, err := LoadExistUMLDiagramWithHistory("fonts.uml", *saved, *history)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Arial"}, loaded.GetFontFallbacks())
+	for _, g := range loaded.GetDrawData().Gadgets {
+		assert.Equal(t, "Arial", g.Attributes[0][0].Lines[0].Runs[len(g.Attributes[0][0].Lines[0].Runs)-1].FontFile)
+	}
+	assert.NoError(t, loaded.Undo())
+	assert.NoError(t, loaded.Undo())
+	assert.Empty(t, loaded.GetFontFallbacks())
+	runs = loaded.GetDrawData().Gadgets[0].Attributes[0][0].Lines[0].Runs
+	assert.Len(t, runs, 1)
+
+	// a fallback missing on this machine does not prevent loading and is kept
+	saved.FontFallbacks = []string{"NoSuchFont", "Arial"}
+	loaded, err = LoadExistUMLDiagram("fonts.uml", *saved)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"NoSuchFont", "Arial"}, loaded.GetFontFallbacks())
+	arial, err := utils.ResolveFontFile("Arial")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{arial}, loaded.fontFallbackFiles)
+	assert.Equal(t, []string{`font "NoSuchFont" is not available, using a substitute`}, loaded.GetLoadWarnings())
+}
+
+func TestUMLDiagram_MissingAttributeFont(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("missing.uml", ClassDiagram)
+	assert.NoError(t, err)
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "a"))
+	saved, err := d.SaveToFile("missing.uml")
+	assert.NoError(t, err)
+	assert.Equal(t, "Ink Free", saved.Gadgets[0].Attributes[0].Font)
+
+	saved.Filetype >>= 1
+	saved.Gadgets[0].Attributes[0].Font = "No Such Font"
+	loaded, err := LoadExistUMLDiagram("missing.uml", *saved)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`font "No Such Font" is not available, using a substitute`}, loaded.GetLoadWarnings())
+	att := loaded.GetDrawData().Gadgets[0].Attributes[0][0]
+	assert.Equal(t, "No Such Font", att.FontFamily)
+	assert.Equal(t, "Inkfree", att.FontFile)
+
+	// the missing font is kept in the file
+	resaved, err := loaded.SaveToFile("missing.uml")
+	assert.NoError(t, err)
+	assert.Equal(t, "No Such Font", resaved.Gadgets[0].Attributes[0].Font)
+
+	// undoing a new font brings the missing one back, not the substitute
+	assert.NoError(t, loaded.SelectComponent(utils.Point{X: 10, Y: 10}))
+	assert.Error(t, loaded.SetAttrFontComponent(0, 0, "Another Missing Font"))
+	assert.NoError(t, loaded.SetAttrFontComponent(0, 0, "Inkfree"))
+	assert.Equal(t, "Ink Free", loaded.GetDrawData().Gadgets[0].Attributes[0][0].FontFamily)
+	assert.NoError(t, loaded.Undo())
+	att = loaded.GetDrawData().Gadgets[0].Attributes[0][0]
+	assert.Equal(t, "No Such Font", att.FontFamily)
+	assert.Equal(t, "Inkfree", att.FontFile)
+	assert.Len(t, loaded.GetLoadWarnings(), 1)
+	resaved, err = loaded.SaveToFile("missing.uml")
+	assert.NoError(t, err)
+	assert.Equal(t, "No Such Font", resaved.Gadgets[0].Attributes[0].Font)
+	assert.NoError(t, loaded.Redo())
+	assert.Equal(t, "Ink Free", loaded.GetDrawData().Gadgets[0].Attributes[0][0].FontFamily)
+}
+
+func TestUMLDiagram_SetMaxWidthComponent(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("wrap.uml", ClassDiagram)
+	assert.NoError(t, err)
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor,
+		"VeryLongClassNameThatStretches With Spaces In It"))
+	wide := d.GetDrawData().Gadgets[0].Width
+
+	assert.Error(t, d.SetMaxWidthComponent(100))
+	assert.NoError(t, d.SelectComponent(utils.Point{X: 2, Y: 2}))
+	assert.Error(t, d.SetMaxWidthComponent(-5))
+	assert.NoError(t, d.SetMaxWidthComponent(100))
+	gadget := d.GetDrawData().Gadgets[0]
+	assert.LessOrEqual(t, gadget.Width, 100)
+	assert.Greater(t, len(gadget.Attributes[0][0].Lines), 1)
+
+	// saved with the gadget and undoable
+	saved, history, err := d.SaveToFileWithHistory("wrap.uml")
+	assert.NoError(t, err)
+	assert.Equal(t, 100, saved.Gadgets[0].MaxWidth)
+	saved.Filetype >>= 1
+	loaded, err := LoadExistUMLDiagramWithHistory("wrap.uml", *saved, *history)
+	assert.NoError(t, err)
+	assert.Equal(t, gadget.Width, loaded.GetDrawData().Gadgets[0].Width)
+	assert.NoError(t, loaded.Undo())
+	assert.Equal(t, wide, loaded.GetDrawData().Gadgets[0].Width)
+	assert.Len(t, loaded.GetDrawData().Gadgets[0].Attributes[0][0].Lines, 1)
+}
+
+func TestUMLDiagram_SetAttrLookComponent(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("look.uml", ClassDiagram)
+	assert.NoError(t, err)
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "a"))
+	assert.NoError(t, d.SelectComponent(utils.Point{X: 5, Y: 5}))
+	att := func(d *UMLDiagram) drawdata.Attribute {
+		return d.GetDrawData().Gadgets[0].Attributes[0][0]
+	}
+
+	assert.NoError(t, d.SetAttrColorComponent(0, 0, "#00FF00"))
+	assert.NoError(t, d.SetAttrAlignComponent(0, 0, int(attribute.AlignCenter)))
+	assert.NoError(t, d.SetAttrStyleComponent(0, 0, attribute.Underline|attribute.Strikethrough))
+	assert.Error(t, d.SetAttrColorComponent(0, 0, "green"))
+	assert.Error(t, d.SetAttrAlignComponent(0, 0, 7))
+	assert.Equal(t, "#00FF00", att(d).Color)
+	assert.Equal(t, int(attribute.AlignCenter), att(d).Align)
+	assert.Equal(t, attribute.Underline|attribute.Strikethrough, att(d).FontStyle)
+
+	// the setters survive saving the history
+	saved, history, err := d.SaveToFileWithHistory("look.uml")
+	assert.NoError(t, err)
+	saved.Filetype >>= 1
+	loaded, err := LoadExistUMLDiagramWithHistory("look.uml", *saved, *history)
+	assert.NoError(t, err)
+	assert.Equal(t, "#00FF00", att(loaded).Color)
+	assert.Equal(t, int(attribute.AlignCenter), att(loaded).Align)
+	assert.NoError(t, loaded.Undo())
+	assert.NoError(t, loaded.Undo())
+	assert.Equal(t, int(attribute.AlignLeft), att(loaded).Align)
+	assert.NoError(t, loaded.Undo())
+	assert.Equal(t, drawdata.DefaultAttributeColor, att(loaded).Color)
+	assert.NoError(t, loaded.Redo())
+	assert.Equal(t, "#00FF00", att(loaded).Color)
+}
+
+func TestUMLDiagram_Sections(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("sections.uml", ClassDiagram)
+	assert.NoError(t, err)
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "A"))
+	assert.NoError(t, d.SelectComponent(utils.Point{X: 5, Y: 5}))
+	assert.NoError(t, d.AddAttributeToGadget(2, "m()"))
+	names := func(d *UMLDiagram) []string {
+		sections := d.GetDrawData().Gadgets[0].Sections
+		names := make([]string, len(sections))
+		for i, s := range sections {
+			names[i] = s.Name
+		}
+		return names
+	}
+
+	assert.NoError(t, d.AddSectionToGadget(-1, "Responsibilities"))
+	assert.NoError(t, d.MoveSectionInGadget(3, 1))
+	assert.NoError(t, d.SetSectionNameComponent(1, "Duties"))
+	assert.NoError(t, d.SetSectionCollapsedComponent(3, true))
+	assert.NoError(t, d.RemoveSectionFromGadget(3))
+	assert.Error(t, d.RemoveSectionFromGadget(0))
+	assert.Error(t, d.SetSectionNameComponent(9, "Nope"))
+	assert.Equal(t, []string{"Header", "Duties", "Attributes"}, names(d))
+
+	// the history survives saving, undoing the removal restores the attributes of the section
+	saved, history, err := d.SaveToFileWithHistory("sections.uml")
+	assert.NoError(t, err)
+	saved.Filetype >>= 1
+	loaded, err := LoadExistUMLDiagramWithHistory("sections.uml", *saved, *history)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Header", "Duties", "Attributes"}, names(loaded))
+	assert.NoError(t, loaded.Undo())
+	assert.Equal(t, []string{"Header", "Duties", "Attributes", "Methods"}, names(loaded))
+	g := loaded.GetDrawData().Gadgets[0]
+	assert.True(t, g.Sections[3].Collapsed)
+	assert.NoError(t, loaded.Undo())
+	assert.Equal(t, "m()", loaded.GetDrawData().Gadgets[0].Attributes[3][0].Content)
+	assert.NoError(t, loaded.Undo())
+	assert.NoError(t, loaded.Undo())
+	assert.NoError(t, loaded.Undo())
+	assert.Equal(t, []string{"Header", "Attributes", "Methods"}, names(loaded))
+	assert.NoError(t, loaded.Redo())
+	assert.Equal(t, []string{"Header", "Attributes", "Methods", "Responsibilities"}, names(loaded))
+
+	// files of older versions keep the section in the ratio of the attribute
+	legacy := utils.SavedDiagram{
+		Filetype: utils.ClassDiagram >> 1,
+		Gadgets: []utils.SavedGad{{
+			GadgetType: int(component.Class),
+			Point:      "0, 0",
+			Color:      drawdata.DefaultGadgetColor,
+			Attributes: []utils.SavedAtt{
+				{Content: "A", Size: 12, Font: "Ink Free"},
+				{Content: "m()", Size: 12, Font: "Ink Free", Ratio: 0.6},
+			},
+		}},
+	}
+	loaded, err = LoadExistUMLDiagram("legacy.uml", legacy)
+	assert.NoError(t, err)
+	assert.Equal(t, component.DefaultSectionNames, names(loaded))
+	assert.Equal(t, "m()", loaded.GetDrawData().Gadgets[0].Attributes[2][0].Content)
+}
+
+func TestUMLDiagram_SetSizeComponent(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("size.uml", ClassDiagram)
+	assert.NoError(t, err)
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "short"))
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 400, Y: 0}, 0, drawdata.DefaultGadgetColor, "a much longer header"))
+	sizes := func(d *UMLDiagram) [][2]int {
+		sizes := make([][2]int, 0)
+		for _, g := range d.GetDrawData().Gadgets {
+			sizes = append(sizes, [2]int{g.Width, g.Height})
+		}
+		return sizes
+	}
+	auto := sizes(d)
+	assert.NoError(t, d.SelectAllComponents())
+
+	// all selected gadgets get the same size, in one undo step
+	assert.NoError(t, d.SetSizeComponent(300, 100))
+	assert.ElementsMatch(t, [][2]int{{300, 100}, {300, 100}}, sizes(d))
+	assert.Error(t, d.SetSizeComponent(-1, 100))
+	assert.NoError(t, d.Undo())
+	assert.ElementsMatch(t, auto, sizes(d))
+	assert.NoError(t, d.Redo())
+
+	// a drag is one undo step
+	assert.NoError(t, d.StartDrag())
+	assert.NoError(t, d.SetSizeComponent(310, 110))
+	assert.NoError(t, d.SetSizeComponent(320, 120))
+	assert.NoError(t, d.EndDrag())
+	assert.ElementsMatch(t, [][2]int{{320, 120}, {320, 120}}, sizes(d))
+	assert.NoError(t, d.Undo())
+	assert.ElementsMatch(t, [][2]int{{300, 100}, {300, 100}}, sizes(d))
+	assert.NoError(t, d.Redo())
+
+	assert.NoError(t, d.AutoSizeComponent())
+	assert.ElementsMatch(t, auto, sizes(d))
+
+	// saved with the history
+	saved, history, err := d.SaveToFileWithHistory("size.uml")
+	assert.NoError(t, err)
+	saved.Filetype >>= 1
+	loaded, err := LoadExistUMLDiagramWithHistory("size.uml", *saved, *history)
+	assert.NoError(t, err)
+	assert.NoError(t, loaded.Undo())
+	assert.ElementsMatch(t, [][2]int{{320, 120}, {320, 120}}, sizes(loaded))
+	saved, err = loaded.SaveToFile("size.uml")
+	assert.NoError(t, err)
+	assert.Equal(t, 320, saved.Gadgets[0].Width)
+	assert.Equal(t, 120, saved.Gadgets[0].Height)
+}
+
+func TestUMLDiagram_SnapToGrid(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("grid.uml", ClassDiagram)
+	assert.NoError(t, err)
+	assert.Equal(t, drawdata.DefaultGridSize, d.GetGridSize())
+	assert.False(t, d.IsGridEnabled())
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "a"))
+	assert.NoError(t, d.SelectAllComponents())
+
+	// moves are left alone while the grid is off
+	assert.NoError(t, d.SetPointComponent(utils.Point{X: 13, Y: 27}))
+	assert.Equal(t, utils.Point{X: 13, Y: 27}, selectedPoint(t, d))
+
+	assert.Error(t, d.SetGridSize(0))
+	assert.NoError(t, d.SetGridSize(20))
+	assert.NoError(t, d.SetGridEnabled(true))
+	assert.Equal(t, 20, d.GetDrawData().GridSize)
+	assert.True(t, d.GetDrawData().GridEnabled)
+	assert.NoError(t, d.SetPointComponent(utils.Point{X: 13, Y: 27}))
+	assert.Equal(t, utils.Point{X: 20, Y: 20}, selectedPoint(t, d))
+	assert.NoError(t, d.SetPointComponent(utils.Point{X: -9, Y: 31}))
+	assert.Equal(t, utils.Point{X: 0, Y: 40}, selectedPoint(t, d))
+
+	// the settings are undoable and saved with the diagram
+	saved, history, err := d.SaveToFileWithHistory("grid.uml")
+	assert.NoError(t, err)
+	assert.Equal(t, 20, saved.GridSize)
+	assert.True(t, saved.GridEnabled)
+	saved.Filetype >>= 1
+	loaded, err := LoadExistUMLDiagramWithHistory("grid.uml", *saved, *history)
+	assert.NoError(t, err)
+	assert.Equal(t, 20, loaded.GetGridSize())
+	assert.True(t, loaded.IsGridEnabled())
+	assert.NoError(t, loaded.Undo())
+	assert.NoError(t, loaded.Undo())
+	assert.NoError(t, loaded.Undo())
+	assert.False(t, loaded.IsGridEnabled())
+	assert.NoError(t, loaded.Undo())
+	assert.Equal(t, drawdata.DefaultGridSize, loaded.GetGridSize())
+	saved, err = loaded.SaveToFile("grid.uml")
+	assert.NoError(t, err)
+	assert.Equal(t, drawdata.DefaultGridSize, saved.GridSize)
+	assert.False(t, saved.GridEnabled)
+}
+
+func TestUMLDiagram_AlignmentGuides(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("guides.uml", ClassDiagram)
+	assert.NoError(t, err)
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 3, Y: 0}, 0, drawdata.DefaultGadgetColor, "a"))
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 500, Y: 0}, 0, drawdata.DefaultGadgetColor, "b"))
+	assert.NoError(t, d.SelectAllComponents())
+	assert.NoError(t, d.SetSizeComponent(200, 200))
+	assert.NoError(t, d.SelectComponentsInRect(utils.Point{X: 450, Y: -10}, utils.Point{X: 750, Y: 250}, false))
+
+	// the top of b snaps to the middle of a, no guides are reported outside a drag
+	assert.NoError(t, d.SetPointComponent(utils.Point{X: 503, Y: 97}))
+	assert.Equal(t, utils.Point{X: 503, Y: 100}, selectedPoint(t, d))
+	assert.Empty(t, d.GetDrawData().Guides)
+
+	// while dragging, the edges b shares with a are reported
+	assert.NoError(t, d.StartDrag())
+	assert.NoError(t, d.SetPointComponent(utils.Point{X: 300, Y: 50}))
+	assert.Empty(t, d.GetDrawData().Guides)
+	assert.NoError(t, d.SetPointComponent(utils.Point{X: 206, Y: 2}))
+	assert.Equal(t, utils.Point{X: 203, Y: 0}, selectedPoint(t, d))
+	assert.Equal(t, []drawdata.Guide{
+		{Vertical: true, Position: 203, Start: 0, End: 200},
+		{Vertical: false, Position: 0, Start: 3, End: 403},
+		{Vertical: false, Position: 100, Start: 3, End: 403},
+		{Vertical: false, Position: 200, Start: 3, End: 403},
+	}, d.GetDrawData().Guides)
+	assert.NoError(t, d.EndDrag())
+	assert.Empty(t, d.GetDrawData().Guides)
+	assert.NoError(t, d.Undo())
+	assert.Equal(t, utils.Point{X: 503, Y: 100}, selectedPoint(t, d))
+
+	// a nearby gadget wins over the grid
+	assert.NoError(t, d.SetGridSize(50))
+	assert.NoError(t, d.SetGridEnabled(true))
+	assert.NoError(t, d.SetPointComponent(utils.Point{X: 190, Y: 290}))
+	assert.Equal(t, utils.Point{X: 203, Y: 300}, selectedPoint(t, d))
+}
+
+// selectedPoint returns the point of the only selected gadget
+func selectedPoint(t *testing.T, ud *UMLDiagram) utils.Point {
+	g, err := ud.getSelectedGadget()
+	assert.NoError(t, err)
+	return g.GetPoint()
+}
+
+// selectOnly selects the component at p and nothing else
+func selectOnly(t *testing.T, ud *UMLDiagram, p utils.Point) {
+	assert.NoError(t, ud.ClearSelection())
+	assert.NoError(t, ud.SelectComponent(p))
+}
+
+func TestUMLDiagram_Arrange(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("arrange.uml", ClassDiagram)
+	assert.NoError(t, err)
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "a"))
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 150, Y: 40}, 0, drawdata.DefaultGadgetColor, "b"))
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 500, Y: 20}, 0, drawdata.DefaultGadgetColor, "c"))
+	assert.NoError(t, d.SelectAllComponents())
+	assert.NoError(t, d.SetSizeComponent(100, 100))
+	assert.NoError(t, d.SelectComponentsInRect(utils.Point{X: 140, Y: 30}, utils.Point{X: 310, Y: 170}, false))
+	assert.NoError(t, d.SetSizeComponent(150, 120))
+	boxes := func(d *UMLDiagram) [][4]int {
+		boxes := make([][4]int, 0)
+		for _, g := range d.GetDrawData().Gadgets {
+			boxes = append(boxes, [4]int{g.X, g.Y, g.Width, g.Height})
+		}
+		return boxes
+	}
+	initial := [][4]int{{0, 0, 100, 100}, {150, 40, 150, 120}, {500, 20, 100, 100}}
+	assert.ElementsMatch(t, initial, boxes(d))
+
+	// one gadget cannot be arranged
+	assert.Error(t, d.AlignComponent(AlignLeft))
+	assert.Error(t, d.MatchWidthComponent())
+	assert.NoError(t, d.SelectAllComponents())
+	assert.Error(t, d.AlignComponent(Alignment(42)))
+
+	cases := []struct {
+		alignment Alignment
+		expected  [][4]int
+	}{
+		{AlignLeft, [][4]int{{0, 0, 100, 100}, {0, 40, 150, 120}, {0, 20, 100, 100}}},
+		{AlignRight, [][4]int{{500, 0, 100, 100}, {450, 40, 150, 120}, {500, 20, 100, 100}}},
+		{AlignTop, [][4]int{{0, 0, 100, 100}, {150, 0, 150, 120}, {500, 0, 100, 100}}},
+		{AlignBottom, [][4]int{{0, 60, 100, 100}, {150, 40, 150, 120}, {500, 60, 100, 100}}},
+		{AlignCenter, [][4]int{{250, 0, 100, 100}, {225, 40, 150, 120}, {250, 20, 100, 100}}},
+		{AlignMiddle, [][4]int{{0, 30, 100, 100}, {150, 20, 150, 120}, {500, 30, 100, 100}}},
+	}
+	for _, c := range cases {
+		assert.NoError(t, d.AlignComponent(c.alignment))
+		assert.ElementsMatch(t, c.expected, boxes(d), "alignment %d", c.alignment)
+		// one undo step
+		assert.NoError(t, d.Undo())
+		assert.ElementsMatch(t, initial, boxes(d))
+	}
+
+	// the outer gadgets stay, the gaps between them become equal
+	assert.NoError(t, d.DistributeHorizontallyComponent())
+	assert.ElementsMatch(t, [][4]int{{0, 0, 100, 100}, {225, 40, 150, 120}, {500, 20, 100, 100}}, boxes(d))
+	assert.NoError(t, d.Undo())
+	assert.ElementsMatch(t, initial, boxes(d))
+	assert.NoError(t, d.DistributeVerticallyComponent())
+	assert.ElementsMatch(t, initial, boxes(d))
+
+	assert.NoError(t, d.MatchWidthComponent())
+	assert.ElementsMatch(t, [][4]int{{0, 0, 150, 100}, {150, 40, 150, 120}, {500, 20, 150, 100}}, boxes(d))
+	assert.NoError(t, d.MatchHeightComponent())
+	assert.ElementsMatch(t, [][4]int{{0, 0, 150, 120}, {150, 40, 150, 120}, {500, 20, 150, 120}}, boxes(d))
+
+	// saved with the history
+	saved, history, err := d.SaveToFileWithHistory("arrange.uml")
+	assert.NoError(t, err)
+	saved.Filetype >>= 1
+	loaded, err := LoadExistUMLDiagramWithHistory("arrange.uml", *saved, *history)
+	assert.NoError(t, err)
+	assert.NoError(t, loaded.Undo())
+	assert.NoError(t, loaded.Undo())
+	assert.ElementsMatch(t, initial, boxes(loaded))
+
+	assert.NoError(t, d.SelectComponentsInRect(utils.Point{X: -10, Y: -10}, utils.Point{X: 310, Y: 170}, false))
+	assert.Error(t, d.DistributeHorizontallyComponent())
+}
+
+func TestUMLDiagram_ZOrder(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("zorder.uml", ClassDiagram)
+	assert.NoError(t, err)
+	for _, p := range []utils.Point{{X: 0, Y: 0}, {X: 50, Y: 50}, {X: 100, Y: 100}} {
+		assert.NoError(t, d.AddGadget(component.Class, p, 0, drawdata.DefaultGadgetColor, "g"))
+	}
+	assert.NoError(t, d.SelectAllComponents())
+	assert.NoError(t, d.SetSizeComponent(120, 120))
+	// the gadgets from the bottom to the top, by their x, and their layers
+	order := func() ([]int, []int) {
+		xs, layers := make([]int, 0), make([]int, 0)
+		for _, g := range d.GetDrawData().Gadgets {
+			xs = append(xs, g.X)
+			layers = append(layers, g.Layer)
+		}
+		return xs, layers
+	}
+	// the point covered by all three gadgets
+	top := func() int {
+		c, err := d.componentsContainer.Search(utils.Point{X: 110, Y: 110})
+		assert.NoError(t, err)
+		g, err := d.componentsContainer.SearchGadget(utils.Point{X: 110, Y: 110})
+		assert.NoError(t, err)
+		assert.Equal(t, c, g)
+		return g.GetPoint().X
+	}
+
+	// ties on the same layer are broken by insertion order
+	xs, layers := order()
+	assert.Equal(t, []int{0, 50, 100}, xs)
+	assert.Equal(t, []int{0, 0, 0}, layers)
+	assert.Equal(t, 100, top())
+	// dragging a gadget does not restack it
+	assert.NoError(t, d.ClearSelection())
+	assert.NoError(t, d.SelectComponent(utils.Point{X: 110, Y: 110}))
+	assert.NoError(t, d.SetPointComponent(utils.Point{X: 20, Y: 20}))
+	xs, _ = order()
+	assert.Equal(t, []int{0, 50, 20}, xs)
+	assert.Equal(t, 20, top())
+	assert.NoError(t, d.SetPointComponent(utils.Point{X: 100, Y: 100}))
+	assert.Equal(t, 100, top())
+
+	assert.NoError(t, d.SelectComponentsInRect(utils.Point{X: -1, Y: -1}, utils.Point{X: 121, Y: 121}, false))
+	assert.NoError(t, d.BringToFrontComponent())
+	xs, layers = order()
+	assert.Equal(t, []int{50, 100, 0}, xs)
+	assert.Equal(t, []int{0, 1, 2}, layers)
+	assert.Equal(t, 0, top())
+	// one undo step
+	assert.NoError(t, d.Undo())
+	xs, layers = order()
+	assert.Equal(t, []int{0, 50, 100}, xs)
+	assert.Equal(t, []int{0, 0, 0}, layers)
+
+	assert.NoError(t, d.BringForwardComponent())
+	xs, layers = order()
+	assert.Equal(t, []int{50, 0, 100}, xs)
+	assert.Equal(t, []int{0, 1, 2}, layers)
+	assert.NoError(t, d.BringForwardComponent())
+	xs, _ = order()
+	assert.Equal(t, []int{50, 100, 0}, xs)
+	// already on top
+	assert.NoError(t, d.BringForwardComponent())
+	xs, _ = order()
+	assert.Equal(t, []int{50, 100, 0}, xs)
+	assert.NoError(t, d.SendBackwardComponent())
+	xs, _ = order()
+	assert.Equal(t, []int{50, 0, 100}, xs)
+	assert.NoError(t, d.SendToBackComponent())
+	xs, layers = order()
+	assert.Equal(t, []int{0, 50, 100}, xs)
+	assert.Equal(t, []int{0, 1, 2}, layers)
+
+	// several components keep their order among themselves
+	assert.NoError(t, d.SelectComponentsInRect(utils.Point{X: -1, Y: -1}, utils.Point{X: 171, Y: 171}, false))
+	assert.NoError(t, d.BringToFrontComponent())
+	xs, _ = order()
+	assert.Equal(t, []int{100, 0, 50}, xs)
+	assert.NoError(t, d.SendBackwardComponent())
+	xs, _ = order()
+	assert.Equal(t, []int{0, 50, 100}, xs)
+
+	assert.NoError(t, d.ClearSelection())
+	assert.Error(t, d.BringToFrontComponent())
+}
+
+func TestUMLDiagram_ZOrderPackages(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("zorderpackages.uml", ClassDiagram)
+	assert.NoError(t, err)
+	assert.NoError(t, d.AddGadget(component.Package, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "pkg"))
+	selectOnly(t, d, utils.Point{X: 1, Y: 1})
+	assert.NoError(t, d.SetSizeComponent(200, 200))
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 300, Y: 300}, 0, drawdata.DefaultGadgetColor, "class"))
+	selectOnly(t, d, utils.Point{X: 301, Y: 301})
+	assert.NoError(t, d.SetPointComponent(utils.Point{X: 50, Y: 50}))
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 400, Y: 0}, 0, drawdata.DefaultGadgetColor, "other"))
+	// the gadgets from the bottom to the top, by their x
+	order := func() []int {
+		xs := make([]int, 0)
+		for _, g := range d.GetDrawData().Gadgets {
+			xs = append(xs, g.X)
+		}
+		return xs
+	}
+	assert.Equal(t, []int{0, 50, 400}, order())
+
+	// the package steps over its sibling, not over its own child
+	selectOnly(t, d, utils.Point{X: 150, Y: 5})
+	assert.NoError(t, d.BringForwardComponent())
+	assert.Equal(t, []int{400, 0, 50}, order())
+	assert.NoError(t, d.SendBackwardComponent())
+	assert.Equal(t, []int{0, 50, 400}, order())
+
+	// the only child of a package has no sibling to step over
+	selectOnly(t, d, utils.Point{X: 60, Y: 60})
+	state := d.GetHistoryState()
+	assert.NoError(t, d.BringForwardComponent())
+	assert.NoError(t, d.SendBackwardComponent())
+	assert.Equal(t, []int{0, 50, 400}, order())
+	assert.Equal(t, state, d.GetHistoryState(), "nothing to undo")
+}
+
+func TestUMLDiagram_DrawOrder(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("draworder.uml", ClassDiagram)
+	assert.NoError(t, err)
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "a"))
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 300, Y: 0}, 0, drawdata.DefaultGadgetColor, "b"))
+	assert.NoError(t, d.StartAddAssociation(utils.Point{X: 1, Y: 1}))
+	assert.NoError(t, d.EndAddAssociation(component.Dependency, utils.Point{X: 301, Y: 1}))
+	// a gadget on a higher layer over the middle of the association
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 100, Y: 0}, 1, drawdata.DefaultGadgetColor, "c"))
+
+	// the association is drawn below the gadget, as it is hit below it
+	dd := d.GetDrawData()
+	assert.Equal(t, []drawdata.DrawnComponent{
+		{Index: 0}, {Index: 1}, {Association: true, Index: 0}, {Index: 2},
+	}, dd.Order)
+	assert.Equal(t, 100, dd.Gadgets[2].X)
+	top, err := d.componentsContainer.Search(utils.Point{X: 110, Y: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, top.GetLayer())
+
+	// hidden components are left out of the order
+	assert.NoError(t, d.AddLayer("Hidden"))
+	selectOnly(t, d, utils.Point{X: 310, Y: 10})
+	assert.NoError(t, d.SetLayerNameComponent("Hidden"))
+	assert.NoError(t, d.SetLayerHidden("Hidden", true))
+	assert.Equal(t, []drawdata.DrawnComponent{{Index: 0}, {Index: 1}}, d.GetDrawData().Order)
+}
+
+func TestUMLDiagram_NamedLayers(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("layers.uml", ClassDiagram)
+	assert.NoError(t, err)
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "a"))
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 300, Y: 0}, 0, drawdata.DefaultGadgetColor, "b"))
+	assert.NoError(t, d.SelectAllComponents())
+	assert.NoError(t, d.SetSizeComponent(100, 100))
+	a, err := d.componentsContainer.SearchGadget(utils.Point{X: 10, Y: 10})
+	assert.NoError(t, err)
+
+	assert.NoError(t, d.AddLayer("Core"))
+	assert.NoError(t, d.AddLayer("Notes"))
+	assert.Error(t, d.AddLayer(""))
+	assert.Error(t, d.AddLayer("Core"))
+	assert.Equal(t, []drawdata.Layer{{Name: "Core"}, {Name: "Notes"}}, d.GetDrawData().Layers)
+
+	assert.NoError(t, d.SelectComponent(utils.Point{X: 10, Y: 10}))
+	assert.NoError(t, d.ClearSelection())
+	assert.NoError(t, d.SelectComponent(utils.Point{X: 10, Y: 10}))
+	assert.Error(t, d.SetLayerNameComponent("Nope"))
+	assert.NoError(t, d.SetLayerNameComponent("Core"))
+	assert.Equal(t, "Core", a.GetLayerName())
+
+	// hidden components are unselected, not drawn and cannot be hit,
+	// the associations of a hidden gadget neither
+	assert.NoError(t, d.StartAddAssociation(utils.Point{X: 10, Y: 10}))
+	assert.NoError(t, d.EndAddAssociation(component.Dependency, utils.Point{X: 310, Y: 10}))
+	assert.NoError(t, d.ClearSelection())
+	assert.NoError(t, d.SelectComponent(utils.Point{X: 200, Y: 10}))
+	assert.Len(t, d.componentsSelected, 1)
+	assert.NoError(t, d.SelectComponent(utils.Point{X: 10, Y: 10}))
+	assert.NoError(t, d.SetLayerHidden("Core", true))
+	assert.False(t, a.GetIsSelected())
+	assert.Empty(t, d.componentsSelected)
+	assert.Len(t, d.GetDrawData().Gadgets, 1)
+	assert.Empty(t, d.GetDrawData().Associations)
+	assert.NoError(t, d.SelectComponent(utils.Point{X: 10, Y: 10}))
+	assert.NoError(t, d.SelectComponent(utils.Point{X: 200, Y: 10}))
+	assert.Empty(t, d.componentsSelected)
+	assert.NoError(t, d.SelectAllComponents())
+	assert.Len(t, d.componentsSelected, 1)
+	assert.Error(t, d.SetLayerNameComponent("Core"))
+	assert.NoError(t, d.ClearSelection())
+	assert.NoError(t, d.SetLayerHidden("Core", false))
+	assert.Len(t, d.GetDrawData().Gadgets, 2)
+	assert.Len(t, d.GetDrawData().Associations, 1)
+	assert.NoError(t, d.SelectComponent(utils.Point{X: 200, Y: 10}))
+	assert.NoError(t, d.RemoveSelectedComponents())
+
+	// locked components are drawn but cannot be selected
+	assert.NoError(t, d.SetLayerLocked("Core", true))
+	assert.NoError(t, d.SelectComponent(utils.Point{X: 10, Y: 10}))
+	assert.Empty(t, d.componentsSelected)
+	assert.NoError(t, d.SelectComponentsInRect(utils.Point{X: -10, Y: -10}, utils.Point{X: 500, Y: 200}, false))
+	assert.Len(t, d.componentsSelected, 1)
+	assert.False(t, a.GetIsSelected())
+	assert.Len(t, d.GetDrawData().Gadgets, 2)
+	assert.NoError(t, d.ClearSelection())
+	assert.NoError(t, d.SetLayerLocked("Core", false))
+
+	// components follow a renamed layer, and go to the default one with a removed layer
+	assert.Error(t, d.RenameLayer("Core", "Notes"))
+	assert.Error(t, d.RenameLayer("Nope", "Base"))
+	assert.NoError(t, d.RenameLayer("Core", "Base"))
+	assert.Equal(t, "Base", a.GetLayerName())
+	assert.NoError(t, d.RemoveLayer("Base"))
+	assert.Equal(t, "", a.GetLayerName())
+	assert.Equal(t, []drawdata.Layer{{Name: "Notes"}}, d.GetLayers())
+	assert.NoError(t, d.Undo())
+	assert.Equal(t, "Base", a.GetLayerName())
+	assert.Equal(t, []drawdata.Layer{{Name: "Base"}, {Name: "Notes"}}, d.GetLayers())
+	assert.NoError(t, d.SetLayerHidden("Notes", true))
+
+	// saved with the diagram and the history
+	saved, history, err := d.SaveToFileWithHistory("layers.uml")
+	assert.NoError(t, err)
+	assert.Equal(t, []utils.SavedLayer{{Name: "Base"}, {Name: "Notes", Hidden: true}}, saved.Layers)
+	saved.Filetype >>= 1
+	loaded, err := LoadExistUMLDiagramWithHistory("layers.uml", *saved, *history)
+	assert.NoError(t, err)
+	assert.Equal(t, d.GetLayers(), loaded.GetLayers())
+	assert.ElementsMatch(t, []string{"Base", ""}, []string{loaded.GetDrawData().Gadgets[0].LayerName, loaded.GetDrawData().Gadgets[1].LayerName})
+	assert.NoError(t, loaded.Undo())
+	assert.NoError(t, loaded.Undo())
+	assert.Equal(t, []drawdata.Layer{{Name: "Core"}, {Name: "Notes"}}, loaded.GetLayers())
+
+	// a layer that is not defined is dropped with a warning
+	saved.Layers = nil
+	loaded, err = LoadExistUMLDiagram("layers.uml", *saved)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`layer "Base" is not defined, its components are on the default layer`}, loaded.GetLoadWarnings())
+	for _, g := range loaded.GetDrawData().Gadgets {
+		assert.Equal(t, "", g.LayerName)
+	}
+	saved.Layers = []utils.SavedLayer{{Name: "Base"}, {Name: "Base"}}
+	_, err = LoadExistUMLDiagram("layers.uml", *saved)
+	assert.Error(t, err)
+
+	// pasted components keep their layer only if it can take them
+	assert.NoError(t, d.SelectComponent(utils.Point{X: 10, Y: 10}))
+	fragment, err := d.CopySelectedComponents()
+	assert.NoError(t, err)
+	assert.NoError(t, d.PasteComponents(fragment, utils.Point{X: 0, Y: 200}))
+	pasted, err := d.componentsContainer.SearchGadget(utils.Point{X: 10, Y: 210})
+	assert.NoError(t, err)
+	assert.Equal(t, "Base", pasted.GetLayerName())
+	other, err := CreateEmptyUMLDiagram("other.uml", ClassDiagram)
+	assert.NoError(t, err)
+	assert.NoError(t, other.PasteComponents(fragment, utils.Point{}))
+	assert.Equal(t, "", other.GetDrawData().Gadgets[0].LayerName)
+}
+
+func TestUMLDiagram_Packages(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("packages.uml", ClassDiagram)
+	assert.NoError(t, err)
+	assert.NoError(t, d.AddGadget(component.Package, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "pkg"))
+	selectOnly(t, d, utils.Point{X: 1, Y: 1})
+	assert.NoError(t, d.SetSizeComponent(200, 200))
+	// a higher layer than its child, which is still on top of it
+	assert.NoError(t, d.SetLayerComponent(5))
+	pkg, err := d.visibleGadgetAt(utils.Point{X: 1, Y: 1})
+	assert.NoError(t, err)
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 300, Y: 300}, 0, drawdata.DefaultGadgetColor, "class"))
+	selectOnly(t, d, utils.Point{X: 301, Y: 301})
+	assert.NoError(t, d.SetSizeComponent(40, 40))
+	class, err := d.visibleGadgetAt(utils.Point{X: 301, Y: 301})
+	assert.NoError(t, err)
+	assert.Nil(t, class.GetParent())
+
+	// dropped inside the package, it goes into it
+	assert.NoError(t, d.StartDrag())
+	assert.NoError(t, d.SetPointComponent(utils.Point{X: 150, Y: 150}))
+	assert.NoError(t, d.SetPointComponent(utils.Point{X: 50, Y: 50}))
+	assert.NoError(t, d.EndDrag())
+	assert.Equal(t, pkg, class.GetParent())
+	hit, err := d.visibleGadgetAt(utils.Point{X: 60, Y: 60})
+	assert.NoError(t, err)
+	assert.Equal(t, class, hit)
+	gadgets := d.GetDrawData().Gadgets
+	assert.Equal(t, "pkg", gadgets[0].Attributes[0][0].Content)
+	assert.Equal(t, "class", gadgets[1].Attributes[0][0].Content)
+
+	// the package moves its child
+	selectOnly(t, d, utils.Point{X: 1, Y: 1})
+	assert.NoError(t, d.SetPointComponent(utils.Point{X: 100, Y: 100}))
+	assert.Equal(t, utils.Point{X: 150, Y: 150}, class.GetPoint())
+	assert.NoError(t, d.Undo())
+	assert.Equal(t, utils.Point{X: 50, Y: 50}, class.GetPoint())
+
+	// and grows around it
+	selectOnly(t, d, utils.Point{X: 60, Y: 60})
+	assert.NoError(t, d.SetPointComponent(utils.Point{X: 150, Y: 150}))
+	assert.Equal(t, pkg, class.GetParent())
+	dd := pkg.GetDrawData().(drawdata.Gadget)
+	assert.Equal(t, 150+40+component.PackagePadding, dd.Width)
+
+	// dragged out of it, it goes back to the top level
+	assert.NoError(t, d.StartDrag())
+	assert.NoError(t, d.SetPointComponent(utils.Point{X: 220, Y: 220}))
+	assert.NoError(t, d.SetPointComponent(utils.Point{X: 400, Y: 400}))
+	assert.NoError(t, d.EndDrag())
+	assert.Nil(t, class.GetParent())
+	assert.Empty(t, pkg.GetChildren())
+	dd = pkg.GetDrawData().(drawdata.Gadget)
+	assert.Equal(t, 200, dd.Width)
+	// one undo step back into the package
+	assert.NoError(t, d.Undo())
+	assert.Equal(t, pkg, class.GetParent())
+	assert.Equal(t, utils.Point{X: 150, Y: 150}, class.GetPoint())
+	assert.NoError(t, d.Redo())
+	assert.Nil(t, class.GetParent())
+	assert.NoError(t, d.Undo())
+
+	// removed with the package, and back with it
+	selectOnly(t, d, utils.Point{X: 1, Y: 1})
+	assert.NoError(t, d.RemoveSelectedComponents())
+	assert.Zero(t, d.componentsContainer.Len())
+	assert.NoError(t, d.Undo())
+	assert.Equal(t, 2, d.componentsContainer.Len())
+	assert.Equal(t, []*component.Gadget{class}, pkg.GetChildren())
+
+	// the hierarchy is saved
+	saved, history, err := d.SaveToFileWithHistory("packages.uml")
+	assert.NoError(t, err)
+	saved.Filetype >>= 1
+	loaded, err := LoadExistUMLDiagramWithHistory("packages.uml", *saved, *history)
+	assert.NoError(t, err)
+	loadedClass, err := loaded.visibleGadgetAt(utils.Point{X: 160, Y: 160})
+	assert.NoError(t, err)
+	assert.NotNil(t, loadedClass.GetParent())
+	assert.Equal(t, component.Package, loadedClass.GetParent().GetGadgetType())
+	// removed gadgets are saved in the history with their package
+	assert.NoError(t, loaded.Redo())
+	assert.Zero(t, loaded.componentsContainer.Len())
+	saved2, history, err := loaded.SaveToFileWithHistory("packages.uml")
+	assert.NoError(t, err)
+	saved2.Filetype >>= 1
+	loaded, err = LoadExistUMLDiagramWithHistory("packages.uml", *saved2, *history)
+	assert.NoError(t, err)
+	assert.NoError(t, loaded.Undo())
+	loadedClass, err = loaded.visibleGadgetAt(utils.Point{X: 160, Y: 160})
+	assert.NoError(t, err)
+	assert.NotNil(t, loadedClass.GetParent())
+	assert.Equal(t, []*component.Gadget{loadedClass}, loadedClass.GetParent().GetChildren())
+
+	// a package cannot be in its own child
+	for i := range saved.Gadgets {
+		if len(saved.Gadgets[i].Children) == 0 {
+			saved.Gadgets[i].Children = []int{1 - i}
+		}
+	}
+	_, err = LoadExistUMLDiagram("packages.uml", *saved)
+	assert.Error(t, err)
+
+	// copies keep the hierarchy
+	selectOnly(t, d, utils.Point{X: 1, Y: 1})
+	assert.NoError(t, d.DuplicateSelectedComponents(utils.Point{X: 500, Y: 0}))
+	assert.Equal(t, 4, d.componentsContainer.Len())
+	copied, err := d.visibleGadgetAt(utils.Point{X: 660, Y: 160})
+	assert.NoError(t, err)
+	assert.NotEqual(t, class, copied)
+	assert.NotNil(t, copied.GetParent())
+	assert.NotEqual(t, pkg, copied.GetParent())
+	selectOnly(t, d, utils.Point{X: 1, Y: 1})
+	fragment, err := d.CopySelectedComponents()
+	assert.NoError(t, err)
+	assert.Len(t, fragment.Gadgets, 2)
+	assert.NoError(t, d.PasteComponents(fragment, utils.Point{X: 0, Y: 500}))
+	pasted, err := d.visibleGadgetAt(utils.Point{X: 160, Y: 660})
+	assert.NoError(t, err)
+	assert.NotNil(t, pasted.GetParent())
+
+	// a gadget added inside a package goes into it
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 20, Y: 20}, 0, drawdata.DefaultGadgetColor, "new"))
+	assert.Len(t, pkg.GetChildren(), 2)
+	assert.NoError(t, d.Undo())
+	assert.Len(t, pkg.GetChildren(), 1)
+}
+
+func TestUMLDiagram_PackageOverhang(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("overhang.uml", ClassDiagram)
+	assert.NoError(t, err)
+	assert.NoError(t, d.AddGadget(component.Package, utils.Point{X: 100, Y: 100}, 0, drawdata.DefaultGadgetColor, "pkg"))
+	selectOnly(t, d, utils.Point{X: 101, Y: 101})
+	assert.NoError(t, d.SetSizeComponent(200, 200))
+	pkg, err := d.visibleGadgetAt(utils.Point{X: 101, Y: 101})
+	assert.NoError(t, err)
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 400, Y: 400}, 0, drawdata.DefaultGadgetColor, "class"))
+	selectOnly(t, d, utils.Point{X: 401, Y: 401})
+	assert.NoError(t, d.SetSizeComponent(40, 40))
+	class, err := d.visibleGadgetAt(utils.Point{X: 401, Y: 401})
+	assert.NoError(t, err)
+	assert.NoError(t, d.SetPointComponent(utils.Point{X: 102, Y: 102}))
+	assert.Equal(t, pkg, class.GetParent())
+	// the child overhangs the top-left corner of the package, which is drawn around it
+	childPoint := class.GetPoint()
+	drawn := utils.Point{X: childPoint.X - component.PackagePadding, Y: childPoint.Y - component.PackagePadding}
+	assert.Less(t, drawn.X, 100)
+	assert.Equal(t, drawn, gadgetBox(pkg).min)
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 0, Y: 400}, 0, drawdata.DefaultGadgetColor, "other"))
+	other, err := d.visibleGadgetAt(utils.Point{X: 1, Y: 401})
+	assert.NoError(t, err)
+
+	// moved by its drawn top-left corner
+	selectOnly(t, d, utils.Point{X: 250, Y: 250})
+	assert.NoError(t, d.SetPointComponent(utils.Point{X: 90, Y: 140}))
+	assert.Equal(t, utils.Point{X: 90, Y: 140}, gadgetBox(pkg).min)
+	assert.Equal(t, utils.Point{X: 90 + component.PackagePadding, Y: 140 + component.PackagePadding}, gadgetBox(class).min)
+	assert.NoError(t, d.Undo())
+	assert.Equal(t, drawn, gadgetBox(pkg).min)
+
+	// aligned by its drawn box, the child selected with it moves once
+	selectOnly(t, d, utils.Point{X: 250, Y: 250})
+	assert.NoError(t, d.SelectComponent(utils.Point{X: 110, Y: 110}))
+	assert.NoError(t, d.SelectComponent(utils.Point{X: 1, Y: 401}))
+	assert.NoError(t, d.AlignComponent(AlignLeft))
+	assert.Equal(t, 0, gadgetBox(pkg).min.X)
+	assert.Equal(t, 0, gadgetBox(other).min.X)
+	assert.Equal(t, component.PackagePadding, gadgetBox(class).min.X)
+	assert.NoError(t, d.Undo())
+	assert.Equal(t, drawn, gadgetBox(pkg).min)
+	assert.Equal(t, childPoint, class.GetPoint())
+}
+
+func TestUMLDiagram_PackageLockedChild(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("lockedchild.uml", ClassDiagram)
+	assert.NoError(t, err)
+	assert.NoError(t, d.AddGadget(component.Package, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "pkg"))
+	selectOnly(t, d, utils.Point{X: 1, Y: 1})
+	assert.NoError(t, d.SetSizeComponent(200, 200))
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 300, Y: 300}, 0, drawdata.DefaultGadgetColor, "class"))
+	selectOnly(t, d, utils.Point{X: 301, Y: 301})
+	assert.NoError(t, d.SetSizeComponent(40, 40))
+	assert.NoError(t, d.SetPointComponent(utils.Point{X: 50, Y: 50}))
+	class, err := d.visibleGadgetAt(utils.Point{X: 60, Y: 60})
+	assert.NoError(t, err)
+	assert.NotNil(t, class.GetParent())
+	assert.NoError(t, d.AddLayer("Frozen"))
+	assert.NoError(t, d.SetLayerNameComponent("Frozen"))
+	assert.NoError(t, d.SetLayerLocked("Frozen", true))
+	point := class.GetPoint()
+
+	// the package cannot move or remove the child on a locked layer
+	selectOnly(t, d, utils.Point{X: 150, Y: 5})
+	assert.Error(t, d.SetPointComponent(utils.Point{X: 150, Y: 150}))
+	assert.Error(t, d.RemoveSelectedComponents())
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 300, Y: 0}, 0, drawdata.DefaultGadgetColor, "other"))
+	assert.NoError(t, d.SelectComponent(utils.Point{X: 301, Y: 1}))
+	assert.Error(t, d.AlignComponent(AlignTop))
+	assert.Equal(t, point, class.GetPoint())
+	assert.Equal(t, 3, d.componentsContainer.Len())
+
+	// it can once the layer is unlocked
+	assert.NoError(t, d.SetLayerLocked("Frozen", false))
+	selectOnly(t, d, utils.Point{X: 150, Y: 5})
+	assert.NoError(t, d.SetPointComponent(utils.Point{X: 150, Y: 150}))
+	assert.NotEqual(t, point, class.GetPoint())
+}
+
+func TestUMLDiagram_LockedComponents(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("locked.uml", ClassDiagram)
+	assert.NoError(t, err)
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "st"))
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 200, Y: 0}, 0, drawdata.DefaultGadgetColor, "en"))
+	assert.NoError(t, d.StartAddAssociation(utils.Point{X: 1, Y: 1}))
+	assert.NoError(t, d.EndAddAssociation(component.Composition, utils.Point{X: 201, Y: 1}))
+	assert.NoError(t, d.SelectComponent(utils.Point{X: 10, Y: 30}))
+	assert.NoError(t, d.SetLockedComponent(true))
+	assert.True(t, d.GetDrawData().Gadgets[0].IsLocked)
+
+	// still selected and copied, but not moved, resized, edited or removed
+	point := selectedPoint(t, d)
+	assert.Error(t, d.SetPointComponent(utils.Point{X: 50, Y: 50}))
+	assert.Error(t, d.SetSizeComponent(100, 100))
+	assert.Error(t, d.SetMaxWidthComponent(100))
+	assert.Error(t, d.AddAttributeToGadget(1, "field"))
+	assert.Error(t, d.SetAttrContentComponent(0, 0, "renamed"))
+	assert.Error(t, d.AddSectionToGadget(1, "more"))
+	assert.Error(t, d.RemoveSelectedComponents())
+	assert.Equal(t, point, selectedPoint(t, d))
+	assert.Equal(t, 3, d.componentsContainer.Len())
+	fragment, err := d.CopySelectedComponents()
+	assert.NoError(t, err)
+	assert.True(t, fragment.Gadgets[0].Locked)
+	assert.Error(t, d.SetColorComponent("#FF0000"))
+
+	// with another gadget, the locked one keeps the whole selection from moving
+	assert.NoError(t, d.SelectComponent(utils.Point{X: 210, Y: 30}))
+	assert.Error(t, d.SetPointComponent(utils.Point{X: 50, Y: 50}))
+	assert.Error(t, d.AlignComponent(AlignTop))
+
+	// an unlocked gadget cannot be moved or removed with a locked association
+	assert.NoError(t, d.ClearSelection())
+	assert.NoError(t, d.SelectComponent(utils.Point{X: 100, Y: 0}))
+	assert.NoError(t, d.SetLockedComponent(true))
+	assert.True(t, d.GetDrawData().Associations[0].IsLocked)
+	assert.Error(t, d.SetAssociationType(component.Dependency))
+	assert.NoError(t, d.ClearSelection())
+	assert.NoError(t, d.SelectComponent(utils.Point{X: 210, Y: 30}))
+	assert.Error(t, d.SetPointComponent(utils.Point{X: 200, Y: 50}))
+	assert.Error(t, d.RemoveSelectedComponents())
+
+	// the lock is saved
+	saved, history, err := d.SaveToFileWithHistory("locked.uml")
+	assert.NoError(t, err)
+	saved.Filetype >>= 1
+	loaded, err := LoadExistUMLDiagramWithHistory("locked.uml", *saved, *history)
+	assert.NoError(t, err)
+	assert.True(t, loaded.GetDrawData().Associations[0].IsLocked)
+	assert.Equal(t, []bool{true, false}, []bool{loaded.GetDrawData().Gadgets[0].IsLocked, loaded.GetDrawData().Gadgets[1].IsLocked})
+
+	// unlocking is one undo step and makes it editable again
+	assert.NoError(t, d.ClearSelection())
+	assert.NoError(t, d.SelectComponent(utils.Point{X: 100, Y: 0}))
+	assert.NoError(t, d.SetLockedComponent(false))
+	assert.NoError(t, d.ClearSelection())
+	assert.NoError(t, d.SelectComponent(utils.Point{X: 10, Y: 30}))
+	assert.NoError(t, d.SetLockedComponent(false))
+	assert.NoError(t, d.SetPointComponent(utils.Point{X: 0, Y: 50}))
+	assert.NoError(t, d.Undo())
+	assert.NoError(t, d.Undo())
+	assert.Error(t, d.SetPointComponent(utils.Point{X: 0, Y: 50}))
+}
+
+func TestUMLDiagram_Properties(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("properties.uml", ClassDiagram)
+	assert.NoError(t, err)
+	props := d.GetProperties()
+	assert.Equal(t, drawdata.DefaultDiagramColor, props.BackgroundColor)
+	assert.Equal(t, drawdata.DefaultGadgetColor, props.GadgetColor)
+	assert.False(t, props.Created.IsZero())
+	created := props.Created
+
+	props.Title = "Orders"
+	props.Description = "The order domain"
+	props.Author = "someone"
+	props.Tags = []string{" domain ", "", "orders", "domain"}
+	props.BackgroundColor = "#102030"
+	props.GadgetColor = "#ABC"
+	props.Created = time.Time{}
+	assert.NoError(t, d.SetProperties(props))
+	props = d.GetProperties()
+	assert.Equal(t, "Orders", props.Title)
+	assert.Equal(t, []string{"domain", "orders"}, props.Tags)
+	assert.Equal(t, created, props.Created, "the creation time is kept")
+	assert.Equal(t, d.GetLastModified(), props.Modified)
+	assert.Equal(t, "#102030", d.GetDrawData().Color)
+
+	// new gadgets without a color take the gadget color
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 0, Y: 0}, 0, "", "g"))
+	assert.Equal(t, "#ABC", d.GetDrawData().Gadgets[0].Color)
+	assert.NoError(t, d.Undo())
+
+	invalid := props
+	invalid.BackgroundColor = "white"
+	assert.Error(t, d.SetProperties(invalid))
+	invalid = props
+	invalid.GadgetColor = ""
+	assert.Error(t, d.SetProperties(invalid))
+
+	// saved along with the diagram and its history
+	saved, history, err := d.SaveToFileWithHistory("properties.uml")
+	assert.NoError(t, err)
+	assert.Equal(t, "Orders", saved.Title)
+	saved.Filetype >>= 1
+	loaded, err := LoadExistUMLDiagramWithHistory("properties.uml", *saved, *history)
+	assert.NoError(t, err)
+	loadedProps := loaded.GetProperties()
+	assert.Equal(t, props.Description, loadedProps.Description)
+	assert.Equal(t, props.Author, loadedProps.Author)
+	assert.Equal(t, props.Tags, loadedProps.Tags)
+	assert.Equal(t, "#102030", loaded.GetDrawData().Color)
+	assert.Equal(t, created.Unix(), loadedProps.Created.Unix())
+
+	// one undo step
+	assert.NoError(t, loaded.Undo())
+	assert.Equal(t, "", loaded.GetProperties().Title)
+	assert.Equal(t, drawdata.DefaultDiagramColor, loaded.GetDrawData().Color)
+	assert.NoError(t, loaded.Redo())
+	assert.Equal(t, "#ABC", loaded.GetProperties().GadgetColor)
+
+	// files of older versions have the defaults
+	saved.Title, saved.BackgroundColor, saved.GadgetColor, saved.Created = "", "", "", ""
+	loaded, err = LoadExistUMLDiagram("properties.uml", *saved)
+	assert.NoError(t, err)
+	assert.Equal(t, drawdata.DefaultDiagramColor, loaded.GetProperties().BackgroundColor)
+	assert.True(t, loaded.GetProperties().Created.IsZero())
+	saved.GadgetColor = "#12345"
+	_, err = LoadExistUMLDiagram("properties.uml", *saved)
+	assert.Error(t, err)
+	saved.GadgetColor, saved.Created = "", "yesterday"
+	_, err = LoadExistUMLDiagram("properties.uml", *saved)
+	assert.Error(t, err)
+}
+
+func TestUMLDiagram_Styles(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("styles.uml", ClassDiagram)
+	assert.NoError(t, err)
+	italic := int(attribute.Italic)
+	entity := utils.SavedStyle{Name: "Entity", Color: "#DAE8FC", Size: 14, TextStyle: &italic}
+	assert.NoError(t, d.SetStyleSheet([]utils.SavedStyle{entity}))
+	assert.Error(t, d.SetStyleSheet([]utils.SavedStyle{entity, entity}), "names are unique")
+	assert.Error(t, d.SetStyleSheet([]utils.SavedStyle{{Name: " "}}))
+	assert.Error(t, d.SetStyleSheet([]utils.SavedStyle{{Name: "Bad", Color: "blue"}}))
+	assert.Len(t, d.GetStyleSheet(), 1)
+
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 0, Y: 0}, 0, "#123456", "a"))
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 300, Y: 0}, 0, "#123456", "b"))
+	gadgetAt := func(x int) drawdata.Gadget {
+		for _, g := range d.GetDrawData().Gadgets {
+			if g.X == x {
+				return g
+			}
+		}
+		t.Fatalf("no gadget at x = %d", x)
+		return drawdata.Gadget{}
+	}
+
+	assert.Error(t, d.SetStyleComponent("Missing"))
+	assert.NoError(t, d.SelectAllComponents())
+	assert.NoError(t, d.SetStyleComponent("Entity"))
+	for _, x := range []int{0, 300} {
+		g := gadgetAt(x)
+		assert.Equal(t, "Entity", g.Style)
+		assert.Equal(t, "#DAE8FC", g.Color)
+		assert.Equal(t, 14, g.Attributes[0][0].FontSize)
+		assert.Equal(t, italic, g.Attributes[0][0].FontStyle)
+	}
+
+	// setting a property of a styled gadget overrides its style
+	selectOnly(t, d, utils.Point{X: 10, Y: 10})
+	assert.NoError(t, d.SetColorComponent("#FF0000"))
+	assert.NoError(t, d.SetAttrSizeComponent(0, 0, 20))
+	entity.Color, entity.Size = "#00FF00", 10
+	state := d.cmdManager.GetState()
+	assert.NoError(t, d.SetStyleSheet([]utils.SavedStyle{entity}))
+	assert.Equal(t, "#FF0000", gadgetAt(0).Color)
+	assert.Equal(t, 20, gadgetAt(0).Attributes[0][0].FontSize)
+	assert.Equal(t, "#00FF00", gadgetAt(300).Color)
+	assert.Equal(t, 10, gadgetAt(300).Attributes[0][0].FontSize)
+	// the styles belong to the project, editing them is not an undo step of the diagram
+	assert.Equal(t, state, d.cmdManager.GetState())
+	// a style sheet that fails to apply is not kept
+	assert.Error(t, d.SetStyleSheet([]utils.SavedStyle{{Name: "Bad", Color: "blue"}}))
+	assert.Equal(t, "#00FF00", d.GetStyleSheet()[0].Color)
+
+	// setting the style again drops the overrides
+	assert.NoError(t, d.SetStyleComponent("Entity"))
+	assert.Equal(t, "#00FF00", gadgetAt(0).Color)
+	assert.Equal(t, 10, gadgetAt(0).Attributes[0][0].FontSize)
+	assert.NoError(t, d.Undo())
+	assert.Equal(t, "#FF0000", gadgetAt(0).Color)
+
+	// the overrides are saved, a gadget without a style keeps its look
+	saved, history, err := d.SaveToFileWithHistory("styles.uml")
+	assert.NoError(t, err)
+	saved.Filetype >>= 1
+	loaded, err := LoadExistUMLDiagramWithHistory("styles.uml", *saved, *history)
+	assert.NoError(t, err)
+	entity.Color = "#0000FF"
+	assert.NoError(t, loaded.SetStyleSheet([]utils.SavedStyle{entity}))
+	for _, g := range loaded.GetDrawData().Gadgets {
+		if g.X == 0 {
+			assert.Equal(t, "#FF0000", g.Color)
+		} else {
+			assert.Equal(t, "#0000FF", g.Color)
+		}
+	}
+	assert.NoError(t, loaded.SetStyleSheet(nil))
+	for _, g := range loaded.GetDrawData().Gadgets {
+		assert.Equal(t, "Entity", g.Style, "the style name is kept while the style is missing")
+	}
+
+	// attributes added later take the style, except for the overridden properties
+	selectOnly(t, d, utils.Point{X: 310, Y: 10})
+	assert.NoError(t, d.AddAttributeToGadget(1, "field"))
+	assert.Equal(t, 10, gadgetAt(300).Attributes[1][0].FontSize)
+	assert.Equal(t, italic, gadgetAt(300).Attributes[1][0].FontStyle)
+	selectOnly(t, d, utils.Point{X: 10, Y: 10})
+	assert.NoError(t, d.AddAttributeToGadget(1, "field"))
+	assert.NotEqual(t, 10, gadgetAt(0).Attributes[1][0].FontSize)
+	assert.Equal(t, italic, gadgetAt(0).Attributes[1][0].FontStyle)
+
+	// pasted gadgets take the look of their style in the diagram they are pasted into
+	selectOnly(t, d, utils.Point{X: 310, Y: 10})
+	fragment, err := d.CopySelectedComponents()
+	assert.NoError(t, err)
+	other, err := CreateEmptyUMLDiagram("other.uml", ClassDiagram)
+	assert.NoError(t, err)
+	assert.NoError(t, other.SetStyleSheet([]utils.SavedStyle{{Name: "Entity", Color: "#FFFFFF", Size: 12}}))
+	assert.NoError(t, other.PasteComponents(fragment, utils.Point{}))
+	pasted := other.GetDrawData().Gadgets[0]
+	assert.Equal(t, "#FFFFFF", pasted.Color)
+	assert.Equal(t, 12, pasted.Attributes[1][0].FontSize)
+	assert.Equal(t, italic, pasted.Attributes[1][0].FontStyle)
+
+	// locked gadgets follow their style too
+	selectOnly(t, d, utils.Point{X: 310, Y: 10})
+	assert.NoError(t, d.SetLockedComponent(true))
+	bold := int(attribute.Bold)
+	entity.TextStyle = &bold
+	assert.NoError(t, d.SetStyleSheet([]utils.SavedStyle{entity}))
+	assert.Equal(t, bold, gadgetAt(0).Attributes[0][0].FontStyle)
+	assert.Equal(t, bold, gadgetAt(300).Attributes[0][0].FontStyle)
+	assert.NoError(t, d.SetLockedComponent(false))
+
+	assert.NoError(t, d.SelectAllComponents())
+	assert.NoError(t, d.SetStyleComponent(""))
+	assert.Equal(t, "", gadgetAt(300).Style)
+	assert.Equal(t, "#0000FF", gadgetAt(300).Color, "the look is kept")
+}
+
+func TestUMLDiagram_Annotations(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("annotations.uml", ClassDiagram)
+	assert.NoError(t, err)
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "Order"))
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 200, Y: 0}, 0, drawdata.DefaultGadgetColor, "Billing"))
+	assert.NoError(t, d.StartAddAssociation(utils.Point{X: 1, Y: 1}))
+	assert.NoError(t, d.EndAddAssociation(component.Dependency, utils.Point{X: 201, Y: 1}))
+
+	assert.NoError(t, d.SelectComponent(utils.Point{X: 10, Y: 30}))
+	assert.NoError(t, d.SetStereotypesComponent([]string{"«entity»"}))
+	assert.NoError(t, d.SetTaggedValuesComponent(map[string]string{"table": "orders"}))
+	assert.Error(t, d.SetStereotypesComponent([]string{""}))
+	assert.NoError(t, d.ClearSelection())
+	assert.NoError(t, d.SelectComponent(utils.Point{X: 100, Y: 0}))
+	assert.NoError(t, d.SetStereotypesComponent([]string{"use", "entity"}))
+
+	assert.Equal(t, []string{"entity", "use"}, d.GetStereotypes())
+	withEntity := d.ComponentsWithStereotype("entity")
+	assert.Len(t, withEntity, 2)
+	_, isGadget := withEntity[0].(*component.Gadget)
+	assert.True(t, isGadget, "gadgets are below associations")
+	assert.Len(t, d.ComponentsWithTaggedValue("table"), 1)
+	assert.Empty(t, d.ComponentsWithTaggedValue("missing"))
+
+	// saved with the diagram and its history, each edit is one undo step
+	saved, history, err := d.SaveToFileWithHistory("annotations.uml")
+	assert.NoError(t, err)
+	saved.Filetype >>= 1
+	loaded, err := LoadExistUMLDiagramWithHistory("annotations.uml", *saved, *history)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"entity", "use"}, loaded.GetStereotypes())
+	assert.Len(t, loaded.ComponentsWithTaggedValue("table"), 1)
+	// the stereotypes of the association, the selection changes, then the tagged values
+	for range 4 {
+		assert.NoError(t, loaded.Undo())
+	}
+	assert.Empty(t, loaded.ComponentsWithTaggedValue("table"))
+	assert.Equal(t, []string{"entity"}, loaded.ComponentsWithStereotype("entity")[0].GetStereotypes())
+	assert.NoError(t, loaded.Undo())
+	assert.Empty(t, loaded.GetStereotypes())
+
+	// locked components cannot be annotated
+	assert.NoError(t, d.SetLockedComponent(true))
+	assert.Error(t, d.SetStereotypesComponent([]string{"service"}))
+}
+
+func TestUMLDiagram_Lint(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("lint.uml", ClassDiagram)
+	assert.NoError(t, err)
+	for _, g := range []struct {
+		x, y   int
+		header string
+	}{{0, 0, "Animal"}, {200, 0, "Dog"}, {400, 0, "Animal"}, {0, 200, ""}, {600, 0, "Runner"}} {
+		assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: g.x, Y: g.y}, 0, drawdata.DefaultGadgetColor, g.header))
+	}
+	selectOnly(t, d, utils.Point{X: 605, Y: 20})
+	assert.NoError(t, d.SetStereotypesComponent([]string{InterfaceStereotype}))
+	// Animal and Dog extend each other, the interface Runner extends the class Dog
+	for _, ends := range [][2]utils.Point{
+		{{X: 5, Y: 20}, {X: 205, Y: 20}},
+		{{X: 205, Y: 30}, {X: 5, Y: 30}},
+		{{X: 605, Y: 10}, {X: 210, Y: 10}},
+	} {
+		assert.NoError(t, d.StartAddAssociation(ends[0]))
+		assert.NoError(t, d.EndAddAssociation(component.Extension, ends[1]))
+	}
+	selectOnly(t, d, utils.Point{X: 105, Y: 20})
+	assert.NoError(t, d.AddAttributeToAssociation(0.5, "first"))
+	assert.NoError(t, d.AddAttributeToAssociation(0.5, "second"))
+
+	diagnostics := d.Lint()
+	counts := make(map[string]int)
+	for _, diag := range diagnostics {
+		counts[diag.Rule]++
+		switch diag.Kind {
+		case "gadget":
+			assert.True(t, slices.ContainsFunc(d.GetDrawData().Gadgets, func(g drawdata.Gadget) bool { return g.ID == diag.ID }))
+		case "association":
+			assert.True(t, slices.ContainsFunc(d.GetDrawData().Associations, func(a drawdata.Association) bool { return a.ID == diag.ID }))
+		}
+	}
+	assert.Equal(t, map[string]int{
+		"duplicate-class-name":               2,
+		"cyclic-inheritance":                 2,
+		"interface-extends-class":            1,
+		"empty-header":                       1,
+		"overlapping-association-attributes": 1,
+		"unconnected-class":                  2,
+	}, counts)
+	assert.Equal(t, SeverityError, diagnostics[0].Severity)
+	assert.Equal(t, SeverityInfo, diagnostics[len(diagnostics)-1].Severity)
+	emptyHeader := func() Diagnostic {
+		index := slices.IndexFunc(d.Lint(), func(diag Diagnostic) bool { return diag.Rule == "empty-header" })
+		assert.GreaterOrEqual(t, index, 0)
+		return d.Lint()[index]
+	}
+	diag := emptyHeader()
+	assert.Equal(t, "gadget", diag.Kind)
+	index := slices.IndexFunc(d.GetDrawData().Gadgets, func(g drawdata.Gadget) bool { return g.ID == diag.ID })
+	assert.GreaterOrEqual(t, index, 0)
+	assert.Equal(t, 0, d.GetDrawData().Gadgets[index].X)
+	assert.Equal(t, 200, d.GetDrawData().Gadgets[index].Y)
+
+	// the id stays the same when the gadget moves in the drawing order or is hidden
+	selectOnly(t, d, utils.Point{X: 5, Y: 205})
+	assert.NoError(t, d.BringToFrontComponent())
+	assert.Equal(t, diag.ID, emptyHeader().ID)
+	assert.Equal(t, diag.ID, d.GetDrawData().Gadgets[len(d.GetDrawData().Gadgets)-1].ID)
+	assert.NoError(t, d.AddLayer("Hidden"))
+	assert.NoError(t, d.SetLayerNameComponent("Hidden"))
+	assert.NoError(t, d.SetLayerHidden("Hidden", true))
+	assert.Equal(t, diag.ID, emptyHeader().ID)
+
+	// rules can be added from Go
+	n := len(lintRules)
+	t.Cleanup(func() { lintRules = lintRules[:n] })
+	assert.Error(t, RegisterLintRule(LintRule{Name: "empty-header", Check: lintEmptyHeaders}))
+	assert.Error(t, RegisterLintRule(LintRule{Name: "no-check"}))
+	assert.NoError(t, RegisterLintRule(LintRule{Name: "needs-title", Check: func(ud *UMLDiagram) []LintFinding {
+		if ud.GetProperties().Title != "" {
+			return nil
+		}
+		return []LintFinding{{Severity: SeverityWarning, Message: "the diagram has no title"}}
+	}}))
+	assert.Equal(t, "needs-title", LintRuleNames()[n])
+	index = slices.IndexFunc(d.Lint(), func(diag Diagnostic) bool { return diag.Rule == "needs-title" })
+	assert.GreaterOrEqual(t, index, 0)
+	assert.Equal(t, "", d.Lint()[index].Kind)
+	assert.Equal(t, uint64(0), d.Lint()[index].ID)
+}
+
+func TestUMLDiagram_SearchAndReplace(t *testing.T) {
+	d, err := CreateEmptyUMLDiagram("search.uml", ClassDiagram)
+	assert.NoError(t, err)
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "Customer"))
+	selectOnly(t, d, utils.Point{X: 5, Y: 5})
+	assert.NoError(t, d.AddAttributeToGadget(1, "customerId: int"))
+	assert.NoError(t, d.AddGadget(component.Class, utils.Point{X: 200, Y: 0}, 0, drawdata.DefaultGadgetColor, "Café customer"))
+	assert.NoError(t, d.StartAddAssociation(utils.Point{X: 5, Y: 5}))
+	assert.NoError(t, d.EndAddAssociation(component.Dependency, utils.Point{X: 205, Y: 5}))
+	selectOnly(t, d, utils.Point{X: 100, Y: 5})
+	assert.NoError(t, d.AddAttributeToAssociation(0.5, "customer of"))
+
+	_, err = d.Search(SearchOptions{})
+	assert.Error(t, err)
+	_, err = d.Search(SearchOptions{Query: "(", Regex: true})
+	assert.Error(t, err)
+
+	matches, err := d.Search(SearchOptions{Query: "customer"})
+	assert.NoError(t, err)
+	assert.Len(t, matches, 4)
+	matches, err = d.Search(SearchOptions{Query: "customer", CaseSensitive: true})
+	assert.NoError(t, err)
+	assert.Len(t, matches, 3)
+	for _, m := range matches {
+		assert.Equal(t, "search.uml", m.Diagram)
+		switch m.Content {
+		case "Café customer":
+			assert.Equal(t, [][2]int{{5, 13}}, m.Ranges, "ranges count characters")
+		case "customer of":
+			assert.Equal(t, "association", m.Kind)
+			assert.Equal(t, d.GetDrawData().Associations[0].ID, m.ID)
+			assert.Equal(t, 0, m.Attribute)
+		case "customerId: int":
+			assert.Equal(t, "gadget", m.Kind)
+			assert.Equal(t, 1, m.Section)
+		}
+	}
+	matches, err = d.Search(SearchOptions{Query: `^c\w+Id`, Regex: true})
+	assert.NoError(t, err)
+	assert.Len(t, matches, 1)
+
+	// the replacement is one undo step, locked components are left out
+	selectOnly(t, d, utils.Point{X: 210, Y: 30})
+	assert.NoError(t, d.SetLockedComponent(true))
+	n, err := d.ReplaceAll(SearchOptions{Query: `customer(\w*)`, Regex: true}, "client$1")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	matches, err = d.Search(SearchOptions{Query: "client"})
+	assert.NoError(t, err)
+	assert.Len(t, matches, 3)
+	assert.Contains(t, []string{matches[0].Content, matches[1].Content, matches[2].Content}, "clientId: int")
+	assert.NoError(t, d.Undo())
+	matches, err = d.Search(SearchOptions{Query: "client"})
+	assert.NoError(t, err)
+	assert.Empty(t, matches)
+
+	n, err = d.ReplaceAll(SearchOptions{Query: "$"}, "!")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n, "a literal query does not match the end of the content")
+}
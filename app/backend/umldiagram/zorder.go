@@ -0,0 +1,110 @@
+package umldiagram
+
+import (
+	"slices"
+	"time"
+
+	"Dr.uml/backend/command"
+	"Dr.uml/backend/component"
+	"Dr.uml/backend/components"
+	"Dr.uml/backend/utils/duerror"
+)
+
+// BringToFrontComponent puts the selected components on top of all the others
+func (ud *UMLDiagram) BringToFrontComponent() duerror.DUError {
+	return ud.reorder(func(order []component.Component, selected map[component.Component]bool) {
+		slices.SortStableFunc(order, func(a, b component.Component) int {
+			return boolRank(selected[a]) - boolRank(selected[b])
+		})
+	})
+}
+
+// SendToBackComponent puts the selected components below all the others
+func (ud *UMLDiagram) SendToBackComponent() duerror.DUError {
+	return ud.reorder(func(order []component.Component, selected map[component.Component]bool) {
+		slices.SortStableFunc(order, func(a, b component.Component) int {
+			return boolRank(selected[b]) - boolRank(selected[a])
+		})
+	})
+}
+
+// BringForwardComponent moves each selected component one step up
+func (ud *UMLDiagram) BringForwardComponent() duerror.DUError {
+	return ud.reorder(func(order []component.Component, selected map[component.Component]bool) {
+		for i := len(order) - 2; i >= 0; i-- {
+			if selected[order[i]] && !selected[order[i+1]] {
+				order[i], order[i+1] = order[i+1], order[i]
+			}
+		}
+	})
+}
+
+// SendBackwardComponent moves each selected component one step down
+func (ud *UMLDiagram) SendBackwardComponent() duerror.DUError {
+	return ud.reorder(func(order []component.Component, selected map[component.Component]bool) {
+		for i := 1; i < len(order); i++ {
+			if selected[order[i]] && !selected[order[i-1]] {
+				order[i], order[i-1] = order[i-1], order[i]
+			}
+		}
+	})
+}
+
+func boolRank(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// componentsByLayer returns all the components from the bottom to the top
+func (ud *UMLDiagram) componentsByLayer() []component.Component {
+	comps := ud.componentsContainer.GetAll()
+	slices.SortStableFunc(comps, components.CompareLayer)
+	return comps
+}
+
+// reorder rearranges the stacking order with move and renumbers the layers of all the components
+// from 0 at the bottom, as one undo step. A component is stacked among its siblings, the components
+// of the same package or of the top level, so move is given each group of siblings from the bottom to the top.
+func (ud *UMLDiagram) reorder(move func(order []component.Component, selected map[component.Component]bool)) duerror.DUError {
+	if _, err := ud.getSelectedComponents(); err != nil {
+		return err
+	}
+	siblings := make(map[*component.Gadget][]component.Component)
+	for _, c := range ud.componentsByLayer() {
+		var parent *component.Gadget
+		if g, ok := c.(*component.Gadget); ok {
+			parent = g.GetParent()
+		}
+		siblings[parent] = append(siblings[parent], c)
+	}
+	// the packages are followed by their children, which are on top of them
+	order := make([]component.Component, 0, ud.componentsContainer.Len())
+	var visit func(parent *component.Gadget)
+	visit = func(parent *component.Gadget) {
+		group := siblings[parent]
+		move(group, ud.componentsSelected)
+		for _, c := range group {
+			order = append(order, c)
+			if g, ok := c.(*component.Gadget); ok {
+				visit(g)
+			}
+		}
+	}
+	visit(nil)
+	cmds := make([]command.Command, 0, len(order))
+	for layer, c := range order {
+		if c.GetLayer() == layer {
+			continue
+		}
+		cmd, err := ud.newSetterCommand(c, propertyLayer, 0, 0, c.GetLayer(), layer)
+		if err != nil {
+			return err
+		}
+		cmd.before = ud.GetLastModified()
+		cmd.after = time.Now()
+		cmds = append(cmds, cmd)
+	}
+	return ud.executeAll(cmds)
+}
@@ -0,0 +1,102 @@
+package umlproject
+
+import (
+	"slices"
+	"time"
+
+	"Dr.uml/backend/umldiagram"
+	"Dr.uml/backend/utils/duerror"
+)
+
+// DiagramFailure is a diagram of the project that could not be loaded or changed, the others are still searched
+type DiagramFailure struct {
+	Diagram string `json:"diagram"`
+	Error   string `json:"error"`
+}
+
+// ProjectSearchResult is what SearchProject found
+type ProjectSearchResult struct {
+	Matches  []umldiagram.SearchMatch `json:"matches"`
+	Failures []DiagramFailure         `json:"failures"`
+}
+
+// ProjectReplaceResult is what ReplaceInProject changed
+type ProjectReplaceResult struct {
+	Replaced int              `json:"replaced"` // how many attributes changed
+	Failures []DiagramFailure `json:"failures"`
+}
+
+// projectDiagram is a diagram of the project, loaded if it is not open
+type projectDiagram struct {
+	name    string
+	diagram *umldiagram.UMLDiagram
+	loaded  bool
+}
+
+// SearchProject searches the attributes of every diagram of the project, sorted by diagram name.
+// Diagrams that are not open are read for the search only, those that cannot be read are reported.
+func (p *UMLProject) SearchProject(opts umldiagram.SearchOptions) (ProjectSearchResult, duerror.DUError) {
+	if err := opts.Validate(); err != nil {
+		return ProjectSearchResult{}, err
+	}
+	diagrams, failures := p.allDiagrams()
+	res := ProjectSearchResult{Matches: make([]umldiagram.SearchMatch, 0), Failures: failures}
+	for _, d := range diagrams {
+		matches, err := d.diagram.Search(opts)
+		if err != nil {
+			return ProjectSearchResult{}, err
+		}
+		res.Matches = append(res.Matches, matches...)
+	}
+	return res, nil
+}
+
+// ReplaceInProject replaces the matches of a search in every diagram of the project, as one undo step
+// in each diagram that changes. The diagrams that were not open are opened if they change, without
+// becoming the current diagram. Those that cannot be read or changed are reported, the others are still changed.
+func (p *UMLProject) ReplaceInProject(opts umldiagram.SearchOptions, replacement string) (ProjectReplaceResult, duerror.DUError) {
+	if err := opts.Validate(); err != nil {
+		return ProjectReplaceResult{}, err
+	}
+	diagrams, failures := p.allDiagrams()
+	res := ProjectReplaceResult{Failures: failures}
+	for _, d := range diagrams {
+		n, err := d.diagram.ReplaceAll(opts, replacement)
+		if err != nil {
+			res.Failures = append(res.Failures, DiagramFailure{Diagram: d.name, Error: err.Error()})
+			continue
+		}
+		if n > 0 && d.loaded {
+			p.activeDiagrams[d.name] = d.diagram
+		}
+		res.Replaced += n
+	}
+	if res.Replaced > 0 {
+		p.lastModified = time.Now()
+	}
+	return res, nil
+}
+
+// allDiagrams returns the diagrams of the project sorted by name, reading the ones that are not open
+// without opening them, and the diagrams that could not be read
+func (p *UMLProject) allDiagrams() ([]projectDiagram, []DiagramFailure) {
+	names := p.GetAvailableDiagramsNames()
+	slices.Sort(names)
+	res := make([]projectDiagram, 0, len(names))
+	failures := make([]DiagramFailure, 0)
+	for _, name := range names {
+		if d, ok := p.activeDiagrams[name]; ok {
+			res = append(res, projectDiagram{name: name, diagram: d})
+			continue
+		}
+		d, err := p.readDiagram(name)
+		if err != nil {
+			failures = append(failures, DiagramFailure{Diagram: name, Error: err.Error()})
+			continue
+		}
+		if d != nil {
+			res = append(res, projectDiagram{name: name, diagram: d, loaded: true})
+		}
+	}
+	return res, failures
+}
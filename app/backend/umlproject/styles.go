@@ -0,0 +1,129 @@
+package umlproject
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"Dr.uml/backend/component/attribute"
+	"Dr.uml/backend/umldiagram"
+	"Dr.uml/backend/utils"
+	"Dr.uml/backend/utils/duerror"
+)
+
+// theme is a built-in set of styles
+type theme struct {
+	name   string
+	styles []utils.SavedStyle
+}
+
+func textStyle(style attribute.Textstyle) *int {
+	s := int(style)
+	return &s
+}
+
+// themes are the built-in themes, applying one adds its styles to the project and replaces those of the same name
+var themes = []theme{
+	{"light", []utils.SavedStyle{
+		{Name: "Entity", Color: "#DAE8FC", TextStyle: textStyle(0)},
+		{Name: "Service", Color: "#D5E8D4", TextStyle: textStyle(0)},
+		{Name: "External", Color: "#F5F5F5", TextStyle: textStyle(attribute.Italic)},
+	}},
+	{"dark", []utils.SavedStyle{
+		{Name: "Entity", Color: "#1E3A5F", TextStyle: textStyle(0)},
+		{Name: "Service", Color: "#1E4D2B", TextStyle: textStyle(0)},
+		{Name: "External", Color: "#3C3C3C", TextStyle: textStyle(attribute.Italic)},
+	}},
+	{"print", []utils.SavedStyle{
+		{Name: "Entity", Color: "#FFFFFF", TextStyle: textStyle(0)},
+		{Name: "Service", Color: "#FFFFFF", TextStyle: textStyle(attribute.Bold)},
+		{Name: "External", Color: "#FFFFFF", TextStyle: textStyle(attribute.Italic)},
+	}},
+}
+
+// ListThemes returns the names of the built-in themes
+func (p *UMLProject) ListThemes() []string {
+	names := make([]string, 0, len(themes))
+	for _, t := range themes {
+		names = append(names, t.name)
+	}
+	return names
+}
+
+// ApplyTheme adds the styles of a built-in theme to the project, replacing the styles of the same name
+func (p *UMLProject) ApplyTheme(name string) duerror.DUError {
+	index := slices.IndexFunc(themes, func(t theme) bool { return t.name == name })
+	if index < 0 {
+		return duerror.NewInvalidArgumentError(fmt.Sprintf("theme %q not found", name))
+	}
+	styles := slices.Clone(p.styles)
+	for _, style := range themes[index].styles {
+		styles = putStyle(styles, style)
+	}
+	return p.setStyles(styles)
+}
+
+// GetStyles returns the styles of the project
+func (p *UMLProject) GetStyles() []utils.SavedStyle {
+	return slices.Clone(p.styles)
+}
+
+// SetStyle adds a style to the project or replaces the one of the same name,
+// the gadgets that use it in the open diagrams are restyled, the others when their diagram is opened
+func (p *UMLProject) SetStyle(style utils.SavedStyle) duerror.DUError {
+	if err := umldiagram.ValidateStyle(style); err != nil {
+		return err
+	}
+	if style.Font != "" {
+		if _, err := utils.LookupFont(style.Font); err != nil {
+			return err
+		}
+	}
+	return p.setStyles(putStyle(slices.Clone(p.styles), style))
+}
+
+// RemoveStyle removes a style from the project, the gadgets that use it keep their look
+func (p *UMLProject) RemoveStyle(name string) duerror.DUError {
+	index := slices.IndexFunc(p.styles, func(s utils.SavedStyle) bool { return s.Name == name })
+	if index < 0 {
+		return duerror.NewInvalidArgumentError(fmt.Sprintf("style %q not found", name))
+	}
+	return p.setStyles(slices.Delete(slices.Clone(p.styles), index, index+1))
+}
+
+// SetStyleComponent gives the selected gadgets a style of the project, empty for none
+func (p *UMLProject) SetStyleComponent(name string) duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.SetStyleComponent(name); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+func putStyle(styles []utils.SavedStyle, style utils.SavedStyle) []utils.SavedStyle {
+	index := slices.IndexFunc(styles, func(s utils.SavedStyle) bool { return s.Name == style.Name })
+	if index < 0 {
+		return append(styles, style)
+	}
+	styles[index] = style
+	return styles
+}
+
+// setStyles replaces the styles of the project and restyles the open diagrams. The styles are checked
+// before any diagram is restyled, and the restyling is not an undo step of the diagrams.
+func (p *UMLProject) setStyles(styles []utils.SavedStyle) duerror.DUError {
+	if err := umldiagram.ValidateStyleSheet(styles); err != nil {
+		return err
+	}
+	p.styles = styles
+	p.lastModified = time.Now()
+	for _, name := range p.GetActiveDiagramsNames() {
+		if err := p.activeDiagrams[name].SetStyleSheet(p.styles); err != nil {
+			return err
+		}
+	}
+	return nil
+}
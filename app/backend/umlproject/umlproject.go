@@ -6,11 +6,13 @@ import (
 	"fmt"
 	"maps"
 	"os"
+	"path/filepath"
 	"slices"
 	"time"
 
 	"github.com/labstack/gommon/log"
 
+	"Dr.uml/backend/command"
 	"Dr.uml/backend/component"
 	"Dr.uml/backend/drawdata"
 	"Dr.uml/backend/umldiagram"
@@ -29,8 +31,21 @@ type UMLProject struct {
 	availableDiagrams map[string]bool                   // Use a map to store diagrams, keyed by their ID
 	activeDiagrams    map[string]*umldiagram.UMLDiagram // Keep track of active diagrams
 	runFrontend       bool
+	keepHistory       bool // save the undo history of diagrams in a sidecar file
+	clipboard         *utils.SavedDiagram
+	pasteCount        int                // pastes since the last copy, each one lands a bit further away
+	styles            []utils.SavedStyle // styles the gadgets of all diagrams can refer to
 }
 
+// pasteOffset is how far, on both axes, pasted and duplicated components are moved from the originals
+const pasteOffset = 20
+
+// historyFileSuffix is appended to a diagram file name to get its undo history file
+const historyFileSuffix = ".history"
+
+// projectFontDir is the directory, next to the project file, of the fonts that come with the project
+const projectFontDir = "fonts"
+
 // Constructor
 func CreateEmptyUMLProject(fileName string) (*UMLProject, duerror.DUError) {
 	// TODO: also check the file is exist or not
@@ -73,7 +88,16 @@ func (p *UMLProject) GetActiveDiagramsNames() []string {
 	return activeNames
 }
 
+func (p *UMLProject) GetKeepHistory() bool {
+	return p.keepHistory
+}
+
 // Setter
+// SetKeepHistory turns on saving the undo history of diagrams next to them, so it survives closing and reopening.
+func (p *UMLProject) SetKeepHistory(keep bool) {
+	p.keepHistory = keep
+}
+
 func (p *UMLProject) SetPointComponent(point utils.Point) duerror.DUError {
 	if p.currentDiagram == nil {
 		return duerror.NewInvalidArgumentError("No current diagram selected")
@@ -96,6 +120,54 @@ func (p *UMLProject) SetLayerComponent(layer int) duerror.DUError {
 	return nil
 }
 
+// BringToFrontComponent puts the selected components on top of all the others.
+func (p *UMLProject) BringToFrontComponent() duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.BringToFrontComponent(); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+// SendToBackComponent puts the selected components below all the others.
+func (p *UMLProject) SendToBackComponent() duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.SendToBackComponent(); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+// BringForwardComponent moves each selected component one step up.
+func (p *UMLProject) BringForwardComponent() duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.BringForwardComponent(); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+// SendBackwardComponent moves each selected component one step down.
+func (p *UMLProject) SendBackwardComponent() duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.SendBackwardComponent(); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
 func (p *UMLProject) SetColorComponent(colorHexStr string) duerror.DUError {
 	if p.currentDiagram == nil {
 		return duerror.NewInvalidArgumentError("No current diagram selected")
@@ -107,6 +179,142 @@ func (p *UMLProject) SetColorComponent(colorHexStr string) duerror.DUError {
 	return nil
 }
 
+// SetLockedComponent locks or unlocks the selected components, locked ones cannot be moved, resized, edited or removed
+func (p *UMLProject) SetLockedComponent(locked bool) duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.SetLockedComponent(locked); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+func (p *UMLProject) SetStereotypesComponent(stereotypes []string) duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.SetStereotypesComponent(stereotypes); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+func (p *UMLProject) SetTaggedValuesComponent(tags map[string]string) duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.SetTaggedValuesComponent(tags); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+// GetStereotypes returns the stereotypes used in the current diagram
+func (p *UMLProject) GetStereotypes() ([]string, duerror.DUError) {
+	if p.currentDiagram == nil {
+		return nil, duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	return p.currentDiagram.GetStereotypes(), nil
+}
+
+func (p *UMLProject) SetMaxWidthComponent(width int) duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.SetMaxWidthComponent(width); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+// SetSizeComponent resizes the selected gadgets of the current diagram, 0 on an axis takes the size of the content.
+func (p *UMLProject) SetSizeComponent(width int, height int) duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.SetSizeComponent(width, height); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+func (p *UMLProject) AutoSizeComponent() duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.AutoSizeComponent(); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+// AlignComponent lines the selected gadgets up along an edge or center.
+func (p *UMLProject) AlignComponent(alignment umldiagram.Alignment) duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.AlignComponent(alignment); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+// DistributeHorizontallyComponent spreads the selected gadgets with equal gaps from left to right.
+func (p *UMLProject) DistributeHorizontallyComponent() duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.DistributeHorizontallyComponent(); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+// DistributeVerticallyComponent spreads the selected gadgets with equal gaps from top to bottom.
+func (p *UMLProject) DistributeVerticallyComponent() duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.DistributeVerticallyComponent(); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+// MatchWidthComponent gives the selected gadgets the width of the widest one.
+func (p *UMLProject) MatchWidthComponent() duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.MatchWidthComponent(); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+// MatchHeightComponent gives the selected gadgets the height of the tallest one.
+func (p *UMLProject) MatchHeightComponent() duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.MatchHeightComponent(); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
 func (p *UMLProject) SetAttrContentComponent(section int, index int, content string) duerror.DUError {
 	if p.currentDiagram == nil {
 		return duerror.NewInvalidArgumentError("No current diagram selected")
@@ -151,6 +359,28 @@ func (p *UMLProject) SetAttrFontComponent(section int, index int, font string) d
 	return nil
 }
 
+func (p *UMLProject) SetAttrColorComponent(section int, index int, color string) duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.SetAttrColorComponent(section, index, color); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+func (p *UMLProject) SetAttrAlignComponent(section int, index int, align int) duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.SetAttrAlignComponent(section, index, align); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
 func (p *UMLProject) SetAttrRatioComponent(section int, index int, ratio float64) duerror.DUError {
 	if p.currentDiagram == nil {
 		return duerror.NewInvalidArgumentError("No current diagram selected")
@@ -228,6 +458,9 @@ func (p *UMLProject) CreateEmptyUMLDiagram(diagramType umldiagram.DiagramType, d
 	if err != nil {
 		return err
 	}
+	if err = d.SetStyleSheet(p.styles); err != nil {
+		return err
+	}
 	p.availableDiagrams[diagramName] = true
 	p.activeDiagrams[diagramName] = d
 	p.lastModified = time.Now()
@@ -279,6 +512,92 @@ func (p *UMLProject) RedoDiagramChange() duerror.DUError {
 	return nil
 }
 
+func (p *UMLProject) BeginTransaction() duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	return p.currentDiagram.BeginTransaction()
+}
+
+func (p *UMLProject) CommitTransaction() duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.CommitTransaction(); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+func (p *UMLProject) RollbackTransaction() duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.RollbackTransaction(); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+func (p *UMLProject) StartDrag() duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	return p.currentDiagram.StartDrag()
+}
+
+func (p *UMLProject) EndDrag() duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	return p.currentDiagram.EndDrag()
+}
+
+func (p *UMLProject) GetHistoryState() (int, duerror.DUError) {
+	if p.currentDiagram == nil {
+		return 0, duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	return p.currentDiagram.GetHistoryState(), nil
+}
+
+func (p *UMLProject) ListHistoryBranches() ([]command.Branch, duerror.DUError) {
+	if p.currentDiagram == nil {
+		return nil, duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	return p.currentDiagram.ListHistoryBranches(), nil
+}
+
+func (p *UMLProject) SwitchHistoryBranch(id int) duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.SwitchHistoryBranch(id); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+func (p *UMLProject) GoToHistoryState(id int) duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.GoToHistoryState(id); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+func (p *UMLProject) DiffHistoryStates(from int, to int) ([]umldiagram.HistoryChange, duerror.DUError) {
+	if p.currentDiagram == nil {
+		return nil, duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	return p.currentDiagram.DiffHistoryStates(from, to)
+}
+
 func (p *UMLProject) AddGadget(gadgetType component.GadgetType, point utils.Point, layer int, colorHexStr string, header string) duerror.DUError {
 	if p.currentDiagram == nil {
 		return duerror.NewInvalidArgumentError("No current diagram selected")
@@ -341,68 +660,490 @@ func (p *UMLProject) RemoveAttributeFromGadget(section int, index int) duerror.D
 	return nil
 }
 
-func (p *UMLProject) AddAttributeToAssociation(ratio float64, content string) duerror.DUError {
+// AddSectionToGadget inserts an empty section into the selected gadget at index, -1 appends it.
+func (p *UMLProject) AddSectionToGadget(index int, name string) duerror.DUError {
 	if p.currentDiagram == nil {
 		return duerror.NewInvalidArgumentError("No current diagram selected")
 	}
-	if err := p.currentDiagram.AddAttributeToAssociation(ratio, content); err != nil {
+	if err := p.currentDiagram.AddSectionToGadget(index, name); err != nil {
 		return err
 	}
 	p.lastModified = time.Now()
 	return nil
 }
 
-func (p *UMLProject) RemoveAttributeFromAssociation(index int) duerror.DUError {
+func (p *UMLProject) RemoveSectionFromGadget(section int) duerror.DUError {
 	if p.currentDiagram == nil {
 		return duerror.NewInvalidArgumentError("No current diagram selected")
 	}
-	if err := p.currentDiagram.RemoveAttributeFromAssociation(index); err != nil {
+	if err := p.currentDiagram.RemoveSectionFromGadget(section); err != nil {
 		return err
 	}
 	p.lastModified = time.Now()
 	return nil
 }
 
-func (p *UMLProject) SelectComponent(point utils.Point) duerror.DUError {
+func (p *UMLProject) MoveSectionInGadget(from int, to int) duerror.DUError {
 	if p.currentDiagram == nil {
 		return duerror.NewInvalidArgumentError("No current diagram selected")
 	}
-	if err := p.currentDiagram.SelectComponent(point); err != nil {
+	if err := p.currentDiagram.MoveSectionInGadget(from, to); err != nil {
 		return err
 	}
 	p.lastModified = time.Now()
 	return nil
 }
 
-// draw
-func (p *UMLProject) GetDrawData() drawdata.Diagram {
+func (p *UMLProject) SetSectionNameComponent(section int, name string) duerror.DUError {
 	if p.currentDiagram == nil {
-		return drawdata.Diagram{}
+		return duerror.NewInvalidArgumentError("No current diagram selected")
 	}
-	return p.currentDiagram.GetDrawData()
-}
-
-func (p *UMLProject) InvalidateCanvas() duerror.DUError {
-	if !p.runFrontend {
-		return nil
+	if err := p.currentDiagram.SetSectionNameComponent(section, name); err != nil {
+		return err
 	}
+	p.lastModified = time.Now()
+	return nil
+}
 
+func (p *UMLProject) SetSectionCollapsedComponent(section int, collapsed bool) duerror.DUError {
 	if p.currentDiagram == nil {
 		return duerror.NewInvalidArgumentError("No current diagram selected")
 	}
-	runtime.EventsEmit(p.ctx, "backend-event", p.GetDrawData())
+	if err := p.currentDiagram.SetSectionCollapsedComponent(section, collapsed); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
 	return nil
 }
 
-func (p *UMLProject) OpenDiagram(filename string) duerror.DUError {
-	err := utils.ValidateFilePath(filename)
-	if err != nil {
+func (p *UMLProject) AddAttributeToAssociation(ratio float64, content string) duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.AddAttributeToAssociation(ratio, content); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+func (p *UMLProject) RemoveAttributeFromAssociation(index int) duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.RemoveAttributeFromAssociation(index); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+func (p *UMLProject) SelectComponent(point utils.Point) duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.SelectComponent(point); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+func (p *UMLProject) ToggleSelectComponent(point utils.Point) duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.ToggleSelectComponent(point); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+func (p *UMLProject) SelectComponentsInRect(p1 utils.Point, p2 utils.Point, additive bool) duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.SelectComponentsInRect(p1, p2, additive); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+func (p *UMLProject) SelectAllComponents() duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.SelectAllComponents(); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+func (p *UMLProject) ClearSelection() duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.ClearSelection(); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+func (p *UMLProject) InvertSelection() duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.InvertSelection(); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+func (p *UMLProject) GetFontFallbacks() ([]string, duerror.DUError) {
+	if p.currentDiagram == nil {
+		return nil, duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	return p.currentDiagram.GetFontFallbacks(), nil
+}
+
+// SetFontFallbacks sets the fonts of the current diagram used for glyphs its attribute fonts lack.
+func (p *UMLProject) SetFontFallbacks(fonts []string) duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.SetFontFallbacks(fonts); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+// GetDiagramProperties returns the title, description, author, tags, colors and timestamps of the current diagram.
+func (p *UMLProject) GetDiagramProperties() (umldiagram.DiagramProperties, duerror.DUError) {
+	if p.currentDiagram == nil {
+		return umldiagram.DiagramProperties{}, duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	return p.currentDiagram.GetProperties(), nil
+}
+
+// SetDiagramProperties sets the properties of the current diagram, its timestamps are kept.
+func (p *UMLProject) SetDiagramProperties(props umldiagram.DiagramProperties) duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.SetProperties(props); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+// GetGridSize returns the grid spacing of the current diagram.
+func (p *UMLProject) GetGridSize() (int, duerror.DUError) {
+	if p.currentDiagram == nil {
+		return 0, duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	return p.currentDiagram.GetGridSize(), nil
+}
+
+// SetGridSize sets the grid spacing of the current diagram.
+func (p *UMLProject) SetGridSize(size int) duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.SetGridSize(size); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+// IsGridEnabled reports whether moves in the current diagram snap to the grid.
+func (p *UMLProject) IsGridEnabled() (bool, duerror.DUError) {
+	if p.currentDiagram == nil {
+		return false, duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	return p.currentDiagram.IsGridEnabled(), nil
+}
+
+// SetGridEnabled turns snapping to the grid of the current diagram on or off.
+func (p *UMLProject) SetGridEnabled(enabled bool) duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.SetGridEnabled(enabled); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+// GetLayers returns the named layers of the current diagram.
+func (p *UMLProject) GetLayers() ([]drawdata.Layer, duerror.DUError) {
+	if p.currentDiagram == nil {
+		return nil, duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	return p.currentDiagram.GetLayers(), nil
+}
+
+// AddLayer adds an empty named layer to the current diagram.
+func (p *UMLProject) AddLayer(name string) duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.AddLayer(name); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+// RemoveLayer removes a named layer of the current diagram, its components move to the default layer.
+func (p *UMLProject) RemoveLayer(name string) duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.RemoveLayer(name); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+// RenameLayer renames a named layer of the current diagram.
+func (p *UMLProject) RenameLayer(name string, newName string) duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.RenameLayer(name, newName); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+// SetLayerHidden hides or shows the components of a named layer.
+func (p *UMLProject) SetLayerHidden(name string, hidden bool) duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.SetLayerHidden(name, hidden); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+// SetLayerLocked locks or unlocks the components of a named layer.
+func (p *UMLProject) SetLayerLocked(name string, locked bool) duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.SetLayerLocked(name, locked); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+// SetLayerNameComponent moves the selected components to a named layer, "" for the default layer.
+func (p *UMLProject) SetLayerNameComponent(name string) duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.SetLayerNameComponent(name); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+// LintDiagram runs the lint rules over the current diagram, the diagnostics identify the components by their
+// index in the draw data
+func (p *UMLProject) LintDiagram() ([]umldiagram.Diagnostic, duerror.DUError) {
+	if p.currentDiagram == nil {
+		return nil, duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	return p.currentDiagram.Lint(), nil
+}
+
+// ListLintRules returns the names of the lint rules in the order they run
+func (p *UMLProject) ListLintRules() []string {
+	return umldiagram.LintRuleNames()
+}
+
+// GetLoadWarnings returns the problems found while loading the current diagram that did not stop it, e.g. missing fonts.
+func (p *UMLProject) GetLoadWarnings() ([]string, duerror.DUError) {
+	if p.currentDiagram == nil {
+		return nil, duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	return p.currentDiagram.GetLoadWarnings(), nil
+}
+
+// ListFonts returns the fonts attributes can use: those of the project, those bundled with the app and the system ones.
+func (p *UMLProject) ListFonts() []utils.FontInfo {
+	return utils.ListFonts()
+}
+
+// RescanFonts looks for the available fonts again, e.g. after fonts were installed.
+func (p *UMLProject) RescanFonts() {
+	utils.RescanFonts()
+}
+
+// ImportFont copies a font file into the font directory of the project, so the project can be shared along with it.
+func (p *UMLProject) ImportFont(filename string) (utils.FontInfo, duerror.DUError) {
+	if p.name == "" {
+		return utils.FontInfo{}, duerror.NewInvalidArgumentError("the project must be saved before importing fonts")
+	}
+	family, err := utils.FontFamily(filename)
+	if err != nil {
+		return utils.FontInfo{}, err
+	}
+	data, rerr := os.ReadFile(filename)
+	if rerr != nil {
+		return utils.FontInfo{}, duerror.NewFileIOError(fmt.Sprintf("Failed to read font file %s.\n Error: %s", filename, rerr.Error()))
+	}
+	dir := p.fontDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return utils.FontInfo{}, duerror.NewFileIOError(fmt.Sprintf("Failed to create font directory %s.\n Error: %s", dir, err.Error()))
+	}
+	target := filepath.Join(dir, filepath.Base(filename))
+	if err := os.WriteFile(target, data, 0644); err != nil {
+		return utils.FontInfo{}, duerror.NewFileIOError(fmt.Sprintf("Failed to write font file %s.\n Error: %s", target, err.Error()))
+	}
+	utils.SetProjectFontDir(dir)
+	return utils.LookupFont(family)
+}
+
+func (p *UMLProject) fontDir() string {
+	return filepath.Join(filepath.Dir(p.name), projectFontDir)
+}
+
+// CopyComponents puts the selected gadgets of the current diagram, with the associations between them, on the clipboard.
+func (p *UMLProject) CopyComponents() duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	fragment, err := p.currentDiagram.CopySelectedComponents()
+	if err != nil {
+		return err
+	}
+	p.clipboard = &fragment
+	p.pasteCount = 0
+	return nil
+}
+
+func (p *UMLProject) CutComponents() duerror.DUError {
+	if err := p.CopyComponents(); err != nil {
+		return err
+	}
+	return p.RemoveSelectedComponents()
+}
+
+// PasteComponents adds the clipboard to the current diagram, which may differ from the one it was copied from.
+func (p *UMLProject) PasteComponents() duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if p.clipboard == nil {
+		return duerror.NewInvalidArgumentError("clipboard is empty")
+	}
+	offset := pasteOffset * (p.pasteCount + 1)
+	if err := p.currentDiagram.PasteComponents(*p.clipboard, utils.Point{X: offset, Y: offset}); err != nil {
 		return err
 	}
+	p.pasteCount++
+	p.lastModified = time.Now()
+	return nil
+}
+
+func (p *UMLProject) DuplicateComponents() duerror.DUError {
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	if err := p.currentDiagram.DuplicateSelectedComponents(utils.Point{X: pasteOffset, Y: pasteOffset}); err != nil {
+		return err
+	}
+	p.lastModified = time.Now()
+	return nil
+}
+
+// GetClipboardText returns the clipboard in the saved diagram format, so it can be passed to another instance.
+func (p *UMLProject) GetClipboardText() (string, duerror.DUError) {
+	if p.clipboard == nil {
+		return "", duerror.NewInvalidArgumentError("clipboard is empty")
+	}
+	data, err := json.Marshal(p.clipboard)
+	if err != nil {
+		return "", duerror.NewParsingError(err.Error())
+	}
+	return string(data), nil
+}
+
+// SetClipboardText replaces the clipboard with a fragment in the saved diagram format.
+func (p *UMLProject) SetClipboardText(text string) duerror.DUError {
+	var fragment utils.SavedDiagram
+	if err := json5.Unmarshal([]byte(text), &fragment); err != nil {
+		return duerror.NewParsingError(err.Error())
+	}
+	if fragment.Filetype&utils.FiletypeDiagram == 0 {
+		return duerror.NewParsingError("clipboard text is not a diagram fragment")
+	}
+	p.clipboard = &fragment
+	p.pasteCount = 0
+	return nil
+}
+
+// draw
+func (p *UMLProject) GetDrawData() drawdata.Diagram {
+	if p.currentDiagram == nil {
+		return drawdata.Diagram{}
+	}
+	return p.currentDiagram.GetDrawData()
+}
+
+func (p *UMLProject) InvalidateCanvas() duerror.DUError {
+	if !p.runFrontend {
+		return nil
+	}
+
+	if p.currentDiagram == nil {
+		return duerror.NewInvalidArgumentError("No current diagram selected")
+	}
+	runtime.EventsEmit(p.ctx, "backend-event", p.GetDrawData())
+	return nil
+}
+
+func (p *UMLProject) OpenDiagram(filename string) duerror.DUError {
+	dia, err := p.readDiagram(filename)
+	if err != nil {
+		return err
+	}
+	if dia == nil {
+		return nil
+	}
+	p.availableDiagrams[filename] = true
+	p.activeDiagrams[filename] = dia
+	p.lastModified = time.Now()
+	p.currentDiagram = dia
+	return nil
+}
+
+// readDiagram loads a diagram file without opening it in the project, it returns nil for submodules
+func (p *UMLProject) readDiagram(filename string) (*umldiagram.UMLDiagram, duerror.DUError) {
+	err := utils.ValidateFilePath(filename)
+	if err != nil {
+		return nil, err
+	}
 
 	file, err := os.OpenFile(filename, os.O_RDONLY, 0644)
 	if err != nil {
-		return duerror.NewFileIOError(fmt.Sprintf("Failed to open file %s.\n Error: %s", filename, err.Error()))
+		return nil, duerror.NewFileIOError(fmt.Sprintf("Failed to open file %s.\n Error: %s", filename, err.Error()))
 	}
 	defer func(file *os.File) {
 		err := file.Close()
@@ -415,33 +1156,32 @@ func (p *UMLProject) OpenDiagram(filename string) duerror.DUError {
 	var savedFileData utils.SavedDiagram
 
 	if err := decoder.Decode(&savedFileData); err != nil {
-		return duerror.NewInvalidArgumentError(fmt.Sprintf("Failed to decode file %s.\n Error: %s", filename, err.Error()))
+		return nil, duerror.NewInvalidArgumentError(fmt.Sprintf("Failed to decode file %s.\n Error: %s", filename, err.Error()))
 	}
 	if savedFileData.Filetype&utils.SupportedFiletypes == 0 {
-		return duerror.NewInvalidArgumentError(fmt.Sprintf("Unsupported file type %d in file %s", savedFileData.Filetype, filename))
+		return nil, duerror.NewInvalidArgumentError(fmt.Sprintf("Unsupported file type %d in file %s", savedFileData.Filetype, filename))
 	}
 	savedFileData.Filetype >>= 1 // Remove the first bit, which is used to indicate if the file is a diagram or submodule
 	switch savedFileData.Filetype {
 	case utils.FiletypeDiagram:
-		dia, err := umldiagram.LoadExistUMLDiagram(filename, savedFileData)
+		dia, err := p.loadDiagram(filename, savedFileData)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		p.availableDiagrams[filename] = true
-		p.activeDiagrams[filename] = dia
-		p.lastModified = time.Now()
-		p.currentDiagram = dia
-
-		break
+		// styles may have changed since the diagram was saved
+		if err := dia.SetStyleSheet(p.styles); err != nil {
+			return nil, err
+		}
+		for _, warning := range dia.GetLoadWarnings() {
+			log.Warn(fmt.Sprintf("Diagram %s: %s", filename, warning))
+		}
+		return dia, nil
 	case utils.FiletypeSubmodule:
-
 		// TODO
-		break
+		return nil, nil
 	default:
-		return duerror.NewInvalidArgumentError(fmt.Sprintf("Unknown filetype %d", savedFileData.Filetype))
+		return nil, duerror.NewInvalidArgumentError(fmt.Sprintf("Unknown filetype %d", savedFileData.Filetype))
 	}
-
-	return nil
 }
 
 // SaveDiagram saves the current diagram to a file.
@@ -452,7 +1192,14 @@ func (p *UMLProject) SaveDiagram(filename string) duerror.DUError {
 		return duerror.NewInvalidArgumentError("No current diagram selected")
 	}
 	originalFilename := p.currentDiagram.GetName()
-	savedFileData, err := p.currentDiagram.SaveToFile(filename)
+	var savedFileData *utils.SavedDiagram
+	var history *utils.SavedHistory
+	var err duerror.DUError
+	if p.keepHistory {
+		savedFileData, history, err = p.currentDiagram.SaveToFileWithHistory(filename)
+	} else {
+		savedFileData, err = p.currentDiagram.SaveToFile(filename)
+	}
 	if err != nil {
 		return duerror.NewParsingError(fmt.Sprintf("Failed to export diagram %s.\n Error: %s", filename, err.Error()))
 	}
@@ -481,11 +1228,60 @@ func (p *UMLProject) SaveDiagram(filename string) duerror.DUError {
 	if _, err := file.Write(data); err != nil {
 		return duerror.NewFileIOError(fmt.Sprintf("Failed to write data to file %s.\n Error: %s", filename, err.Error()))
 	}
+	if history != nil {
+		if err := saveHistoryFile(filename+historyFileSuffix, history); err != nil {
+			return err
+		}
+	}
 
 	p.lastModified = time.Now()
 	return nil
 }
 
+// loadDiagram loads a diagram, restoring its undo history if it is kept and still matches the diagram file.
+func (p *UMLProject) loadDiagram(filename string, savedFileData utils.SavedDiagram) (*umldiagram.UMLDiagram, duerror.DUError) {
+	if p.keepHistory {
+		history, err := loadHistoryFile(filename + historyFileSuffix)
+		if err != nil {
+			log.Warn(fmt.Sprintf("Ignoring undo history of diagram %s.\n Error: %s", filename, err.Error()))
+		} else if history != nil {
+			dia, err := umldiagram.LoadExistUMLDiagramWithHistory(filename, savedFileData, *history)
+			if err == nil {
+				return dia, nil
+			}
+			log.Warn(fmt.Sprintf("Ignoring undo history of diagram %s.\n Error: %s", filename, err.Error()))
+		}
+	}
+	return umldiagram.LoadExistUMLDiagram(filename, savedFileData)
+}
+
+func saveHistoryFile(filename string, history *utils.SavedHistory) duerror.DUError {
+	data, err := json.Marshal(history)
+	if err != nil {
+		return duerror.NewFileIOError(fmt.Sprintf("Failed to marshal history to JSON for file %s.\n Error: %s", filename, err.Error()))
+	}
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return duerror.NewFileIOError(fmt.Sprintf("Failed to write history file %s.\n Error: %s", filename, err.Error()))
+	}
+	return nil
+}
+
+// loadHistoryFile returns nil without error if the history file does not exist.
+func loadHistoryFile(filename string) (*utils.SavedHistory, duerror.DUError) {
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, duerror.NewFileIOError(fmt.Sprintf("Failed to read history file %s.\n Error: %s", filename, err.Error()))
+	}
+	var history utils.SavedHistory
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, duerror.NewParsingError(fmt.Sprintf("Failed to decode history file %s.\n Error: %s", filename, err.Error()))
+	}
+	return &history, nil
+}
+
 func (p *UMLProject) LoadProject(filename string) duerror.DUError {
 	if err := utils.ValidateFilePath(filename); err != nil {
 		return err
@@ -508,6 +1304,7 @@ func (p *UMLProject) LoadProject(filename string) duerror.DUError {
 		return duerror.NewParsingError(fmt.Sprintf("Failed to decode project file %s.\n Error: %s", filename, err.Error()))
 	}
 	p.name = filename
+	utils.SetProjectFontDir(p.fontDir())
 	p.lastModified = time.Now()
 	p.lastSave = time.Now()
 	p.availableDiagrams = make(map[string]bool)
@@ -515,6 +1312,12 @@ func (p *UMLProject) LoadProject(filename string) duerror.DUError {
 	for _, diagramName := range projectData.Diagrams {
 		p.availableDiagrams[diagramName] = true
 	}
+	for _, style := range projectData.Styles {
+		if err := umldiagram.ValidateStyle(style); err != nil {
+			return duerror.NewParsingError(fmt.Sprintf("Invalid style in project file %s.\n Error: %s", filename, err.Error()))
+		}
+	}
+	p.styles = projectData.Styles
 
 	return nil
 }
@@ -526,8 +1329,10 @@ func (p *UMLProject) SaveProject(filename string) duerror.DUError {
 		}
 		p.name = filename
 	}
+	utils.SetProjectFontDir(p.fontDir())
 	projectData := utils.SavedProject{
 		Diagrams: p.GetAvailableDiagramsNames(),
+		Styles:   p.styles,
 	}
 	for _, diagram := range p.activeDiagrams {
 		if diagram.HasUnsavedChanges() {
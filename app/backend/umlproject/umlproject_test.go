@@ -3,6 +3,7 @@ package umlproject
 import (
 	"encoding/json"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -640,3 +641,320 @@ func TestDumlEndToEnd(t *testing.T) {
 		assert.True(t, found, "Association %+v not found after reload", a1)
 	}
 }
+
+func TestKeepHistory(t *testing.T) {
+	diagramName := t.TempDir() + "/HistoryDiagram"
+
+	p, err := CreateEmptyUMLProject("HistoryProject")
+	assert.NoError(t, err)
+	p.SetKeepHistory(true)
+	assert.True(t, p.GetKeepHistory())
+	assert.NoError(t, p.CreateEmptyUMLDiagram(umldiagram.ClassDiagram, diagramName))
+	assert.NoError(t, p.SelectDiagram(diagramName))
+	assert.NoError(t, p.AddGadget(component.Class, utils.Point{X: 20, Y: 30}, 0, drawdata.DefaultGadgetColor, "header1"))
+	assert.NoError(t, p.AddGadget(component.Class, utils.Point{X: 220, Y: 230}, 0, drawdata.DefaultGadgetColor, "header2"))
+	assert.NoError(t, p.SaveDiagram(diagramName))
+	assert.NoError(t, p.CloseDiagram(diagramName))
+
+	_, err = os.Stat(diagramName + historyFileSuffix)
+	assert.NoError(t, err)
+
+	// the last session's work can still be undone after reopening
+	p2, err := CreateEmptyUMLProject("HistoryProject")
+	assert.NoError(t, err)
+	p2.SetKeepHistory(true)
+	assert.NoError(t, p2.OpenDiagram(diagramName))
+	assert.Equal(t, 2, len(p2.GetDrawData().Gadgets))
+	assert.NoError(t, p2.UndoDiagramChange())
+	assert.Equal(t, 1, len(p2.GetDrawData().Gadgets))
+	assert.NoError(t, p2.RedoDiagramChange())
+	assert.Equal(t, 2, len(p2.GetDrawData().Gadgets))
+
+	// without keeping history, the diagram opens with an empty history
+	p3, err := CreateEmptyUMLProject("HistoryProject")
+	assert.NoError(t, err)
+	assert.NoError(t, p3.OpenDiagram(diagramName))
+	assert.NoError(t, p3.UndoDiagramChange())
+	assert.Equal(t, 2, len(p3.GetDrawData().Gadgets))
+}
+
+func TestClipboard(t *testing.T) {
+	p, err := CreateEmptyUMLProject("ClipboardProject")
+	assert.NoError(t, err)
+	assert.Error(t, p.PasteComponents())
+
+	assert.NoError(t, p.CreateEmptyUMLDiagram(umldiagram.ClassDiagram, "Source"))
+	assert.NoError(t, p.CreateEmptyUMLDiagram(umldiagram.ClassDiagram, "Target"))
+	assert.NoError(t, p.SelectDiagram("Source"))
+	assert.NoError(t, p.AddGadget(component.Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "A"))
+	assert.NoError(t, p.AddGadget(component.Class, utils.Point{X: 200, Y: 200}, 0, drawdata.DefaultGadgetColor, "B"))
+	assert.NoError(t, p.AddGadget(component.Class, utils.Point{X: 400, Y: 400}, 0, drawdata.DefaultGadgetColor, "C"))
+	assert.NoError(t, p.StartAddAssociation(utils.Point{X: 0, Y: 0}))
+	assert.NoError(t, p.EndAddAssociation(component.Extension, utils.Point{X: 200, Y: 200}))
+	assert.NoError(t, p.StartAddAssociation(utils.Point{X: 210, Y: 210}))
+	assert.NoError(t, p.EndAddAssociation(component.Composition, utils.Point{X: 400, Y: 400}))
+
+	// copy A and B, the association to C is dropped
+	assert.NoError(t, p.SelectComponent(utils.Point{X: 3, Y: 15}))
+	assert.NoError(t, p.SelectComponent(utils.Point{X: 203, Y: 215}))
+	assert.NoError(t, p.CopyComponents())
+
+	assert.NoError(t, p.SelectDiagram("Target"))
+	assert.NoError(t, p.PasteComponents())
+	dd := p.GetDrawData()
+	assert.Len(t, dd.Gadgets, 2)
+	assert.Len(t, dd.Associations, 1)
+	assert.Equal(t, int(component.Extension), dd.Associations[0].AssType)
+	for _, g := range dd.Gadgets {
+		assert.True(t, g.IsSelected)
+		assert.Contains(t, []int{20, 220}, g.X)
+	}
+
+	// each paste lands further away
+	assert.NoError(t, p.PasteComponents())
+	assert.Len(t, p.GetDrawData().Gadgets, 4)
+	xs := []int{}
+	for _, g := range p.GetDrawData().Gadgets {
+		xs = append(xs, g.X)
+	}
+	assert.ElementsMatch(t, []int{20, 220, 40, 240}, xs)
+
+	// a paste is one undo step
+	assert.NoError(t, p.UndoDiagramChange())
+	assert.Len(t, p.GetDrawData().Gadgets, 2)
+
+	// the clipboard survives a round trip through text
+	text, err := p.GetClipboardText()
+	assert.NoError(t, err)
+	p2, err := CreateEmptyUMLProject("OtherInstance")
+	assert.NoError(t, err)
+	assert.NoError(t, p2.CreateEmptyUMLDiagram(umldiagram.ClassDiagram, "Other"))
+	assert.NoError(t, p2.SelectDiagram("Other"))
+	assert.NoError(t, p2.SetClipboardText(text))
+	assert.NoError(t, p2.PasteComponents())
+	assert.Len(t, p2.GetDrawData().Gadgets, 2)
+	assert.Len(t, p2.GetDrawData().Associations, 1)
+	assert.Error(t, p2.SetClipboardText("not a diagram"))
+	assert.Error(t, p2.SetClipboardText(`{"filetype": 2}`))
+
+	// cut removes the originals
+	assert.NoError(t, p.SelectDiagram("Source"))
+	assert.NoError(t, p.CutComponents())
+	assert.Len(t, p.GetDrawData().Gadgets, 1)
+	assert.Len(t, p.GetDrawData().Associations, 0)
+}
+
+func TestDuplicateComponents(t *testing.T) {
+	p, err := CreateEmptyUMLProject("DuplicateProject")
+	assert.NoError(t, err)
+	assert.NoError(t, p.CreateEmptyUMLDiagram(umldiagram.ClassDiagram, "Diagram"))
+	assert.NoError(t, p.SelectDiagram("Diagram"))
+	assert.NoError(t, p.AddGadget(component.Class, utils.Point{X: 0, Y: 0}, 0, "#123456", "A"))
+	assert.NoError(t, p.AddGadget(component.Class, utils.Point{X: 200, Y: 200}, 0, drawdata.DefaultGadgetColor, "B"))
+	assert.NoError(t, p.StartAddAssociation(utils.Point{X: 0, Y: 0}))
+	assert.NoError(t, p.EndAddAssociation(component.Extension, utils.Point{X: 200, Y: 200}))
+	assert.NoError(t, p.SelectAllComponents())
+
+	assert.NoError(t, p.DuplicateComponents())
+	dd := p.GetDrawData()
+	assert.Len(t, dd.Gadgets, 4)
+	assert.Len(t, dd.Associations, 2)
+	selected := 0
+	for _, g := range dd.Gadgets {
+		if g.IsSelected {
+			selected++
+			assert.Contains(t, []int{20, 220}, g.X)
+		}
+	}
+	assert.Equal(t, 2, selected)
+
+	// duplicating does not touch the clipboard
+	_, err = p.GetClipboardText()
+	assert.Error(t, err)
+
+	assert.NoError(t, p.UndoDiagramChange())
+	assert.Len(t, p.GetDrawData().Gadgets, 2)
+	assert.Len(t, p.GetDrawData().Associations, 1)
+}
+
+func TestImportFont(t *testing.T) {
+	dir := t.TempDir()
+	p, err := CreateEmptyUMLProject(filepath.Join(dir, "project.uml"))
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		utils.SetProjectFontDir("")
+	})
+
+	_, err = p.ImportFont(filepath.Join(dir, "missing.ttf"))
+	assert.Error(t, err)
+
+	info, err := p.ImportFont(utils.DefaultFontFile())
+	assert.NoError(t, err)
+	assert.Equal(t, utils.FontInfo{Family: "Ink Free", File: filepath.Join(dir, "fonts", "Inkfree.ttf"), Source: utils.FontSourceProject}, info)
+	assert.Contains(t, p.ListFonts(), info)
+
+	// an unnamed project has no directory to put fonts in
+	unnamed := &UMLProject{}
+	_, err = unnamed.ImportFont(utils.DefaultFontFile())
+	assert.Error(t, err)
+}
+
+func TestOpenDiagramMissingFont(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "missing.uml")
+	p, err := CreateEmptyUMLProject(filepath.Join(dir, "project.uml"))
+	assert.NoError(t, err)
+	assert.NoError(t, p.CreateEmptyUMLDiagram(umldiagram.ClassDiagram, filename))
+	assert.NoError(t, p.SelectDiagram(filename))
+	assert.NoError(t, p.AddGadget(component.Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "a"))
+	assert.NoError(t, p.SaveDiagram(filename))
+
+	data, rerr := os.ReadFile(filename)
+	assert.NoError(t, rerr)
+	var saved utils.SavedDiagram
+	assert.NoError(t, json.Unmarshal(data, &saved))
+	saved.Gadgets[0].Attributes[0].Font = "No Such Font"
+	data, rerr = json.Marshal(saved)
+	assert.NoError(t, rerr)
+	assert.NoError(t, os.WriteFile(filename, data, 0644))
+
+	p, err = CreateEmptyUMLProject(filepath.Join(dir, "project.uml"))
+	assert.NoError(t, err)
+	_, err = p.GetLoadWarnings()
+	assert.Error(t, err)
+	assert.NoError(t, p.OpenDiagram(filename))
+	warnings, err := p.GetLoadWarnings()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`font "No Such Font" is not available, using a substitute`}, warnings)
+	assert.Equal(t, "No Such Font", p.GetDrawData().Gadgets[0].Attributes[0][0].FontFamily)
+}
+
+func TestStyles(t *testing.T) {
+	dir := t.TempDir()
+	projectName, diagramName, closedName := dir+"/StyleProject", dir+"/StyleDiagram", dir+"/ClosedDiagram"
+
+	p, err := CreateEmptyUMLProject(projectName)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"light", "dark", "print"}, p.ListThemes())
+	assert.Error(t, p.ApplyTheme("sepia"))
+	assert.NoError(t, p.ApplyTheme("light"))
+	assert.Len(t, p.GetStyles(), 3)
+	assert.Error(t, p.SetStyle(utils.SavedStyle{Name: "Bad", Color: "red"}))
+	assert.NoError(t, p.SetStyle(utils.SavedStyle{Name: "Note", Color: "#FFFFCC"}))
+	assert.Len(t, p.GetStyles(), 4)
+
+	for _, name := range []string{closedName, diagramName} {
+		assert.NoError(t, p.CreateEmptyUMLDiagram(umldiagram.ClassDiagram, name))
+		assert.NoError(t, p.SelectDiagram(name))
+		assert.NoError(t, p.AddGadget(component.Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "A"))
+		assert.NoError(t, p.SelectAllComponents())
+		assert.NoError(t, p.SetStyleComponent("Entity"))
+		assert.Equal(t, "#DAE8FC", p.GetDrawData().Gadgets[0].Color)
+		assert.NoError(t, p.SaveDiagram(name))
+	}
+	assert.NoError(t, p.CloseDiagram(closedName))
+
+	// editing a style restyles the open diagrams, and the others once opened
+	assert.NoError(t, p.ApplyTheme("dark"))
+	assert.Equal(t, "#1E3A5F", p.GetDrawData().Gadgets[0].Color)
+	// it is not an undo step of the diagrams, which keep the styles of the project
+	assert.NoError(t, p.UndoDiagramChange())
+	assert.Equal(t, "", p.GetDrawData().Gadgets[0].Style, "the last edit is undone")
+	assert.Equal(t, p.GetStyles(), p.activeDiagrams[diagramName].GetStyleSheet())
+	assert.NoError(t, p.RedoDiagramChange())
+	assert.Equal(t, "#1E3A5F", p.GetDrawData().Gadgets[0].Color, "redone with the current styles")
+	assert.NoError(t, p.SaveProject(projectName))
+	assert.NoError(t, p.OpenDiagram(closedName))
+	assert.Equal(t, "#1E3A5F", p.GetDrawData().Gadgets[0].Color)
+	// restyling a diagram being opened is not an edit
+	assert.Zero(t, p.activeDiagrams[closedName].GetHistoryState(), "nothing to undo")
+
+	assert.Error(t, p.RemoveStyle("Missing"))
+	assert.NoError(t, p.RemoveStyle("Note"))
+
+	// the styles are saved with the project
+	p2, err := CreateEmptyUMLProject(projectName)
+	assert.NoError(t, err)
+	assert.NoError(t, p2.LoadProject(projectName))
+	assert.Len(t, p2.GetStyles(), 4)
+	assert.Equal(t, "#1E3A5F", p2.GetStyles()[0].Color)
+}
+
+func TestLintDiagram(t *testing.T) {
+	p, err := CreateEmptyUMLProject("LintProject")
+	assert.NoError(t, err)
+	_, err = p.LintDiagram()
+	assert.Error(t, err)
+	assert.Contains(t, p.ListLintRules(), "cyclic-inheritance")
+
+	assert.NoError(t, p.CreateEmptyUMLDiagram(umldiagram.ClassDiagram, "Diagram"))
+	assert.NoError(t, p.SelectDiagram("Diagram"))
+	assert.NoError(t, p.AddGadget(component.Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "Lonely"))
+	diagnostics, err := p.LintDiagram()
+	assert.NoError(t, err)
+	assert.Len(t, diagnostics, 1)
+	assert.Equal(t, "unconnected-class", diagnostics[0].Rule)
+	assert.Equal(t, umldiagram.SeverityInfo, diagnostics[0].Severity)
+	assert.Equal(t, p.GetDrawData().Gadgets[0].ID, diagnostics[0].ID)
+}
+
+func TestSearchProject(t *testing.T) {
+	dir := t.TempDir()
+	closedName, openName := dir+"/ClosedDiagram", dir+"/OpenDiagram"
+
+	p, err := CreateEmptyUMLProject("SearchProject")
+	assert.NoError(t, err)
+	for _, name := range []string{closedName, openName} {
+		assert.NoError(t, p.CreateEmptyUMLDiagram(umldiagram.ClassDiagram, name))
+		assert.NoError(t, p.SelectDiagram(name))
+		assert.NoError(t, p.AddGadget(component.Class, utils.Point{X: 0, Y: 0}, 0, drawdata.DefaultGadgetColor, "Customer"))
+		assert.NoError(t, p.SaveDiagram(name))
+	}
+	assert.NoError(t, p.CloseDiagram(closedName))
+	// a diagram that cannot be read does not stop the others from being searched
+	brokenName := dir + "/BrokenDiagram"
+	assert.NoError(t, os.WriteFile(brokenName, []byte("not a diagram"), 0644))
+	p.availableDiagrams[brokenName] = true
+
+	// closed diagrams are searched without being opened, the current one stays current
+	found, err := p.SearchProject(umldiagram.SearchOptions{Query: "customer"})
+	assert.NoError(t, err)
+	assert.Len(t, found.Matches, 2)
+	assert.Equal(t, closedName, found.Matches[0].Diagram)
+	assert.Equal(t, openName, found.Matches[1].Diagram)
+	assert.Len(t, found.Failures, 1)
+	assert.Equal(t, brokenName, found.Failures[0].Diagram)
+	assert.Equal(t, openName, p.GetCurrentDiagramName())
+	assert.NotContains(t, p.GetActiveDiagramsNames(), closedName)
+
+	// invalid options are rejected before any diagram is read
+	_, err = p.SearchProject(umldiagram.SearchOptions{Query: "(", Regex: true})
+	assert.Error(t, err)
+	replaced, err := p.ReplaceInProject(umldiagram.SearchOptions{Query: "(", Regex: true}, "Client")
+	assert.Error(t, err)
+	assert.Empty(t, replaced.Failures)
+
+	// closed diagrams that do not change are not opened
+	replaced, err = p.ReplaceInProject(umldiagram.SearchOptions{Query: "Nobody"}, "Client")
+	assert.NoError(t, err)
+	assert.Zero(t, replaced.Replaced)
+	assert.Len(t, replaced.Failures, 1)
+	assert.NotContains(t, p.GetActiveDiagramsNames(), closedName)
+
+	replaced, err = p.ReplaceInProject(umldiagram.SearchOptions{Query: "Customer", CaseSensitive: true}, "Client")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, replaced.Replaced)
+	assert.Contains(t, p.GetActiveDiagramsNames(), closedName)
+	assert.Equal(t, openName, p.GetCurrentDiagramName())
+	found, err = p.SearchProject(umldiagram.SearchOptions{Query: "Client"})
+	assert.NoError(t, err)
+	assert.Len(t, found.Matches, 2)
+
+	// one undo step in each diagram
+	assert.NoError(t, p.UndoDiagramChange())
+	assert.Equal(t, "Customer", p.GetDrawData().Gadgets[0].Attributes[0][0].Content)
+	assert.NoError(t, p.SelectDiagram(closedName))
+	assert.Equal(t, "Client", p.GetDrawData().Gadgets[0].Attributes[0][0].Content)
+	assert.NoError(t, p.UndoDiagramChange())
+	assert.Equal(t, "Customer", p.GetDrawData().Gadgets[0].Attributes[0][0].Content)
+}
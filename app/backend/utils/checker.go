@@ -50,3 +50,16 @@ func ValidateFilePath(path string) duerror.DUError {
 
 	return nil
 }
+
+// ValidateHexColor checks that color is a hex color of the form #RGB or #RRGGBB.
+func ValidateHexColor(color string) duerror.DUError {
+	if len(color) != 4 && len(color) != 7 || color[0] != '#' {
+		return duerror.NewInvalidArgumentError("color should be of the form #RGB or #RRGGBB")
+	}
+	for _, r := range color[1:] {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return duerror.NewInvalidArgumentError("color should be of the form #RGB or #RRGGBB")
+		}
+	}
+	return nil
+}
@@ -45,3 +45,29 @@ func TestValidateFilePath(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateHexColor(t *testing.T) {
+	tests := []struct {
+		name     string
+		color    string
+		hasError bool
+	}{
+		{"Long", "#FF5733", false},
+		{"Short", "#abc", false},
+		{"Empty", "", true},
+		{"NoHash", "FF5733", true},
+		{"BadDigit", "#GG5733", true},
+		{"WrongLength", "#FF57", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateHexColor(tt.color)
+			if tt.hasError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package utils
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"Dr.uml/backend/utils/duerror"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// maxCachedMeasurements bounds the memoised measurements, the cache is emptied when it is reached
+const maxCachedMeasurements = 1 << 16
+
+// faceKey identifies a face built from a font file
+type faceKey struct {
+	file  string
+	size  int
+	style int
+}
+
+// measureKey identifies a measured string, chain holds the font files joined by a NUL byte
+type measureKey struct {
+	chain string
+	size  int
+	style int
+	str   string
+}
+
+type textSize struct {
+	height int
+	width  int
+	runs   []TextRun
+}
+
+// cachedFace guards a face, faces keep internal buffers and cannot be used concurrently
+type cachedFace struct {
+	mu     sync.Mutex
+	face   font.Face
+	height int
+}
+
+// fontCache keeps parsed fonts, their faces and the measured strings. It is safe for concurrent use.
+type fontCache struct {
+	mu    sync.RWMutex
+	fonts map[string]*opentype.Font
+	faces map[faceKey]*cachedFace
+	sizes map[measureKey]textSize
+}
+
+var fonts = newFontCache()
+
+func newFontCache() *fontCache {
+	return &fontCache{
+		fonts: make(map[string]*opentype.Font),
+		faces: make(map[faceKey]*cachedFace),
+		sizes: make(map[measureKey]textSize),
+	}
+}
+
+// ClearFontCache drops every cached font, face and measurement, e.g. after a font file changed on disk.
+func ClearFontCache() {
+	fonts.clear()
+}
+
+func (fc *fontCache) clear() {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	for _, f := range fc.faces {
+		f.face.Close()
+	}
+	fc.fonts = make(map[string]*opentype.Font)
+	fc.faces = make(map[faceKey]*cachedFace)
+	fc.sizes = make(map[measureKey]textSize)
+}
+
+// measure returns the size of str and the runs it splits into, measuring it only the first time it is asked for.
+// Every rune is measured with the first font of chain that has a glyph for it,
+// runes no font has are measured as the missing glyph box of the first font.
+func (fc *fontCache) measure(str string, size int, style int, chain []string) (textSize, duerror.DUError) {
+	mkey := measureKey{chain: strings.Join(chain, "\x00"), size: size, style: style, str: str}
+	fc.mu.RLock()
+	res, ok := fc.sizes[mkey]
+	fc.mu.RUnlock()
+	if ok {
+		return res, nil
+	}
+
+	faces := make([]*cachedFace, len(chain))
+	for i, file := range chain {
+		f, err := fc.face(faceKey{file: file, size: size, style: style})
+		if err != nil {
+			return textSize{}, err
+		}
+		faces[i] = f
+	}
+
+	res = textSize{height: faces[0].height, runs: make([]TextRun, 0, 1)}
+	var width, runWidth fixed.Int26_6
+	runStart, runFont := 0, -1
+	for i, r := range str {
+		used, advance := 0, fixed.Int26_6(0)
+		for j, f := range faces {
+			f.mu.Lock()
+			a, ok := f.face.GlyphAdvance(r)
+			f.mu.Unlock()
+			if j == 0 {
+				advance = a
+			}
+			if ok {
+				used, advance = j, a
+				break
+			}
+		}
+		if used != runFont {
+			if runFont >= 0 {
+				res.runs = append(res.runs, TextRun{Content: str[runStart:i], FontFile: chain[runFont], Width: runWidth.Round()})
+			}
+			runStart, runFont, runWidth = i, used, 0
+			res.height = max(res.height, faces[used].height)
+		}
+		runWidth += advance
+		width += advance
+	}
+	if runFont >= 0 {
+		res.runs = append(res.runs, TextRun{Content: str[runStart:], FontFile: chain[runFont], Width: runWidth.Round()})
+	}
+	res.width = width.Round()
+
+	fc.mu.Lock()
+	if len(fc.sizes) >= maxCachedMeasurements {
+		fc.sizes = make(map[measureKey]textSize)
+	}
+	fc.sizes[mkey] = res
+	fc.mu.Unlock()
+	return res, nil
+}
+
+// face returns the face for key, parsing the font file on first use.
+func (fc *fontCache) face(key faceKey) (*cachedFace, duerror.DUError) {
+	fc.mu.RLock()
+	f, ok := fc.faces[key]
+	fc.mu.RUnlock()
+	if ok {
+		return f, nil
+	}
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	// another goroutine may have built it in the meantime
+	if f, ok = fc.faces[key]; ok {
+		return f, nil
+	}
+	fnt, ok := fc.fonts[key.file]
+	if !ok {
+		var err duerror.DUError
+		if fnt, err = loadFont(key.file); err != nil {
+			return nil, err
+		}
+		fc.fonts[key.file] = fnt
+	}
+	face, err := opentype.NewFace(fnt, &opentype.FaceOptions{
+		Size:    float64(key.size),
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		return nil, duerror.NewFileIOError(err.Error())
+	}
+	metrics := face.Metrics()
+	f = &cachedFace{face: face, height: (metrics.Ascent + metrics.Descent).Round()}
+	fc.faces[key] = f
+	return f, nil
+}
+
+// ValidateFontFile checks that file can be read and parsed as a font.
+func ValidateFontFile(file string) duerror.DUError {
+	_, err := fonts.font(file)
+	return err
+}
+
+// FontFamily returns the family name stored in a font file.
+func FontFamily(file string) (string, duerror.DUError) {
+	fnt, err := fonts.font(file)
+	if err != nil {
+		return "", err
+	}
+	return fontFamily(fnt)
+}
+
+func (fc *fontCache) font(file string) (*opentype.Font, duerror.DUError) {
+	fc.mu.RLock()
+	fnt, ok := fc.fonts[file]
+	fc.mu.RUnlock()
+	if ok {
+		return fnt, nil
+	}
+	fnt, err := loadFont(file)
+	if err != nil {
+		return nil, err
+	}
+	fc.mu.Lock()
+	fc.fonts[file] = fnt
+	fc.mu.Unlock()
+	return fnt, nil
+}
+
+// loadFont parses a font file, the first font of a collection stands for the whole file.
+func loadFont(file string) (*opentype.Font, duerror.DUError) {
+	fontBytes, err := os.ReadFile(file)
+	if err != nil {
+		return nil, duerror.NewFileIOError(err.Error())
+	}
+	fnt, err := opentype.Parse(fontBytes)
+	if err == nil {
+		return fnt, nil
+	}
+	collection, collErr := opentype.ParseCollection(fontBytes)
+	if collErr != nil {
+		return nil, duerror.NewFileIOError(err.Error())
+	}
+	if fnt, collErr = collection.Font(0); collErr != nil {
+		return nil, duerror.NewFileIOError(collErr.Error())
+	}
+	return fnt, nil
+}
+
+func fontFamily(fnt *opentype.Font) (string, duerror.DUError) {
+	family, err := fnt.Name(nil, sfnt.NameIDFamily)
+	if err != nil {
+		return "", duerror.NewFileIOError(err.Error())
+	}
+	return family, nil
+}
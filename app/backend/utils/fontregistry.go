@@ -0,0 +1,227 @@
+package utils
+
+import (
+	"cmp"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"Dr.uml/backend/drawdata"
+	"Dr.uml/backend/utils/duerror"
+	"golang.org/x/image/font/sfnt"
+)
+
+// where a font of the registry comes from
+const (
+	FontSourceProject = "project"
+	FontSourceBundled = "bundled"
+	FontSourceSystem  = "system"
+	FontSourceFile    = "file" // a font file given by its path
+)
+
+// FontInfo is a font available to attributes
+type FontInfo struct {
+	Family string `json:"family"`
+	File   string `json:"file"`
+	Source string `json:"source"`
+}
+
+// systemFontDirs returns the font directories of a Linux system
+var systemFontDirs = func() []string {
+	dirs := []string{"/usr/share/fonts", "/usr/local/share/fonts"}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".local", "share", "fonts"), filepath.Join(home, ".fonts"))
+	}
+	return dirs
+}
+
+// fontRegistry knows the fonts of the project, bundled with the app and installed on the system.
+// The project and bundled fonts are scanned on first use and again after the project font directory changes.
+// The system fonts are many and seldom needed, they are scanned once a font is not found among the others
+// or all fonts are listed.
+type fontRegistry struct {
+	mu            sync.Mutex
+	scanned       bool
+	systemScanned bool
+	projectDir    string
+	system        []scannedFont       // kept when the project fonts are scanned again
+	fonts         []FontInfo          // sorted by family
+	byName        map[string]FontInfo // lower case family and file name without extension
+}
+
+var registry = &fontRegistry{}
+
+// BundledFontDir returns the directory of the fonts shipped with the app.
+func BundledFontDir() string {
+	return os.Getenv("APP_ROOT") + "/frontend/src/assets/fonts"
+}
+
+// DefaultFontFile returns the font file attributes use unless told otherwise.
+func DefaultFontFile() string {
+	return os.Getenv("APP_ROOT") + drawdata.DefaultAttributeFontFile
+}
+
+// SetProjectFontDir sets the directory of the fonts that come with the project, "" for none.
+// The fonts of the directory are looked for again on next use, even if it did not change.
+func SetProjectFontDir(dir string) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.projectDir = dir
+	registry.scanned = false
+}
+
+// RescanFonts forgets the known fonts, they are looked for again on next use.
+func RescanFonts() {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.scanned = false
+	registry.systemScanned = false
+}
+
+// ListFonts returns the available fonts sorted by family.
+// A family found in several places is listed once, project fonts win over bundled ones, which win over system ones.
+func ListFonts() []FontInfo {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.scanSystem()
+	registry.scan()
+	return slices.Clone(registry.fonts)
+}
+
+// LookupFont finds a font by its family or by its file name without extension, ignoring case.
+// An absolute path to a font file is accepted as well.
+func LookupFont(name string) (FontInfo, duerror.DUError) {
+	if name == "" {
+		return FontInfo{}, duerror.NewInvalidArgumentError("font name is empty")
+	}
+	if filepath.IsAbs(name) {
+		family, err := FontFamily(name)
+		if err != nil {
+			return FontInfo{}, err
+		}
+		return FontInfo{Family: family, File: name, Source: FontSourceFile}, nil
+	}
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.scan()
+	info, ok := registry.byName[strings.ToLower(name)]
+	if !ok && !registry.systemScanned {
+		registry.scanSystem()
+		registry.scan()
+		info, ok = registry.byName[strings.ToLower(name)]
+	}
+	if !ok {
+		return FontInfo{}, duerror.NewInvalidArgumentError(fmt.Sprintf("font %s not found", name))
+	}
+	return info, nil
+}
+
+// ResolveFontFile returns the file of a font name as understood by LookupFont.
+func ResolveFontFile(name string) (string, duerror.DUError) {
+	info, err := LookupFont(name)
+	if err != nil {
+		return "", err
+	}
+	return info.File, nil
+}
+
+func (r *fontRegistry) scan() {
+	if r.scanned {
+		return
+	}
+	r.fonts = make([]FontInfo, 0)
+	r.byName = make(map[string]FontInfo)
+	families := make(map[string]int) // index in r.fonts
+	regular := make(map[string]bool) // the listed file of the family is its regular style
+	add := func(found []scannedFont) {
+		for _, scanned := range found {
+			info := scanned.info
+			base := strings.TrimSuffix(filepath.Base(info.File), filepath.Ext(info.File))
+			if _, ok := r.byName[strings.ToLower(base)]; !ok {
+				r.byName[strings.ToLower(base)] = info
+			}
+			family := strings.ToLower(info.Family)
+			if i, ok := families[family]; ok {
+				// the regular style stands for a family found in one place
+				if r.fonts[i].Source == info.Source && !regular[family] && scanned.regular {
+					r.fonts[i] = info
+					r.byName[family] = info
+					regular[family] = true
+				}
+				continue
+			}
+			families[family] = len(r.fonts)
+			regular[family] = scanned.regular
+			r.byName[family] = info
+			r.fonts = append(r.fonts, info)
+		}
+	}
+	if r.projectDir != "" {
+		add(scanFontDir(r.projectDir, FontSourceProject))
+	}
+	add(scanFontDir(BundledFontDir(), FontSourceBundled))
+	if r.systemScanned {
+		add(r.system)
+	}
+	slices.SortStableFunc(r.fonts, func(a, b FontInfo) int {
+		return cmp.Compare(strings.ToLower(a.Family), strings.ToLower(b.Family))
+	})
+	r.scanned = true
+}
+
+// scanSystem looks for the system fonts, they are added to the others on the next scan
+func (r *fontRegistry) scanSystem() {
+	if r.systemScanned {
+		return
+	}
+	r.system = make([]scannedFont, 0)
+	for _, dir := range systemFontDirs() {
+		r.system = append(r.system, scanFontDir(dir, FontSourceSystem)...)
+	}
+	r.systemScanned = true
+	r.scanned = false
+}
+
+type scannedFont struct {
+	info    FontInfo
+	regular bool
+}
+
+// scanFontDir returns the fonts found under dir, files that do not parse are skipped.
+// Fonts are parsed on their own rather than through the font cache, most of them are never drawn.
+func scanFontDir(dir, source string) []scannedFont {
+	found := make([]scannedFont, 0)
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// unreadable or missing directories have no fonts
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".ttf", ".otf", ".ttc", ".otc":
+		default:
+			return nil
+		}
+		fnt, lerr := loadFont(path)
+		if lerr != nil {
+			return nil
+		}
+		family, lerr := fontFamily(fnt)
+		if lerr != nil || family == "" {
+			return nil
+		}
+		style, _ := fnt.Name(nil, sfnt.NameIDSubfamily)
+		found = append(found, scannedFont{
+			info:    FontInfo{Family: family, File: path, Source: source},
+			regular: style == "Regular" || style == "Book",
+		})
+		return nil
+	})
+	return found
+}
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func withFontDirs(t *testing.T, system []string, project string) {
+	old := systemFontDirs
+	systemFontDirs = func() []string { return system }
+	SetProjectFontDir(project)
+	RescanFonts()
+	t.Cleanup(func() {
+		systemFontDirs = old
+		SetProjectFontDir("")
+		RescanFonts()
+	})
+}
+
+func TestListFonts(t *testing.T) {
+	withFontDirs(t, nil, "")
+	arial := FontInfo{Family: "Arial", File: filepath.Join(BundledFontDir(), "Arial.ttf"), Source: FontSourceBundled}
+	fonts := ListFonts()
+	assert.Equal(t, []FontInfo{arial, {Family: "Ink Free", File: DefaultFontFile(), Source: FontSourceBundled}}, fonts)
+
+	// project fonts win over bundled ones, files that are not fonts are skipped
+	project := t.TempDir()
+	data, err := os.ReadFile(DefaultFontFile())
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(project, "MyInk.ttf"), data, 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(project, "broken.ttf"), []byte("not a font"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(project, "notes.txt"), []byte("hi"), 0644))
+	// a font whose names can be read but whose glyphs cannot
+	assert.NoError(t, os.WriteFile(filepath.Join(project, "BadGlyphs.ttf"), breakTable(t, data, "loca"), 0644))
+	SetProjectFontDir(project)
+	fonts = ListFonts()
+	assert.Equal(t, []FontInfo{arial, {Family: "Ink Free", File: filepath.Join(project, "MyInk.ttf"), Source: FontSourceProject}}, fonts)
+
+	info, err := LookupFont("myink")
+	assert.NoError(t, err)
+	assert.Equal(t, FontSourceProject, info.Source)
+}
+
+// breakTable returns a copy of a font whose table of the given tag points past the end of the file
+func breakTable(t *testing.T, data []byte, tag string) []byte {
+	broken := slices.Clone(data)
+	numTables := int(binary.BigEndian.Uint16(broken[4:]))
+	for i := range numTables {
+		record := broken[12+16*i:]
+		if string(record[:4]) == tag {
+			binary.BigEndian.PutUint32(record[12:], uint32(len(broken)))
+			return broken
+		}
+	}
+	t.Fatalf("no %s table", tag)
+	return nil
+}
+
+func TestLookupFont(t *testing.T) {
+	withFontDirs(t, nil, "")
+
+	info, err := LookupFont("ink free")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultFontFile(), info.File)
+
+	info, err = LookupFont("Inkfree")
+	assert.NoError(t, err)
+	assert.Equal(t, "Ink Free", info.Family)
+
+	info, err = LookupFont(DefaultFontFile())
+	assert.NoError(t, err)
+	assert.Equal(t, FontInfo{Family: "Ink Free", File: DefaultFontFile(), Source: FontSourceFile}, info)
+
+	_, err = LookupFont("No Such Font")
+	assert.Error(t, err)
+	_, err = ResolveFontFile("")
+	assert.Error(t, err)
+}
+
+func TestLookupFontScansSystemOnMiss(t *testing.T) {
+	system := t.TempDir()
+	data, err := os.ReadFile(DefaultFontFile())
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(system, "SystemInk.ttf"), data, 0644))
+	withFontDirs(t, nil, "")
+	scans := 0
+	systemFontDirs = func() []string {
+		scans++
+		return []string{system}
+	}
+
+	// the bundled fonts are found without looking at the system
+	info, err := LookupFont("ink free")
+	assert.NoError(t, err)
+	assert.Equal(t, FontSourceBundled, info.Source)
+	assert.Zero(t, scans)
+
+	info, err = LookupFont("systemink")
+	assert.NoError(t, err)
+	assert.Equal(t, FontInfo{Family: "Ink Free", File: filepath.Join(system, "SystemInk.ttf"), Source: FontSourceSystem}, info)
+	assert.Equal(t, 1, scans)
+
+	// the system fonts are kept when the project fonts change
+	SetProjectFontDir(t.TempDir())
+	_, err = LookupFont("systemink")
+	assert.NoError(t, err)
+	_, err = LookupFont("No Such Font")
+	assert.Error(t, err)
+	assert.Equal(t, 1, scans)
+}
@@ -1,5 +1,7 @@
 package utils
 
+import "encoding/json"
+
 const (
 	FiletypeDiagram    = 0b0001
 	FiletypeSubmodule  = 0b0010
@@ -13,34 +15,121 @@ type SavedAtt struct {
 	Content  string  `json:"content"`
 	Size     int     `json:"size"`
 	Style    int     `json:"style"`
-	FontFile string  `json:"fontFile"`
-	Ratio    float64 `json:"ratio,omitempty"`
+	Font     string  `json:"font,omitempty"`     // font family
+	FontFile string  `json:"fontFile,omitempty"` // path of the font, only read from files of older versions
+	Color    string  `json:"color,omitempty"`
+	Align    int     `json:"align,omitempty"`
+	Section  int     `json:"section,omitempty"` // section of a gadget attribute
+	Ratio    float64 `json:"ratio,omitempty"`   // position of an association attribute, files of older versions keep 0.3*section of gadget attributes here
+}
+
+type SavedSection struct {
+	Name      string `json:"name"`
+	Collapsed bool   `json:"collapsed,omitempty"`
 }
 
 type SavedGad struct {
-	GadgetType int        `json:"GadgetType"`
-	Point      string     `json:"point"`
-	Layer      int        `json:"layer"`
-	Color      string     `json:string`
-	Attributes []SavedAtt `json:"attributes"`
+	GadgetType     int               `json:"GadgetType"`
+	Point          string            `json:"point"`
+	Layer          int               `json:"layer"`
+	LayerName      string            `json:"layerName,omitempty"` // the named layer, empty for the default one
+	Locked         bool              `json:"locked,omitempty"`
+	Color          string            `json:"Color"`
+	Style          string            `json:"style,omitempty"`          // name of the style of the gadget
+	StyleOverrides int               `json:"styleOverrides,omitempty"` // properties set on the gadget rather than by its style
+	MaxWidth       int               `json:"maxWidth,omitempty"`
+	Width          int               `json:"width,omitempty"` // size set by the user, 0 for the size of the content
+	Height         int               `json:"height,omitempty"`
+	Sections       []SavedSection    `json:"sections,omitempty"` // files of older versions have none, their gadgets have the default sections
+	Attributes     []SavedAtt        `json:"attributes"`
+	Children       []int             `json:"children,omitempty"` // indices of the gadgets a package contains
+	Stereotypes    []string          `json:"stereotypes,omitempty"`
+	TaggedValues   map[string]string `json:"taggedValues,omitempty"`
 }
 
 type SavedAss struct {
-	AssType         int        `json:"assType"`
-	Layer           int        `json:"layer"`
-	Parents         []int      `json:"parents"`
-	StartPointRatio [2]float64 `json:"startPointRatio"`
-	EndPointRatio   [2]float64 `json:"endPointRatio"`
-	Attributes      []SavedAtt `json:"attributes"`
+	AssType         int               `json:"assType"`
+	Layer           int               `json:"layer"`
+	LayerName       string            `json:"layerName,omitempty"`
+	Locked          bool              `json:"locked,omitempty"`
+	Parents         []int             `json:"parents"`
+	StartPointRatio [2]float64        `json:"startPointRatio"`
+	EndPointRatio   [2]float64        `json:"endPointRatio"`
+	Attributes      []SavedAtt        `json:"attributes"`
+	Stereotypes     []string          `json:"stereotypes,omitempty"`
+	TaggedValues    map[string]string `json:"taggedValues,omitempty"`
 }
 
 type SavedDiagram struct {
-	Filetype     int        `json:"filetype"`
-	LastEdit     string     `json:"lastEdit"`
-	Gadgets      []SavedGad `json:"Gadgets"`
-	Associations []SavedAss `json:"Associations"`
+	Filetype      int          `json:"filetype"`
+	LastEdit      string       `json:"lastEdit"`
+	Gadgets       []SavedGad   `json:"Gadgets"`
+	Associations  []SavedAss   `json:"Associations"`
+	FontFallbacks []string     `json:"fontFallbacks,omitempty"` // font names tried for glyphs missing from the attribute fonts
+	GridSize      int          `json:"gridSize,omitempty"`      // 0 for the default size
+	GridEnabled   bool         `json:"gridEnabled,omitempty"`
+	Layers        []SavedLayer `json:"layers,omitempty"` // the named layers components can be assigned to
+
+	Title           string   `json:"title,omitempty"`
+	Description     string   `json:"description,omitempty"`
+	Author          string   `json:"author,omitempty"`
+	Tags            []string `json:"tags,omitempty"`
+	BackgroundColor string   `json:"backgroundColor,omitempty"` // empty for the default color
+	GadgetColor     string   `json:"gadgetColor,omitempty"`     // color of new gadgets, empty for the default color
+	Created         string   `json:"created,omitempty"`         // RFC3339, files of older versions have none
+}
+
+type SavedLayer struct {
+	Name   string `json:"name"`
+	Hidden bool   `json:"hidden,omitempty"`
+	Locked bool   `json:"locked,omitempty"`
+}
+
+// SavedHistory is the undo/redo history of a diagram, kept in a sidecar file next to the diagram file.
+type SavedHistory struct {
+	DiagramLastEdit string                  `json:"diagramLastEdit"` // LastEdit of the diagram file it belongs to
+	Components      []SavedHistoryComponent `json:"components"`
+	Undo            []SavedCommand          `json:"undo"`
+	Redo            []SavedCommand          `json:"redo"`
+}
+
+// SavedHistoryComponent is a component referenced by the history.
+// Components in the diagram point into its Gadgets/Associations by Live,
+// components that only exist in the history (e.g. removed ones) are stored in full.
+type SavedHistoryComponent struct {
+	Kind        string    `json:"kind"`
+	Live        int       `json:"live"`
+	Gadget      *SavedGad `json:"gadget,omitempty"`
+	Association *SavedAss `json:"association,omitempty"` // Parents point into SavedHistory.Components
+	Parent      *int      `json:"parent,omitempty"`      // the package of a gadget stored in full, into SavedHistory.Components
+}
+
+type SavedCommand struct {
+	Kind       string          `json:"kind"`
+	Before     string          `json:"before"`
+	After      string          `json:"after"`
+	Components []int           `json:"components,omitempty"` // indices into SavedHistory.Components
+	Property   string          `json:"property,omitempty"`
+	Section    int             `json:"section,omitempty"`
+	Index      int             `json:"index,omitempty"`
+	Content    string          `json:"content,omitempty"`
+	Ratio      float64         `json:"ratio,omitempty"`
+	OldValue   json.RawMessage `json:"oldValue,omitempty"`
+	NewValue   json.RawMessage `json:"newValue,omitempty"`
+	Commands   []SavedCommand  `json:"commands,omitempty"`
+	Parents    [][3]int        `json:"parents,omitempty"` // a moved gadget, its old and its new package, -1 for none
 }
 
 type SavedProject struct {
-	Diagrams []string `json:"diagrams"`
+	Diagrams []string     `json:"diagrams"`
+	Styles   []SavedStyle `json:"styles,omitempty"`
+}
+
+// SavedStyle is a named style gadgets refer to. Properties left empty are not set by the style.
+type SavedStyle struct {
+	Name      string `json:"name"`
+	Color     string `json:"color,omitempty"`     // fill color
+	Font      string `json:"font,omitempty"`      // font family, file name or path
+	Size      int    `json:"size,omitempty"`      // font size
+	TextStyle *int   `json:"textStyle,omitempty"` // bold, italic, ... as the attribute text style
 }
@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"Dr.uml/backend/utils/duerror"
+)
+
+// TextLine is a measured line of a laid out text
+type TextLine struct {
+	Content string
+	Y       int // offset of the top of the line from the top of the text
+	Width   int
+	Height  int
+	Runs    []TextRun
+}
+
+// LayoutText breaks str into lines at newlines and, when maxWidth is positive, wraps the lines at spaces
+// so that they fit in maxWidth. Words wider than maxWidth are broken between runes.
+// It returns the height and width of the whole text and the measured lines.
+func LayoutText(str string, size int, style int, fontFiles []string, maxWidth int) (int, int, []TextLine, duerror.DUError) {
+	if maxWidth < 0 {
+		return 0, 0, nil, duerror.NewInvalidArgumentError("max width cannot be negative")
+	}
+	contents := make([]string, 0, 1)
+	for _, paragraph := range strings.Split(str, "\n") {
+		if maxWidth == 0 {
+			contents = append(contents, paragraph)
+			continue
+		}
+		wrapped, err := wrapParagraph(paragraph, size, style, fontFiles, maxWidth)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		contents = append(contents, wrapped...)
+	}
+
+	height, width := 0, 0
+	lines := make([]TextLine, 0, len(contents))
+	for _, content := range contents {
+		h, w, runs, err := MeasureTextRuns(content, size, style, fontFiles)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		lines = append(lines, TextLine{Content: content, Y: height, Width: w, Height: h, Runs: runs})
+		height += h
+		width = max(width, w)
+	}
+	return height, width, lines, nil
+}
+
+// wrapParagraph greedily fills lines with words, the spaces a line is broken at are dropped.
+func wrapParagraph(paragraph string, size int, style int, fontFiles []string, maxWidth int) ([]string, duerror.DUError) {
+	measure := func(s string) (int, duerror.DUError) {
+		_, w, _, err := MeasureTextRuns(s, size, style, fontFiles)
+		return w, err
+	}
+
+	lines := make([]string, 0, 1)
+	var line strings.Builder
+	lineWidth, wrapped := 0, false
+	flush := func() {
+		lines = append(lines, strings.TrimRightFunc(line.String(), unicode.IsSpace))
+		line.Reset()
+		lineWidth, wrapped = 0, true
+	}
+	for _, token := range splitWords(paragraph) {
+		space := strings.TrimSpace(token) == ""
+		if space && wrapped && line.Len() == 0 {
+			continue
+		}
+		w, err := measure(token)
+		if err != nil {
+			return nil, err
+		}
+		if !space && line.Len() > 0 && lineWidth+w > maxWidth {
+			flush()
+		}
+		if !space && w > maxWidth {
+			// the word alone does not fit, break it between runes
+			for _, r := range token {
+				rw, err := measure(string(r))
+				if err != nil {
+					return nil, err
+				}
+				if line.Len() > 0 && lineWidth+rw > maxWidth {
+					flush()
+				}
+				line.WriteRune(r)
+				lineWidth += rw
+			}
+			continue
+		}
+		line.WriteString(token)
+		lineWidth += w
+	}
+	if line.Len() > 0 || len(lines) == 0 {
+		flush()
+	}
+	return lines, nil
+}
+
+// splitWords splits s into alternating runs of spaces and of other runes.
+func splitWords(s string) []string {
+	tokens := make([]string, 0)
+	start := 0
+	for i := 0; i < len(s); {
+		r, n := utf8.DecodeRuneInString(s[i:])
+		if i > start {
+			prev, _ := utf8.DecodeLastRuneInString(s[:i])
+			if unicode.IsSpace(prev) != unicode.IsSpace(r) {
+				tokens = append(tokens, s[start:i])
+				start = i
+			}
+		}
+		i += n
+	}
+	if start < len(s) {
+		tokens = append(tokens, s[start:])
+	}
+	return tokens
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"os"
+	"testing"
+
+	"Dr.uml/backend/drawdata"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LayoutText(t *testing.T) {
+	fontFiles := []string{os.Getenv("APP_ROOT") + drawdata.DefaultAttributeFontFile}
+	lineHeight, _, err := GetTextSize("a", 12, fontFiles[0])
+	assert.NoError(t, err)
+	wordWidth := func(s string) int {
+		_, w, err := GetTextSize(s, 12, fontFiles[0])
+		assert.NoError(t, err)
+		return w
+	}
+	contents := func(lines []TextLine) []string {
+		res := make([]string, len(lines))
+		for i, line := range lines {
+			res[i] = line.Content
+		}
+		return res
+	}
+
+	tests := []struct {
+		name     string
+		str      string
+		maxWidth int
+		expected []string
+	}{
+		{"single line", "+ method(a: int): void", 0, []string{"+ method(a: int): void"}},
+		{"newlines", "first\n\nthird", 0, []string{"first", "", "third"}},
+		{"empty", "", 0, []string{""}},
+		{"fits", "short text", 1000, []string{"short text"}},
+		{"wrapped at spaces", "alpha beta gamma", wordWidth("alpha beta") + 1, []string{"alpha beta", "gamma"}},
+		{"indent kept, spaces at breaks dropped", "  alpha    beta", wordWidth("  alpha") + 1, []string{"  alpha", "beta"}},
+		{"newlines and wrapping", "alpha beta\ngamma", max(wordWidth("alpha"), wordWidth("gamma")) + 1, []string{"alpha", "beta", "gamma"}},
+		{"long word broken", "abcdefgh", wordWidth("abcdefgh") - 1, []string{"abcdefg", "h"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			height, width, lines, err := LayoutText(tt.str, 12, 0, fontFiles, tt.maxWidth)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, contents(lines))
+			assert.Equal(t, lineHeight*len(lines), height)
+			maxLine := 0
+			for i, line := range lines {
+				assert.Equal(t, lineHeight*i, line.Y)
+				assert.Equal(t, lineHeight, line.Height)
+				assert.Equal(t, wordWidth(line.Content), line.Width)
+				if tt.maxWidth > 0 {
+					assert.LessOrEqual(t, line.Width, tt.maxWidth)
+				}
+				maxLine = max(maxLine, line.Width)
+			}
+			assert.Equal(t, maxLine, width)
+		})
+	}
+
+	_, _, _, err = LayoutText("a", 12, 0, fontFiles, -1)
+	assert.Error(t, err)
+	_, _, _, err = LayoutText("a", 0, 0, fontFiles, 0)
+	assert.Error(t, err)
+}
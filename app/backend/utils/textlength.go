@@ -1,80 +1,43 @@
 package utils
 
 import (
-	"Dr.uml/backend/drawdata"
-	"os"
+	"slices"
 
 	"Dr.uml/backend/utils/duerror"
-	"golang.org/x/image/font"
-	"golang.org/x/image/font/opentype"
-	"golang.org/x/image/math/fixed"
 )
 
-func loadFont(file string) (*opentype.Font, duerror.DUError) {
-	fontBytes, err := os.ReadFile(file)
-	if err != nil {
-		return nil, duerror.NewFileIOError(err.Error())
-	}
-	fnt, err := opentype.Parse(fontBytes)
-	if err != nil {
-		return nil, duerror.NewFileIOError(err.Error())
-	}
-	return fnt, nil
+// TextRun is a piece of measured text drawn with a single font of a fallback chain
+type TextRun struct {
+	Content  string
+	FontFile string
+	Width    int
 }
 
+// GetTextSize returns the height and width of str drawn in the regular style of fontFile.
 func GetTextSize(str string, size int, fontFile string) (int, int, duerror.DUError) {
-	defaultFontFile := os.Getenv("APP_ROOT") + drawdata.DefaultAttributeFontFile
-	if fontFile == "" {
-		fontFile = defaultFontFile
+	return MeasureText(str, size, 0, fontFile)
+}
+
+// MeasureText returns the height and width of str drawn with the given size and style flags.
+// An empty fontFile stands for the default attribute font. Fonts, faces and results are cached.
+func MeasureText(str string, size int, style int, fontFile string) (int, int, duerror.DUError) {
+	height, width, _, err := MeasureTextRuns(str, size, style, []string{fontFile})
+	return height, width, err
+}
+
+// MeasureTextRuns measures str with a font fallback chain, each rune uses the first font of fontFiles having it.
+// It returns the height and width of the whole text and the runs of consecutive runes sharing a font.
+// An empty first font stands for the default attribute font.
+func MeasureTextRuns(str string, size int, style int, fontFiles []string) (int, int, []TextRun, duerror.DUError) {
+	if len(fontFiles) == 0 || fontFiles[0] == "" {
+		fontFiles = append([]string{DefaultFontFile()}, fontFiles[min(len(fontFiles), 1):]...)
 	}
-	dpi := 72
 	if size <= 0 {
-		return 0, 0, duerror.NewInvalidArgumentError("size must be greater than 0")
-	}
-	fnt, err := loadFont(fontFile)
-	if err != nil {
-		return 0, 0, err
+		return 0, 0, nil, duerror.NewInvalidArgumentError("size must be greater than 0")
 	}
-	face, err := opentype.NewFace(fnt, &opentype.FaceOptions{
-		Size:    float64(size),
-		DPI:     float64(dpi),
-		Hinting: font.HintingFull,
-	})
+	res, err := fonts.measure(str, size, style, fontFiles)
 	if err != nil {
-		return 0, 0, duerror.NewFileIOError(err.Error())
-	}
-	defer face.Close()
-
-	// Draw the string to an image
-	/*	imgWidth := 400
-		imgHeight := 100
-		img := image.NewRGBA(image.Rect(0, 0, imgWidth, imgHeight))
-		d := &font.Drawer{
-			Dst:  img,
-			Src:  image.NewUniform(color.Black),
-			Face: face,
-			Dot:  fixed.P(10, 50),
-		}
-		d.DrawString(str)
-
-		outFile, err := os.Create("output.png")
-		if err != nil {
-			log.Fatalf("failed to create output file: %v", err)
-		}
-		defer outFile.Close()
-		if err := png.Encode(outFile, img); err != nil {
-			log.Fatalf("failed to encode image: %v", err)
-		}*/
-
-	var width fixed.Int26_6
-	for _, r := range str {
-		advance, ok := face.GlyphAdvance(r)
-		if !ok {
-			return 0, 0, duerror.NewFileIOError("glyph not found")
-		}
-		width += advance
+		return 0, 0, nil, err
 	}
-	metrics := face.Metrics()
-	height := metrics.Ascent + metrics.Descent
-	return height.Round(), width.Round(), nil
+	return res.height, res.width, slices.Clone(res.runs), nil
 }
@@ -1,9 +1,12 @@
 package utils
 
 import (
-	"Dr.uml/backend/drawdata"
+	"fmt"
 	"os"
+	"sync"
 	"testing"
+
+	"Dr.uml/backend/drawdata"
 )
 
 func Test_GetTextSize(t *testing.T) {
@@ -51,3 +54,133 @@ func Test_GetTextSize(t *testing.T) {
 		})
 	}
 }
+
+func Test_MeasureText_Cache(t *testing.T) {
+	ClearFontCache()
+	fontFile := os.Getenv("APP_ROOT") + drawdata.DefaultAttributeFontFile
+
+	height, width, err := MeasureText("Hello, World!", 12, 0, fontFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fonts.fonts) != 1 || len(fonts.faces) != 1 || len(fonts.sizes) != 1 {
+		t.Errorf("expected one cached font, face and size, got %d, %d, %d",
+			len(fonts.fonts), len(fonts.faces), len(fonts.sizes))
+	}
+
+	// same result from the cache, another size shares the parsed font
+	h, w, err := MeasureText("Hello, World!", 12, 0, fontFile)
+	if err != nil || h != height || w != width {
+		t.Errorf("expected %v, %v from the cache, got %v, %v, %v", height, width, h, w, err)
+	}
+	if _, _, err = MeasureText("Hello, World!", 24, 0, fontFile); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fonts.fonts) != 1 || len(fonts.faces) != 2 {
+		t.Errorf("expected one font and two faces, got %d, %d", len(fonts.fonts), len(fonts.faces))
+	}
+
+	// failures are not cached
+	for i := 0; i < 2; i++ {
+		if _, _, err = MeasureText("Hello", 12, 0, "/nonexistent/font.ttf"); err == nil {
+			t.Errorf("expected error for a missing font file")
+		}
+	}
+
+	ClearFontCache()
+	if len(fonts.fonts) != 0 || len(fonts.faces) != 0 || len(fonts.sizes) != 0 {
+		t.Errorf("expected an empty cache")
+	}
+}
+
+func Test_MeasureText_Concurrent(t *testing.T) {
+	ClearFontCache()
+	fontFile := os.Getenv("APP_ROOT") + drawdata.DefaultAttributeFontFile
+	expectedHeight, expectedWidth, err := MeasureText("Hello, World!", 12, 0, fontFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				str := fmt.Sprintf("attribute %d", j)
+				if _, _, err := MeasureText(str, 10+i%4, 0, fontFile); err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				height, width, err := MeasureText("Hello, World!", 12, 0, fontFile)
+				if err != nil || height != expectedHeight || width != expectedWidth {
+					t.Errorf("expected %v, %v, got %v, %v, %v", expectedHeight, expectedWidth, height, width, err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func Benchmark_MeasureText(b *testing.B) {
+	fontFile := os.Getenv("APP_ROOT") + drawdata.DefaultAttributeFontFile
+	for i := 0; i < b.N; i++ {
+		if _, _, err := MeasureText(fmt.Sprintf("attribute %d", i%500), 12, 0, fontFile); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Test_MeasureTextRuns(t *testing.T) {
+	inkfree := os.Getenv("APP_ROOT") + drawdata.DefaultAttributeFontFile
+	arial := os.Getenv("APP_ROOT") + "/frontend/src/assets/fonts/Arial.ttf"
+
+	// Inkfree has no greek letters, Arial does
+	height, width, runs, err := MeasureTextRuns("ab αβ cd", 12, 0, []string{inkfree, arial})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []TextRun{
+		{Content: "ab ", FontFile: inkfree},
+		{Content: "αβ", FontFile: arial},
+		{Content: " cd", FontFile: inkfree},
+	}
+	if len(runs) != len(expected) {
+		t.Fatalf("expected %d runs, got %v", len(expected), runs)
+	}
+	total := 0
+	for i, run := range runs {
+		if run.Content != expected[i].Content {
+			t.Errorf("expected run %q, got %q", expected[i].Content, run.Content)
+		}
+		if run.FontFile != expected[i].FontFile {
+			t.Errorf("expected run %q in %s, got %s", run.Content, expected[i].FontFile, run.FontFile)
+		}
+		total += run.Width
+	}
+	if total < width-len(runs) || total > width+len(runs) {
+		t.Errorf("expected runs to add up to %d, got %d", width, total)
+	}
+	arialHeight, _, _ := GetTextSize("αβ", 12, arial)
+	if height != max(arialHeight, 15) {
+		t.Errorf("expected the height of the tallest font, got %d", height)
+	}
+
+	// no font has it, measured as the missing glyph box of the first font
+	_, width, runs, err = MeasureTextRuns("中文", 12, 0, []string{inkfree})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if width <= 0 || len(runs) != 1 || runs[0].FontFile != inkfree {
+		t.Errorf("expected one run in the first font, got %v with width %d", runs, width)
+	}
+
+	// the default font stands in for an empty one
+	_, _, runs, err = MeasureTextRuns("ab", 12, 0, nil)
+	if err != nil || len(runs) != 1 || runs[0].FontFile != inkfree {
+		t.Errorf("expected one run in the default font, got %v, %v", runs, err)
+	}
+
+	if _, _, _, err = MeasureTextRuns("ab", 12, 0, []string{inkfree, "/nonexistent/font.ttf"}); err == nil {
+		t.Errorf("expected error for a missing fallback font")
+	}
+}
@@ -1,5 +1,5 @@
 import React, { useCallback, useEffect, useRef, useMemo } from 'react';
-import { AssociationProps, CanvasProps, GadgetProps } from '../utils/Props';
+import { AssociationProps, CanvasProps, DrawnComponent, GadgetProps } from '../utils/Props';
 import { createGad } from '../utils/createGadget';
 import { createAss } from '../utils/createAssociation';
 import { useCanvasMouseEvents } from '../hooks/useCanvasMouseEvents';
@@ -40,14 +40,15 @@ const DrawingCanvas: React.FC<{
                     ctx.fillStyle = canvasBackgroundColor;
                     ctx.fillRect(0, 0, canvas.width, canvas.height);
 
-                    backendData?.gadgets?.forEach((gadget: GadgetProps) => {
-                        const gad = createGad("Class", gadget, backendData.margin);
-                        gad.draw(ctx, backendData.margin, backendData.lineWidth);
-                    });
-
-                    backendData?.associations?.forEach((association: AssociationProps) => {
-                        const ass = createAss("Association", association, backendData.margin);
-                        ass.draw(ctx, backendData.margin, backendData.lineWidth);
+                    // from the bottom to the top, in the order the backend hit-tests them
+                    backendData?.order?.forEach((drawn: DrawnComponent) => {
+                        if (drawn.association) {
+                            const association: AssociationProps = backendData.associations![drawn.index];
+                            createAss("Association", association, backendData.margin).draw(ctx, backendData.margin, backendData.lineWidth);
+                        } else {
+                            const gadget: GadgetProps = backendData.gadgets![drawn.index];
+                            createGad("Class", gadget, backendData.margin).draw(ctx, backendData.margin, backendData.lineWidth);
+                        }
                     });
                 }
             }
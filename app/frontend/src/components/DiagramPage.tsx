@@ -42,6 +42,7 @@ const DiagramPage: React.FC<DiagramPageProps> = ({ projectData, onDiagramSelecte
                 color: diagramData.color,
                 lineWidth: diagramData.lineWidth,
                 gadgets: diagramData.gadgets?.map((gadget: any) => ({
+                    id: gadget.id,
                     gadgetType: gadget.gadgetType.toString(),
                     x: gadget.x,
                     y: gadget.y,
@@ -53,6 +54,7 @@ const DiagramPage: React.FC<DiagramPageProps> = ({ projectData, onDiagramSelecte
                     attributes: gadget.attributes
                 })) || [],
                 associations: diagramData.associations?.map((association: any) => ({
+                    id: association.id,
                     assType: association.assType,
                     layer: association.layer,
                     startX: association.startX,
@@ -68,7 +70,8 @@ const DiagramPage: React.FC<DiagramPageProps> = ({ projectData, onDiagramSelecte
                         fontFile: attr.fontFile,
                         ratio: attr.ratio
                     })) || []
-                })) || []
+                })) || [],
+                order: diagramData.order || []
             };
 
             // Call the callback to switch to editor view with the properly formatted data
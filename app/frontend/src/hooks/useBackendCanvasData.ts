@@ -1,10 +1,11 @@
 import { useEffect, useState, useCallback } from "react";
 import { offBackendEvent, onBackendEvent } from "../utils/wailsBridge";
 import { GetDrawData } from "../../wailsjs/go/umlproject/UMLProject";
-import { CanvasProps, GadgetProps, AssociationProps } from "../utils/Props";
+import { CanvasProps, GadgetProps, AssociationProps, DrawnComponent } from "../utils/Props";
 
 // Type definitions for backend data
 interface BackendGadget {
+    id: number;
     gadgetType: any;
     x: number;
     y: number;
@@ -17,6 +18,7 @@ interface BackendGadget {
 }
 
 interface BackendAssociation {
+    id: number;
     assType: number;
     layer: number;
     startX: number;
@@ -35,6 +37,7 @@ interface BackendDiagram {
     lineWidth: number;
     gadgets: BackendGadget[];
     associations?: BackendAssociation[];
+    order?: DrawnComponent[];
 }
 
 /**
@@ -53,6 +56,7 @@ const transformAssociationAttribute = (attr: any) => ({
  * Transforms a backend gadget to frontend format
  */
 const transformGadget = (gadget: BackendGadget): GadgetProps => ({
+    id: gadget.id,
     gadgetType: gadget.gadgetType.toString(),
     x: gadget.x,
     y: gadget.y,
@@ -68,6 +72,7 @@ const transformGadget = (gadget: BackendGadget): GadgetProps => ({
  * Transforms a backend association to frontend format
  */
 const transformAssociation = (association: BackendAssociation): AssociationProps => ({
+    id: association.id,
     assType: association.assType,
     layer: association.layer,
     startX: association.startX,
@@ -88,7 +93,8 @@ const transformDiagramToCanvasProps = (diagram: BackendDiagram): CanvasProps =>
     color: diagram.color,
     lineWidth: diagram.lineWidth,
     gadgets: diagram.gadgets?.map(transformGadget) || [],
-    associations: diagram.associations?.map(transformAssociation) || []
+    associations: diagram.associations?.map(transformAssociation) || [],
+    order: diagram.order || []
 });
 
 export function useBackendCanvasData() {
@@ -4,9 +4,17 @@ export interface CanvasProps {
     lineWidth: number;
     gadgets?: GadgetProps[];
     associations?: AssociationProps[];
+    order?: DrawnComponent[];
+}
+
+// a component in the drawing order, index is its position in gadgets or in associations
+export interface DrawnComponent {
+    association: boolean;
+    index: number;
 }
 
 export interface GadgetProps {
+    id: number;
     gadgetType: string;
     x: number;
     y: number;
@@ -26,6 +34,7 @@ export interface GadgetProps {
 }
 
 export interface AssociationProps {
+    id: number;
     assType: number;
     layer: number;
     startX: number;
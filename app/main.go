@@ -34,9 +34,11 @@ func main() {
 		},
 		EnumBind: []interface{}{
 			umldiagram.AllDiagramTypes,
+			umldiagram.AllAlignments,
 			component.AllGadgetTypes,
 			component.AllAssociationTypes,
 			attribute.AllTextstyleTypes,
+			attribute.AllTextAlignTypes,
 		},
 	})
 